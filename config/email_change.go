@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const defaultEmailChangeTokenTTL = 24 * time.Hour
+
+// GetEmailChangeConfig reads EMAIL_CHANGE_URL, the page that confirms a new email address, and
+// EMAIL_CHANGE_TOKEN_TTL. Without a URL the confirmation email carries the bare token.
+func GetEmailChangeConfig() (models.EmailChangeConfig, error) {
+	emailChange := models.EmailChangeConfig{
+		ConfirmURL: os.Getenv("EMAIL_CHANGE_URL"),
+		TokenTTL:   defaultEmailChangeTokenTTL,
+	}
+	if emailChange.ConfirmURL != "" {
+		if confirmURL, err := url.Parse(emailChange.ConfirmURL); err != nil || !confirmURL.IsAbs() {
+			return emailChange, errors.New("EMAIL_CHANGE_URL must be an absolute URL")
+		}
+	}
+	if ttl := os.Getenv("EMAIL_CHANGE_TOKEN_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			return emailChange, fmt.Errorf("invalid EMAIL_CHANGE_TOKEN_TTL: %s", ttl)
+		}
+		emailChange.TokenTTL = duration
+	}
+	return emailChange, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEmailChangeConfig_Defaults(t *testing.T) {
+	emailChange, err := GetEmailChangeConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.EmailChangeConfig{TokenTTL: 24 * time.Hour}, emailChange)
+}
+
+func TestGetEmailChangeConfig_FromEnv(t *testing.T) {
+	t.Setenv("EMAIL_CHANGE_URL", "https://app.example.com/confirm-email")
+	t.Setenv("EMAIL_CHANGE_TOKEN_TTL", "2h")
+
+	emailChange, err := GetEmailChangeConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.EmailChangeConfig{ConfirmURL: "https://app.example.com/confirm-email", TokenTTL: 2 * time.Hour}, emailChange)
+}
+
+func TestGetEmailChangeConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"relative url", map[string]string{"EMAIL_CHANGE_URL": "/confirm-email"}},
+		{"invalid ttl", map[string]string{"EMAIL_CHANGE_TOKEN_TTL": "forever"}},
+		{"negative ttl", map[string]string{"EMAIL_CHANGE_TOKEN_TTL": "-1h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := GetEmailChangeConfig()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+var defaultFederatedScopes = []string{"openid", "email", "profile"}
+
+// GetFederatedProviders reads the upstream identity providers listed in FEDERATED_PROVIDERS.
+// Each provider NAME is configured through FEDERATED_<NAME>_* variables, for example
+// FEDERATED_GOOGLE_ISSUER, FEDERATED_GOOGLE_CLIENT_ID and FEDERATED_GOOGLE_CLIENT_SECRET.
+// Providers missing an issuer, client id or client secret are skipped.
+func GetFederatedProviders() []models.FederatedProviderConfig {
+	var providers []models.FederatedProviderConfig
+	for _, name := range strings.Split(os.Getenv("FEDERATED_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		provider := federatedProviderFromEnv(name)
+		if provider.Issuer == "" || provider.ClientID == "" || provider.ClientSecret == "" {
+			log.Printf("Skipping federated provider %s: issuer, client id and client secret are required", name)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+func federatedProviderFromEnv(name string) models.FederatedProviderConfig {
+	prefix := "FEDERATED_" + strings.ToUpper(name) + "_"
+	env := func(key, fallback string) string {
+		if value := os.Getenv(prefix + key); value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	scopes := defaultFederatedScopes
+	if configured := os.Getenv(prefix + "SCOPES"); configured != "" {
+		scopes = strings.Fields(strings.ReplaceAll(configured, ",", " "))
+	}
+
+	return models.FederatedProviderConfig{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(env("ISSUER", ""), "/"),
+		ClientID:     env("CLIENT_ID", ""),
+		ClientSecret: env("CLIENT_SECRET", ""),
+		RedirectURL:  env("REDIRECT_URL", ""),
+		Scopes:       scopes,
+		ClaimMapping: models.FederatedClaimMapping{
+			Email:         env("CLAIM_EMAIL", "email"),
+			EmailVerified: env("CLAIM_EMAIL_VERIFIED", "email_verified"),
+			GivenName:     env("CLAIM_GIVEN_NAME", "given_name"),
+			MiddleName:    env("CLAIM_MIDDLE_NAME", "middle_name"),
+			FamilyName:    env("CLAIM_FAMILY_NAME", "family_name"),
+		},
+	}
+}
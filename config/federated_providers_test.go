@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFederatedProviders(t *testing.T) {
+	t.Setenv("FEDERATED_PROVIDERS", "Google, corp, incomplete")
+	t.Setenv("FEDERATED_GOOGLE_ISSUER", "https://accounts.google.com/")
+	t.Setenv("FEDERATED_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("FEDERATED_GOOGLE_CLIENT_SECRET", "google-secret")
+	t.Setenv("FEDERATED_CORP_ISSUER", "https://login.corp.example")
+	t.Setenv("FEDERATED_CORP_CLIENT_ID", "corp-client")
+	t.Setenv("FEDERATED_CORP_CLIENT_SECRET", "corp-secret")
+	t.Setenv("FEDERATED_CORP_SCOPES", "openid,email")
+	t.Setenv("FEDERATED_CORP_CLAIM_EMAIL", "upn")
+	t.Setenv("FEDERATED_INCOMPLETE_ISSUER", "https://incomplete.example")
+
+	providers := GetFederatedProviders()
+
+	require.Len(t, providers, 2)
+	assert.Equal(t, "google", providers[0].Name)
+	assert.Equal(t, "https://accounts.google.com", providers[0].Issuer)
+	assert.Equal(t, []string{"openid", "email", "profile"}, providers[0].Scopes)
+	assert.Equal(t, "email", providers[0].ClaimMapping.Email)
+	assert.Equal(t, "corp", providers[1].Name)
+	assert.Equal(t, []string{"openid", "email"}, providers[1].Scopes)
+	assert.Equal(t, "upn", providers[1].ClaimMapping.Email)
+	assert.Equal(t, "given_name", providers[1].ClaimMapping.GivenName)
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+// GetInvitationConfig reads INVITATION_URL, the page where invitees accept their invitation, and
+// INVITATION_TTL. Without a URL the invitation carries the bare token.
+func GetInvitationConfig() (models.InvitationConfig, error) {
+	invitation := models.InvitationConfig{
+		AcceptURL: os.Getenv("INVITATION_URL"),
+		TokenTTL:  defaultInvitationTTL,
+	}
+	if invitation.AcceptURL != "" {
+		if acceptURL, err := url.Parse(invitation.AcceptURL); err != nil || !acceptURL.IsAbs() {
+			return invitation, errors.New("INVITATION_URL must be an absolute URL")
+		}
+	}
+	if ttl := os.Getenv("INVITATION_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			return invitation, fmt.Errorf("invalid INVITATION_TTL: %s", ttl)
+		}
+		invitation.TokenTTL = duration
+	}
+	return invitation, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetInvitationConfig_Defaults(t *testing.T) {
+	invitation, err := GetInvitationConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.InvitationConfig{TokenTTL: 7 * 24 * time.Hour}, invitation)
+}
+
+func TestGetInvitationConfig_FromEnv(t *testing.T) {
+	t.Setenv("INVITATION_URL", "https://app.example.com/accept-invitation")
+	t.Setenv("INVITATION_TTL", "48h")
+
+	invitation, err := GetInvitationConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.InvitationConfig{AcceptURL: "https://app.example.com/accept-invitation", TokenTTL: 48 * time.Hour}, invitation)
+}
+
+func TestGetInvitationConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"relative url", map[string]string{"INVITATION_URL": "/accept-invitation"}},
+		{"invalid ttl", map[string]string{"INVITATION_TTL": "a week"}},
+		{"negative ttl", map[string]string{"INVITATION_TTL": "-1h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := GetInvitationConfig()
+			assert.Error(t, err)
+		})
+	}
+}
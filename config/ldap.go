@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const (
+	AuthBackendDatabase = "database"
+	AuthBackendLDAP     = "ldap"
+)
+
+// GetAuthBackends returns the login backends listed in AUTH_BACKENDS in the order they are tried.
+// Only the local database is used when it is not set.
+func GetAuthBackends() []string {
+	var backends []string
+	for _, backend := range strings.Split(os.Getenv("AUTH_BACKENDS"), ",") {
+		backend = strings.ToLower(strings.TrimSpace(backend))
+		if backend != "" {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == 0 {
+		return []string{AuthBackendDatabase}
+	}
+	return backends
+}
+
+// GetLDAPConfig reads the LDAP_* variables. LDAP_GROUP_ROLES maps groups onto roles as semicolon
+// separated group_dn=role entries, for example "CN=Admins,OU=Groups,DC=corp,DC=example=admin".
+func GetLDAPConfig() (models.LDAPConfig, error) {
+	env := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	ldapConfig := models.LDAPConfig{
+		URL:                 env("LDAP_URL", ""),
+		BindDN:              env("LDAP_BIND_DN", ""),
+		BindPassword:        env("LDAP_BIND_PASSWORD", ""),
+		BaseDN:              env("LDAP_BASE_DN", ""),
+		UserFilter:          env("LDAP_USER_FILTER", "(&(objectClass=person)(mail=%s))"),
+		EmailAttribute:      env("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		GivenNameAttribute:  env("LDAP_GIVEN_NAME_ATTRIBUTE", "givenName"),
+		FamilyNameAttribute: env("LDAP_FAMILY_NAME_ATTRIBUTE", "sn"),
+		GroupAttribute:      env("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		GroupRoles:          map[string]string{},
+	}
+	if ldapConfig.URL == "" || ldapConfig.BaseDN == "" {
+		return ldapConfig, errors.New("LDAP_URL and LDAP_BASE_DN are required")
+	}
+	if !strings.Contains(ldapConfig.UserFilter, "%s") {
+		return ldapConfig, errors.New("LDAP_USER_FILTER must contain a %s placeholder for the login email")
+	}
+	if startTLS := os.Getenv("LDAP_START_TLS"); startTLS != "" {
+		enabled, err := strconv.ParseBool(startTLS)
+		if err != nil {
+			return ldapConfig, errors.New("LDAP_START_TLS must be true or false")
+		}
+		ldapConfig.StartTLS = enabled
+	}
+
+	for _, entry := range strings.Split(os.Getenv("LDAP_GROUP_ROLES"), ";") {
+		// Group DNs contain '=' themselves, so the role is whatever follows the last one
+		separator := strings.LastIndex(entry, "=")
+		if separator <= 0 {
+			continue
+		}
+		group := strings.ToLower(strings.TrimSpace(entry[:separator]))
+		role := strings.TrimSpace(entry[separator+1:])
+		if group != "" && role != "" {
+			ldapConfig.GroupRoles[group] = role
+		}
+	}
+	return ldapConfig, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAuthBackends(t *testing.T) {
+	t.Setenv("AUTH_BACKENDS", "")
+	assert.Equal(t, []string{AuthBackendDatabase}, GetAuthBackends())
+
+	t.Setenv("AUTH_BACKENDS", " LDAP, database ")
+	assert.Equal(t, []string{AuthBackendLDAP, AuthBackendDatabase}, GetAuthBackends())
+}
+
+func TestGetLDAPConfig(t *testing.T) {
+	t.Setenv("LDAP_URL", "ldaps://dc.corp.example")
+	t.Setenv("LDAP_BASE_DN", "DC=corp,DC=example")
+	t.Setenv("LDAP_USER_FILTER", "(userPrincipalName=%s)")
+	t.Setenv("LDAP_START_TLS", "true")
+	t.Setenv("LDAP_GROUP_ROLES", "CN=Admins,OU=Groups,DC=corp,DC=example=admin; CN=Staff,DC=corp,DC=example = viewer;invalid")
+
+	ldapConfig, err := GetLDAPConfig()
+
+	require.NoError(t, err)
+	assert.True(t, ldapConfig.StartTLS)
+	assert.Equal(t, "mail", ldapConfig.EmailAttribute)
+	assert.Equal(t, "memberOf", ldapConfig.GroupAttribute)
+	assert.Equal(t, map[string]string{
+		"cn=admins,ou=groups,dc=corp,dc=example": "admin",
+		"cn=staff,dc=corp,dc=example":            "viewer",
+	}, ldapConfig.GroupRoles)
+}
+
+func TestGetLDAPConfig_MissingURL(t *testing.T) {
+	t.Setenv("LDAP_URL", "")
+	t.Setenv("LDAP_BASE_DN", "DC=corp,DC=example")
+
+	_, err := GetLDAPConfig()
+
+	assert.EqualError(t, err, "LDAP_URL and LDAP_BASE_DN are required")
+}
+
+func TestGetLDAPConfig_FilterWithoutPlaceholder(t *testing.T) {
+	t.Setenv("LDAP_URL", "ldap://dc.corp.example")
+	t.Setenv("LDAP_BASE_DN", "DC=corp,DC=example")
+	t.Setenv("LDAP_USER_FILTER", "(objectClass=user)")
+
+	_, err := GetLDAPConfig()
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GetOIDCIssuer reads OIDC_ISSUER, the public base URL used as the iss claim and for every advertised endpoint.
+// It is required, the host a request was made against cannot be trusted to tell it.
+func GetOIDCIssuer() (string, error) {
+	issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+	if issuer == "" {
+		return "", errors.New("OIDC_ISSUER is required")
+	}
+	if issuerURL, err := url.Parse(issuer); err != nil || !issuerURL.IsAbs() || issuerURL.Host == "" {
+		return "", errors.New("OIDC_ISSUER must be an absolute URL")
+	}
+	return issuer, nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOIDCIssuer(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://id.example.com/")
+
+	issuer, err := GetOIDCIssuer()
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://id.example.com", issuer)
+}
+
+func TestGetOIDCIssuer_Invalid(t *testing.T) {
+	for name, value := range map[string]string{"unset": "", "relative url": "/auth", "no host": "https://"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("OIDC_ISSUER", value)
+			_, err := GetOIDCIssuer()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const defaultPasswordSetupTokenTTL = 72 * time.Hour
+
+// GetOnboardingConfig reads ONBOARDING_MODE, which is password unless set to setup_link. A setup link
+// needs PASSWORD_SETUP_URL and is valid for PASSWORD_SETUP_TOKEN_TTL.
+func GetOnboardingConfig() (models.OnboardingConfig, error) {
+	onboarding := models.OnboardingConfig{
+		Mode:     strings.ToLower(os.Getenv("ONBOARDING_MODE")),
+		SetupURL: os.Getenv("PASSWORD_SETUP_URL"),
+		TokenTTL: defaultPasswordSetupTokenTTL,
+	}
+	switch onboarding.Mode {
+	case "", models.OnboardingPassword:
+		onboarding.Mode = models.OnboardingPassword
+		return onboarding, nil
+	case models.OnboardingSetupLink:
+	default:
+		return onboarding, fmt.Errorf("unsupported ONBOARDING_MODE: %s", onboarding.Mode)
+	}
+
+	if setupURL, err := url.Parse(onboarding.SetupURL); err != nil || !setupURL.IsAbs() {
+		return onboarding, errors.New("PASSWORD_SETUP_URL must be an absolute URL when ONBOARDING_MODE is setup_link")
+	}
+	if ttl := os.Getenv("PASSWORD_SETUP_TOKEN_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration <= 0 {
+			return onboarding, fmt.Errorf("invalid PASSWORD_SETUP_TOKEN_TTL: %s", ttl)
+		}
+		onboarding.TokenTTL = duration
+	}
+	return onboarding, nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOnboardingConfig_DefaultsToPassword(t *testing.T) {
+	onboarding, err := GetOnboardingConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.OnboardingPassword, onboarding.Mode)
+}
+
+func TestGetOnboardingConfig_SetupLink(t *testing.T) {
+	t.Setenv("ONBOARDING_MODE", "SETUP_LINK")
+	t.Setenv("PASSWORD_SETUP_URL", "https://app.example.com/set-password")
+	t.Setenv("PASSWORD_SETUP_TOKEN_TTL", "24h")
+
+	onboarding, err := GetOnboardingConfig()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.OnboardingConfig{
+		Mode:     models.OnboardingSetupLink,
+		SetupURL: "https://app.example.com/set-password",
+		TokenTTL: 24 * time.Hour,
+	}, onboarding)
+}
+
+func TestGetOnboardingConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"unknown mode", map[string]string{"ONBOARDING_MODE": "magic"}},
+		{"missing url", map[string]string{"ONBOARDING_MODE": "setup_link"}},
+		{"relative url", map[string]string{"ONBOARDING_MODE": "setup_link", "PASSWORD_SETUP_URL": "/set-password"}},
+		{"invalid ttl", map[string]string{"ONBOARDING_MODE": "setup_link", "PASSWORD_SETUP_URL": "https://app.example.com", "PASSWORD_SETUP_TOKEN_TTL": "forever"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := GetOnboardingConfig()
+			assert.Error(t, err)
+		})
+	}
+}
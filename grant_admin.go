@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+const grantAdminCommand = "grant-admin"
+
+// runGrantAdmin implements `grant-admin EMAIL`, which adds the user with the email to the admin role. It is
+// how the first admin is made, every later one can be granted the role through the API. It returns the exit
+// code, 1 when the user or the role cannot be found and 2 for invalid arguments.
+func runGrantAdmin(dbService services.IDatabaseOperationService, roleService services.IRoleService, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(grantAdminCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s EMAIL\n", grantAdminCommand)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	email := flags.Arg(0)
+	user, err := dbService.FindUserByEmail(email)
+	if err != nil {
+		fmt.Fprintf(stderr, "Cannot find the user %s: %v\n", email, err)
+		return 1
+	}
+	role, err := roleService.FindRoleByName(models.RoleAdmin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Cannot find the %s role: %v\n", models.RoleAdmin, err)
+		return 1
+	}
+	if err := roleService.AddRoleMembers(role.ID, []uint{user.ID}); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s has the %s role\n", user.Email, models.RoleAdmin)
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestRunGrantAdmin(t *testing.T) {
+	t.Run("adds the user to the admin role", func(t *testing.T) {
+		dbService := new(mocks.MockDatabaseOperationService)
+		dbService.On("FindUserByEmail", "jane@example.com").Return(&models.User{ID: 7, Email: "jane@example.com"}, nil)
+		roleService := new(mocks.MockRoleService)
+		roleService.On("FindRoleByName", models.RoleAdmin).Return(&models.Role{ID: 1, RoleName: models.RoleAdmin}, nil)
+		roleService.On("AddRoleMembers", uint(1), []uint{7}).Return(nil)
+		var stdout, stderr bytes.Buffer
+
+		assert.Equal(t, 0, runGrantAdmin(dbService, roleService, []string{"jane@example.com"}, &stdout, &stderr))
+		assert.Equal(t, "jane@example.com has the admin role\n", stdout.String())
+		roleService.AssertExpectations(t)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		dbService := new(mocks.MockDatabaseOperationService)
+		dbService.On("FindUserByEmail", "who@example.com").Return(nil, gorm.ErrRecordNotFound)
+		roleService := new(mocks.MockRoleService)
+		var stdout, stderr bytes.Buffer
+
+		assert.Equal(t, 1, runGrantAdmin(dbService, roleService, []string{"who@example.com"}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "who@example.com")
+		roleService.AssertNotCalled(t, "AddRoleMembers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing admin role", func(t *testing.T) {
+		dbService := new(mocks.MockDatabaseOperationService)
+		dbService.On("FindUserByEmail", "jane@example.com").Return(&models.User{ID: 7, Email: "jane@example.com"}, nil)
+		roleService := new(mocks.MockRoleService)
+		roleService.On("FindRoleByName", models.RoleAdmin).Return(nil, errors.New("record not found"))
+		var stdout, stderr bytes.Buffer
+
+		assert.Equal(t, 1, runGrantAdmin(dbService, roleService, []string{"jane@example.com"}, &stdout, &stderr))
+		roleService.AssertNotCalled(t, "AddRoleMembers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"a@example.com", "b@example.com"}, {"-role", "editor", "a@example.com"}} {
+			dbService := new(mocks.MockDatabaseOperationService)
+			roleService := new(mocks.MockRoleService)
+			var stdout, stderr bytes.Buffer
+
+			assert.Equal(t, 2, runGrantAdmin(dbService, roleService, args, &stdout, &stderr), args)
+			dbService.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCursorPageSize = 50
+	maxCursorPageSize     = 500
+)
+
+// cursorPage reads the after and limit query parameters of the admin listings, which page by ascending
+// ID. It responds with 400 and returns false when either is invalid.
+func cursorPage(c *gin.Context) (uint64, int, bool) {
+	var afterID uint64
+	if after := c.Query("after"); after != "" {
+		var err error
+		if afterID, err = strconv.ParseUint(after, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after cursor"})
+			return 0, 0, false
+		}
+	}
+	limit, ok := pageLimit(c)
+	return afterID, limit, ok
+}
+
+// pageLimit reads the limit query parameter of the admin listings. It responds with 400 and returns false
+// when it is invalid.
+func pageLimit(c *gin.Context) (int, bool) {
+	limit := defaultCursorPageSize
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxCursorPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxCursorPageSize)})
+			return 0, false
+		}
+	}
+	return limit, true
+}
+
+func nextCursor(lastID uint64) string {
+	return strconv.FormatUint(lastID, 10)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type DeadLetterHandler struct {
+	outboxService services.IOutboxService
+}
+
+func NewDeadLetterHandler(outboxService services.IOutboxService) *DeadLetterHandler {
+	return &DeadLetterHandler{outboxService: outboxService}
+}
+
+// ListDeadLetters pages through the deliveries that ran out of attempts, oldest first. The optional query
+// parameters are after, the next_cursor of the previous page, limit and type.
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	afterID, limit, ok := cursorPage(c)
+	if !ok {
+		return
+	}
+
+	deadLetters, err := h.outboxService.ListDeadLetters(afterID, limit, c.Query("type"))
+	if err != nil {
+		log.Printf("Failed to list dead letters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead letters"})
+		return
+	}
+	response := models.DeadLetterList{DeadLetters: deadLetters}
+	if len(deadLetters) == limit {
+		response.NextCursor = nextCursor(deadLetters[len(deadLetters)-1].ID)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ReplayDeadLetter hands one dead letter back to the outbox relay. Dead letters whose credentials are no
+// longer needed are refused with a conflict.
+func (h *DeadLetterHandler) ReplayDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dead letter ID"})
+		return
+	}
+	if err := h.outboxService.ReplayDeadLetter(id); err != nil {
+		if errors.Is(err, services.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrDeadLetterStale) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to replay dead letter %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letter"})
+		return
+	}
+	c.JSON(http.StatusAccepted, models.DeadLetterReplayResult{Replayed: 1})
+}
+
+// ReplayDeadLetters hands every dead letter back to the outbox relay, only those of the type query
+// parameter when it is given
+func (h *DeadLetterHandler) ReplayDeadLetters(c *gin.Context) {
+	replayed, err := h.outboxService.ReplayDeadLetters(c.Query("type"))
+	if err != nil {
+		log.Printf("Failed to replay dead letters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay dead letters"})
+		return
+	}
+	c.JSON(http.StatusAccepted, models.DeadLetterReplayResult{Replayed: replayed})
+}
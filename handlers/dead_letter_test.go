@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deadLetterRouter(outboxService services.IOutboxService) *gin.Engine {
+	handler := NewDeadLetterHandler(outboxService)
+	return newTestRouter(0, func(router *gin.Engine) {
+		router.GET("/dead-letters", handler.ListDeadLetters)
+		router.POST("/dead-letters/replay", handler.ReplayDeadLetters)
+		router.POST("/dead-letters/:id/replay", handler.ReplayDeadLetter)
+	})
+}
+
+func TestListDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns a cursor when the page is full", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ListDeadLetters", uint64(10), 2, models.OutboxPasswordDelivery).
+			Return([]models.DeadLetter{{ID: 11}, {ID: 14}}, nil)
+
+		w := serve(deadLetterRouter(mockService), http.MethodGet, "/dead-letters?after=10&limit=2&type=password_delivery")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response models.DeadLetterList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.DeadLetters, 2)
+		assert.Equal(t, "14", response.NextCursor)
+		assert.NotContains(t, w.Body.String(), "payload")
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ListDeadLetters", uint64(0), defaultCursorPageSize, "").Return([]models.DeadLetter{{ID: 1}}, nil)
+
+		w := serve(deadLetterRouter(mockService), http.MethodGet, "/dead-letters")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "next_cursor")
+	})
+
+	t.Run("rejects invalid paging", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		router := deadLetterRouter(mockService)
+
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/dead-letters?after=abc").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/dead-letters?limit=0").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/dead-letters?limit=501").Code)
+		mockService.AssertNotCalled(t, "ListDeadLetters")
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ListDeadLetters", uint64(0), defaultCursorPageSize, "").Return(nil, errors.New("connection refused"))
+
+		w := serve(deadLetterRouter(mockService), http.MethodGet, "/dead-letters")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "connection refused")
+	})
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("replays the message", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ReplayDeadLetter", uint64(7)).Return(nil)
+
+		w := serve(deadLetterRouter(mockService), http.MethodPost, "/dead-letters/7/replay")
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.JSONEq(t, `{"replayed":1}`, w.Body.String())
+	})
+
+	t.Run("unknown message", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ReplayDeadLetter", uint64(7)).Return(services.ErrDeadLetterNotFound)
+
+		w := serve(deadLetterRouter(mockService), http.MethodPost, "/dead-letters/7/replay")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("redacted message", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+		mockService.On("ReplayDeadLetter", uint64(7)).Return(services.ErrDeadLetterStale)
+
+		w := serve(deadLetterRouter(mockService), http.MethodPost, "/dead-letters/7/replay")
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		mockService := new(mocks.MockOutboxService)
+
+		w := serve(deadLetterRouter(mockService), http.MethodPost, "/dead-letters/abc/replay")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "ReplayDeadLetter")
+	})
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(mocks.MockOutboxService)
+	mockService.On("ReplayDeadLetters", models.OutboxPasswordSetupLink).Return(int64(3), nil)
+
+	w := serve(deadLetterRouter(mockService), http.MethodPost, "/dead-letters/replay?type=password_setup_link")
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"replayed":3}`, w.Body.String())
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func emailChangeRouter(emailChangeService services.IEmailChangeService, userID uint) *gin.Engine {
+	handler := NewEmailChangeHandler(emailChangeService)
+	return newTestRouter(userID, func(router *gin.Engine) {
+		router.POST("/me/email", handler.RequestEmailChange)
+		router.POST("/auth/email/confirm", handler.ConfirmEmailChange)
+	})
+}
+
+func TestRequestEmailChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	input := models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "secret"}
+	body := `{"new_email":"new@example.com","current_password":"secret"}`
+
+	tests := []struct {
+		name       string
+		serviceErr error
+		expected   int
+	}{
+		{"accepted", nil, http.StatusAccepted},
+		{"wrong password", services.ErrInvalidCredentials, http.StatusForbidden},
+		{"same address", services.ErrEmailUnchanged, http.StatusBadRequest},
+		{"address in use", services.ErrEmailTaken, http.StatusConflict},
+		{"deleted user", services.ErrProfileNotFound, http.StatusNotFound},
+		{"delivery cannot send it", services.ErrEmailChangeUnavailable, http.StatusNotImplemented},
+		{"database error", errors.New("connection lost"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockEmailChangeService)
+			mockService.On("RequestEmailChange", uint(7), input).Return(tt.serviceErr)
+
+			w := postJSON(emailChangeRouter(mockService, 7), "/me/email", body)
+
+			assert.Equal(t, tt.expected, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("invalid email", func(t *testing.T) {
+		mockService := new(mocks.MockEmailChangeService)
+
+		w := postJSON(emailChangeRouter(mockService, 7), "/me/email", `{"new_email":"not-an-email","current_password":"secret"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "RequestEmailChange")
+	})
+
+	t.Run("needs a token issued to a user", func(t *testing.T) {
+		mockService := new(mocks.MockEmailChangeService)
+
+		w := postJSON(emailChangeRouter(mockService, 0), "/me/email", body)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		serviceErr error
+		expected   int
+	}{
+		{"confirmed", nil, http.StatusOK},
+		{"invalid token", services.ErrInvalidEmailChangeToken, http.StatusBadRequest},
+		{"address taken meanwhile", services.ErrEmailTaken, http.StatusConflict},
+		{"database error", errors.New("connection lost"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockEmailChangeService)
+			mockService.On("ConfirmEmailChange", "token").Return(tt.serviceErr)
+
+			w := postJSON(emailChangeRouter(mockService, 0), "/auth/email/confirm", `{"token":"token"}`)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		w := postJSON(emailChangeRouter(new(mocks.MockEmailChangeService), 0), "/auth/email/confirm", `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
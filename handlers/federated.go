@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+const federatedStateCookie = "federated_state"
+
+type FederatedHandler struct {
+	federatedLoginService *services.FederatedLoginService
+	issuer                string
+}
+
+// NewFederatedHandler builds the default redirect URIs from issuer, see config.GetOIDCIssuer
+func NewFederatedHandler(federatedLoginService *services.FederatedLoginService, issuer string) *FederatedHandler {
+	return &FederatedHandler{federatedLoginService: federatedLoginService, issuer: issuer}
+}
+
+func (h *FederatedHandler) callbackURL(provider string) string {
+	return h.issuer + "/auth/federated/" + provider + "/callback"
+}
+
+func (h *FederatedHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.federatedLoginService.Providers()})
+}
+
+// Login redirects the browser to the upstream provider. The state is also kept in a cookie so the
+// callback can only be completed by the browser that started the flow.
+func (h *FederatedHandler) Login(c *gin.Context) {
+	provider := c.Param("provider")
+	authURL, state, err := h.federatedLoginService.AuthorizationURL(provider, h.callbackURL(provider))
+	if errors.Is(err, services.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "Unknown identity provider", Error: "unknown_provider"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Success: false, Message: "Identity provider unavailable", Error: "provider_unavailable"})
+		return
+	}
+
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(federatedStateCookie, state, int(utils.FederatedStateLifetime.Seconds()), "/auth/federated", "", secure, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *FederatedHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+	if upstreamError := c.Query("error"); upstreamError != "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Success: false, Message: "Identity provider denied the login", Error: upstreamError})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "code and state are required", Error: "invalid_request"})
+		return
+	}
+	if cookieState, err := c.Cookie(federatedStateCookie); err != nil || cookieState != state {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "State does not match this browser session", Error: "invalid_state"})
+		return
+	}
+	c.SetCookie(federatedStateCookie, "", -1, "/auth/federated", "", false, true)
+
+	token, err := h.federatedLoginService.CompleteLogin(provider, code, state, h.callbackURL(provider), loginMetadata(c))
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "Unknown identity provider", Error: "unknown_provider"})
+		return
+	case errors.Is(err, services.ErrInvalidState):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: err.Error(), Error: "invalid_state"})
+		return
+	case errors.Is(err, services.ErrAccountInactive):
+		c.JSON(http.StatusForbidden, ErrorResponse{Success: false, Message: "Account is not active", Error: "account_inactive"})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Success: false, Message: err.Error(), Error: "authentication_failed"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, LoginResponse{
+		Success: true,
+		Message: "Login successful",
+		Token:   token,
+	})
+}
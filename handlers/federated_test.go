@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFederatedHandler(t *testing.T) (*FederatedHandler, *tests.OIDCProviderStub, *mocks.MockDatabaseOperationService, *mocks.MockFederatedIdentityService) {
+	stub := tests.NewOIDCProviderStub("upstream-client", "upstream-secret")
+	t.Cleanup(stub.Close)
+	connector := services.NewOIDCConnector(models.FederatedProviderConfig{
+		Name:         "stub",
+		Issuer:       stub.Issuer(),
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		Scopes:       []string{"openid", "email"},
+		ClaimMapping: models.FederatedClaimMapping{Email: "email", EmailVerified: "email_verified"},
+	})
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockIdentityService := new(mocks.MockFederatedIdentityService)
+	federatedLoginService := services.NewFederatedLoginService([]*services.OIDCConnector{connector}, mockDBService, mockIdentityService, nil)
+	return NewFederatedHandler(federatedLoginService, "http://auth.example.com"), stub, mockDBService, mockIdentityService
+}
+
+func TestFederatedLogin_RedirectsToProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, stub, _, _ := newTestFederatedHandler(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "http://auth.example.com/auth/federated/stub/login", nil)
+	c.Params = gin.Params{{Key: "provider", Value: "stub"}}
+
+	handler.Login(c)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, stub.Issuer()+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, "http://auth.example.com/auth/federated/stub/callback", location.Query().Get("redirect_uri"))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, federatedStateCookie, cookies[0].Name)
+	assert.Equal(t, location.Query().Get("state"), cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+}
+
+func TestFederatedLogin_UnknownProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := newTestFederatedHandler(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/unknown/login", nil)
+	c.Params = gin.Params{{Key: "provider", Value: "unknown"}}
+
+	handler.Login(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFederatedCallback_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, stub, mockDBService, mockIdentityService := newTestFederatedHandler(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-1"}
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-1").Return(&models.FederatedIdentity{UserID: user.ID}, nil)
+	mockDBService.On("FindUserByID", user.ID).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID, FirstName: mocks.TestUserFirstName}, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{IPAddress: "192.0.2.1", UserAgent: "test-browser/1.0"}).Return(nil)
+
+	loginRecorder := httptest.NewRecorder()
+	loginContext, _ := gin.CreateTestContext(loginRecorder)
+	loginContext.Request = httptest.NewRequest(http.MethodGet, "http://auth.example.com/auth/federated/stub/login", nil)
+	loginContext.Params = gin.Params{{Key: "provider", Value: "stub"}}
+	handler.Login(loginContext)
+	location, err := url.Parse(loginRecorder.Header().Get("Location"))
+	require.NoError(t, err)
+	state := location.Query().Get("state")
+	code := stub.IssueCode(location.Query().Get("nonce"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "http://auth.example.com/auth/federated/stub/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	c.Request.AddCookie(loginRecorder.Result().Cookies()[0])
+	c.Request.Header.Set("User-Agent", "test-browser/1.0")
+	c.Params = gin.Params{{Key: "provider", Value: "stub"}}
+
+	handler.Callback(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockDBService.AssertCalled(t, "RecordLogin", user.ID, models.LoginMetadata{IPAddress: "192.0.2.1", UserAgent: "test-browser/1.0"})
+	var response LoginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.NotEmpty(t, response.Token)
+}
+
+func TestFederatedCallback_StateCookieMismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := newTestFederatedHandler(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/stub/callback?code=abc&state=xyz", nil)
+	c.Request.AddCookie(&http.Cookie{Name: federatedStateCookie, Value: "other"})
+	c.Params = gin.Params{{Key: "provider", Value: "stub"}}
+
+	handler.Callback(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFederatedCallback_UnverifiedEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, stub, _, mockIdentityService := newTestFederatedHandler(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-2", "email": "someone@example.com"}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-2").Return(nil, errors.New("record not found"))
+
+	loginRecorder := httptest.NewRecorder()
+	loginContext, _ := gin.CreateTestContext(loginRecorder)
+	loginContext.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/stub/login", nil)
+	loginContext.Params = gin.Params{{Key: "provider", Value: "stub"}}
+	handler.Login(loginContext)
+	location, _ := url.Parse(loginRecorder.Header().Get("Location"))
+	state := location.Query().Get("state")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/stub/callback?"+url.Values{"code": {stub.IssueCode(location.Query().Get("nonce"))}, "state": {state}}.Encode(), nil)
+	c.Request.AddCookie(&http.Cookie{Name: federatedStateCookie, Value: state})
+	c.Params = gin.Params{{Key: "provider", Value: "stub"}}
+
+	handler.Callback(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestFederatedCallback_DisabledUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, stub, mockDBService, mockIdentityService := newTestFederatedHandler(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-1"}
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusDisabled}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-1").Return(&models.FederatedIdentity{UserID: user.ID}, nil)
+	mockDBService.On("FindUserByID", user.ID).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID, FirstName: mocks.TestUserFirstName}, nil)
+
+	loginRecorder := httptest.NewRecorder()
+	loginContext, _ := gin.CreateTestContext(loginRecorder)
+	loginContext.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/stub/login", nil)
+	loginContext.Params = gin.Params{{Key: "provider", Value: "stub"}}
+	handler.Login(loginContext)
+	location, _ := url.Parse(loginRecorder.Header().Get("Location"))
+	state := location.Query().Get("state")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/federated/stub/callback?"+url.Values{"code": {stub.IssueCode(location.Query().Get("nonce"))}, "state": {state}}.Encode(), nil)
+	c.Request.AddCookie(&http.Cookie{Name: federatedStateCookie, Value: state})
+	c.Params = gin.Params{{Key: "provider", Value: "stub"}}
+
+	handler.Callback(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "account_inactive")
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type InvitationHandler struct {
+	invitationService services.IInvitationService
+}
+
+func NewInvitationHandler(invitationService services.IInvitationService) *InvitationHandler {
+	return &InvitationHandler{invitationService: invitationService}
+}
+
+// CreateInvitation sends an invitation with the roles to an address that has no account yet
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var input models.InvitationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	inviterID, _ := currentUserID(c)
+
+	invitation, err := h.invitationService.CreateInvitation(inviterID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidInvitation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvitationUnavailable):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Failed to create invitation: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitations pages through the invitations, oldest first. The optional query parameters are after, the
+// next_cursor of the previous page, limit and status.
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	afterID, limit, ok := cursorPage(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.ListInvitations(afterID, limit, c.Query("status"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInvitation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to list invitations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invitations"})
+		return
+	}
+	response := models.InvitationList{Invitations: invitations}
+	if len(invitations) == limit {
+		response.NextCursor = nextCursor(uint64(invitations[len(invitations)-1].ID))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeInvitation keeps a pending invitation from being accepted
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	if err := h.invitationService.RevokeInvitation(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvitationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvitationNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Failed to revoke invitation %d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation creates the account the token was sent for, with the password the invitee chose
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var input models.InvitationAcceptRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidInvitationToken), errors.Is(err, services.ErrInvalidInvitation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Failed to accept invitation: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Account created, you can log in now", "email": user.Email})
+}
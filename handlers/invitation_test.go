@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// invitationRouter authenticates every request as the admin with ID 1, accepting ignores the user
+func invitationRouter(invitationService services.IInvitationService) *gin.Engine {
+	handler := NewInvitationHandler(invitationService)
+	return newTestRouter(1, func(router *gin.Engine) {
+		router.POST("/admin/invitations", handler.CreateInvitation)
+		router.GET("/admin/invitations", handler.ListInvitations)
+		router.POST("/admin/invitations/:id/revoke", handler.RevokeInvitation)
+		router.POST("/auth/invitations/accept", handler.AcceptInvitation)
+	})
+}
+
+func TestCreateInvitation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	input := models.InvitationRequest{Email: "jane@example.com", FirstName: "Jane", Roles: []string{"editor"}}
+	body := `{"email":"jane@example.com","first_name":"Jane","roles":["editor"]}`
+
+	t.Run("creates the invitation as the admin", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+		mockService.On("CreateInvitation", uint(1), input).Return(&models.InvitationSummary{
+			ID: 3, Email: "jane@example.com", Roles: []string{"editor"}, Status: models.InvitationPending,
+		}, nil)
+
+		w := postJSON(invitationRouter(mockService), "/admin/invitations", body)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var invitation models.InvitationSummary
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitation))
+		assert.Equal(t, uint(3), invitation.ID)
+		assert.Equal(t, models.InvitationPending, invitation.Status)
+		assert.NotContains(t, w.Body.String(), "token")
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+
+		w := postJSON(invitationRouter(mockService), "/admin/invitations", `{"email":"jane"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "CreateInvitation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("maps errors", func(t *testing.T) {
+		tests := []struct {
+			err      error
+			expected int
+		}{
+			{fmt.Errorf("%w: role editor does not exist", services.ErrInvalidInvitation), http.StatusBadRequest},
+			{services.ErrEmailTaken, http.StatusConflict},
+			{services.ErrInvitationUnavailable, http.StatusNotImplemented},
+			{errors.New("database down"), http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			mockService := new(mocks.MockInvitationService)
+			mockService.On("CreateInvitation", uint(1), input).Return(nil, tt.err)
+
+			w := postJSON(invitationRouter(mockService), "/admin/invitations", body)
+
+			assert.Equal(t, tt.expected, w.Code, tt.err.Error())
+		}
+	})
+}
+
+func TestListInvitations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns a cursor when the page is full", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+		mockService.On("ListInvitations", uint64(10), 2, models.InvitationPending).
+			Return([]models.InvitationSummary{{ID: 11, Roles: []string{}}, {ID: 14, Roles: []string{}}}, nil)
+
+		w := serve(invitationRouter(mockService), http.MethodGet, "/admin/invitations?after=10&limit=2&status=pending")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response models.InvitationList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Invitations, 2)
+		assert.Equal(t, "14", response.NextCursor)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+		mockService.On("ListInvitations", uint64(0), defaultCursorPageSize, "lost").
+			Return(nil, fmt.Errorf("%w: unknown status lost", services.ErrInvalidInvitation))
+
+		w := serve(invitationRouter(mockService), http.MethodGet, "/admin/invitations?status=lost")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"revokes", nil, http.StatusOK},
+		{"unknown invitation", services.ErrInvitationNotFound, http.StatusNotFound},
+		{"already used", services.ErrInvitationNotPending, http.StatusConflict},
+		{"database failure", errors.New("database down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockInvitationService)
+			mockService.On("RevokeInvitation", uint(3)).Return(tt.err)
+
+			w := postJSON(invitationRouter(mockService), "/admin/invitations/3/revoke", "")
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+
+	t.Run("invalid invitation ID", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+
+		w := postJSON(invitationRouter(mockService), "/admin/invitations/jane/revoke", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "RevokeInvitation", mock.Anything)
+	})
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	input := models.InvitationAcceptRequest{Token: "token", Password: "correct horse", FirstName: "Jane", LastName: "Doe"}
+	body := `{"token":"token","password":"correct horse","first_name":"Jane","last_name":"Doe"}`
+
+	t.Run("creates the account", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+		mockService.On("AcceptInvitation", input).Return(&models.User{ID: 7, Email: "jane@example.com"}, nil)
+
+		w := postJSON(invitationRouter(mockService), "/auth/invitations/accept", body)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"email":"jane@example.com"`)
+	})
+
+	t.Run("short password", func(t *testing.T) {
+		mockService := new(mocks.MockInvitationService)
+
+		w := postJSON(invitationRouter(mockService), "/auth/invitations/accept", `{"token":"token","password":"short"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "AcceptInvitation", mock.Anything)
+	})
+
+	t.Run("maps errors", func(t *testing.T) {
+		tests := []struct {
+			err      error
+			expected int
+		}{
+			{services.ErrInvalidInvitationToken, http.StatusBadRequest},
+			{fmt.Errorf("%w: first_name is required", services.ErrInvalidInvitation), http.StatusBadRequest},
+			{services.ErrEmailTaken, http.StatusConflict},
+			{errors.New("database down"), http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			mockService := new(mocks.MockInvitationService)
+			mockService.On("AcceptInvitation", input).Return(nil, tt.err)
+
+			w := postJSON(invitationRouter(mockService), "/auth/invitations/accept", body)
+
+			assert.Equal(t, tt.expected, w.Code, tt.err.Error())
+		}
+	})
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
 )
 
 // LoginResponse represents the structure of a successful login response
@@ -23,6 +25,11 @@ type ErrorResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// loginMetadata describes the client of the request, it is recorded as the user's last login
+func loginMetadata(c *gin.Context) models.LoginMetadata {
+	return models.LoginMetadata{IPAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
 func (h *UserHandler) LoginUser(c *gin.Context) {
 	var input models.LoginRequest
 
@@ -69,11 +76,22 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 	}
 
 	// Attempt login
+	input.Metadata = loginMetadata(c)
 	token, err := h.userLoginService.Login(input)
 	if err != nil {
 		// Log failed login attempt for security monitoring
 		log.Printf("Failed login attempt for email: %s from IP: %s - Error: %v", input.Email, c.ClientIP(), err)
 		
+		// Only users who know the password learn that the account is not active
+		if errors.Is(err, services.ErrAccountInactive) {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Success: false,
+				Message: "Account is not active",
+				Error:   "account_inactive",
+			})
+			return
+		}
+
 		// Return generic error message to prevent information disclosure
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Success: false,
@@ -18,8 +18,7 @@ func TestLoginUser(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	t.Run("successful login", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
@@ -32,6 +31,7 @@ func TestLoginUser(t *testing.T) {
 		user := &models.User{
 			Email:    mocks.TestUserEmail,
 			Password: mocks.TestUserPasswordHash,
+			Status:   models.UserStatusActive,
 		}
 		userDetails := &models.UserDetail{
 			FirstName: mocks.TestUserFirstName,
@@ -40,11 +40,15 @@ func TestLoginUser(t *testing.T) {
 
 		mockDBService.On("FindUserByEmail", loginRequest.Email).Return(user, nil)
 		mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+		loginMetadata := models.LoginMetadata{IPAddress: "203.0.113.7", UserAgent: "test-client/1.0"}
+		mockDBService.On("RecordLogin", user.ID, loginMetadata).Return(nil)
 
 		// Create a request
 		requestBody, _ := json.Marshal(loginRequest)
 		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(requestBody))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", loginMetadata.UserAgent)
+		req.RemoteAddr = "203.0.113.7:51000"
 
 		// Create a ResponseRecorder to capture the response
 		w := httptest.NewRecorder()
@@ -67,12 +71,14 @@ func TestLoginUser(t *testing.T) {
 		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
 		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
 		assert.Equal(t, "1; mode=block", w.Header().Get("X-XSS-Protection"))
+
+		// The login is recorded with the client it came from
+		mockDBService.AssertCalled(t, "RecordLogin", user.ID, loginMetadata)
 	})
 
 	t.Run("bad request with invalid JSON", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -100,8 +106,7 @@ func TestLoginUser(t *testing.T) {
 
 	t.Run("Invalid Email format Error Test", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -137,8 +142,7 @@ func TestLoginUser(t *testing.T) {
 
 	t.Run("Unauthorized Login Test", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -151,6 +155,7 @@ func TestLoginUser(t *testing.T) {
 		user := &models.User{
 			Email:    mocks.TestUserEmail,
 			Password: mocks.TestUserPasswordHash,
+			Status:   models.UserStatusActive,
 		}
 		userDetails := &models.UserDetail{
 			FirstName: mocks.TestUserFirstName,
@@ -182,10 +187,39 @@ func TestLoginUser(t *testing.T) {
 		assert.Equal(t, "authentication_failed", response.Error)
 	})
 
+	t.Run("Suspended account", func(t *testing.T) {
+		mockDBService := new(mocks.MockDatabaseOperationService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
+		mockLoginService := services.NewUserLoginService(mockDBService)
+		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
+
+		user := &models.User{
+			Email:    mocks.TestUserEmail,
+			Password: mocks.TestUserPasswordHash,
+			Status:   models.UserStatusSuspended,
+		}
+		mockDBService.On("FindUserByEmail", user.Email).Return(user, nil)
+		mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{FirstName: mocks.TestUserFirstName}, nil)
+
+		requestBody, _ := json.Marshal(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+		req, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		handler.LoginUser(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		var response ErrorResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "account_inactive", response.Error)
+		assert.NotContains(t, w.Body.String(), "token")
+	})
+
 	t.Run("Empty email validation", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -219,8 +253,7 @@ func TestLoginUser(t *testing.T) {
 
 	t.Run("Missing email field validation", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -248,8 +281,7 @@ func TestLoginUser(t *testing.T) {
 
 	t.Run("Empty password validation", func(t *testing.T) {
 		mockDBService := new(mocks.MockDatabaseOperationService)
-		mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-		mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+		mockRegService := services.NewUserRegistrationService(mockDBService)
 		mockLoginService := services.NewUserLoginService(mockDBService)
 		handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"bytes"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
@@ -23,3 +27,40 @@ func TestMain(m *testing.M) {
 	// Exit with the appropriate code
 	os.Exit(code)
 }
+
+// newTestRouter returns a router with the routes added by register. Every request is authenticated as userID,
+// zero leaves the request without a user.
+func newTestRouter(userID uint, register func(router *gin.Engine)) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID > 0 {
+			c.Set("user_id", userID)
+		}
+	})
+	register(router)
+	return router
+}
+
+// serve sends a request without a body to the router
+func serve(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// serveBody sends a request with the body in the content type to the router
+func serveBody(router http.Handler, method, target, contentType string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, bytes.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	router.ServeHTTP(w, request)
+	return w
+}
+
+func serveJSON(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	return serveBody(router, method, target, "application/json", []byte(body))
+}
+
+func postJSON(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	return serveJSON(router, http.MethodPost, path, body)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	issuer      string
+}
+
+// NewOIDCHandler advertises issuer, see config.GetOIDCIssuer, as the iss claim and the base of every endpoint
+func NewOIDCHandler(oidcService *services.OIDCService, issuer string) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, issuer: issuer}
+}
+
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oidcService.Discovery(h.issuer))
+}
+
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	keySet, err := h.oidcService.KeySet()
+	if err != nil {
+		log.Printf("Failed to load OIDC signing key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "signing key unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, keySet)
+}
+
+func (h *OIDCHandler) Token(c *gin.Context) {
+	var input models.TokenRequest
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.OAuthError{Error: services.ErrInvalidRequest.Error(), ErrorDescription: "grant_type is required"})
+		return
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		input.ClientID, input.ClientSecret = clientID, clientSecret
+	}
+	input.Metadata = loginMetadata(c)
+
+	// Token responses must never be cached (RFC 6749 section 5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	response, err := h.oidcService.IssueToken(h.issuer, input)
+	if err != nil {
+		var status int
+		switch {
+		case errors.Is(err, services.ErrInvalidClient):
+			status = http.StatusUnauthorized
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		case errors.Is(err, services.ErrInvalidGrant), errors.Is(err, services.ErrInvalidRequest), errors.Is(err, services.ErrUnsupportedGrantType):
+			status = http.StatusBadRequest
+		default:
+			c.JSON(http.StatusInternalServerError, models.OAuthError{Error: "server_error"})
+			return
+		}
+		log.Printf("Token request from client %s rejected: %v", input.ClientID, err)
+		c.JSON(status, models.OAuthError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	email, _ := c.Get("email")
+	emailString, _ := email.(string)
+	userInfo, err := h.oidcService.UserInfo(emailString)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	c.JSON(http.StatusOK, userInfo)
+}
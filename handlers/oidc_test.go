@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestOIDCHandler() (*OIDCHandler, *mocks.MockDatabaseOperationService, *mocks.MockOAuthClientService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	mockTokenStore.On("CreateRefreshToken", mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+	loginService := services.NewUserLoginService(mockDBService)
+	oidcService := services.NewOIDCService(loginService, mockDBService, mockClientService, mockTokenStore)
+	return NewOIDCHandler(oidcService, "https://id.example.com"), mockDBService, mockClientService
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _ := newTestOIDCHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "http://auth.example.com/.well-known/openid-configuration", nil)
+
+	handler.Discovery(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var configuration models.OpenIDConfiguration
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &configuration))
+	assert.Equal(t, "https://id.example.com", configuration.Issuer)
+	assert.Equal(t, "https://id.example.com/oauth/token", configuration.TokenEndpoint)
+	assert.Equal(t, "https://id.example.com/userinfo", configuration.UserInfoEndpoint)
+	assert.Equal(t, "https://id.example.com/.well-known/jwks.json", configuration.JwksURI)
+	assert.Contains(t, configuration.ScopesSupported, "openid")
+	assert.Empty(t, configuration.AuthorizationEndpoint)
+	assert.Empty(t, configuration.ResponseTypesSupported)
+	assert.Equal(t, []string{"password", "refresh_token"}, configuration.GrantTypesSupported)
+}
+
+func TestOIDCDiscovery_IgnoresRequestHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _ := newTestOIDCHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "http://attacker.example.net/.well-known/openid-configuration", nil)
+	c.Request.Header.Set("X-Forwarded-Proto", "https")
+
+	handler.Discovery(c)
+
+	var configuration models.OpenIDConfiguration
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &configuration))
+	assert.Equal(t, "https://id.example.com", configuration.Issuer)
+	assert.NotContains(t, w.Body.String(), "attacker")
+}
+
+func TestOIDCJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _ := newTestOIDCHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+
+	handler.JWKS(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var keySet models.JSONWebKeySet
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &keySet))
+	assert.Len(t, keySet.Keys, 1)
+	assert.Equal(t, "RS256", keySet.Keys[0].Algorithm)
+}
+
+func TestOIDCToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("password grant with openid scope returns an id token", func(t *testing.T) {
+		handler, mockDBService, mockClientService := newTestOIDCHandler()
+		user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash, Status: models.UserStatusActive}
+		userDetails := &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+		mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+		mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+		mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+		mockDBService.On("RecordLogin", user.ID, mock.Anything).Return(nil)
+
+		form := url.Values{
+			"grant_type": {"password"},
+			"username":   {mocks.TestUserEmail},
+			"password":   {mocks.TestUserPassword},
+			"scope":      {"openid profile"},
+		}
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		c.Request.SetBasicAuth(mocks.TestClientID, mocks.TestClientSecret)
+
+		handler.Token(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		var response models.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.AccessToken)
+		assert.NotEmpty(t, response.IDToken)
+		assert.NotEmpty(t, response.RefreshToken)
+		mockDBService.AssertExpectations(t)
+	})
+
+	t.Run("invalid client credentials", func(t *testing.T) {
+		handler, _, mockClientService := newTestOIDCHandler()
+		mockClientService.On("AuthenticateClient", mocks.TestClientID, "wrong").Return(nil, errors.New("Invalid client"))
+
+		form := url.Values{"grant_type": {"password"}, "client_id": {mocks.TestClientID}, "client_secret": {"wrong"}}
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		handler.Token(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error": "invalid_client"}`, w.Body.String())
+	})
+
+	t.Run("missing grant type", func(t *testing.T) {
+		handler, _, _ := newTestOIDCHandler()
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(""))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		handler.Token(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+	})
+}
+
+func TestOIDCUserInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns standard claims for the token subject", func(t *testing.T) {
+		handler, mockDBService, _ := newTestOIDCHandler()
+		user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+		userDetails := &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+		mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+		mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		c.Set("email", mocks.TestUserEmail)
+
+		handler.UserInfo(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"sub": "1",
+			"given_name": "Test",
+			"family_name": "User",
+			"name": "Test User",
+			"email": "user1@testmail.com",
+			"email_verified": false
+		}`, w.Body.String())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		handler, mockDBService, _ := newTestOIDCHandler()
+		mockDBService.On("FindUserByEmail", "ghost@example.com").Return(nil, errors.New("record not found"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		c.Set("email", "ghost@example.com")
+
+		handler.UserInfo(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type PasswordSetupHandler struct {
+	passwordSetupService services.IPasswordSetupService
+}
+
+func NewPasswordSetupHandler(passwordSetupService services.IPasswordSetupService) *PasswordSetupHandler {
+	return &PasswordSetupHandler{passwordSetupService: passwordSetupService}
+}
+
+// SetupPassword lets a user who registered in setup_link onboarding mode choose their password
+func (h *PasswordSetupHandler) SetupPassword(c *gin.Context) {
+	var input models.PasswordSetupRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordSetupService.CompletePasswordSetup(input.Token, input.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidSetupToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password set successfully, you can now log in"})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func performPasswordSetup(handler *PasswordSetupHandler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/password/setup", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.SetupPassword(c)
+	return w
+}
+
+func TestSetupPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("sets the password", func(t *testing.T) {
+		mockService := new(mocks.MockPasswordSetupService)
+		mockService.On("CompletePasswordSetup", "setup-token", "chosen-password").Return(nil)
+
+		w := performPasswordSetup(NewPasswordSetupHandler(mockService), `{"token":"setup-token","password":"chosen-password"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("rejects a short password", func(t *testing.T) {
+		mockService := new(mocks.MockPasswordSetupService)
+
+		w := performPasswordSetup(NewPasswordSetupHandler(mockService), `{"token":"setup-token","password":"short"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "CompletePasswordSetup")
+	})
+
+	t.Run("rejects an invalid token", func(t *testing.T) {
+		mockService := new(mocks.MockPasswordSetupService)
+		mockService.On("CompletePasswordSetup", "used-token", "chosen-password").Return(services.ErrInvalidSetupToken)
+
+		w := performPasswordSetup(NewPasswordSetupHandler(mockService), `{"token":"used-token","password":"chosen-password"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), services.ErrInvalidSetupToken.Error())
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		mockService := new(mocks.MockPasswordSetupService)
+		mockService.On("CompletePasswordSetup", "setup-token", "chosen-password").Return(errors.New("connection refused"))
+
+		w := performPasswordSetup(NewPasswordSetupHandler(mockService), `{"token":"setup-token","password":"chosen-password"}`)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "connection refused")
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type ProfileHandler struct {
+	profileService services.IProfileService
+}
+
+func NewProfileHandler(profileService services.IProfileService) *ProfileHandler {
+	return &ProfileHandler{profileService: profileService}
+}
+
+// currentUserID is the user the access token was issued to, set by the auth middleware
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return 0, false
+	}
+	id, ok := userID.(uint)
+	return id, ok && id > 0
+}
+
+func (h *ProfileHandler) respondWithError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidProfile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProfileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetMe returns the details, email and roles of the authenticated user
+func (h *ProfileHandler) GetMe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not issued to a user"})
+		return
+	}
+	profile, err := h.profileService.GetProfile(userID)
+	if err != nil {
+		h.respondWithError(c, err, "Failed to load profile")
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateMe changes the names and preferred locale of the authenticated user
+func (h *ProfileHandler) UpdateMe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not issued to a user"})
+		return
+	}
+	var input models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := h.profileService.UpdateProfile(userID, input)
+	if err != nil {
+		h.respondWithError(c, err, "Failed to update profile")
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
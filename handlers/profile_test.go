@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func profileRouter(profileService services.IProfileService, userID uint) *gin.Engine {
+	handler := NewProfileHandler(profileService)
+	return newTestRouter(userID, func(router *gin.Engine) {
+		router.GET("/me", handler.GetMe)
+		router.PATCH("/me", handler.UpdateMe)
+	})
+}
+
+func TestGetMe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns the profile", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+		lastLoginAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+		mockService.On("GetProfile", uint(7)).Return(&models.UserProfile{
+			UserDetail:  models.UserDetail{UserID: 7, FirstName: "Jane", LastName: "Doe"},
+			Email:       mocks.TestUserEmail,
+			Roles:       []string{"admin"},
+			CreatedAt:   time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC),
+			UpdatedAt:   time.Date(2026, time.October, 2, 8, 0, 0, 0, time.UTC),
+			LastLoginAt: &lastLoginAt,
+		}, nil)
+
+		w := serve(profileRouter(mockService, 7), http.MethodGet, "/me")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var profile map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &profile))
+		assert.Equal(t, float64(7), profile["user_id"])
+		assert.Equal(t, "Jane", profile["first_name"])
+		assert.Equal(t, mocks.TestUserEmail, profile["email"])
+		assert.Equal(t, []any{"admin"}, profile["roles"])
+		assert.Equal(t, "2026-10-01T08:00:00Z", profile["created_at"])
+		assert.Equal(t, "2026-10-02T08:00:00Z", profile["updated_at"])
+		assert.Equal(t, "2026-10-18T09:30:00Z", profile["last_login_at"])
+	})
+
+	t.Run("needs a token issued to a user", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+
+		w := serve(profileRouter(mockService, 0), http.MethodGet, "/me")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockService.AssertNotCalled(t, "GetProfile", uint(0))
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+		mockService.On("GetProfile", uint(7)).Return(nil, services.ErrProfileNotFound)
+
+		w := serve(profileRouter(mockService, 7), http.MethodGet, "/me")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestUpdateMe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lastName := "Smith"
+
+	t.Run("updates the given fields", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+		mockService.On("UpdateProfile", uint(7), models.UpdateProfileRequest{LastName: &lastName}).
+			Return(&models.UserProfile{UserDetail: models.UserDetail{UserID: 7, LastName: lastName}, Roles: []string{}}, nil)
+
+		w := serveJSON(profileRouter(mockService, 7), http.MethodPatch, "/me", `{"last_name": "Smith"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"last_name":"Smith"`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+		mockService.On("UpdateProfile", uint(7), models.UpdateProfileRequest{LastName: new(string)}).
+			Return(nil, errors.Join(services.ErrInvalidProfile, errors.New("last_name must not be empty")))
+
+		w := serveJSON(profileRouter(mockService, 7), http.MethodPatch, "/me", `{"last_name": ""}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+
+		w := serveJSON(profileRouter(mockService, 7), http.MethodPatch, "/me", `{"last_name": 5}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "UpdateProfile")
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		mockService := new(mocks.MockProfileService)
+		mockService.On("UpdateProfile", uint(7), models.UpdateProfileRequest{LastName: &lastName}).Return(nil, errors.New("connection refused"))
+
+		w := serveJSON(profileRouter(mockService, 7), http.MethodPatch, "/me", `{"last_name": "Smith"}`)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"error": "Failed to update profile"}`, w.Body.String())
+	})
+}
@@ -19,8 +19,7 @@ import (
 // TestRegisterUser_Success tests the successful registration flow.
 func TestRegisterUser_Success(t *testing.T) {
 	mockDBService := new(mocks.MockDatabaseOperationService)
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
 	mockLoginService := services.NewUserLoginService(mockDBService)
 	handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -32,8 +31,8 @@ func TestRegisterUser_Success(t *testing.T) {
 	}
 	body, _ := json.Marshal(input)
 
-	// Mock the CreateUser method of the mockDBService to return no error.
-	mockDBService.On("CreateUser", mock.Anything, mock.Anything).Return(nil)
+	// Mock the CreateUserWithOutbox method of the mockDBService to return no error.
+	mockDBService.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	// Create a test request.
 	w := httptest.NewRecorder()
@@ -55,8 +54,7 @@ func TestRegisterUser_Success(t *testing.T) {
 // TestRegisterUser_InternalServerError tests the case where the CreateUser method fails.
 func TestRegisterUser_InternalServerError(t *testing.T) {
 	mockDBService := new(mocks.MockDatabaseOperationService)
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
 	mockLoginService := services.NewUserLoginService(mockDBService)
 	handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -68,8 +66,8 @@ func TestRegisterUser_InternalServerError(t *testing.T) {
 	}
 	body, _ := json.Marshal(input)
 
-	// Mock the CreateUser method to return an error.
-	mockDBService.On("CreateUser", mock.Anything, mock.Anything).Return(errors.New("failed to create user"))
+	// Mock the CreateUserWithOutbox method to return an error.
+	mockDBService.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("failed to create user"))
 
 	// Create a test request.
 	w := httptest.NewRecorder()
@@ -88,10 +86,27 @@ func TestRegisterUser_InternalServerError(t *testing.T) {
 	mockDBService.AssertExpectations(t)
 }
 
+func TestRegisterUser_EmailTaken(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
+	mockLoginService := services.NewUserLoginService(mockDBService)
+	handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
+	mockDBService.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(services.ErrEmailTaken)
+
+	body := `{"email": "test@example.com", "first_name": "FirstName", "last_name": "LastName"}`
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.RegisterUser(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error": "email address is already in use"}`, w.Body.String())
+}
+
 func TestRegisterUser_InvalidJson(t *testing.T) {
 	mockDBService := new(mocks.MockDatabaseOperationService)
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
 	mockLoginService := services.NewUserLoginService(mockDBService)
 	handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
 
@@ -110,3 +125,32 @@ func TestRegisterUser_InvalidJson(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "error")
 }
+
+func TestRegisterUser_PreferredLocale(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
+	mockLoginService := services.NewUserLoginService(mockDBService)
+	handler := &UserHandler{userRegistrationService: *mockRegService, userLoginService: *mockLoginService}
+	mockDBService.On("CreateUserWithOutbox", mock.Anything, mock.MatchedBy(func(userDetail *models.UserDetail) bool {
+		return userDetail.PreferredLocale == "pt-BR"
+	}), mock.Anything).Return(nil)
+
+	register := func(locale string) *httptest.ResponseRecorder {
+		body := `{"email": "test@example.com", "first_name": "FirstName", "last_name": "LastName", "preferred_locale": "` + locale + `"}`
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler.RegisterUser(c)
+		return w
+	}
+
+	// the locale is stored in its canonical form
+	assert.Equal(t, http.StatusOK, register("pt_br").Code)
+	mockDBService.AssertExpectations(t)
+
+	w := register("portuguese please")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "invalid locale portuguese please"}`, w.Body.String())
+	mockDBService.AssertNumberOfCalls(t, "CreateUserWithOutbox", 1)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type SCIMHandler struct {
+	scimService *services.SCIMService
+	baseURL     string
+}
+
+// NewSCIMHandler builds the resource locations from issuer, see config.GetOIDCIssuer
+func NewSCIMHandler(scimService *services.SCIMService, issuer string) *SCIMHandler {
+	return &SCIMHandler{scimService: scimService, baseURL: issuer + "/scim/v2"}
+}
+
+func respondSCIM(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+func respondSCIMError(c *gin.Context, err error) {
+	status, scimType := http.StatusInternalServerError, ""
+	switch {
+	case errors.Is(err, services.ErrSCIMNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrSCIMUniqueness):
+		status, scimType = http.StatusConflict, services.ErrSCIMUniqueness.Error()
+	case errors.Is(err, services.ErrSCIMInvalidValue):
+		status, scimType = http.StatusBadRequest, services.ErrSCIMInvalidValue.Error()
+	case errors.Is(err, services.ErrSCIMInvalidFilter):
+		status, scimType = http.StatusBadRequest, services.ErrSCIMInvalidFilter.Error()
+	case errors.Is(err, services.ErrSCIMInvalidPath):
+		status, scimType = http.StatusBadRequest, services.ErrSCIMInvalidPath.Error()
+	}
+
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("SCIM request %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+		detail = "Internal server error"
+	}
+	respondSCIM(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMErrorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+func bindSCIM(c *gin.Context, target any) bool {
+	if err := c.ShouldBindJSON(target); err != nil {
+		respondSCIM(c, http.StatusBadRequest, models.SCIMError{
+			Schemas:  []string{models.SCIMErrorSchema},
+			Status:   strconv.Itoa(http.StatusBadRequest),
+			SCIMType: "invalidSyntax",
+			Detail:   "Request body is not a valid SCIM resource",
+		})
+		return false
+	}
+	return true
+}
+
+// scimPage reads startIndex and count, count is -1 when the client did not send one
+func scimPage(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil {
+		count = -1
+	}
+	return startIndex, count
+}
+
+func scimExcludesMembers(c *gin.Context) bool {
+	for _, attribute := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, count := scimPage(c)
+	response, err := h.scimService.ListUsers(h.baseURL, c.Query("filter"), startIndex, count)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, response)
+}
+
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.scimService.GetUser(h.baseURL, c.Param("id"))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, user)
+}
+
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var input models.SCIMUser
+	if !bindSCIM(c, &input) {
+		return
+	}
+	user, err := h.scimService.CreateUser(h.baseURL, input)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Header("Location", user.Meta.Location)
+	respondSCIM(c, http.StatusCreated, user)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var input models.SCIMUser
+	if !bindSCIM(c, &input) {
+		return
+	}
+	user, err := h.scimService.ReplaceUser(h.baseURL, c.Param("id"), input)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, user)
+}
+
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var patch models.SCIMPatchRequest
+	if !bindSCIM(c, &patch) {
+		return
+	}
+	user, err := h.scimService.PatchUser(h.baseURL, c.Param("id"), patch)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, user)
+}
+
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if err := h.scimService.DeleteUser(c.Param("id")); err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex, count := scimPage(c)
+	response, err := h.scimService.ListGroups(h.baseURL, c.Query("filter"), startIndex, count, scimExcludesMembers(c))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, response)
+}
+
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	group, err := h.scimService.GetGroup(h.baseURL, c.Param("id"), scimExcludesMembers(c))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, group)
+}
+
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var input models.SCIMGroup
+	if !bindSCIM(c, &input) {
+		return
+	}
+	group, err := h.scimService.CreateGroup(h.baseURL, input)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Header("Location", group.Meta.Location)
+	respondSCIM(c, http.StatusCreated, group)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var input models.SCIMGroup
+	if !bindSCIM(c, &input) {
+		return
+	}
+	group, err := h.scimService.ReplaceGroup(h.baseURL, c.Param("id"), input)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, group)
+}
+
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	var patch models.SCIMPatchRequest
+	if !bindSCIM(c, &patch) {
+		return
+	}
+	group, err := h.scimService.PatchGroup(h.baseURL, c.Param("id"), patch)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	respondSCIM(c, http.StatusOK, group)
+}
+
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if err := h.scimService.DeleteGroup(c.Param("id")); err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSCIMHandler() (*SCIMHandler, *mocks.MockDatabaseOperationService, *mocks.MockRoleService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockRoleService := new(mocks.MockRoleService)
+	return NewSCIMHandler(services.NewSCIMService(mockDBService, mockRoleService, new(mocks.MockUserDataService), nil), "https://auth.example.com"), mockDBService, mockRoleService
+}
+
+func TestSCIMGetUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, mockDBService, mockRoleService := newTestSCIMHandler()
+	createdAt := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC)
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(&models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, CreatedAt: createdAt, UpdatedAt: createdAt}, nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(&models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, UpdatedAt: createdAt.Add(time.Hour)}, nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "https://auth.example.com/scim/v2/Users/1", nil)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.GetUser(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+	var user models.SCIMUser
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, []string{models.SCIMUserSchema}, user.Schemas)
+	assert.Equal(t, mocks.TestUserEmail, user.UserName)
+	assert.Equal(t, "https://auth.example.com/scim/v2/Users/1", user.Meta.Location)
+	assert.Equal(t, createdAt, *user.Meta.Created)
+	assert.Equal(t, createdAt.Add(time.Hour), *user.Meta.LastModified)
+}
+
+func TestSCIMGetUser_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, mockDBService, _ := newTestSCIMHandler()
+	mockDBService.On("FindUserByID", uint(7)).Return(nil, errors.New("record not found"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/scim/v2/Users/7", nil)
+	c.Params = gin.Params{{Key: "id", Value: "7"}}
+
+	handler.GetUser(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var scimError models.SCIMError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &scimError))
+	assert.Equal(t, "404", scimError.Status)
+	assert.Equal(t, []string{models.SCIMErrorSchema}, scimError.Schemas)
+}
+
+func TestSCIMCreateUser_Conflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, mockDBService, _ := newTestSCIMHandler()
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(&models.User{ID: mocks.TestUserId}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/scim/v2/Users", strings.NewReader(`{"schemas":["`+models.SCIMUserSchema+`"],"userName":"`+mocks.TestUserEmail+`"}`))
+	c.Request.Header.Set("Content-Type", "application/scim+json")
+
+	handler.CreateUser(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var scimError models.SCIMError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &scimError))
+	assert.Equal(t, "uniqueness", scimError.SCIMType)
+}
+
+func TestSCIMCreateUser_InvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _ := newTestSCIMHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/scim/v2/Users", strings.NewReader(`{"userName":`))
+
+	handler.CreateUser(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalidSyntax")
+}
+
+func TestSCIMListGroups_InvalidFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _ := newTestSCIMHandler()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, `/scim/v2/Groups?filter=displayName+co+"eng"`, nil)
+
+	handler.ListGroups(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalidFilter")
+}
+
+func TestSCIMDeleteGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, mockRoleService := newTestSCIMHandler()
+	mockRoleService.On("FindRoleByID", uint(4)).Return(&models.Role{ID: 4, RoleName: "engineering"}, nil)
+	mockRoleService.On("DeleteRole", uint(4)).Return(nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/scim/v2/Groups/4", nil)
+	c.Params = gin.Params{{Key: "id", Value: "4"}}
+
+	handler.DeleteGroup(c)
+
+	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+	mockRoleService.AssertExpectations(t)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type TokenHandler struct {
+	introspectionService *services.TokenIntrospectionService
+}
+
+func NewTokenHandler(introspectionService *services.TokenIntrospectionService) *TokenHandler {
+	return &TokenHandler{introspectionService: introspectionService}
+}
+
+func bindIntrospectionRequest(c *gin.Context) (models.IntrospectionRequest, bool) {
+	var input models.IntrospectionRequest
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.OAuthError{Error: services.ErrInvalidRequest.Error(), ErrorDescription: "token is required"})
+		return input, false
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		input.ClientID, input.ClientSecret = clientID, clientSecret
+	}
+	return input, true
+}
+
+func respondInvalidClient(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	c.JSON(http.StatusUnauthorized, models.OAuthError{Error: services.ErrInvalidClient.Error()})
+}
+
+func (h *TokenHandler) Introspect(c *gin.Context) {
+	input, ok := bindIntrospectionRequest(c)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+
+	response, err := h.introspectionService.Introspect(input)
+	if err != nil {
+		respondInvalidClient(c)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *TokenHandler) Revoke(c *gin.Context) {
+	input, ok := bindIntrospectionRequest(c)
+	if !ok {
+		return
+	}
+
+	err := h.introspectionService.Revoke(input)
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case errors.Is(err, services.ErrInvalidClient):
+		respondInvalidClient(c)
+	case errors.Is(err, services.ErrUnauthorizedClient):
+		c.JSON(http.StatusBadRequest, models.OAuthError{Error: err.Error()})
+	default:
+		log.Printf("Failed to revoke token for client %s: %v", input.ClientID, err)
+		c.JSON(http.StatusServiceUnavailable, models.OAuthError{Error: "temporarily_unavailable"})
+	}
+}
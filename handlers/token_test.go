@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestTokenHandler() (*TokenHandler, *mocks.MockTokenStoreService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockDBService.On("FindUserByID", mock.Anything).Return(&models.User{Email: mocks.TestUserEmail, Status: models.UserStatusActive}, nil)
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+	mockClientService.On("AuthenticateClient", mock.Anything, mock.Anything).Return(nil, errors.New("Invalid client"))
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	return NewTokenHandler(services.NewTokenIntrospectionService(mockDBService, mockClientService, mockTokenStore)), mockTokenStore
+}
+
+func newTokenFormRequest(path string, form url.Values, clientSecret string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(mocks.TestClientID, clientSecret)
+	return req
+}
+
+func TestTokenIntrospect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("active access token", func(t *testing.T) {
+		handler, mockTokenStore := newTestTokenHandler()
+		accessToken, _ := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId})
+		mockTokenStore.On("IsAccessTokenRevoked", mock.AnythingOfType("string")).Return(false, nil)
+		mockTokenStore.On("IsAccessTokenEmailRevoked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newTokenFormRequest("/oauth/introspect", url.Values{"token": {accessToken}}, mocks.TestClientSecret)
+
+		handler.Introspect(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"active":true`)
+		assert.Contains(t, w.Body.String(), mocks.TestUserEmail)
+	})
+
+	t.Run("unknown token is inactive", func(t *testing.T) {
+		handler, mockTokenStore := newTestTokenHandler()
+		mockTokenStore.On("FindRefreshToken", mock.AnythingOfType("string")).Return(nil, errors.New("record not found"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newTokenFormRequest("/oauth/introspect", url.Values{"token": {"garbage"}}, mocks.TestClientSecret)
+
+		handler.Introspect(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"active": false}`, w.Body.String())
+	})
+
+	t.Run("invalid client", func(t *testing.T) {
+		handler, _ := newTestTokenHandler()
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newTokenFormRequest("/oauth/introspect", url.Values{"token": {"garbage"}}, "wrong")
+
+		handler.Introspect(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error": "invalid_client"}`, w.Body.String())
+	})
+}
+
+func TestTokenRevoke(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("revokes a refresh token", func(t *testing.T) {
+		handler, mockTokenStore := newTestTokenHandler()
+		tokenHash := utils.HashToken("refresh-token")
+		mockTokenStore.On("FindRefreshToken", tokenHash).Return(&models.RefreshToken{ClientID: mocks.TestClientID}, nil)
+		mockTokenStore.On("RevokeRefreshToken", tokenHash).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newTokenFormRequest("/oauth/revoke", url.Values{"token": {"refresh-token"}, "token_type_hint": {"refresh_token"}}, mocks.TestClientSecret)
+
+		handler.Revoke(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTokenStore.AssertExpectations(t)
+	})
+
+	t.Run("refresh token issued to another client", func(t *testing.T) {
+		handler, mockTokenStore := newTestTokenHandler()
+		mockTokenStore.On("FindRefreshToken", utils.HashToken("refresh-token")).Return(&models.RefreshToken{ClientID: "other-client"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newTokenFormRequest("/oauth/revoke", url.Values{"token": {"refresh-token"}, "token_type_hint": {"refresh_token"}}, mocks.TestClientSecret)
+
+		handler.Revoke(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": "unauthorized_client"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type UserDataHandler struct {
+	userDataService services.IUserDataService
+}
+
+func NewUserDataHandler(userDataService services.IUserDataService) *UserDataHandler {
+	return &UserDataHandler{userDataService: userDataService}
+}
+
+// ExportMyData hands the authenticated user everything stored about them
+func (h *UserDataHandler) ExportMyData(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not issued to a user"})
+		return
+	}
+	h.export(c, userID)
+}
+
+// ExportUserData hands an admin everything stored about the user of the path
+func (h *UserDataHandler) ExportUserData(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	h.export(c, userID)
+}
+
+// EraseUser erases the data of the user of the path for good
+func (h *UserDataHandler) EraseUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	actorID, _ := currentUserID(c)
+
+	if err := h.userDataService.EraseUser(userID, actorID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStatusChange):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserErased):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrProfileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase user"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserDataHandler) export(c *gin.Context, userID uint) {
+	export, err := h.userDataService.ExportUserData(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrProfileNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export user data"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d.json"`, userID))
+	c.JSON(http.StatusOK, export)
+}
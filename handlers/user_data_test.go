@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func userDataRouter(userDataService services.IUserDataService, userID uint) *gin.Engine {
+	handler := NewUserDataHandler(userDataService)
+	return newTestRouter(userID, func(router *gin.Engine) {
+		router.GET("/me/data", handler.ExportMyData)
+		router.GET("/admin/users/:id/data", handler.ExportUserData)
+		router.POST("/admin/users/:id/erase", handler.EraseUser)
+	})
+}
+
+func TestExportUserData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	export := &models.UserDataExport{
+		ExportedAt: time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC),
+		User:       models.UserDataAccount{ID: 7, Email: "jane@example.com", Status: models.UserStatusActive},
+		Roles:      []string{"admin"},
+	}
+
+	t.Run("own data", func(t *testing.T) {
+		mockService := new(mocks.MockUserDataService)
+		mockService.On("ExportUserData", uint(7)).Return(export, nil)
+
+		w := serve(userDataRouter(mockService, 7), http.MethodGet, "/me/data")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `attachment; filename="user-7.json"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		var exported models.UserDataExport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+		assert.Equal(t, *export, exported)
+	})
+
+	t.Run("token not issued to a user", func(t *testing.T) {
+		mockService := new(mocks.MockUserDataService)
+
+		assert.Equal(t, http.StatusUnauthorized, serve(userDataRouter(mockService, 0), http.MethodGet, "/me/data").Code)
+		mockService.AssertNotCalled(t, "ExportUserData", mock.Anything)
+	})
+
+	t.Run("data of another user", func(t *testing.T) {
+		mockService := new(mocks.MockUserDataService)
+		mockService.On("ExportUserData", uint(7)).Return(export, nil)
+		mockService.On("ExportUserData", uint(8)).Return(nil, services.ErrProfileNotFound)
+		mockService.On("ExportUserData", uint(9)).Return(nil, errors.New("connection lost"))
+		router := userDataRouter(mockService, 1)
+
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/admin/users/7/data").Code)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/admin/users/8/data").Code)
+		assert.Equal(t, http.StatusInternalServerError, serve(router, http.MethodGet, "/admin/users/9/data").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/admin/users/jane/data").Code)
+	})
+}
+
+func TestEraseUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("erases the user", func(t *testing.T) {
+		mockService := new(mocks.MockUserDataService)
+		mockService.On("EraseUser", uint(7), uint(1)).Return(nil)
+
+		w := serve(userDataRouter(mockService, 1), http.MethodPost, "/admin/users/7/erase")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			err      error
+			expected int
+		}{
+			{services.ErrInvalidStatusChange, http.StatusBadRequest},
+			{services.ErrUserErased, http.StatusConflict},
+			{services.ErrProfileNotFound, http.StatusNotFound},
+			{errors.New("connection lost"), http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			mockService := new(mocks.MockUserDataService)
+			mockService.On("EraseUser", uint(7), uint(1)).Return(tt.err)
+
+			w := serve(userDataRouter(mockService, 1), http.MethodPost, "/admin/users/7/erase")
+
+			assert.Equal(t, tt.expected, w.Code, tt.err.Error())
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+var userDirectoryCSVHeader = []string{"id", "email", "first_name", "middle_name", "last_name", "preferred_locale", "roles", "status", "created_at",
+	"updated_at", "last_login_at", "last_login_ip"}
+
+type UserDirectoryHandler struct {
+	userDirectoryService services.IUserDirectoryService
+}
+
+func NewUserDirectoryHandler(userDirectoryService services.IUserDirectoryService) *UserDirectoryHandler {
+	return &UserDirectoryHandler{userDirectoryService: userDirectoryService}
+}
+
+// userDirectoryQuery reads the q, role, status, created_after, created_before and sort query parameters.
+// Dates are RFC 3339 timestamps or plain dates. It responds with 400 and returns false when one is invalid.
+func userDirectoryQuery(c *gin.Context) (models.UserDirectoryQuery, bool) {
+	query := models.UserDirectoryQuery{
+		Search: c.Query("q"),
+		Role:   c.Query("role"),
+		Status: c.Query("status"),
+		Sort:   c.Query("sort"),
+	}
+	for parameter, target := range map[string]**time.Time{
+		"created_after":  &query.CreatedAfter,
+		"created_before": &query.CreatedBefore,
+	} {
+		value := c.Query(parameter)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if date, err = time.Parse(time.DateOnly, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + parameter})
+				return query, false
+			}
+		}
+		*target = &date
+	}
+	return query, true
+}
+
+// ListUsers pages through the users matching the query parameters, see userDirectoryQuery. The optional
+// after and limit parameters page like the other admin listings.
+func (h *UserDirectoryHandler) ListUsers(c *gin.Context) {
+	query, ok := userDirectoryQuery(c)
+	if !ok {
+		return
+	}
+	if query.Limit, ok = pageLimit(c); !ok {
+		return
+	}
+	query.After = c.Query("after")
+
+	page, err := h.userDirectoryService.ListUsers(query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUserDirectoryQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to list users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// ExportUsers writes every user matching the query parameters as CSV. Errors after the first row can only
+// be logged, the download then ends early.
+func (h *UserDirectoryHandler) ExportUsers(c *gin.Context) {
+	query, ok := userDirectoryQuery(c)
+	if !ok {
+		return
+	}
+
+	writer := csv.NewWriter(c.Writer)
+	started := false
+	err := h.userDirectoryService.ExportUsers(query, func(user models.UserDirectoryEntry) error {
+		if !started {
+			started = true
+			writeUserDirectoryCSVHeader(c)
+			if err := writer.Write(userDirectoryCSVHeader); err != nil {
+				return err
+			}
+		}
+		return writer.Write(csvSafeRow(
+			strconv.FormatUint(uint64(user.ID), 10),
+			user.Email,
+			user.FirstName,
+			user.MiddleName,
+			user.LastName,
+			user.PreferredLocale,
+			strings.Join(user.Roles, ";"),
+			user.Status,
+			user.CreatedAt.UTC().Format(time.RFC3339),
+			user.UpdatedAt.UTC().Format(time.RFC3339),
+			formatOptionalTime(user.LastLoginAt),
+			user.LastLoginIP,
+		))
+	})
+	if err == nil && !started {
+		// no user matched, the export is just the header
+		started = true
+		writeUserDirectoryCSVHeader(c)
+		err = writer.Write(userDirectoryCSVHeader)
+	}
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if err != nil {
+		if started {
+			log.Printf("Failed to export users: %v", err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidUserDirectoryQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to export users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export users"})
+	}
+}
+
+func writeUserDirectoryCSVHeader(c *gin.Context) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+	c.Status(http.StatusOK)
+}
+
+// csvSafeRow prefixes cells that a spreadsheet would evaluate as a formula with a quote, so that names and
+// emails chosen by users are shown as text when an admin opens the export
+func csvSafeRow(cells ...string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
+
+// formatOptionalTime leaves the CSV cell empty when the time is not set
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
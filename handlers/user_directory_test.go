@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func userDirectoryRouter(userDirectoryService services.IUserDirectoryService) *gin.Engine {
+	handler := NewUserDirectoryHandler(userDirectoryService)
+	return newTestRouter(0, func(router *gin.Engine) {
+		router.GET("/admin/users", handler.ListUsers)
+		router.GET("/admin/users/export", handler.ExportUsers)
+	})
+}
+
+var directoryUser = models.UserDirectoryEntry{
+	ID:        7,
+	Email:     "jane@example.com",
+	FirstName: "Jane",
+	LastName:  "Doe, Jr.",
+	Roles:     []string{"admin", "editor"},
+	Status:    models.UserStatusActive,
+	CreatedAt: time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2026, time.October, 2, 8, 0, 0, 0, time.UTC),
+}
+
+var loggedInDirectoryUser = func() models.UserDirectoryEntry {
+	lastLoginAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	user := directoryUser
+	user.ID, user.Email, user.FirstName, user.LastName, user.Roles = 8, "john@example.com", "John", "Doe", []string{}
+	user.LastLoginAt, user.LastLoginIP, user.LastLoginUserAgent = &lastLoginAt, "203.0.113.7", "test-client/1.0"
+	return user
+}()
+
+func TestListUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("passes the query to the service", func(t *testing.T) {
+		createdAfter := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+		createdBefore := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ListUsers", models.UserDirectoryQuery{
+			Search:        "jane doe",
+			Role:          "admin",
+			Status:        models.UserStatusActive,
+			CreatedAfter:  &createdAfter,
+			CreatedBefore: &createdBefore,
+			Sort:          "-created_at",
+			After:         "cursor",
+			Limit:         10,
+		}).Return(&models.UserDirectoryPage{Users: []models.UserDirectoryEntry{directoryUser, loggedInDirectoryUser}, CursorPage: models.CursorPage{NextCursor: "next"}}, nil)
+
+		w := serve(userDirectoryRouter(mockService), http.MethodGet,
+			"/admin/users?q=jane+doe&role=admin&status=active&created_after=2026-10-01&created_before=2026-10-19T12:00:00Z&sort=-created_at&after=cursor&limit=10")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var page models.UserDirectoryPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, []models.UserDirectoryEntry{directoryUser, loggedInDirectoryUser}, page.Users)
+		assert.Equal(t, "next", page.NextCursor)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		router := userDirectoryRouter(mockService)
+
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/admin/users?created_after=yesterday").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(router, http.MethodGet, "/admin/users?limit=0").Code)
+		mockService.AssertNotCalled(t, "ListUsers", mock.Anything)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ListUsers", mock.Anything).Return(nil, services.ErrInvalidUserDirectoryQuery)
+
+		assert.Equal(t, http.StatusBadRequest, serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users?sort=password").Code)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ListUsers", mock.Anything).Return(nil, errors.New("connection lost"))
+
+		assert.Equal(t, http.StatusInternalServerError, serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users").Code)
+	})
+}
+
+func TestExportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("writes the users as CSV", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ExportUsers", models.UserDirectoryQuery{Role: "admin"}, mock.Anything).
+			Return([]models.UserDirectoryEntry{directoryUser, loggedInDirectoryUser}, nil)
+
+		w := serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users/export?role=admin")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,email,first_name,middle_name,last_name,preferred_locale,roles,status,created_at,updated_at,last_login_at,last_login_ip\n"+
+			`7,jane@example.com,Jane,,"Doe, Jr.",,admin;editor,active,2026-10-01T08:00:00Z,2026-10-02T08:00:00Z,,`+"\n"+
+			`8,john@example.com,John,,Doe,,,active,2026-10-01T08:00:00Z,2026-10-02T08:00:00Z,2026-10-18T09:30:00Z,203.0.113.7`+"\n", w.Body.String())
+	})
+
+	t.Run("escapes formulas", func(t *testing.T) {
+		user := directoryUser
+		user.FirstName = "=HYPERLINK(\"https://evil.example\")"
+		user.MiddleName = "+1"
+		user.LastName = "-2"
+		user.Email = "@SUM(A1)@example.com"
+		user.PreferredLocale = "\tde"
+		user.Roles = []string{"\rviewer"}
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ExportUsers", models.UserDirectoryQuery{}, mock.Anything).Return([]models.UserDirectoryEntry{user}, nil)
+
+		w := serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users/export")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"7", "'@SUM(A1)@example.com", `'=HYPERLINK("https://evil.example")`, "'+1", "'-2", "'\tde", "'\rviewer"}, records[1][:7])
+	})
+
+	t.Run("no matching users", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ExportUsers", models.UserDirectoryQuery{}, mock.Anything).Return(nil, nil)
+
+		w := serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users/export")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "id,email,first_name,middle_name,last_name,preferred_locale,roles,status,created_at,updated_at,last_login_at,last_login_ip\n", w.Body.String())
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		mockService := new(mocks.MockUserDirectoryService)
+		mockService.On("ExportUsers", mock.Anything, mock.Anything).Return(nil, services.ErrInvalidUserDirectoryQuery)
+
+		w := serve(userDirectoryRouter(mockService), http.MethodGet, "/admin/users/export?status=unknown")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	})
+}
@@ -10,8 +10,7 @@ import (
 
 func TestNewUserHandler(t *testing.T) {
 	mockDBService := new(mocks.MockDatabaseOperationService)
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
 	mockLoginService := services.NewUserLoginService(mockDBService)
 	handler := NewUserHandler(*mockRegService, *mockLoginService)
 
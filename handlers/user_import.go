@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+// maxUserImportSize is the largest file accepted, comfortably more than services.MaxUserImportRows rows
+const maxUserImportSize = 5 << 20
+
+var userImportContentTypes = map[string]string{
+	"text/csv":             models.UserImportCSV,
+	"application/x-ndjson": models.UserImportJSONLines,
+	"application/jsonl":    models.UserImportJSONLines,
+}
+
+type UserImportHandler struct {
+	userImportService services.IUserImportService
+}
+
+func NewUserImportHandler(userImportService services.IUserImportService) *UserImportHandler {
+	return &UserImportHandler{userImportService: userImportService}
+}
+
+// ImportUsers registers the users of the file in the request body, or in the file field of a multipart form.
+// The format query parameter is csv or jsonl, without it the format follows the content type or the name of
+// the uploaded file.
+func (h *UserImportHandler) ImportUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUserImportSize)
+	format := c.Query("format")
+	var data io.Reader = c.Request.Body
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if contentType == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			respondWithUserImportReadError(c, err, "The file field is missing")
+			return
+		}
+		defer file.Close()
+		data = file
+		if format == "" {
+			format = models.UserImportFormatOf(header.Filename)
+		}
+	} else if format == "" {
+		format = userImportContentTypes[contentType]
+	}
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set format to csv or jsonl"})
+		return
+	}
+
+	result, err := h.userImportService.ImportUsers(format, data)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, result)
+	case errors.Is(err, services.ErrUserImportRejected):
+		c.JSON(http.StatusUnprocessableEntity, result)
+	case errors.Is(err, services.ErrUserImportIncomplete):
+		c.JSON(http.StatusInternalServerError, result)
+	case errors.Is(err, services.ErrInvalidUserImport):
+		respondWithUserImportReadError(c, err, err.Error())
+	default:
+		log.Printf("Failed to import users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import users"})
+	}
+}
+
+func respondWithUserImportReadError(c *gin.Context, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 5 MB"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": message})
+}
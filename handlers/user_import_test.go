@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const importCSV = "email,first_name,last_name\njane@example.com,Jane,Doe\n"
+
+func userImportRouter(userImportService services.IUserImportService) *gin.Engine {
+	handler := NewUserImportHandler(userImportService)
+	return newTestRouter(0, func(router *gin.Engine) {
+		router.POST("/admin/users/import", handler.ImportUsers)
+	})
+}
+
+func TestImportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("imports the body in the format of its content type", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportCSV, importCSV).
+			Return(&models.UserImportResult{Rows: 1, Created: 1, Errors: []models.UserImportRowError{}}, nil)
+
+		w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import", "text/csv; charset=utf-8", []byte(importCSV))
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"rows":1,"created":1,"errors":[]}`, w.Body.String())
+	})
+
+	t.Run("the format parameter wins", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportJSONLines, "{}\n").Return(&models.UserImportResult{Rows: 1, Created: 1}, nil)
+
+		w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import?format=jsonl", "text/plain", []byte("{}\n"))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("imports an uploaded file in the format of its name", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportJSONLines, "{}\n").Return(&models.UserImportResult{Rows: 1, Created: 1}, nil)
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, err := form.CreateFormFile("file", "users.ndjson")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("{}\n"))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+
+		w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import", form.FormDataContentType(), body.Bytes())
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+
+		w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import", "application/json", []byte("[]"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "ImportUsers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportCSV, mock.Anything).
+			Return(nil, fmt.Errorf("%w: %w", services.ErrInvalidUserImport, &http.MaxBytesError{Limit: maxUserImportSize}))
+
+		w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import", "text/csv", []byte(strings.Repeat("x", 100)))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		result := &models.UserImportResult{Rows: 1, Errors: []models.UserImportRowError{{Line: 2, Email: "jane@example.com", Errors: []string{"email is already in use"}}}}
+		tests := []struct {
+			name     string
+			result   *models.UserImportResult
+			err      error
+			expected int
+		}{
+			{"invalid rows", result, services.ErrUserImportRejected, http.StatusUnprocessableEntity},
+			{"failed batches", result, services.ErrUserImportIncomplete, http.StatusInternalServerError},
+			{"unreadable file", nil, fmt.Errorf("%w: no rows", services.ErrInvalidUserImport), http.StatusBadRequest},
+			{"database error", nil, errors.New("connection lost"), http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mockService := new(mocks.MockUserImportService)
+				mockService.On("ImportUsers", models.UserImportCSV, importCSV).Return(tt.result, tt.err)
+
+				w := serveBody(userImportRouter(mockService), http.MethodPost, "/admin/users/import", "text/csv", []byte(importCSV))
+
+				assert.Equal(t, tt.expected, w.Code)
+				if tt.result != nil {
+					var body models.UserImportResult
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+					assert.Equal(t, *tt.result, body)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type UserStatusHandler struct {
+	userStatusService services.IUserStatusService
+}
+
+func NewUserStatusHandler(userStatusService services.IUserStatusService) *UserStatusHandler {
+	return &UserStatusHandler{userStatusService: userStatusService}
+}
+
+// SuspendUser temporarily blocks the user from logging in
+func (h *UserStatusHandler) SuspendUser(c *gin.Context) {
+	h.changeStatus(c, models.UserStatusSuspended)
+}
+
+// DisableUser blocks the user from logging in until they are reactivated
+func (h *UserStatusHandler) DisableUser(c *gin.Context) {
+	h.changeStatus(c, models.UserStatusDisabled)
+}
+
+// ReactivateUser lets a suspended or disabled user log in again
+func (h *UserStatusHandler) ReactivateUser(c *gin.Context) {
+	h.changeStatus(c, models.UserStatusActive)
+}
+
+// DeleteUser marks the user as deleted for good, the row is kept
+func (h *UserStatusHandler) DeleteUser(c *gin.Context) {
+	h.changeStatus(c, models.UserStatusDeleted)
+}
+
+// userIDParam reads the user ID of the path, answering 400 when it is not one
+func userIDParam(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// changeStatus moves the user of the path to status, the body with a reason is optional
+func (h *UserStatusHandler) changeStatus(c *gin.Context, status string) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var input models.UserStatusRequest
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actorID, _ := currentUserID(c)
+
+	changed, err := h.userStatusService.ChangeStatus(userID, models.UserStatusChange{
+		Status:  status,
+		Reason:  input.Reason,
+		Method:  models.EventMethodAdmin,
+		ActorID: actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStatusChange):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrProfileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change user status"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, changed)
+}
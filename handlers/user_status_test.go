@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// userStatusRouter authenticates every request as the admin with ID 1
+func userStatusRouter(userStatusService services.IUserStatusService) *gin.Engine {
+	handler := NewUserStatusHandler(userStatusService)
+	return newTestRouter(1, func(router *gin.Engine) {
+		router.POST("/admin/users/:id/suspend", handler.SuspendUser)
+		router.POST("/admin/users/:id/disable", handler.DisableUser)
+		router.POST("/admin/users/:id/reactivate", handler.ReactivateUser)
+		router.POST("/admin/users/:id/delete", handler.DeleteUser)
+	})
+}
+
+func TestChangeUserStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("maps each action to a status", func(t *testing.T) {
+		changedAt := time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC)
+		for action, status := range map[string]string{
+			"suspend":    models.UserStatusSuspended,
+			"disable":    models.UserStatusDisabled,
+			"reactivate": models.UserStatusActive,
+			"delete":     models.UserStatusDeleted,
+		} {
+			mockService := new(mocks.MockUserStatusService)
+			mockService.On("ChangeStatus", uint(7), models.UserStatusChange{
+				Status:  status,
+				Reason:  "left the company",
+				Method:  models.EventMethodAdmin,
+				ActorID: 1,
+			}).Return(&models.UserStatus{ID: 7, Email: "jane@example.com", Status: status, Reason: "left the company", ChangedAt: &changedAt}, nil)
+
+			w := postJSON(userStatusRouter(mockService), "/admin/users/7/"+action, `{"reason":"left the company"}`)
+
+			require.Equal(t, http.StatusOK, w.Code, action)
+			var changed models.UserStatus
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changed))
+			assert.Equal(t, status, changed.Status)
+			mockService.AssertExpectations(t)
+		}
+	})
+
+	t.Run("reason is optional", func(t *testing.T) {
+		mockService := new(mocks.MockUserStatusService)
+		mockService.On("ChangeStatus", uint(7), models.UserStatusChange{Status: models.UserStatusSuspended, Method: models.EventMethodAdmin, ActorID: 1}).
+			Return(&models.UserStatus{ID: 7, Status: models.UserStatusSuspended}, nil)
+
+		w := postJSON(userStatusRouter(mockService), "/admin/users/7/suspend", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid user ID", func(t *testing.T) {
+		mockService := new(mocks.MockUserStatusService)
+
+		w := postJSON(userStatusRouter(mockService), "/admin/users/jane/suspend", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "ChangeStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			err      error
+			expected int
+		}{
+			{services.ErrInvalidStatusChange, http.StatusBadRequest},
+			{services.ErrInvalidStatusTransition, http.StatusConflict},
+			{services.ErrProfileNotFound, http.StatusNotFound},
+			{errors.New("connection lost"), http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			mockService := new(mocks.MockUserStatusService)
+			mockService.On("ChangeStatus", uint(7), mock.Anything).Return(nil, tt.err)
+
+			w := postJSON(userStatusRouter(mockService), "/admin/users/7/reactivate", "")
+
+			assert.Equal(t, tt.expected, w.Code, tt.err.Error())
+		}
+	})
+}
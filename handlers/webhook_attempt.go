@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+type WebhookAttemptHandler struct {
+	attemptService services.IWebhookAttemptService
+}
+
+func NewWebhookAttemptHandler(attemptService services.IWebhookAttemptService) *WebhookAttemptHandler {
+	return &WebhookAttemptHandler{attemptService: attemptService}
+}
+
+// ListAttempts pages through the webhook delivery attempts, oldest first. The optional query parameters
+// are after, the next_cursor of the previous page, limit, endpoint and event_id.
+func (h *WebhookAttemptHandler) ListAttempts(c *gin.Context) {
+	afterID, limit, ok := cursorPage(c)
+	if !ok {
+		return
+	}
+
+	attempts, err := h.attemptService.ListAttempts(afterID, limit, c.Query("endpoint"), c.Query("event_id"))
+	if err != nil {
+		log.Printf("Failed to list webhook attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook attempts"})
+		return
+	}
+	response := models.WebhookDeliveryAttemptList{Attempts: attempts}
+	if len(attempts) == limit {
+		response.NextCursor = nextCursor(attempts[len(attempts)-1].ID)
+	}
+	c.JSON(http.StatusOK, response)
+}
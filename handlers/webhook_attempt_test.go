@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveWebhookAttempts(attemptService *mocks.MockWebhookAttemptService, target string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/attempts", NewWebhookAttemptHandler(attemptService).ListAttempts)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestListWebhookAttempts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("filters by endpoint and event", func(t *testing.T) {
+		mockService := new(mocks.MockWebhookAttemptService)
+		mockService.On("ListAttempts", uint64(3), 1, "partner", "event-1").
+			Return([]models.WebhookDeliveryAttempt{{ID: 4, Endpoint: "partner", EventID: "event-1", Attempt: 1}}, nil)
+
+		w := serveWebhookAttempts(mockService, "/attempts?after=3&limit=1&endpoint=partner&event_id=event-1")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response models.WebhookDeliveryAttemptList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Attempts, 1)
+		assert.Equal(t, "4", response.NextCursor)
+	})
+
+	t.Run("rejects an invalid cursor", func(t *testing.T) {
+		mockService := new(mocks.MockWebhookAttemptService)
+
+		w := serveWebhookAttempts(mockService, "/attempts?after=-1")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "ListAttempts")
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		mockService := new(mocks.MockWebhookAttemptService)
+		mockService.On("ListAttempts", uint64(0), defaultCursorPageSize, "", "").Return(nil, errors.New("connection refused"))
+
+		w := serveWebhookAttempts(mockService, "/attempts")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
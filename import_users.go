@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+)
+
+const importUsersCommand = "import-users"
+
+// runUserImport implements `import-users [-format csv|jsonl] FILE`, which imports the users of the file like
+// POST /admin/users/import and writes the result to stdout as JSON. FILE - reads standard input. It returns
+// the exit code, 1 when any row was not imported and 2 for invalid arguments.
+func runUserImport(importService services.IUserImportService, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(importUsersCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "csv or jsonl, defaults to the extension of FILE")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s [-format csv|jsonl] FILE\n", importUsersCommand)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = models.UserImportFormatOf(path)
+	}
+	if *format == "" {
+		fmt.Fprintf(stderr, "Cannot tell the format of %s, set -format to csv or jsonl\n", path)
+		return 2
+	}
+	var data io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		defer file.Close()
+		data = file
+	}
+
+	result, err := importService.ImportUsers(*format, data)
+	if result != nil {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(result); encodeErr != nil {
+			fmt.Fprintln(stderr, encodeErr)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, services.ErrInvalidUserImport) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunUserImport(t *testing.T) {
+	const data = "email,first_name,last_name\njane@example.com,Jane,Doe\n"
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	t.Run("prints the result", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportCSV, data).
+			Return(&models.UserImportResult{Rows: 1, Created: 1, Errors: []models.UserImportRowError{}}, nil)
+		var stdout, stderr bytes.Buffer
+
+		assert.Equal(t, 0, runUserImport(mockService, []string{path}, &stdout, &stderr))
+		assert.JSONEq(t, `{"rows":1,"created":1,"errors":[]}`, stdout.String())
+	})
+
+	t.Run("rejected rows", func(t *testing.T) {
+		mockService := new(mocks.MockUserImportService)
+		mockService.On("ImportUsers", models.UserImportJSONLines, data).
+			Return(&models.UserImportResult{Rows: 1, Errors: []models.UserImportRowError{{Line: 1, Errors: []string{"email is already in use"}}}}, services.ErrUserImportRejected)
+		var stdout, stderr bytes.Buffer
+
+		assert.Equal(t, 1, runUserImport(mockService, []string{"-format", "jsonl", path}, &stdout, &stderr))
+		assert.Contains(t, stdout.String(), "email is already in use")
+		assert.Contains(t, stderr.String(), services.ErrUserImportRejected.Error())
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"users.xlsx"}, {filepath.Join(t.TempDir(), "missing.csv")}, {"-size", "1", path}} {
+			mockService := new(mocks.MockUserImportService)
+			var stdout, stderr bytes.Buffer
+
+			assert.Equal(t, 2, runUserImport(mockService, args, &stdout, &stderr), args)
+			assert.Empty(t, stdout.String())
+			mockService.AssertNotCalled(t, "ImportUsers")
+		}
+	})
+}
@@ -1,46 +1,510 @@
 package initializer
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/config"
 	"github.com/shibbirmcc/user-auth-and-permissions/handlers"
 	"github.com/shibbirmcc/user-auth-and-permissions/middlewares"
 	"github.com/shibbirmcc/user-auth-and-permissions/migrations"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
 	"github.com/shibbirmcc/user-auth-and-permissions/routes"
 	"github.com/shibbirmcc/user-auth-and-permissions/services"
 	"gorm.io/gorm"
+	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+var (
+	eventPublisher     services.IEventPublisher
+	eventPublisherOnce sync.Once
+	webhookService     *services.WebhookService
+	webhookServiceErr  error
+	webhookServiceOnce sync.Once
+	// passwordDelivery is the password delivery service of the outbox relay
+	passwordDelivery     services.PasswordDeliveryService
+	passwordDeliveryErr  error
+	passwordDeliveryOnce sync.Once
+)
+
+// webhookAttemptPurgeInterval is how often webhook delivery attempts past WEBHOOK_ATTEMPT_RETENTION are purged
+const webhookAttemptPurgeInterval = time.Hour
+
+// outboxDeadLetterPurgeInterval is how often outbox dead letters past OUTBOX_DEAD_LETTER_RETENTION are purged
+const outboxDeadLetterPurgeInterval = time.Hour
+
+// defaultPasswordDeliveryRetentionInterval is how often expired password deliveries are purged when
+// PASSWORD_DELIVERY_RETENTION_INTERVAL is not set
+const defaultPasswordDeliveryRetentionInterval = time.Hour
+
+// InitializeEventPublisher returns the publisher for domain events, shared by every service. Events go to
+// KAFKA_EVENTS_TOPIC and the endpoints in WEBHOOK_ENDPOINTS. Without either it returns nil and no events
+// are published.
+func InitializeEventPublisher(db *gorm.DB) services.IEventPublisher {
+	eventPublisherOnce.Do(func() {
+		var publishers services.MultiEventPublisher
+		if os.Getenv("KAFKA_EVENTS_TOPIC") == "" {
+			log.Println("KAFKA_EVENTS_TOPIC is not set, domain events are not published to Kafka")
+		} else if publisher, err := services.NewKafkaEventPublisher(); err != nil {
+			log.Printf("Domain events are not published to Kafka: %v", err)
+		} else {
+			publishers = append(publishers, publisher)
+		}
+		if os.Getenv("WEBHOOK_ENDPOINTS") != "" {
+			if publisher, err := InitializeWebhookService(db); err != nil {
+				log.Printf("Domain events are not published to webhooks: %v", err)
+			} else {
+				publishers = append(publishers, publisher)
+			}
+		}
+
+		switch len(publishers) {
+		case 0:
+		case 1:
+			eventPublisher = publishers[0]
+		default:
+			eventPublisher = publishers
+		}
+	})
+	return eventPublisher
+}
+
+// CloseEventPublisher waits for the domain events that are still being delivered, until the context is done.
+// Events published afterwards are dropped.
+func CloseEventPublisher(ctx context.Context) error {
+	if eventPublisher == nil {
+		return nil
+	}
+	return services.CloseEventPublisher(ctx, eventPublisher)
+}
+
+// InitializeWebhookService returns the webhook service shared by the password delivery and the domain
+// events. It also starts purging old delivery attempts.
+func InitializeWebhookService(db *gorm.DB) (*services.WebhookService, error) {
+	webhookServiceOnce.Do(func() {
+		attemptService := newWebhookAttemptService(db)
+		webhookService, webhookServiceErr = services.NewWebhookService(attemptService)
+		if webhookServiceErr == nil {
+			attemptService.StartRetention(context.Background(), webhookAttemptPurgeInterval)
+		}
+	})
+	return webhookService, webhookServiceErr
+}
+
+func newWebhookAttemptService(db *gorm.DB) *services.WebhookAttemptService {
+	retention, err := services.WebhookAttemptRetentionFromEnv()
+	if err != nil {
+		log.Printf("Invalid webhook attempt retention, using the default: %v", err)
+	}
+	return services.NewWebhookAttemptService(db, retention)
+}
+
 func InitializeServices(db *gorm.DB) (*services.UserRegistrationService, *services.UserLoginService) {
 	databaseOperationService := services.NewDatabaseOperationService(db)
-	passwordDeliveryService, _ := InitializePasswordDeliveryService()
-	userRegistrationService := services.NewUserRegistrationService(passwordDeliveryService, databaseOperationService)
-	userLoginService := services.NewUserLoginService(databaseOperationService)
+	InitializeOutboxRelay(db)
+	onboarding, err := config.GetOnboardingConfig()
+	if err != nil {
+		log.Printf("Invalid onboarding configuration, sending temporary passwords: %v", err)
+		onboarding = models.OnboardingConfig{Mode: models.OnboardingPassword}
+	}
+	userRegistrationService := services.NewUserRegistrationServiceWithOnboarding(databaseOperationService, onboarding, InitializeEventPublisher(db))
+	userLoginService := services.NewUserLoginServiceWithEvents(databaseOperationService, InitializeEventPublisher(db), InitializeAuthenticators(db)...)
 	return userRegistrationService, userLoginService
 }
 
+// InitializeOutboxRelay starts publishing registration outbox messages to the configured password
+// delivery service. Without one the messages stay in the outbox and are retried later. Messages are
+// rendered from the notification templates first, see services.LoadEmailTemplatesFromEnv. It also starts
+// purging old dead letters.
+func InitializeOutboxRelay(db *gorm.DB) *services.OutboxRelay {
+	var passwordDeliveryService services.PasswordDeliveryService
+	if deliveryService, err := sharedPasswordDeliveryService(db); err != nil {
+		log.Printf("Password delivery is not available: %v", err)
+	} else {
+		passwordDeliveryService = deliveryService
+	}
+
+	relayConfig, err := services.OutboxRelayConfigFromEnv()
+	if err != nil {
+		log.Printf("Invalid outbox relay configuration, using defaults: %v", err)
+		relayConfig = services.OutboxRelayConfig{}
+	}
+	templates, err := services.LoadEmailTemplatesFromEnv()
+	if err != nil {
+		log.Printf("Notification templates are not available, deliveries carry no rendered message: %v", err)
+	}
+	outboxService := services.NewOutboxService(db)
+	relay := services.NewOutboxRelayWithTemplates(outboxService, passwordDeliveryService, relayConfig, templates)
+	relay.Start(context.Background())
+	retention, err := services.OutboxDeadLetterRetentionFromEnv()
+	if err != nil {
+		log.Printf("Invalid outbox dead letter retention, using the default: %v", err)
+	}
+	outboxService.StartRetention(context.Background(), outboxDeadLetterPurgeInterval, retention)
+	return relay
+}
+
+// sharedPasswordDeliveryService returns the password delivery service of the outbox relay, what the service
+// can send is checked against the same one
+func sharedPasswordDeliveryService(db *gorm.DB) (services.PasswordDeliveryService, error) {
+	passwordDeliveryOnce.Do(func() {
+		passwordDelivery, passwordDeliveryErr = InitializePasswordDeliveryService(db)
+	})
+	return passwordDelivery, passwordDeliveryErr
+}
+
+// CheckOnboardingDelivery fails when ONBOARDING_MODE is setup_link but PASSWORD_DELIVERY_TYPE cannot send
+// setup links, the users would be created without ever receiving one. A delivery service that could not be
+// created is reported by the outbox relay instead, its messages wait in the outbox.
+func CheckOnboardingDelivery(db *gorm.DB) error {
+	onboarding, err := config.GetOnboardingConfig()
+	if err != nil {
+		// InitializeServices falls back to temporary passwords
+		return nil
+	}
+	deliveryService, err := sharedPasswordDeliveryService(db)
+	if err != nil {
+		return nil
+	}
+	return checkOnboardingDelivery(onboarding, deliveryService)
+}
+
+// canDeliver reports whether the outbox relay can send every one of the message types. When the delivery
+// service could not be created the messages wait in the outbox until it is fixed, so they count as sendable.
+func canDeliver(db *gorm.DB, messageTypes ...string) bool {
+	deliveryService, err := sharedPasswordDeliveryService(db)
+	if err != nil {
+		return true
+	}
+	for _, messageType := range messageTypes {
+		if !services.CanSendOutboxMessage(deliveryService, messageType) {
+			log.Printf("The %s password delivery cannot send %s messages", os.Getenv("PASSWORD_DELIVERY_TYPE"), messageType)
+			return false
+		}
+	}
+	return true
+}
+
+func checkOnboardingDelivery(onboarding models.OnboardingConfig, deliveryService services.PasswordDeliveryService) error {
+	if onboarding.Mode == models.OnboardingSetupLink && !services.CanSendOutboxMessage(deliveryService, models.OutboxPasswordSetupLink) {
+		return fmt.Errorf("the %s password delivery cannot send setup links, set ONBOARDING_MODE to password or use another PASSWORD_DELIVERY_TYPE",
+			os.Getenv("PASSWORD_DELIVERY_TYPE"))
+	}
+	return nil
+}
+
+// InitializeAuthenticators builds the login backends listed in AUTH_BACKENDS. Backends that are unknown
+// or misconfigured are skipped, and the local database is used when none is left.
+func InitializeAuthenticators(db *gorm.DB) []services.Authenticator {
+	databaseOperationService := services.NewDatabaseOperationService(db)
+	var authenticators []services.Authenticator
+	for _, backend := range config.GetAuthBackends() {
+		switch backend {
+		case config.AuthBackendDatabase:
+			authenticators = append(authenticators, services.NewDatabaseAuthenticator(databaseOperationService))
+		case config.AuthBackendLDAP:
+			ldapConfig, err := config.GetLDAPConfig()
+			if err != nil {
+				log.Printf("Skipping LDAP authentication: %v", err)
+				continue
+			}
+			authenticators = append(authenticators, services.NewLDAPAuthenticator(ldapConfig, databaseOperationService, services.NewRoleService(db, InitializeEventPublisher(db)), InitializeEventPublisher(db)))
+		default:
+			log.Printf("Skipping unknown authentication backend: %s", backend)
+		}
+	}
+	return authenticators
+}
+
 func InitializeHandlers(regService *services.UserRegistrationService, loginService *services.UserLoginService) *handlers.UserHandler {
 	return handlers.NewUserHandler(*regService, *loginService)
 }
 
+func InitializeOIDCHandler(db *gorm.DB, loginService *services.UserLoginService, issuer string) *handlers.OIDCHandler {
+	databaseOperationService := services.NewDatabaseOperationService(db)
+	oauthClientService := services.NewOAuthClientService(db)
+	SeedOAuthClients(oauthClientService)
+	tokenStoreService := services.NewTokenStoreService(db)
+	oidcService := services.NewOIDCService(loginService, databaseOperationService, oauthClientService, tokenStoreService)
+	return handlers.NewOIDCHandler(oidcService, issuer)
+}
+
+func InitializeTokenHandler(db *gorm.DB) *handlers.TokenHandler {
+	return handlers.NewTokenHandler(newTokenIntrospectionService(db))
+}
+
+// InitializeFederatedHandler sets up login through the upstream identity providers configured in FEDERATED_PROVIDERS
+func InitializeFederatedHandler(db *gorm.DB, issuer string) *handlers.FederatedHandler {
+	var connectors []*services.OIDCConnector
+	for _, provider := range config.GetFederatedProviders() {
+		connectors = append(connectors, services.NewOIDCConnector(provider))
+	}
+	federatedLoginService := services.NewFederatedLoginService(
+		connectors,
+		services.NewDatabaseOperationService(db),
+		services.NewFederatedIdentityService(db),
+		InitializeEventPublisher(db),
+	)
+	return handlers.NewFederatedHandler(federatedLoginService, issuer)
+}
+
+func InitializePasswordSetupHandler(db *gorm.DB) *handlers.PasswordSetupHandler {
+	return handlers.NewPasswordSetupHandler(services.NewPasswordSetupService(db, InitializeEventPublisher(db)))
+}
+
+func InitializeSCIMHandler(db *gorm.DB, issuer string) *handlers.SCIMHandler {
+	events := InitializeEventPublisher(db)
+	scimService := services.NewSCIMService(services.NewDatabaseOperationService(db), services.NewRoleService(db, events), services.NewUserDataService(db, events), events)
+	return handlers.NewSCIMHandler(scimService, issuer)
+}
+
+func InitializeProfileHandler(db *gorm.DB) *handlers.ProfileHandler {
+	events := InitializeEventPublisher(db)
+	profileService := services.NewProfileService(services.NewDatabaseOperationService(db), services.NewRoleService(db, events), events)
+	return handlers.NewProfileHandler(profileService)
+}
+
+// InitializeEmailChangeHandler reads the confirmation link settings, see config.GetEmailChangeConfig.
+// Invalid settings send the bare token instead of a link.
+func InitializeEmailChangeHandler(db *gorm.DB) *handlers.EmailChangeHandler {
+	emailChange, err := config.GetEmailChangeConfig()
+	if err != nil {
+		log.Printf("Invalid email change configuration, sending confirmation tokens without a link: %v", err)
+		emailChange = models.EmailChangeConfig{TokenTTL: emailChange.TokenTTL}
+	}
+	emailChange.Unavailable = !canDeliver(db, models.OutboxEmailChangeConfirmation, models.OutboxEmailChangeNotice)
+	return handlers.NewEmailChangeHandler(services.NewEmailChangeService(db, emailChange, InitializeEventPublisher(db)))
+}
+
+// InitializeInvitationHandler reads the invitation link settings, see config.GetInvitationConfig. Invalid
+// settings send the bare token instead of a link.
+func InitializeInvitationHandler(db *gorm.DB) *handlers.InvitationHandler {
+	invitation, err := config.GetInvitationConfig()
+	if err != nil {
+		log.Printf("Invalid invitation configuration, sending invitation tokens without a link: %v", err)
+		invitation = models.InvitationConfig{TokenTTL: invitation.TokenTTL}
+	}
+	invitation.Unavailable = !canDeliver(db, models.OutboxInvitation)
+	return handlers.NewInvitationHandler(services.NewInvitationService(db, invitation, InitializeEventPublisher(db)))
+}
+
+// InitializeDeadLetterHandler issues the credentials of replayed dead letters with the same settings as the
+// handlers that issued them first, including their fallbacks for invalid settings
+func InitializeDeadLetterHandler(db *gorm.DB) *handlers.DeadLetterHandler {
+	var reissue models.CredentialReissueConfig
+	var err error
+	if reissue.Onboarding, err = config.GetOnboardingConfig(); err != nil {
+		log.Printf("Invalid onboarding configuration for replayed setup links: %v", err)
+	}
+	if reissue.EmailChange, err = config.GetEmailChangeConfig(); err != nil {
+		reissue.EmailChange = models.EmailChangeConfig{TokenTTL: reissue.EmailChange.TokenTTL}
+	}
+	if reissue.Invitation, err = config.GetInvitationConfig(); err != nil {
+		reissue.Invitation = models.InvitationConfig{TokenTTL: reissue.Invitation.TokenTTL}
+	}
+	return handlers.NewDeadLetterHandler(services.NewOutboxServiceWithReissue(db, reissue))
+}
+
+func InitializeUserDirectoryHandler(db *gorm.DB) *handlers.UserDirectoryHandler {
+	return handlers.NewUserDirectoryHandler(services.NewUserDirectoryService(db))
+}
+
+func InitializeUserStatusHandler(db *gorm.DB) *handlers.UserStatusHandler {
+	userStatusService := services.NewUserStatusService(services.NewDatabaseOperationService(db), InitializeEventPublisher(db))
+	return handlers.NewUserStatusHandler(userStatusService)
+}
+
+func InitializeUserDataHandler(db *gorm.DB) *handlers.UserDataHandler {
+	return handlers.NewUserDataHandler(services.NewUserDataService(db, InitializeEventPublisher(db)))
+}
+
+// InitializeUserImportService sends the imported users their credentials like InitializeServices does for
+// registrations, through the outbox
+func InitializeUserImportService(db *gorm.DB) *services.UserImportService {
+	onboarding, err := config.GetOnboardingConfig()
+	if err != nil {
+		log.Printf("Invalid onboarding configuration, sending temporary passwords: %v", err)
+		onboarding = models.OnboardingConfig{Mode: models.OnboardingPassword}
+	}
+	return services.NewUserImportService(db, onboarding, InitializeEventPublisher(db))
+}
+
+func InitializeUserImportHandler(db *gorm.DB) *handlers.UserImportHandler {
+	return handlers.NewUserImportHandler(InitializeUserImportService(db))
+}
+
+func InitializeWebhookAttemptHandler(db *gorm.DB) *handlers.WebhookAttemptHandler {
+	return handlers.NewWebhookAttemptHandler(newWebhookAttemptService(db))
+}
+
+// InitializeRoleService returns a role service that publishes membership changes as domain events
+func InitializeRoleService(db *gorm.DB) *services.RoleService {
+	return services.NewRoleService(db, InitializeEventPublisher(db))
+}
+
+// InitializeAdminMiddleware only lets users through whose roles have the admin permission. It runs after
+// the auth middleware.
+func InitializeAdminMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return middlewares.RequirePermission(InitializeRoleService(db), models.PermissionAdmin)
+}
+
+// InitializeSCIMAuthMiddleware accepts the comma separated bearer tokens in SCIM_BEARER_TOKENS
+func InitializeSCIMAuthMiddleware() gin.HandlerFunc {
+	var tokens []string
+	for _, token := range strings.Split(os.Getenv("SCIM_BEARER_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		log.Println("SCIM_BEARER_TOKENS is not set, SCIM provisioning is disabled")
+	}
+	return middlewares.SCIMAuthMiddleware(tokens)
+}
+
+// InitializeAuthMiddleware returns the bearer token middleware, which also rejects revoked access tokens,
+// those carrying an email address their user has changed since and those of users who are not active
+func InitializeAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	introspectionService := newTokenIntrospectionService(db)
+	return middlewares.TokenAuthMiddleware(
+		middlewares.RevokedTokenValidator(introspectionService),
+		middlewares.RevokedEmailValidator(introspectionService),
+		middlewares.ActiveUserValidator(introspectionService),
+	)
+}
+
+func newTokenIntrospectionService(db *gorm.DB) *services.TokenIntrospectionService {
+	return services.NewTokenIntrospectionService(
+		services.NewDatabaseOperationService(db),
+		services.NewOAuthClientService(db),
+		services.NewTokenStoreService(db),
+	)
+}
+
+// SeedOAuthClients registers the clients listed in OAUTH_CLIENTS as comma separated client_id:client_secret pairs
+func SeedOAuthClients(clientService services.IOAuthClientService) {
+	for _, entry := range strings.Split(os.Getenv("OAUTH_CLIENTS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		clientID, clientSecret, found := strings.Cut(entry, ":")
+		if !found || clientID == "" || clientSecret == "" {
+			log.Printf("Skipping malformed OAUTH_CLIENTS entry for client %q", clientID)
+			continue
+		}
+		if err := clientService.SaveClient(clientID, clientSecret, clientID); err != nil {
+			log.Printf("Failed to register OAuth client %s: %v", clientID, err)
+		}
+	}
+}
+
 func ApplyMigrations(db *gorm.DB, migrationDirectory string) {
 	migrations.RunMigrations(db, migrationDirectory)
 }
 
-func SetupRouter(userHandler *handlers.UserHandler) *gin.Engine {
+func SetupRouter(userHandler *handlers.UserHandler, idempotencyMiddleware gin.HandlerFunc) *gin.Engine {
 	router := gin.Default()
-	router.Use(middlewares.CORSMiddleware())            // Add CORS middleware
-	routes.ConfigureRouteEndpoints(router, userHandler) // Set up route handlers
+	configureTrustedProxies(router)
+	router.Use(middlewares.CORSMiddleware())                                   // Add CORS middleware
+	routes.ConfigureRouteEndpoints(router, userHandler, idempotencyMiddleware) // Set up route handlers
 	return router
 }
 
-func InitializePasswordDeliveryService() (services.PasswordDeliveryService, error) {
+// configureTrustedProxies makes the client IP, which is recorded as the IP of a login, come from
+// X-Forwarded-For only when the request was forwarded by one of the TRUSTED_PROXIES, a comma separated list of
+// IPs and CIDRs. Without it the IP is the address of the connection.
+func configureTrustedProxies(router *gin.Engine) {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Printf("Invalid TRUSTED_PROXIES, using the address of the connection as client IP: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+}
+
+// InitializeIdempotencyMiddleware remembers the responses to Idempotency-Keys for IDEMPOTENCY_KEY_TTL, 24h by
+// default
+func InitializeIdempotencyMiddleware(db *gorm.DB) gin.HandlerFunc {
+	ttl := 24 * time.Hour
+	if configured := os.Getenv("IDEMPOTENCY_KEY_TTL"); configured != "" {
+		if parsed, err := time.ParseDuration(configured); err != nil || parsed <= 0 {
+			log.Printf("Invalid IDEMPOTENCY_KEY_TTL %s, keeping idempotency keys for %s", configured, ttl)
+		} else {
+			ttl = parsed
+		}
+	}
+	return middlewares.Idempotency(services.NewIdempotencyService(db, ttl))
+}
+
+func InitializePasswordDeliveryService(db *gorm.DB) (services.PasswordDeliveryService, error) {
 	deliveryType := os.Getenv("PASSWORD_DELIVERY_TYPE")
 	switch services.PasswordDeliveryType(deliveryType) {
 	case services.KAFKA_TOPIC:
 		return services.NewKafkaPasswordDeliveryService()
+	case services.POSTGRESQL:
+		return initializePostgresPasswordDeliveryService(db)
+	case services.REDIS:
+		return initializeRedisPasswordDeliveryService()
+	case services.SMTP:
+		return services.NewSMTPPasswordDeliveryService()
+	case services.WEBHOOK:
+		deliveryService, err := InitializeWebhookService(db)
+		if err != nil {
+			return nil, err
+		}
+		return deliveryService, nil
 	default:
 		return nil, fmt.Errorf("unsupported password delivery type: %s", deliveryType)
 	}
 }
+
+func passwordDeliveryRetentionInterval() (time.Duration, error) {
+	configured := os.Getenv("PASSWORD_DELIVERY_RETENTION_INTERVAL")
+	if configured == "" {
+		return defaultPasswordDeliveryRetentionInterval, nil
+	}
+	interval, err := time.ParseDuration(configured)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid PASSWORD_DELIVERY_RETENTION_INTERVAL: %s", configured)
+	}
+	return interval, nil
+}
+
+// initializePostgresPasswordDeliveryService also starts purging deliveries that are past their
+// retention, every PASSWORD_DELIVERY_RETENTION_INTERVAL
+func initializePostgresPasswordDeliveryService(db *gorm.DB) (services.PasswordDeliveryService, error) {
+	deliveryService, err := services.NewPostgresPasswordDeliveryService(db)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := passwordDeliveryRetentionInterval()
+	if err != nil {
+		return nil, err
+	}
+	deliveryService.StartRetention(context.Background(), interval)
+	return deliveryService, nil
+}
+
+// initializeRedisPasswordDeliveryService also trims entries past REDIS_STREAM_TTL from the stream,
+// every PASSWORD_DELIVERY_RETENTION_INTERVAL
+func initializeRedisPasswordDeliveryService() (services.PasswordDeliveryService, error) {
+	interval, err := passwordDeliveryRetentionInterval()
+	if err != nil {
+		return nil, err
+	}
+	deliveryService, err := services.NewRedisPasswordDeliveryService()
+	if err != nil {
+		return nil, err
+	}
+	deliveryService.StartRetention(context.Background(), interval)
+	return deliveryService, nil
+}
@@ -6,7 +6,11 @@ import (
 	"os"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
 	"github.com/shibbirmcc/user-auth-and-permissions/tests"
 	"github.com/stretchr/testify/assert"
 )
@@ -56,7 +60,7 @@ func TestSetupRouter(t *testing.T) {
 	userHandler := InitializeHandlers(regService, loginService)
 
 	// Test SetupRouter function
-	router := SetupRouter(userHandler)
+	router := SetupRouter(userHandler, InitializeIdempotencyMiddleware(db))
 	assert.NotNil(t, router, "Router should not be nil")
 
 	// Test that the router has the expected routes
@@ -64,6 +68,26 @@ func TestSetupRouter(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestConfigureTrustedProxies(t *testing.T) {
+	clientIP := func(trustedProxies string) string {
+		t.Setenv("TRUSTED_PROXIES", trustedProxies)
+		router := gin.New()
+		configureTrustedProxies(router)
+		router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "10.0.0.2:41000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "10.0.0.2", clientIP(""))
+	assert.Equal(t, "203.0.113.7", clientIP("192.0.2.1, 10.0.0.0/8"))
+	assert.Equal(t, "10.0.0.2", clientIP("192.0.2.1"))
+	assert.Equal(t, "10.0.0.2", clientIP("not-an-ip"))
+}
+
 // Helper function to perform requests in the router
 func performRequest(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
 	req := httptest.NewRequest(method, path, nil)
@@ -76,7 +100,7 @@ func TestInitializePasswordDeliveryService_KafkaTopicSuccess(t *testing.T) {
 	tearDownKafkaContainer := tests.SetupKafkaContainer()
 	defer tearDownKafkaContainer()
 
-	service, err := InitializePasswordDeliveryService()
+	service, err := InitializePasswordDeliveryService(nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, service)
 }
@@ -84,7 +108,7 @@ func TestInitializePasswordDeliveryService_KafkaTopicSuccess(t *testing.T) {
 func TestInitializePasswordDeliveryService_InvalidType(t *testing.T) {
 	os.Setenv("PASSWORD_DELIVERY_TYPE", "UNSUPPORTED_TYPE")
 	defer os.Unsetenv("PASSWORD_DELIVERY_TYPE")
-	service, err := InitializePasswordDeliveryService()
+	service, err := InitializePasswordDeliveryService(nil)
 	assert.Error(t, err)
 	assert.Nil(t, service)
 	assert.EqualError(t, err, "unsupported password delivery type: UNSUPPORTED_TYPE")
@@ -95,9 +119,98 @@ func TestInitializePasswordDeliveryService_EmptyDeliveryType(t *testing.T) {
 	os.Setenv("PASSWORD_DELIVERY_TYPE", "")
 	defer os.Unsetenv("PASSWORD_DELIVERY_TYPE")
 
-	service, err := InitializePasswordDeliveryService()
+	service, err := InitializePasswordDeliveryService(nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, service)
 	assert.EqualError(t, err, "unsupported password delivery type: ")
 }
+
+func TestSeedOAuthClients(t *testing.T) {
+	os.Setenv("OAUTH_CLIENTS", "wiki:wiki-secret, dashboard:dash-secret,malformed")
+	defer os.Unsetenv("OAUTH_CLIENTS")
+
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockClientService.On("SaveClient", "wiki", "wiki-secret", "wiki").Return(nil)
+	mockClientService.On("SaveClient", "dashboard", "dash-secret", "dashboard").Return(nil)
+
+	SeedOAuthClients(mockClientService)
+
+	mockClientService.AssertExpectations(t)
+	mockClientService.AssertNumberOfCalls(t, "SaveClient", 2)
+}
+
+func TestInitializeAuthenticators(t *testing.T) {
+	t.Setenv("AUTH_BACKENDS", "ldap,database,kerberos")
+	t.Setenv("LDAP_URL", "ldap://dc.corp.example")
+	t.Setenv("LDAP_BASE_DN", "DC=corp,DC=example")
+
+	authenticators := InitializeAuthenticators(nil)
+
+	assert.Len(t, authenticators, 2)
+	assert.IsType(t, &services.LDAPAuthenticator{}, authenticators[0])
+	assert.IsType(t, &services.DatabaseAuthenticator{}, authenticators[1])
+}
+
+func TestInitializePasswordDeliveryService_PostgresWithoutDatabase(t *testing.T) {
+	t.Setenv("PASSWORD_DELIVERY_TYPE", "POSTGRESQL")
+
+	service, err := InitializePasswordDeliveryService(nil)
+
+	assert.Nil(t, service)
+	assert.EqualError(t, err, "database connection is required for postgres password delivery")
+}
+
+func TestInitializePasswordDeliveryService_Redis(t *testing.T) {
+	server := miniredis.RunT(t)
+	t.Setenv("PASSWORD_DELIVERY_TYPE", "REDIS")
+	t.Setenv("REDIS_ADDR", server.Addr())
+	t.Setenv("REDIS_STREAM", "credentials")
+
+	service, err := InitializePasswordDeliveryService(nil)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &services.RedisPasswordDeliveryService{}, service)
+}
+
+func TestInitializePasswordDeliveryService_SMTP(t *testing.T) {
+	t.Setenv("PASSWORD_DELIVERY_TYPE", "SMTP")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "accounts@example.com")
+
+	service, err := InitializePasswordDeliveryService(nil)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &services.SMTPPasswordDeliveryService{}, service)
+}
+
+func TestCheckOnboardingDelivery(t *testing.T) {
+	t.Setenv("PASSWORD_DELIVERY_TYPE", "REDIS")
+	setupLink := models.OnboardingConfig{Mode: models.OnboardingSetupLink}
+
+	assert.EqualError(t, checkOnboardingDelivery(setupLink, &services.RedisPasswordDeliveryService{}),
+		"the REDIS password delivery cannot send setup links, set ONBOARDING_MODE to password or use another PASSWORD_DELIVERY_TYPE")
+	assert.Error(t, checkOnboardingDelivery(setupLink, &services.PostgresPasswordDeliveryService{}))
+	assert.NoError(t, checkOnboardingDelivery(setupLink, &services.SMTPPasswordDeliveryService{}))
+	assert.NoError(t, checkOnboardingDelivery(setupLink, &services.KafkaPasswordDeliveryService{}))
+	assert.NoError(t, checkOnboardingDelivery(models.OnboardingConfig{Mode: models.OnboardingPassword}, &services.RedisPasswordDeliveryService{}))
+}
+
+func TestInitializeEventPublisher_Disabled(t *testing.T) {
+	t.Setenv("KAFKA_EVENTS_TOPIC", "")
+	t.Setenv("WEBHOOK_ENDPOINTS", "")
+	assert.Nil(t, InitializeEventPublisher(nil))
+}
+
+func TestInitializePasswordDeliveryService_Webhook(t *testing.T) {
+	t.Setenv("PASSWORD_DELIVERY_TYPE", "WEBHOOK")
+	t.Setenv("WEBHOOK_ENDPOINTS", "partner")
+	t.Setenv("WEBHOOK_PARTNER_URL", "https://partner.example/hooks")
+	t.Setenv("WEBHOOK_PARTNER_SECRET", "secret")
+	t.Setenv("WEBHOOK_PARTNER_EVENTS", "user.credentials_issued")
+
+	service, err := InitializePasswordDeliveryService(nil)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &services.WebhookService{}, service)
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	// Aliasing to avoid conflict
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/shibbirmcc/user-auth-and-permissions/config"
 	"github.com/shibbirmcc/user-auth-and-permissions/initializer"
+	"github.com/shibbirmcc/user-auth-and-permissions/routes"
+	"github.com/shibbirmcc/user-auth-and-permissions/services"
 	// Aliasing to avoid conflict
 )
 
+// shutdownTimeout is how long requests in flight and queued domain events are waited for on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	absEnvPath, err := filepath.Abs(".env")
 	if err != nil {
@@ -23,10 +34,54 @@ func main() {
 		os.Exit(1)
 	}
 	initializer.ApplyMigrations(db, "migrations")
+	if len(os.Args) > 1 && os.Args[1] == grantAdminCommand {
+		exitAfterEvents(runGrantAdmin(services.NewDatabaseOperationService(db), initializer.InitializeRoleService(db), os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if err := initializer.CheckOnboardingDelivery(db); err != nil {
+		log.Fatalf("Invalid onboarding configuration: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == importUsersCommand {
+		// the users are sent their credentials by the outbox relay of the running service
+		exitAfterEvents(runUserImport(initializer.InitializeUserImportService(db), os.Args[2:], os.Stdout, os.Stderr))
+	}
 
+	issuer, err := config.GetOIDCIssuer()
+	if err != nil {
+		log.Fatalf("Invalid OpenID Connect configuration: %v", err)
+	}
 	userRegService, userLoginService := initializer.InitializeServices(db)
 	userHandler := initializer.InitializeHandlers(userRegService, userLoginService)
-	router := initializer.SetupRouter(userHandler)
+	oidcHandler := initializer.InitializeOIDCHandler(db, userLoginService, issuer)
+	tokenHandler := initializer.InitializeTokenHandler(db)
+	federatedHandler := initializer.InitializeFederatedHandler(db, issuer)
+	scimHandler := initializer.InitializeSCIMHandler(db, issuer)
+	passwordSetupHandler := initializer.InitializePasswordSetupHandler(db)
+	profileHandler := initializer.InitializeProfileHandler(db)
+	emailChangeHandler := initializer.InitializeEmailChangeHandler(db)
+	deadLetterHandler := initializer.InitializeDeadLetterHandler(db)
+	webhookAttemptHandler := initializer.InitializeWebhookAttemptHandler(db)
+	userDirectoryHandler := initializer.InitializeUserDirectoryHandler(db)
+	userStatusHandler := initializer.InitializeUserStatusHandler(db)
+	userDataHandler := initializer.InitializeUserDataHandler(db)
+	userImportHandler := initializer.InitializeUserImportHandler(db)
+	invitationHandler := initializer.InitializeInvitationHandler(db)
+	authMiddleware := initializer.InitializeAuthMiddleware(db)
+	adminMiddleware := initializer.InitializeAdminMiddleware(db)
+	router := initializer.SetupRouter(userHandler, initializer.InitializeIdempotencyMiddleware(db))
+	routes.ConfigureOIDCEndpoints(router, oidcHandler, authMiddleware)
+	routes.ConfigureTokenEndpoints(router, tokenHandler)
+	routes.ConfigureFederatedEndpoints(router, federatedHandler)
+	routes.ConfigureSCIMEndpoints(router, scimHandler, initializer.InitializeSCIMAuthMiddleware())
+	routes.ConfigurePasswordSetupEndpoints(router, passwordSetupHandler)
+	routes.ConfigureProfileEndpoints(router, profileHandler, authMiddleware)
+	routes.ConfigureEmailChangeEndpoints(router, emailChangeHandler, authMiddleware)
+	routes.ConfigureDeadLetterEndpoints(router, deadLetterHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureWebhookEndpoints(router, webhookAttemptHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureUserDirectoryEndpoints(router, userDirectoryHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureUserStatusEndpoints(router, userStatusHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureUserDataEndpoints(router, userDataHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureUserImportEndpoints(router, userImportHandler, authMiddleware, adminMiddleware)
+	routes.ConfigureInvitationEndpoints(router, invitationHandler, authMiddleware, adminMiddleware)
 
 	// Start the server
 	port := os.Getenv("PORT")
@@ -34,7 +89,34 @@ func main() {
 		port = "8080"
 	}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start the server: %v", err)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start the server: %v", err)
+		}
+	}()
+	<-ctx.Done()
+
+	// requests in flight are finished first, they can still publish events
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down the server: %v", err)
+	}
+	if err := initializer.CloseEventPublisher(shutdownCtx); err != nil {
+		log.Printf("Domain events were lost on shutdown: %v", err)
+	}
+}
+
+// exitAfterEvents waits until the domain events published by a command were delivered, then exits with its
+// exit code
+func exitAfterEvents(code int) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := initializer.CloseEventPublisher(ctx); err != nil {
+		log.Printf("Domain events were lost: %v", err)
 	}
+	cancel()
+	os.Exit(code)
 }
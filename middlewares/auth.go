@@ -1,15 +1,90 @@
 package middlewares
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
 )
 
-func TokenAuthMiddleware() gin.HandlerFunc {
+// TokenValidator performs additional checks on a token whose signature and expiry have already been verified
+type TokenValidator func(claims jwt.MapClaims) error
+
+type RevocationChecker interface {
+	IsAccessTokenRevoked(jti string) (bool, error)
+}
+
+// RevokedTokenValidator rejects access tokens whose jti has been revoked
+func RevokedTokenValidator(checker RevocationChecker) TokenValidator {
+	return func(claims jwt.MapClaims) error {
+		jti, _ := claims["jti"].(string)
+		revoked, err := checker.IsAccessTokenRevoked(jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return errors.New("token has been revoked")
+		}
+		return nil
+	}
+}
+
+type EmailRevocationChecker interface {
+	IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error)
+}
+
+// RevokedEmailValidator rejects access tokens carrying an email address their user has changed since the
+// token was issued
+func RevokedEmailValidator(checker EmailRevocationChecker) TokenValidator {
+	return func(claims jwt.MapClaims) error {
+		userID, _ := claims["user_id"].(float64)
+		email, _ := claims["email"].(string)
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil || userID <= 0 {
+			return nil
+		}
+		revoked, err := checker.IsAccessTokenEmailRevoked(uint(userID), email, issuedAt.Time)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return errors.New("token has been revoked")
+		}
+		return nil
+	}
+}
+
+type UserStatusChecker interface {
+	IsUserActive(userID uint) (bool, error)
+}
+
+// ActiveUserValidator rejects access tokens of users who are no longer active, tokens that are not issued
+// to a user pass
+func ActiveUserValidator(checker UserStatusChecker) TokenValidator {
+	return func(claims jwt.MapClaims) error {
+		userID, _ := claims["user_id"].(float64)
+		if userID <= 0 {
+			return nil
+		}
+		active, err := checker.IsUserActive(uint(userID))
+		if err != nil {
+			return err
+		}
+		if !active {
+			return errors.New("user is not active")
+		}
+		return nil
+	}
+}
+
+// TokenAuthMiddleware accepts access tokens signed with JWT_SECRET, other tokens signed with it such as the
+// federated login state lack the access token audience and are rejected
+func TokenAuthMiddleware(validators ...TokenValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := getJwtTokenFromHeader(c)
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
@@ -17,7 +92,7 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		}, jwt.WithAudience(models.AccessTokenAudience))
 
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -25,7 +100,16 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			for _, validate := range validators {
+				if err := validate(claims); err != nil {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+					return
+				}
+			}
 			c.Set("email", claims["email"])
+			if userID, ok := claims["user_id"].(float64); ok && userID > 0 {
+				c.Set("user_id", uint(userID))
+			}
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,6 +62,35 @@ func TestTokenAuthMiddleware(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Invalid token")
 	})
 
+	t.Run("Invalid Token - Federated Login State", func(t *testing.T) {
+		state, err := utils.GenerateFederatedState("corp", "nonce")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+state)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Invalid Token - No Audience", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"email": "test@example.com",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
 	t.Run("Missing Authorization Header", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/protected", nil)
 		w := httptest.NewRecorder()
@@ -70,11 +102,148 @@ func TestTokenAuthMiddleware(t *testing.T) {
 	})
 }
 
+type stubRevocationChecker struct {
+	revoked map[string]bool
+}
+
+func (s stubRevocationChecker) IsAccessTokenRevoked(jti string) (bool, error) {
+	return s.revoked[jti], nil
+}
+
+func TestTokenAuthMiddleware_RevokedToken(t *testing.T) {
+	checker := stubRevocationChecker{revoked: map[string]bool{"revoked-jti": true}}
+	router := gin.Default()
+	router.Use(TokenAuthMiddleware(RevokedTokenValidator(checker)))
+	router.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	t.Run("Revoked Token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+generateTokenWithID(t, "revoked-jti"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid token")
+	})
+
+	t.Run("Active Token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+generateTokenWithID(t, "active-jti"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+type stubEmailRevocationChecker struct {
+	revokedAt map[string]time.Time
+}
+
+func (s stubEmailRevocationChecker) IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error) {
+	revokedAt, ok := s.revokedAt[fmt.Sprintf("%d:%s", userID, email)]
+	return ok && !revokedAt.Before(issuedAt), nil
+}
+
+func TestTokenAuthMiddleware_RevokedEmail(t *testing.T) {
+	checker := stubEmailRevocationChecker{revokedAt: map[string]time.Time{"7:old@example.com": time.Now()}}
+	router := gin.Default()
+	router.Use(TokenAuthMiddleware(RevokedEmailValidator(checker)))
+	router.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	tests := []struct {
+		name     string
+		email    string
+		issuedAt time.Time
+		expected int
+	}{
+		{"token with the changed email", "old@example.com", time.Now().Add(-time.Minute), http.StatusUnauthorized},
+		{"token with the current email", "new@example.com", time.Now().Add(-time.Minute), http.StatusOK},
+		{"token issued after the change", "old@example.com", time.Now().Add(time.Minute), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"user_id": 7,
+				"email":   tt.email,
+				"iat":     tt.issuedAt.Unix(),
+				"exp":     time.Now().Add(time.Hour).Unix(),
+				"aud":     models.AccessTokenAudience,
+			})
+			tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+			if err != nil {
+				t.Fatalf("Could not generate token: %v", err)
+			}
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+type stubUserStatusChecker map[uint]bool
+
+func (s stubUserStatusChecker) IsUserActive(userID uint) (bool, error) {
+	return s[userID], nil
+}
+
+func TestTokenAuthMiddleware_InactiveUser(t *testing.T) {
+	router := gin.Default()
+	router.Use(TokenAuthMiddleware(ActiveUserValidator(stubUserStatusChecker{7: true, 8: false})))
+	router.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		expected int
+	}{
+		{"active user", jwt.MapClaims{"user_id": 7}, http.StatusOK},
+		{"suspended user", jwt.MapClaims{"user_id": 8}, http.StatusUnauthorized},
+		{"token not issued to a user", jwt.MapClaims{"email": "test@example.com"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims["exp"] = time.Now().Add(time.Hour).Unix()
+			tt.claims["aud"] = models.AccessTokenAudience
+			tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+			if err != nil {
+				t.Fatalf("Could not generate token: %v", err)
+			}
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func generateTokenWithID(t *testing.T, jti string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"email": "test@example.com",
+		"jti":   jti,
+		"exp":   time.Now().Add(time.Hour * 1).Unix(),
+		"aud":   models.AccessTokenAudience,
+	})
+	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatalf("Could not generate token: %v", err)
+	}
+	return tokenString
+}
+
 // Helper function to generate a valid token
 func generateValidToken(t *testing.T) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"email": "test@example.com",
 		"exp":   time.Now().Add(time.Hour * 1).Unix(),
+		"aud":   models.AccessTokenAudience,
 	})
 	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	if err != nil {
@@ -88,6 +257,7 @@ func generateInvalidTokenWithWrongMethod(t *testing.T) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"email": "test@example.com",
 		"exp":   time.Now().Add(time.Hour * 1).Unix(),
+		"aud":   models.AccessTokenAudience,
 	})
 
 	// Manually set an incorrect signing method in the header
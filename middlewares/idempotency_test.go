@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	const body = `{"email":"jane@example.com"}`
+	sum := sha256.Sum256([]byte(body))
+	requestHash := hex.EncodeToString(sum[:])
+
+	newRouter := func(store IdempotencyStore, status int) (*gin.Engine, *int) {
+		calls := 0
+		router := gin.New()
+		router.POST("/auth/register", Idempotency(store), func(c *gin.Context) {
+			calls++
+			c.JSON(status, gin.H{"calls": calls})
+		})
+		return router, &calls
+	}
+	post := func(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/auth/register", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("stores the response to a new key", func(t *testing.T) {
+		store := new(mocks.MockIdempotencyService)
+		store.On("Reserve", "POST /auth/register", "key-1", requestHash).Return(nil, nil)
+		store.On("Complete", "POST /auth/register", "key-1", http.StatusOK, "application/json; charset=utf-8", `{"calls":1}`).Return(nil)
+		router, calls := newRouter(store, http.StatusOK)
+
+		w := post(router, "key-1", body)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, *calls)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("replays the stored response", func(t *testing.T) {
+		store := new(mocks.MockIdempotencyService)
+		store.On("Reserve", "POST /auth/register", "key-1", requestHash).Return(&models.IdempotencyKey{
+			RequestHash: requestHash, StatusCode: http.StatusConflict, ContentType: "application/json", Body: []byte(`{"calls":1}`),
+		}, nil)
+		router, calls := newRouter(store, http.StatusOK)
+
+		w := post(router, "key-1", body)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.Zero(t, *calls)
+	})
+
+	t.Run("releases the key of a failed request", func(t *testing.T) {
+		store := new(mocks.MockIdempotencyService)
+		store.On("Reserve", "POST /auth/register", "key-1", requestHash).Return(nil, nil)
+		store.On("Release", "POST /auth/register", "key-1").Return(nil)
+		router, _ := newRouter(store, http.StatusInternalServerError)
+
+		assert.Equal(t, http.StatusInternalServerError, post(router, "key-1", body).Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			key      string
+			stored   *models.IdempotencyKey
+			err      error
+			expected int
+		}{
+			{"a key reused for another request", "key-1", &models.IdempotencyKey{RequestHash: "other", StatusCode: http.StatusOK}, nil, http.StatusUnprocessableEntity},
+			{"a retry while the request runs", "key-1", &models.IdempotencyKey{RequestHash: requestHash}, nil, http.StatusConflict},
+			{"a store failure", "key-1", nil, errors.New("database down"), http.StatusInternalServerError},
+			{"a long key", strings.Repeat("k", 256), nil, nil, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				store := new(mocks.MockIdempotencyService)
+				store.On("Reserve", "POST /auth/register", tt.key, requestHash).Return(tt.stored, tt.err)
+				router, calls := newRouter(store, http.StatusOK)
+
+				assert.Equal(t, tt.expected, post(router, tt.key, body).Code)
+				assert.Zero(t, *calls)
+			})
+		}
+	})
+
+	t.Run("requests without a key run as usual", func(t *testing.T) {
+		store := new(mocks.MockIdempotencyService)
+		router, calls := newRouter(store, http.StatusOK)
+
+		assert.Equal(t, http.StatusOK, post(router, "", body).Code)
+		assert.Equal(t, 1, *calls)
+		store.AssertNotCalled(t, "Reserve")
+	})
+}
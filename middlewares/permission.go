@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PermissionChecker interface {
+	HasPermission(userID uint, permissionName string) (bool, error)
+}
+
+// RequirePermission only lets users through who have the permission through one of their roles. It runs
+// after TokenAuthMiddleware, which sets the user ID from the token.
+func RequirePermission(checker PermissionChecker, permissionName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("user_id")
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+		allowed, err := checker.HasPermission(userID, permissionName)
+		if err != nil {
+			log.Printf("Failed to check permission %s of user %d: %v", permissionName, userID, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePermission(t *testing.T) {
+	roleService := new(mocks.MockRoleService)
+	roleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	roleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+	roleService.On("HasPermission", uint(3), models.PermissionAdmin).Return(false, errors.New("database down"))
+
+	router := gin.New()
+	router.GET("/admin", TokenAuthMiddleware(), RequirePermission(roleService, models.PermissionAdmin), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+	request := func(userID uint) *httptest.ResponseRecorder {
+		token, err := utils.GenerateJWT("admin@example.com", models.UserDetail{UserID: userID})
+		require.NoError(t, err)
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request(1).Code)
+	assert.Equal(t, http.StatusForbidden, request(2).Code)
+	assert.Equal(t, http.StatusInternalServerError, request(3).Code)
+	// tokens without a user ID are never allowed
+	assert.Equal(t, http.StatusForbidden, request(0).Code)
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+// SCIMAuthMiddleware accepts requests carrying one of the configured SCIM bearer tokens. Every request
+// is rejected when no token is configured.
+func SCIMAuthMiddleware(tokens []string) gin.HandlerFunc {
+	digests := make([][32]byte, 0, len(tokens))
+	for _, token := range tokens {
+		if token != "" {
+			digests = append(digests, sha256.Sum256([]byte(token)))
+		}
+	}
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			// Comparing digests keeps the comparison constant time regardless of token length
+			presented := sha256.Sum256([]byte(strings.TrimSpace(token)))
+			for _, digest := range digests {
+				if subtle.ConstantTimeCompare(presented[:], digest[:]) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+		c.Header("Content-Type", "application/scim+json")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.SCIMError{
+			Schemas: []string{models.SCIMErrorSchema},
+			Status:  "401",
+			Detail:  "Invalid or missing bearer token",
+		})
+	}
+}
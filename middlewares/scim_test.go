@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSCIMAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/scim/v2/Users", SCIMAuthMiddleware([]string{"first-token", "second-token"}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for name, testCase := range map[string]struct {
+		header string
+		status int
+	}{
+		"configured token":  {"Bearer second-token", http.StatusOK},
+		"lower case scheme": {"bearer first-token", http.StatusOK},
+		"wrong token":       {"Bearer other-token", http.StatusUnauthorized},
+		"missing header":    {"", http.StatusUnauthorized},
+		"basic credentials": {"Basic Zmlyc3QtdG9rZW4=", http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+			if testCase.header != "" {
+				req.Header.Set("Authorization", testCase.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, testCase.status, w.Code)
+		})
+	}
+}
+
+func TestSCIMAuthMiddleware_NoTokenConfigured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/scim/v2/Users", SCIMAuthMiddleware(nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "urn:ietf:params:scim:api:messages:2.0:Error")
+}
@@ -0,0 +1,5 @@
+CREATE TABLE oauth_clients (
+    client_id VARCHAR(255) PRIMARY KEY,
+    client_secret VARCHAR(255) NOT NULL,
+    client_name VARCHAR(255)
+);
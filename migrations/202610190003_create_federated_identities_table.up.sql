@@ -0,0 +1,7 @@
+CREATE TABLE federated_identities (
+    provider VARCHAR(100) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    PRIMARY KEY (provider, subject)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE roles ADD CONSTRAINT roles_role_name_key UNIQUE (role_name);
+
+CREATE TABLE user_roles (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
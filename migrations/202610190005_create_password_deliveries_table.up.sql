@@ -0,0 +1,13 @@
+CREATE TABLE password_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    claimed_by VARCHAR(255),
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    claim_expires_at TIMESTAMP WITH TIME ZONE,
+    acknowledged_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_password_deliveries_pending ON password_deliveries (id) WHERE acknowledged_at IS NULL;
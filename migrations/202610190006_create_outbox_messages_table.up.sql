@@ -0,0 +1,12 @@
+CREATE TABLE outbox_messages (
+    id BIGSERIAL PRIMARY KEY,
+    message_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    failed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_messages_due ON outbox_messages (next_attempt_at) WHERE failed_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE outbox_messages
+    ADD COLUMN event_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN user_id INT;
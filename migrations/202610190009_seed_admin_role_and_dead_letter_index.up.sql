@@ -0,0 +1,12 @@
+INSERT INTO permissions (permission_name)
+SELECT 'admin' WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE permission_name = 'admin');
+
+INSERT INTO roles (role_name)
+SELECT 'admin' WHERE NOT EXISTS (SELECT 1 FROM roles WHERE role_name = 'admin');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.role_name = 'admin' AND permissions.permission_name = 'admin'
+ON CONFLICT DO NOTHING;
+
+CREATE INDEX idx_outbox_messages_failed ON outbox_messages (id) WHERE failed_at IS NOT NULL;
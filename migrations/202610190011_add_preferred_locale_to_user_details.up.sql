@@ -0,0 +1,2 @@
+ALTER TABLE user_details
+    ADD COLUMN preferred_locale VARCHAR(35);
@@ -0,0 +1,4 @@
+-- existing users get the time of the migration, their real registration time is unknown
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_users_created_at ON users (created_at, id);
@@ -0,0 +1,15 @@
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason VARCHAR(500),
+    ADD COLUMN status_changed_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT users_status_check
+        CHECK (status IN ('active', 'pending_verification', 'suspended', 'disabled', 'deleted'));
+
+-- users who never used their password setup link have not verified their address yet
+UPDATE users SET status = 'pending_verification'
+WHERE EXISTS (
+    SELECT 1 FROM password_setup_tokens
+    WHERE password_setup_tokens.user_id = users.id AND password_setup_tokens.used_at IS NULL
+);
+
+CREATE INDEX idx_users_status ON users (status);
@@ -0,0 +1,2 @@
+-- erased users keep an anonymized row, so their ID is never reused and the erasure can be proven
+ALTER TABLE users ADD COLUMN erased_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN last_login_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN last_login_ip VARCHAR(45),
+    ADD COLUMN last_login_user_agent VARCHAR(512);
+
+ALTER TABLE user_details
+    ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+-- existing rows have not changed since the user was created, as far as anybody knows
+UPDATE users SET updated_at = created_at;
+UPDATE user_details SET created_at = users.created_at, updated_at = users.created_at
+FROM users WHERE users.id = user_details.user_id;
@@ -0,0 +1,15 @@
+-- email addresses are unique ignoring case. Users whose addresses only differ in case have to be merged or
+-- renamed first, the migration fails and lists them.
+DO $$
+DECLARE
+	duplicates TEXT;
+BEGIN
+	SELECT string_agg(emails, '; ') INTO duplicates FROM (
+		SELECT string_agg(email, ', ' ORDER BY id) AS emails FROM users GROUP BY LOWER(email) HAVING COUNT(*) > 1
+	) AS duplicated;
+	IF duplicates IS NOT NULL THEN
+		RAISE EXCEPTION 'users with email addresses that only differ in case: %', duplicates;
+	END IF;
+END $$;
+
+CREATE UNIQUE INDEX idx_users_lower_email ON users (LOWER(email));
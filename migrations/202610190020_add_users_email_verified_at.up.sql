@@ -0,0 +1,2 @@
+-- set once the user proved control of the address by redeeming a token sent to it, existing users have not
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
@@ -43,6 +43,76 @@ func TestRunMigrations(t *testing.T) {
 	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'role_permissions');").Scan(&exists)
 	assert.NoError(t, err)
 	assert.True(t, exists, "Expected table 'role_permissions' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'oauth_clients');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'oauth_clients' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'refresh_tokens');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'refresh_tokens' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'revoked_tokens');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'revoked_tokens' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'federated_identities');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'federated_identities' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'user_roles');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'user_roles' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'password_deliveries');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'password_deliveries' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'outbox_messages');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'outbox_messages' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'password_setup_tokens');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'password_setup_tokens' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.columns WHERE table_name = 'outbox_messages' AND column_name = 'event_id');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected column 'outbox_messages.event_id' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM role_permissions JOIN roles ON roles.id = role_permissions.role_id JOIN permissions ON permissions.id = role_permissions.permission_id WHERE roles.role_name = 'admin' AND permissions.permission_name = 'admin');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected role 'admin' to have permission 'admin' after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = 'webhook_delivery_attempts');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected table 'webhook_delivery_attempts' to exist after migration")
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.columns WHERE table_name = 'user_details' AND column_name = 'preferred_locale');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected column 'preferred_locale' in 'user_details' after migration")
+
+	for _, table := range []string{"email_change_tokens", "revoked_token_emails", "idempotency_keys", "invitations", "invitation_roles"} {
+		err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_name = $1);", table).Scan(&exists)
+		assert.NoError(t, err)
+		assert.True(t, exists, "Expected table '%s' to exist after migration", table)
+	}
+
+	err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'created_at');").Scan(&exists)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected column 'created_at' in 'users' after migration")
+
+	for _, column := range []string{"status", "status_reason", "status_changed_at", "erased_at", "updated_at", "last_login_at", "last_login_ip", "last_login_user_agent"} {
+		err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.columns WHERE table_name = 'users' AND column_name = $1);", column).Scan(&exists)
+		assert.NoError(t, err)
+		assert.True(t, exists, "Expected column '%s' in 'users' after migration", column)
+	}
+
+	for _, column := range []string{"created_at", "updated_at"} {
+		err = sqlDB.QueryRow("SELECT EXISTS (SELECT FROM information_schema.columns WHERE table_name = 'user_details' AND column_name = $1);", column).Scan(&exists)
+		assert.NoError(t, err)
+		assert.True(t, exists, "Expected column '%s' in 'user_details' after migration", column)
+	}
 }
 
 func TestRunMigrations_DBConnectionFailure(t *testing.T) {
@@ -7,4 +7,6 @@ const (
 	TestUserId           uint   = 1
 	TestUserFirstName    string = "Test"
 	TestUserLastName     string = "User"
+	TestClientID         string = "test-client"
+	TestClientSecret     string = "test-client-secret"
 )
@@ -14,6 +14,16 @@ func (m *MockDatabaseOperationService) CreateUser(user *models.User, userDetail
 	return args.Error(0)
 }
 
+func (m *MockDatabaseOperationService) CreateUserWithOutbox(user *models.User, userDetail *models.UserDetail, message *models.OutboxMessage) error {
+	args := m.Called(user, userDetail, message)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseOperationService) CreateUserWithPasswordSetup(user *models.User, userDetail *models.UserDetail, setupToken *models.PasswordSetupToken, message *models.OutboxMessage) error {
+	args := m.Called(user, userDetail, setupToken, message)
+	return args.Error(0)
+}
+
 func (m *MockDatabaseOperationService) FindUserByEmail(email string) (*models.User, error) {
 	args := m.Called(email)
 	if args.Get(0) != nil {
@@ -22,6 +32,14 @@ func (m *MockDatabaseOperationService) FindUserByEmail(email string) (*models.Us
 	return nil, args.Error(1)
 }
 
+func (m *MockDatabaseOperationService) FindUserByID(userID uint) (*models.User, error) {
+	args := m.Called(userID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockDatabaseOperationService) FindUserDetailsByUserID(userID uint) (*models.UserDetail, error) {
 	args := m.Called(userID)
 	if args.Get(0) != nil {
@@ -29,3 +47,36 @@ func (m *MockDatabaseOperationService) FindUserDetailsByUserID(userID uint) (*mo
 	}
 	return nil, args.Error(1)
 }
+
+func (m *MockDatabaseOperationService) ListUsers(offset, limit int) ([]models.User, int64, error) {
+	args := m.Called(offset, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.User), args.Get(1).(int64), args.Error(2)
+	}
+	return nil, 0, args.Error(2)
+}
+
+func (m *MockDatabaseOperationService) UpdateUser(user *models.User, userDetail *models.UserDetail) error {
+	args := m.Called(user, userDetail)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseOperationService) UpdateUserDetails(userDetail *models.UserDetail) error {
+	args := m.Called(userDetail)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseOperationService) UpdateUserStatus(userID uint, fromStatus, toStatus, reason string) error {
+	args := m.Called(userID, fromStatus, toStatus, reason)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseOperationService) RecordLogin(userID uint, login models.LoginMetadata) error {
+	args := m.Called(userID, login)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseOperationService) DeleteUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEmailChangeService struct {
+	mock.Mock
+}
+
+func (m *MockEmailChangeService) RequestEmailChange(userID uint, input models.EmailChangeRequest) error {
+	args := m.Called(userID, input)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeService) ConfirmEmailChange(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFederatedIdentityService struct {
+	mock.Mock
+}
+
+func (m *MockFederatedIdentityService) FindFederatedIdentity(provider, subject string) (*models.FederatedIdentity, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.FederatedIdentity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockFederatedIdentityService) LinkFederatedIdentity(identity *models.FederatedIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
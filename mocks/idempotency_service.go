@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdempotencyService struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyService) Reserve(scope, key, requestHash string) (*models.IdempotencyKey, error) {
+	args := m.Called(scope, key, requestHash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.IdempotencyKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdempotencyService) Complete(scope, key string, statusCode int, contentType string, body []byte) error {
+	args := m.Called(scope, key, statusCode, contentType, string(body))
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyService) Release(scope, key string) error {
+	args := m.Called(scope, key)
+	return args.Error(0)
+}
@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInvitationService struct {
+	mock.Mock
+}
+
+func (m *MockInvitationService) CreateInvitation(inviterID uint, input models.InvitationRequest) (*models.InvitationSummary, error) {
+	args := m.Called(inviterID, input)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.InvitationSummary), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInvitationService) ListInvitations(afterID uint64, limit int, status string) ([]models.InvitationSummary, error) {
+	args := m.Called(afterID, limit, status)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.InvitationSummary), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInvitationService) RevokeInvitation(invitationID uint) error {
+	args := m.Called(invitationID)
+	return args.Error(0)
+}
+
+func (m *MockInvitationService) AcceptInvitation(input models.InvitationAcceptRequest) (*models.User, error) {
+	args := m.Called(input)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
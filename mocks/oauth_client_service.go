@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOAuthClientService struct {
+	mock.Mock
+}
+
+func (m *MockOAuthClientService) SaveClient(clientID, clientSecret, clientName string) error {
+	args := m.Called(clientID, clientSecret, clientName)
+	return args.Error(0)
+}
+
+func (m *MockOAuthClientService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	args := m.Called(clientID, clientSecret)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.OAuthClient), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxService struct {
+	mock.Mock
+}
+
+func (m *MockOutboxService) ClaimDue(limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	args := m.Called(limit, lease)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.OutboxMessage), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOutboxService) MarkPublished(id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxService) MarkRetry(id uint64, lastError string, nextAttemptAt time.Time) error {
+	args := m.Called(id, lastError, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxService) MarkFailed(id uint64, lastError string) error {
+	args := m.Called(id, lastError)
+	return args.Error(0)
+}
+
+func (m *MockOutboxService) ListDeadLetters(afterID uint64, limit int, messageType string) ([]models.DeadLetter, error) {
+	args := m.Called(afterID, limit, messageType)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.DeadLetter), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOutboxService) ReplayDeadLetter(id uint64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxService) ReplayDeadLetters(messageType string) (int64, error) {
+	args := m.Called(messageType)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -15,3 +15,31 @@ func (m *MockPasswordDeliveryService) SendPassword(credentials models.UserCreden
 	}
 	return nil
 }
+
+func (m *MockPasswordDeliveryService) SendSetupLink(link models.PasswordSetupLink) error {
+	if m.ShouldFail {
+		return errors.New("mock error: failed to send setup link")
+	}
+	return nil
+}
+
+func (m *MockPasswordDeliveryService) SendEmailChangeConfirmation(confirmation models.EmailChangeConfirmation) error {
+	if m.ShouldFail {
+		return errors.New("mock error: failed to send email change confirmation")
+	}
+	return nil
+}
+
+func (m *MockPasswordDeliveryService) SendEmailChangeNotice(notice models.EmailChangeNotice) error {
+	if m.ShouldFail {
+		return errors.New("mock error: failed to send email change notice")
+	}
+	return nil
+}
+
+func (m *MockPasswordDeliveryService) SendInvitation(invitation models.UserInvitation) error {
+	if m.ShouldFail {
+		return errors.New("mock error: failed to send invitation")
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package mocks
+
+import "github.com/stretchr/testify/mock"
+
+type MockPasswordSetupService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordSetupService) CompletePasswordSetup(token, password string) error {
+	args := m.Called(token, password)
+	return args.Error(0)
+}
@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockProfileService struct {
+	mock.Mock
+}
+
+func (m *MockProfileService) GetProfile(userID uint) (*models.UserProfile, error) {
+	args := m.Called(userID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserProfile), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockProfileService) UpdateProfile(userID uint, input models.UpdateProfileRequest) (*models.UserProfile, error) {
+	args := m.Called(userID, input)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserProfile), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,90 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRoleService struct {
+	mock.Mock
+}
+
+func (m *MockRoleService) ListRoles(offset, limit int) ([]models.Role, int64, error) {
+	args := m.Called(offset, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Role), args.Get(1).(int64), args.Error(2)
+	}
+	return nil, 0, args.Error(2)
+}
+
+func (m *MockRoleService) FindRoleByID(roleID uint) (*models.Role, error) {
+	args := m.Called(roleID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Role), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleService) FindRoleByName(roleName string) (*models.Role, error) {
+	args := m.Called(roleName)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Role), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleService) CreateRole(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) RenameRole(roleID uint, roleName string) error {
+	args := m.Called(roleID, roleName)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) DeleteRole(roleID uint) error {
+	args := m.Called(roleID)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) FindRoleMembers(roleID uint) ([]models.User, error) {
+	args := m.Called(roleID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleService) AddRoleMembers(roleID uint, userIDs []uint) error {
+	args := m.Called(roleID, userIDs)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) RemoveRoleMembers(roleID uint, userIDs []uint) error {
+	args := m.Called(roleID, userIDs)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) SetRoleMembers(roleID uint, userIDs []uint) error {
+	args := m.Called(roleID, userIDs)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) FindRolesByUserID(userID uint) ([]models.Role, error) {
+	args := m.Called(userID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Role), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleService) SyncUserRoles(userID uint, grantedRoles []string, managedRoles []string) error {
+	args := m.Called(userID, grantedRoles, managedRoles)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) HasPermission(userID uint, permissionName string) (bool, error) {
+	args := m.Called(userID, permissionName)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTokenStoreService struct {
+	mock.Mock
+}
+
+func (m *MockTokenStoreService) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	args := m.Called(refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockTokenStoreService) FindRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.RefreshToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTokenStoreService) RevokeRefreshToken(tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockTokenStoreService) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	args := m.Called(jti, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockTokenStoreService) IsAccessTokenRevoked(jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTokenStoreService) IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error) {
+	args := m.Called(userID, email, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
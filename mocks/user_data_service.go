@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserDataService struct {
+	mock.Mock
+}
+
+func (m *MockUserDataService) ExportUserData(userID uint) (*models.UserDataExport, error) {
+	args := m.Called(userID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserDataExport), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserDataService) EraseUser(userID, actorID uint) error {
+	args := m.Called(userID, actorID)
+	return args.Error(0)
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserDirectoryService struct {
+	mock.Mock
+}
+
+func (m *MockUserDirectoryService) ListUsers(query models.UserDirectoryQuery) (*models.UserDirectoryPage, error) {
+	args := m.Called(query)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserDirectoryPage), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ExportUsers hands the users of the first return value to write
+func (m *MockUserDirectoryService) ExportUsers(query models.UserDirectoryQuery, write func(models.UserDirectoryEntry) error) error {
+	args := m.Called(query, write)
+	if users, ok := args.Get(0).([]models.UserDirectoryEntry); ok {
+		for _, user := range users {
+			if err := write(user); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"io"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserImportService struct {
+	mock.Mock
+}
+
+// ImportUsers is called with the content of data, so expectations can match the uploaded file
+func (m *MockUserImportService) ImportUsers(format string, data io.Reader) (*models.UserImportResult, error) {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(format, string(content))
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserImportResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
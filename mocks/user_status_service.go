@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserStatusService struct {
+	mock.Mock
+}
+
+func (m *MockUserStatusService) ChangeStatus(userID uint, change models.UserStatusChange) (*models.UserStatus, error) {
+	args := m.Called(userID, change)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.UserStatus), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWebhookAttemptService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookAttemptService) RecordAttempt(attempt *models.WebhookDeliveryAttempt) error {
+	args := m.Called(attempt)
+	return args.Error(0)
+}
+
+func (m *MockWebhookAttemptService) ListAttempts(afterID uint64, limit int, endpoint, eventID string) ([]models.WebhookDeliveryAttempt, error) {
+	args := m.Called(afterID, limit, endpoint, eventID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.WebhookDeliveryAttempt), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
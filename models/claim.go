@@ -2,11 +2,19 @@ package models
 
 import "github.com/golang-jwt/jwt/v5"
 
+// Audiences of the tokens signed with JWT_SECRET, so a token of one kind is never accepted as another
+const (
+	AccessTokenAudience    = "access_token"
+	FederatedStateAudience = "federated_state"
+)
+
 type Claims struct {
 	Email      string `json:"email"`
 	UserID     uint   `json:"user_id"`
 	FirstName  string `json:"first_name"`
 	MiddleName string `json:"middle_name"`
 	LastName   string `json:"last_name"`
+	// ClientID is the OAuth client the token was issued to, empty for tokens of the login endpoints
+	ClientID string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
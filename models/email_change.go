@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// EmailChangeConfig decides where the link to confirm a new email address points to and how long it works
+type EmailChangeConfig struct {
+	// ConfirmURL is the page that accepts the token, which is appended as the token query parameter. The
+	// token is sent without a link when it is empty.
+	ConfirmURL string
+	TokenTTL   time.Duration
+	// Unavailable refuses every request, the password delivery service cannot send the confirmation or the
+	// notice
+	Unavailable bool
+}
+
+// EmailChangeToken confirms that the user can receive email at NewEmail, only the hash of the token is stored
+type EmailChangeToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	TokenHash string     `gorm:"column:token_hash;unique;not null"`
+	UserID    uint       `gorm:"column:user_id;not null"`
+	NewEmail  string     `gorm:"column:new_email;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+}
+
+// RevokedTokenEmail rejects the access tokens of a user that carry an email claim the user no longer has,
+// if they were issued until RevokedAt. Entries are only needed until the last of those tokens expired.
+type RevokedTokenEmail struct {
+	UserID    uint      `gorm:"column:user_id;primaryKey"`
+	Email     string    `gorm:"column:email;primaryKey"`
+	RevokedAt time.Time `gorm:"column:revoked_at;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
+
+type EmailChangeRequest struct {
+	NewEmail        string `json:"new_email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type EmailChangeConfirmRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailChangeConfirmation is sent to the new address, Email is that new address
+type EmailChangeConfirmation struct {
+	Email         string           `json:"email"`
+	PreviousEmail string           `json:"previous_email"`
+	FirstName     string           `json:"first_name"`
+	MiddleName    string           `json:"middle_name"`
+	LastName      string           `json:"last_name"`
+	ConfirmURL    string           `json:"confirm_url,omitempty"`
+	Token         string           `json:"token"`
+	ExpiresAt     time.Time        `json:"expires_at"`
+	Locale        string           `json:"locale,omitempty"`
+	Message       *RenderedMessage `json:"message,omitempty"`
+}
+
+// EmailChangeNotice tells the current address that a change to NewEmail was requested, so that the owner
+// notices when somebody else did it
+type EmailChangeNotice struct {
+	Email       string           `json:"email"`
+	NewEmail    string           `json:"new_email"`
+	FirstName   string           `json:"first_name"`
+	MiddleName  string           `json:"middle_name"`
+	LastName    string           `json:"last_name"`
+	RequestedAt time.Time        `json:"requested_at"`
+	Locale      string           `json:"locale,omitempty"`
+	Message     *RenderedMessage `json:"message,omitempty"`
+}
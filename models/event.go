@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventSpecVersion = "1.0"
+	// EventContentType is the content type of a whole event in the CloudEvents structured JSON format
+	EventContentType = "application/cloudevents+json"
+
+	EventUserCredentialsIssued          = "user.credentials_issued"
+	EventUserPasswordSetupRequested     = "user.password_setup_requested"
+	EventUserEmailConfirmationRequested = "user.email_confirmation_requested"
+	EventUserEmailChangeNoticeRequested = "user.email_change_notice_requested"
+	EventUserInvitationRequested        = "user.invitation_requested"
+
+	EventUserRegistered      = "user.registered"
+	EventUserUpdated         = "user.updated"
+	EventUserDeleted         = "user.deleted"
+	EventUserLoggedIn        = "user.logged_in"
+	EventUserLoginFailed     = "user.login_failed"
+	EventUserPasswordChanged = "user.password_changed"
+	EventUserEmailChanged    = "user.email_changed"
+	EventUserStatusChanged   = "user.status_changed"
+	EventRoleAssigned        = "role.assigned"
+	EventRoleRevoked         = "role.revoked"
+)
+
+// How a user came to be registered, logged in or changed their password
+const (
+	EventMethodRegistration  = "registration"
+	EventMethodSCIM          = "scim"
+	EventMethodFederated     = "federated"
+	EventMethodLDAP          = "ldap"
+	EventMethodPassword      = "password"
+	EventMethodPasswordSetup = "password_setup"
+	EventMethodAdmin         = "admin"
+	EventMethodImport        = "import"
+	EventMethodInvitation    = "invitation"
+)
+
+// Event is a CloudEvents 1.0 event. Subject is the ID of the user the event is about, DataSchema names
+// the JSON Schema in schemas/events that Data follows and SchemaVersion is the version of that schema.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	SchemaVersion   int             `json:"schemaversion"`
+	Data            json.RawMessage `json:"data"`
+}
+
+type UserRegisteredData struct {
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
+	Method     string `json:"method"`
+}
+
+type UserUpdatedData struct {
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
+}
+
+type UserEmailChangedData struct {
+	Email         string `json:"email"`
+	PreviousEmail string `json:"previous_email"`
+}
+
+type UserStatusChangedData struct {
+	Email          string `json:"email"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+	Reason         string `json:"reason,omitempty"`
+	Method         string `json:"method"`
+}
+
+type UserDeletedData struct {
+	Email string `json:"email"`
+	// Erased is set when the user's data was erased on request, consumers should erase their copies too
+	Erased bool `json:"erased,omitempty"`
+}
+
+type UserLoggedInData struct {
+	Email    string `json:"email"`
+	Method   string `json:"method"`
+	Provider string `json:"provider,omitempty"`
+}
+
+// Why a login failed, the reason of user.login_failed
+const (
+	LoginFailedInvalidCredentials = "invalid_credentials"
+	LoginFailedAccountInactive    = "account_inactive"
+	LoginFailedBackendError       = "backend_error"
+)
+
+// UserLoginFailedData has no subject, so the event does not tell whether the email belongs to a user. The
+// reason is one of the LoginFailed constants and never says more, such as the status of the account.
+type UserLoginFailedData struct {
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+type UserPasswordChangedData struct {
+	Method string `json:"method"`
+}
+
+// RoleMembershipData is the data of role.assigned and role.revoked, the subject is the user
+type RoleMembershipData struct {
+	RoleID   uint   `json:"role_id"`
+	RoleName string `json:"role_name"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "github.com/golang-jwt/jwt/v5"
+
+// FederatedIdentity links an account at an external identity provider to a local user
+type FederatedIdentity struct {
+	Provider string `gorm:"column:provider;primaryKey" json:"provider"`
+	Subject  string `gorm:"column:subject;primaryKey" json:"subject"`
+	UserID   uint   `gorm:"column:user_id;not null" json:"user_id"`
+	Email    string `gorm:"column:email" json:"email"`
+}
+
+type FederatedProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	ClaimMapping FederatedClaimMapping
+}
+
+// FederatedClaimMapping names the upstream ID token claims that hold each user attribute
+type FederatedClaimMapping struct {
+	Email         string
+	EmailVerified string
+	GivenName     string
+	MiddleName    string
+	FamilyName    string
+}
+
+// FederatedClaims are the user attributes extracted from an upstream ID token
+type FederatedClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	MiddleName    string
+	FamilyName    string
+}
+
+// FederatedStateClaims round-trips through the upstream provider in the OAuth state parameter
+type FederatedStateClaims struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+type FederatedTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an Idempotency-Key header, so that a retry is
+// answered the same instead of running again. Keys are unique per Scope, the route they were sent to.
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	Scope       string `gorm:"column:scope;not null"`
+	Key         string `gorm:"column:idempotency_key;not null"`
+	RequestHash string `gorm:"column:request_hash;not null"`
+	// StatusCode is 0 while the first request with the key is still running
+	StatusCode  int       `gorm:"column:status_code;not null"`
+	ContentType string    `gorm:"column:content_type;not null"`
+	Body        []byte    `gorm:"column:body"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;not null"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import "time"
+
+// Statuses of an invitation, only pending invitations can be accepted or revoked
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// InvitationConfig decides where the link to accept an invitation points to and how long it works
+type InvitationConfig struct {
+	// AcceptURL is the page that accepts the token, which is appended as the token query parameter. The
+	// token is sent without a link when it is empty.
+	AcceptURL string
+	TokenTTL  time.Duration
+	// Unavailable refuses new invitations, the password delivery service cannot send them
+	Unavailable bool
+}
+
+// Invitation lets the invitee create their own account with the roles an admin chose, once and until
+// ExpiresAt. Only the hash of the token is stored.
+type Invitation struct {
+	ID              uint   `gorm:"primaryKey"`
+	Email           string `gorm:"column:email;not null"`
+	FirstName       string `gorm:"column:first_name;not null"`
+	MiddleName      string `gorm:"column:middle_name;not null"`
+	LastName        string `gorm:"column:last_name;not null"`
+	PreferredLocale string `gorm:"column:preferred_locale;not null"`
+	// InvitedBy is the admin who sent the invitation
+	InvitedBy  *uint      `gorm:"column:invited_by"`
+	TokenHash  string     `gorm:"column:token_hash;unique;not null"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null"`
+	AcceptedAt *time.Time `gorm:"column:accepted_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	// UserID is the user who accepted the invitation
+	UserID    *uint     `gorm:"column:user_id"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+// Status tells whether the invitation can still be accepted at now
+func (i *Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !i.ExpiresAt.After(now):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// InvitationRole is a role the invitee is given when accepting
+type InvitationRole struct {
+	InvitationID uint `gorm:"primaryKey"`
+	RoleID       uint `gorm:"primaryKey"`
+}
+
+// InvitationRequest invites Email with the roles. The names and locale are suggestions the invitee can
+// change when accepting.
+type InvitationRequest struct {
+	Email           string   `json:"email" binding:"required,email"`
+	FirstName       string   `json:"first_name"`
+	MiddleName      string   `json:"middle_name"`
+	LastName        string   `json:"last_name"`
+	PreferredLocale string   `json:"preferred_locale"`
+	Roles           []string `json:"roles"`
+}
+
+// InvitationAcceptRequest creates the account of the invitation with the chosen password, names and locale
+// left empty are taken from the invitation
+type InvitationAcceptRequest struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	FirstName       string `json:"first_name"`
+	MiddleName      string `json:"middle_name"`
+	LastName        string `json:"last_name"`
+	PreferredLocale string `json:"preferred_locale"`
+}
+
+// InvitationSummary is an invitation as shown to admins
+type InvitationSummary struct {
+	ID              uint       `json:"id"`
+	Email           string     `json:"email"`
+	FirstName       string     `json:"first_name"`
+	MiddleName      string     `json:"middle_name"`
+	LastName        string     `json:"last_name"`
+	PreferredLocale string     `json:"preferred_locale,omitempty"`
+	Roles           []string   `json:"roles"`
+	InvitedBy       *uint      `json:"invited_by,omitempty"`
+	Status          string     `json:"status"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	UserID          *uint      `json:"user_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type InvitationList struct {
+	Invitations []InvitationSummary `json:"invitations"`
+	CursorPage
+}
+
+// UserInvitation is sent to the invitee with the token to accept the invitation
+type UserInvitation struct {
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
+	// InvitedBy is the name of the admin who sent the invitation, or their email address
+	InvitedBy string    `json:"invited_by"`
+	Roles     []string  `json:"roles"`
+	AcceptURL string    `json:"accept_url,omitempty"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Locale is the suggested locale of the invitee, empty for the default one
+	Locale  string           `json:"locale,omitempty"`
+	Message *RenderedMessage `json:"message,omitempty"`
+}
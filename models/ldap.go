@@ -0,0 +1,19 @@
+package models
+
+// LDAPConfig describes how to find and authenticate users in an LDAP or Active Directory server
+type LDAPConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter is a search filter with a single %s placeholder for the escaped login email
+	UserFilter string
+	StartTLS   bool
+
+	EmailAttribute      string
+	GivenNameAttribute  string
+	FamilyNameAttribute string
+	GroupAttribute      string
+	// GroupRoles maps lower cased group DNs onto role names
+	GroupRoles map[string]string
+}
@@ -0,0 +1,31 @@
+package models
+
+// RenderedMessage is a notification rendered from the templates of its message type in the user's
+// locale, so that every delivery channel passes on the same wording
+type RenderedMessage struct {
+	// Locale is the locale of the templates that were used, which falls back to the default locale
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	// HTML is empty when the message type has no HTML template
+	HTML string `json:"html,omitempty"`
+}
+
+// Notification is a payload that is rendered into a message for its recipient
+type Notification interface {
+	NotificationLocale() string
+	SetMessage(message *RenderedMessage)
+}
+
+func (c *UserCredentials) NotificationLocale() string            { return c.Locale }
+func (c *UserCredentials) SetMessage(message *RenderedMessage)   { c.Message = message }
+func (l *PasswordSetupLink) NotificationLocale() string          { return l.Locale }
+func (l *PasswordSetupLink) SetMessage(message *RenderedMessage) { l.Message = message }
+
+func (c *EmailChangeConfirmation) NotificationLocale() string          { return c.Locale }
+func (c *EmailChangeConfirmation) SetMessage(message *RenderedMessage) { c.Message = message }
+func (n *EmailChangeNotice) NotificationLocale() string                { return n.Locale }
+func (n *EmailChangeNotice) SetMessage(message *RenderedMessage)       { n.Message = message }
+
+func (i *UserInvitation) NotificationLocale() string          { return i.Locale }
+func (i *UserInvitation) SetMessage(message *RenderedMessage) { i.Message = message }
@@ -0,0 +1,39 @@
+package models
+
+type OAuthClient struct {
+	ClientID     string `gorm:"column:client_id;primaryKey" json:"client_id"`
+	ClientSecret string `gorm:"column:client_secret;not null" json:"-"`
+	ClientName   string `gorm:"column:client_name" json:"client_name"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Username     string `form:"username"`
+	Password     string `form:"password"`
+	Scope        string `form:"scope"`
+	Nonce        string `form:"nonce"`
+	RefreshToken string `form:"refresh_token"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	// Metadata is recorded as the last login of users who log in with the password grant
+	Metadata LoginMetadata `form:"-"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthError follows the error response format of RFC 6749 section 5.2
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+const (
+	OutboxPasswordDelivery        = "password_delivery"
+	OutboxPasswordSetupLink       = "password_setup_link"
+	OutboxEmailChangeConfirmation = "email_change_confirmation"
+	OutboxEmailChangeNotice       = "email_change_notice"
+	OutboxInvitation              = "invitation"
+)
+
+// OutboxMessage is a side effect written in the same transaction as the change that caused it and
+// published afterwards by the outbox relay. Delivered messages are deleted, messages that ran out of
+// attempts keep FailedAt and LastError. EventID stays the same across retries, so consumers can drop
+// duplicates.
+type OutboxMessage struct {
+	ID            uint64     `gorm:"primaryKey" json:"id"`
+	EventID       string     `gorm:"type:uuid;not null;default:gen_random_uuid()" json:"event_id"`
+	UserID        *uint      `json:"user_id,omitempty"`
+	MessageType   string     `gorm:"not null" json:"message_type"`
+	Payload       string     `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LastError     *string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+}
+
+// DeadLetter is an outbox message that ran out of attempts, as shown to admins. The payload is left out
+// because it can contain credentials.
+type DeadLetter struct {
+	ID          uint64    `json:"id"`
+	EventID     string    `json:"event_id"`
+	UserID      *uint     `json:"user_id,omitempty"`
+	MessageType string    `json:"message_type"`
+	Attempts    int       `json:"attempts"`
+	LastError   *string   `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// CursorPage is embedded in the responses of the paged listings
+type CursorPage struct {
+	// NextCursor is passed as after to get the next page, it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type DeadLetterList struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	CursorPage
+}
+
+// CredentialReissueConfig decides the links and lifetimes of the credentials that replaying a dead letter
+// issues again, the same ones the original message was sent with
+type CredentialReissueConfig struct {
+	Onboarding  OnboardingConfig
+	EmailChange EmailChangeConfig
+	Invitation  InvitationConfig
+}
+
+type DeadLetterReplayResult struct {
+	Replayed int64 `json:"replayed"`
+}
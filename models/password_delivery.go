@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PasswordDelivery is a credential notification waiting in the password_deliveries table for a consumer
+type PasswordDelivery struct {
+	ID             uint64     `gorm:"primaryKey" json:"id"`
+	Email          string     `gorm:"not null" json:"email"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"payload"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ClaimedBy      *string    `json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+}
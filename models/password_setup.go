@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	OnboardingPassword  = "password"
+	OnboardingSetupLink = "setup_link"
+)
+
+// OnboardingConfig decides what a newly registered user receives, a temporary password or a one-time
+// link to choose their own
+type OnboardingConfig struct {
+	Mode string
+	// SetupURL is the page that accepts the token, which is appended as the token query parameter
+	SetupURL string
+	TokenTTL time.Duration
+}
+
+// PasswordSetupToken lets the user set their first password once, only the hash of the token is stored
+type PasswordSetupToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	TokenHash string     `gorm:"column:token_hash;unique;not null"`
+	UserID    uint       `gorm:"column:user_id;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+}
+
+// PasswordSetupLink is the onboarding message sent instead of UserCredentials, it carries no password
+type PasswordSetupLink struct {
+	Email      string    `json:"email"`
+	FirstName  string    `json:"first_name"`
+	MiddleName string    `json:"middle_name"`
+	LastName   string    `json:"last_name"`
+	SetupURL   string    `json:"setup_url"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// UserID is set by the outbox relay and keys the delivery, it is not part of the payload
+	UserID uint `json:"-"`
+	// Locale is the user's preferred locale, empty for the default one
+	Locale string `json:"locale,omitempty"`
+	// Message is added by the outbox relay before the link is handed to the delivery channel
+	Message *RenderedMessage `json:"message,omitempty"`
+}
+
+type PasswordSetupRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
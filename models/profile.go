@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// UserProfile is what the authenticated user sees about themselves
+type UserProfile struct {
+	UserDetail
+	Email     string    `json:"email"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the last change of the account or its details, logins do not count
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LastLoginAt        *time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP        string     `json:"last_login_ip,omitempty"`
+	LastLoginUserAgent string     `json:"last_login_user_agent,omitempty"`
+}
+
+// UpdateProfileRequest changes the fields that are present. First and last name cannot be cleared, an
+// empty middle name or preferred locale removes it.
+type UpdateProfileRequest struct {
+	FirstName       *string `json:"first_name"`
+	MiddleName      *string `json:"middle_name"`
+	LastName        *string `json:"last_name"`
+	PreferredLocale *string `json:"preferred_locale"`
+}
@@ -14,3 +14,14 @@ type RolePermission struct {
 	RoleID       uint `gorm:"primaryKey"`
 	PermissionID uint `gorm:"primaryKey"`
 }
+
+type UserRole struct {
+	UserID uint `gorm:"primaryKey"`
+	RoleID uint `gorm:"primaryKey"`
+}
+
+// PermissionAdmin grants access to the admin endpoints. The migrations seed RoleAdmin with it.
+const PermissionAdmin = "admin"
+
+// RoleAdmin is the role seeded by the migrations, it starts without members, see the grant-admin command
+const RoleAdmin = "admin"
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	TokenHash string     `gorm:"column:token_hash;unique;not null"`
+	UserID    uint       `gorm:"column:user_id;not null"`
+	ClientID  string     `gorm:"column:client_id;not null"`
+	Scope     string     `gorm:"column:scope"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+}
+
+// RevokedToken records the jti of an access token revoked before it expired
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
+
+// IntrospectionRequest is used for both introspection (RFC 7662) and revocation (RFC 7009) requests
+type IntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
@@ -1,9 +1,27 @@
 package models
 
+import "time"
+
 type User struct {
 	ID       uint   `gorm:"primaryKey"`
 	Email    string `gorm:"unique;not null" json:"email"`
 	Password string `gorm:"not null" json:"password"`
+	// CreatedAt is set when the user is created and never written afterwards
+	CreatedAt time.Time `gorm:"column:created_at;<-:create" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+	// Status is one of the UserStatus constants, only active users can log in
+	Status          string     `gorm:"column:status;default:active" json:"status"`
+	StatusReason    string     `gorm:"column:status_reason" json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `gorm:"column:status_changed_at" json:"status_changed_at,omitempty"`
+	// ErasedAt is set when the user's data was erased on request, the row is anonymized and stays deleted
+	ErasedAt *time.Time `gorm:"column:erased_at" json:"erased_at,omitempty"`
+	// EmailVerifiedAt is set when the user redeems a setup link, email change confirmation or invitation sent to
+	// Email. Users created by an admin, an import, SCIM or a federated login have not proven the address.
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at" json:"email_verified_at,omitempty"`
+	// the last login is only written by RecordLogin, it does not change UpdatedAt
+	LastLoginAt        *time.Time `gorm:"column:last_login_at" json:"last_login_at,omitempty"`
+	LastLoginIP        string     `gorm:"column:last_login_ip" json:"last_login_ip,omitempty"`
+	LastLoginUserAgent string     `gorm:"column:last_login_user_agent" json:"last_login_user_agent,omitempty"`
 }
 
 type UserDetail struct {
@@ -11,6 +29,10 @@ type UserDetail struct {
 	FirstName  string `gorm:"column:firstname;not null;size:100" json:"first_name"`
 	MiddleName string `gorm:"column:middlename;size:100" json:"middle_name"`
 	LastName   string `gorm:"column:lastname;not null;size:100" json:"last_name"`
+	// PreferredLocale is a BCP 47 tag such as de or pt-BR, notifications use the default locale when it is empty
+	PreferredLocale string    `gorm:"column:preferred_locale;size:35" json:"preferred_locale,omitempty"`
+	CreatedAt       time.Time `gorm:"column:created_at;<-:create" json:"-"`
+	UpdatedAt       time.Time `gorm:"column:updated_at" json:"-"`
 }
 
 type UserRegitrationRequest struct {
@@ -18,11 +40,20 @@ type UserRegitrationRequest struct {
 	FirstName  string `json:"first_name" binding:"required"`
 	MiddleName string `json:"middle_name"`
 	LastName   string `json:"last_name" binding:"required"`
+	// PreferredLocale is optional and decides the language of the notifications sent to the user
+	PreferredLocale string `json:"preferred_locale"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email    string        `json:"email" binding:"required,email"`
+	Password string        `json:"password" binding:"required"`
+	Metadata LoginMetadata `json:"-"`
+}
+
+// LoginMetadata describes the client a login came from, it is recorded as the user's last login
+type LoginMetadata struct {
+	IPAddress string
+	UserAgent string
 }
 
 type UserCredentials struct {
@@ -31,4 +62,10 @@ type UserCredentials struct {
 	MiddleName string `json:"middle_name"`
 	LastName   string `json:"last_name"`
 	Password   string `json:"password" binding:"required"`
+	// UserID is set by the outbox relay and keys the delivery, it is not part of the payload
+	UserID uint `json:"-"`
+	// Locale is the user's preferred locale, empty for the default one
+	Locale string `json:"locale,omitempty"`
+	// Message is added by the outbox relay before the credentials are handed to the delivery channel
+	Message *RenderedMessage `json:"message,omitempty"`
 }
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// UserDataExport is everything stored about one user, handed out on a data access request. Secrets such as
+// password and token hashes are left out.
+type UserDataExport struct {
+	ExportedAt          time.Time                   `json:"exported_at"`
+	User                UserDataAccount             `json:"user"`
+	Details             *UserDataDetails            `json:"details"`
+	Roles               []string                    `json:"roles"`
+	Sessions            []UserDataSession           `json:"sessions"`
+	FederatedIdentities []UserDataFederatedIdentity `json:"federated_identities"`
+	PasswordSetupLinks  []UserDataToken             `json:"password_setup_links"`
+	EmailChanges        []UserDataEmailChange       `json:"email_changes"`
+	// Messages are the notifications queued for the user, without their contents
+	Messages []UserDataMessage `json:"messages"`
+}
+
+type UserDataAccount struct {
+	ID              uint       `json:"id"`
+	Email           string     `json:"email"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// the last login is recorded with the client it came from
+	LastLoginAt        *time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP        string     `json:"last_login_ip,omitempty"`
+	LastLoginUserAgent string     `json:"last_login_user_agent,omitempty"`
+}
+
+type UserDataDetails struct {
+	FirstName       string    `json:"first_name"`
+	MiddleName      string    `json:"middle_name"`
+	LastName        string    `json:"last_name"`
+	PreferredLocale string    `json:"preferred_locale,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UserDataSession is a refresh token issued to the user
+type UserDataSession struct {
+	ClientID  string     `json:"client_id"`
+	Scope     string     `json:"scope,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type UserDataFederatedIdentity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email,omitempty"`
+}
+
+type UserDataToken struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+type UserDataEmailChange struct {
+	NewEmail  string     `json:"new_email"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+type UserDataMessage struct {
+	EventID     string     `json:"event_id"`
+	MessageType string     `json:"message_type"`
+	Attempts    int        `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+}
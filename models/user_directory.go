@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// UserDirectoryQuery selects and orders the users of the admin directory, empty fields do not filter
+type UserDirectoryQuery struct {
+	// Search matches users whose email or names contain every whitespace separated term, ignoring case
+	Search        string
+	Role          string
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Sort is id, email, first_name, last_name or created_at, prefixed with - for descending order
+	Sort string
+	// After is the next_cursor of the previous page, it is only valid with the same sort
+	After string
+	Limit int
+}
+
+// UserDirectoryEntry is a user joined with their details and the names of their roles
+type UserDirectoryEntry struct {
+	ID              uint      `json:"id"`
+	Email           string    `json:"email"`
+	FirstName       string    `json:"first_name"`
+	MiddleName      string    `json:"middle_name"`
+	LastName        string    `json:"last_name"`
+	PreferredLocale string    `json:"preferred_locale,omitempty"`
+	Roles           []string  `json:"roles"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	// UpdatedAt is the last change of the user or their details
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LastLoginAt        *time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP        string     `json:"last_login_ip,omitempty"`
+	LastLoginUserAgent string     `json:"last_login_user_agent,omitempty"`
+}
+
+type UserDirectoryPage struct {
+	Users []UserDirectoryEntry `json:"users"`
+	CursorPage
+}
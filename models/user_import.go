@@ -0,0 +1,46 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Formats of a bulk user import
+const (
+	// UserImportCSV has a header row naming the columns email, first_name, middle_name, last_name,
+	// preferred_locale and roles, roles are separated by ;
+	UserImportCSV = "csv"
+	// UserImportJSONLines has one UserImportRow object per line
+	UserImportJSONLines = "jsonl"
+)
+
+// UserImportFormatOf returns the format of a file named .csv, .jsonl or .ndjson, and "" for other names
+func UserImportFormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return UserImportCSV
+	case ".jsonl", ".ndjson":
+		return UserImportJSONLines
+	}
+	return ""
+}
+
+// UserImportRow is one user of a bulk import, registered like through /auth/register and given the roles
+type UserImportRow struct {
+	UserRegitrationRequest
+	Roles []string `json:"roles"`
+}
+
+// UserImportRowError lists everything wrong with one row. Line is the line of the file the row starts on,
+// the header of a CSV file is line 1.
+type UserImportRowError struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+type UserImportResult struct {
+	Rows    int                  `json:"rows"`
+	Created int                  `json:"created"`
+	Errors  []UserImportRowError `json:"errors"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	UserStatusActive = "active"
+	// UserStatusPendingVerification users have not chosen a password through their setup link yet
+	UserStatusPendingVerification = "pending_verification"
+	// UserStatusSuspended users are locked out for the time being, UserStatusDisabled ones until further notice
+	UserStatusSuspended = "suspended"
+	UserStatusDisabled  = "disabled"
+	// UserStatusDeleted users keep their row but can never be reactivated
+	UserStatusDeleted = "deleted"
+)
+
+// UserStatusRequest is the optional body of the admin status endpoints
+type UserStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// UserStatusChange moves a user to Status. ActorID is the admin making the change, zero for changes made
+// by a provisioning client.
+type UserStatusChange struct {
+	Status  string
+	Reason  string
+	Method  string
+	ActorID uint
+}
+
+type UserStatus struct {
+	ID        uint       `json:"id"`
+	Email     string     `json:"email"`
+	Status    string     `json:"status"`
+	Reason    string     `json:"reason,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
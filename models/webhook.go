@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// WebhookDeliveryAttempt records one HTTP request to a webhook endpoint. The request body is not kept, it
+// can contain credentials.
+type WebhookDeliveryAttempt struct {
+	ID        uint64 `gorm:"primaryKey" json:"id"`
+	Endpoint  string `gorm:"not null" json:"endpoint"`
+	EventID   string `gorm:"type:uuid;not null" json:"event_id"`
+	EventType string `gorm:"not null" json:"event_type"`
+	Attempt   int    `gorm:"not null" json:"attempt"`
+	// StatusCode is nil when no response was received
+	StatusCode *int      `json:"status_code,omitempty"`
+	Error      *string   `json:"error,omitempty"`
+	DurationMs int64     `gorm:"not null" json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryAttemptList struct {
+	Attempts []WebhookDeliveryAttempt `json:"attempts"`
+	CursorPage
+}
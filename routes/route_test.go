@@ -9,9 +9,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/shibbirmcc/user-auth-and-permissions/handlers"
+	"github.com/shibbirmcc/user-auth-and-permissions/middlewares"
 	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
 	"github.com/shibbirmcc/user-auth-and-permissions/services"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,13 +21,13 @@ import (
 func TestConfigureRouteEndPoints(t *testing.T) {
 
 	mockDBService := new(mocks.MockDatabaseOperationService)
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	mockRegService := services.NewUserRegistrationService(mockPasswordDeliveryService, mockDBService)
+	mockRegService := services.NewUserRegistrationService(mockDBService)
 	mockLoginService := services.NewUserLoginService(mockDBService)
 	userHandler := handlers.NewUserHandler(*mockRegService, *mockLoginService)
 
+	mockIdempotencyService := new(mocks.MockIdempotencyService)
 	router := gin.Default()
-	ConfigureRouteEndpoints(router, userHandler)
+	ConfigureRouteEndpoints(router, userHandler, middlewares.Idempotency(mockIdempotencyService))
 
 	t.Run("RegisterUser endpoint", func(t *testing.T) {
 		input := models.UserRegitrationRequest{
@@ -35,8 +37,8 @@ func TestConfigureRouteEndPoints(t *testing.T) {
 		}
 		body, _ := json.Marshal(input)
 
-		// Mock the CreateUser method of the mockDBService to return no error.
-		mockDBService.On("CreateUser", mock.Anything, mock.Anything).Return(nil)
+		// Mock the CreateUserWithOutbox method of the mockDBService to return no error.
+		mockDBService.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 		// Create HTTP request and record response
 		req := httptest.NewRequest("POST", "/auth/register", bytes.NewBuffer(body))
@@ -50,6 +52,24 @@ func TestConfigureRouteEndPoints(t *testing.T) {
 		assert.Equal(t, http.StatusOK, resp.Code)
 	})
 
+	t.Run("RegisterUser endpoint replays retries", func(t *testing.T) {
+		mockIdempotencyService.On("Reserve", "POST /auth/register", "retry-1", mock.Anything).Return(&models.IdempotencyKey{
+			RequestHash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			StatusCode:  http.StatusOK,
+			ContentType: "application/json; charset=utf-8",
+			Body:        []byte(`{"message":"Registration successful, please confirm your email"}`),
+		}, nil)
+
+		req := httptest.NewRequest("POST", "/auth/register", nil)
+		req.Header.Set("Idempotency-Key", "retry-1")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "true", resp.Header().Get("Idempotent-Replayed"))
+		mockDBService.AssertNumberOfCalls(t, "CreateUserWithOutbox", 1)
+	})
+
 	t.Run("LoginUser endpoint", func(t *testing.T) {
 		loginRequest := models.LoginRequest{
 			Email:    mocks.TestUserEmail,
@@ -59,6 +79,7 @@ func TestConfigureRouteEndPoints(t *testing.T) {
 		user := &models.User{
 			Email:    mocks.TestUserEmail,
 			Password: mocks.TestUserPasswordHash,
+			Status:   models.UserStatusActive,
 		}
 		userDetails := &models.UserDetail{
 			FirstName: mocks.TestUserFirstName,
@@ -67,6 +88,7 @@ func TestConfigureRouteEndPoints(t *testing.T) {
 
 		mockDBService.On("FindUserByEmail", loginRequest.Email).Return(user, nil)
 		mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+		mockDBService.On("RecordLogin", user.ID, mock.Anything).Return(nil)
 
 		// Create a request
 		requestBody, _ := json.Marshal(loginRequest)
@@ -85,3 +107,347 @@ func TestConfigureRouteEndPoints(t *testing.T) {
 	})
 
 }
+
+func TestConfigureOIDCEndpoints(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockClientService := new(mocks.MockOAuthClientService)
+	loginService := services.NewUserLoginService(mockDBService)
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	oidcHandler := handlers.NewOIDCHandler(services.NewOIDCService(loginService, mockDBService, mockClientService, mockTokenStore), "https://auth.example.com")
+
+	router := gin.Default()
+	ConfigureOIDCEndpoints(router, oidcHandler, middlewares.TokenAuthMiddleware())
+
+	t.Run("Discovery endpoint", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/.well-known/openid-configuration", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("UserInfo endpoint requires a token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+}
+
+func TestConfigureTokenEndpoints(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	tokenHandler := handlers.NewTokenHandler(services.NewTokenIntrospectionService(mockDBService, mockClientService, mockTokenStore))
+
+	router := gin.Default()
+	ConfigureTokenEndpoints(router, tokenHandler)
+
+	for _, path := range []string{"/oauth/introspect", "/oauth/revoke"} {
+		t.Run(path+" requires a token parameter", func(t *testing.T) {
+			req := httptest.NewRequest("POST", path, nil)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	}
+}
+
+func TestConfigureFederatedEndpoints(t *testing.T) {
+	federatedLoginService := services.NewFederatedLoginService(nil, new(mocks.MockDatabaseOperationService), new(mocks.MockFederatedIdentityService), nil)
+	router := gin.Default()
+	ConfigureFederatedEndpoints(router, handlers.NewFederatedHandler(federatedLoginService, "https://auth.example.com"))
+
+	req := httptest.NewRequest("GET", "/auth/federated/providers", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"providers":[]}`, resp.Body.String())
+
+	req = httptest.NewRequest("GET", "/auth/federated/unknown/login", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestConfigureSCIMEndpoints(t *testing.T) {
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("ListRoles", 0, services.SCIMDefaultCount).Return([]models.Role{}, int64(0), nil)
+	scimHandler := handlers.NewSCIMHandler(services.NewSCIMService(new(mocks.MockDatabaseOperationService), mockRoleService, new(mocks.MockUserDataService), nil), "https://auth.example.com")
+
+	router := gin.Default()
+	ConfigureSCIMEndpoints(router, scimHandler, middlewares.SCIMAuthMiddleware([]string{"scim-token"}))
+
+	req := httptest.NewRequest("GET", "/scim/v2/Groups", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	req = httptest.NewRequest("GET", "/scim/v2/Groups", nil)
+	req.Header.Set("Authorization", "Bearer scim-token")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"totalResults":0`)
+}
+
+func TestConfigurePasswordSetupEndpoints(t *testing.T) {
+	mockSetupService := new(mocks.MockPasswordSetupService)
+	mockSetupService.On("CompletePasswordSetup", "setup-token", "chosen-password").Return(nil)
+
+	router := gin.Default()
+	ConfigurePasswordSetupEndpoints(router, handlers.NewPasswordSetupHandler(mockSetupService))
+
+	req := httptest.NewRequest("POST", "/auth/password/setup", bytes.NewBufferString(`{"token":"setup-token","password":"chosen-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockSetupService.AssertExpectations(t)
+}
+
+func TestConfigureDeadLetterEndpoints(t *testing.T) {
+	mockOutboxService := new(mocks.MockOutboxService)
+	mockOutboxService.On("ListDeadLetters", uint64(0), 50, "").Return([]models.DeadLetter{}, nil)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+
+	router := gin.Default()
+	ConfigureDeadLetterEndpoints(router, handlers.NewDeadLetterHandler(mockOutboxService),
+		middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(mockRoleService, models.PermissionAdmin))
+
+	for userID, status := range map[uint]int{1: http.StatusOK, 2: http.StatusForbidden} {
+		token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: userID})
+		assert.NoError(t, err)
+		req := httptest.NewRequest("GET", "/admin/deliveries/dead-letters", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, status, resp.Code)
+	}
+}
+
+func TestConfigureWebhookEndpoints(t *testing.T) {
+	mockAttemptService := new(mocks.MockWebhookAttemptService)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+
+	router := gin.Default()
+	ConfigureWebhookEndpoints(router, handlers.NewWebhookAttemptHandler(mockAttemptService),
+		middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(mockRoleService, models.PermissionAdmin))
+
+	token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: 2})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("GET", "/admin/webhooks/attempts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	mockAttemptService.AssertNotCalled(t, "ListAttempts")
+}
+
+func TestConfigureProfileEndpoints(t *testing.T) {
+	mockProfileService := new(mocks.MockProfileService)
+	mockProfileService.On("GetProfile", uint(2)).Return(&models.UserProfile{Email: mocks.TestUserEmail, Roles: []string{}}, nil)
+
+	router := gin.Default()
+	ConfigureProfileEndpoints(router, handlers.NewProfileHandler(mockProfileService), middlewares.TokenAuthMiddleware())
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: 2})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockProfileService.AssertExpectations(t)
+}
+
+func TestConfigureEmailChangeEndpoints(t *testing.T) {
+	mockEmailChangeService := new(mocks.MockEmailChangeService)
+	mockEmailChangeService.On("RequestEmailChange", uint(2), models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "secret"}).Return(nil)
+	mockEmailChangeService.On("ConfirmEmailChange", "token").Return(nil)
+
+	router := gin.Default()
+	ConfigureEmailChangeEndpoints(router, handlers.NewEmailChangeHandler(mockEmailChangeService), middlewares.TokenAuthMiddleware())
+
+	body := `{"new_email":"new@example.com","current_password":"secret"}`
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("POST", "/me/email", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: 2})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/me/email", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("POST", "/auth/email/confirm", bytes.NewBufferString(`{"token":"token"}`)))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockEmailChangeService.AssertExpectations(t)
+}
+
+func TestConfigureUserDirectoryEndpoints(t *testing.T) {
+	mockDirectoryService := new(mocks.MockUserDirectoryService)
+	mockDirectoryService.On("ListUsers", models.UserDirectoryQuery{Limit: 50}).Return(&models.UserDirectoryPage{Users: []models.UserDirectoryEntry{}}, nil)
+	mockDirectoryService.On("ExportUsers", models.UserDirectoryQuery{}, mock.Anything).Return([]models.UserDirectoryEntry{}, nil)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+
+	router := gin.Default()
+	ConfigureUserDirectoryEndpoints(router, handlers.NewUserDirectoryHandler(mockDirectoryService),
+		middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(mockRoleService, models.PermissionAdmin))
+
+	for _, path := range []string{"/admin/users", "/admin/users/export"} {
+		for userID, status := range map[uint]int{1: http.StatusOK, 2: http.StatusForbidden} {
+			token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: userID})
+			assert.NoError(t, err)
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, status, resp.Code, path)
+		}
+	}
+	mockDirectoryService.AssertExpectations(t)
+}
+
+func TestConfigureUserStatusEndpoints(t *testing.T) {
+	mockStatusService := new(mocks.MockUserStatusService)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+
+	router := gin.Default()
+	ConfigureUserStatusEndpoints(router, handlers.NewUserStatusHandler(mockStatusService),
+		middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(mockRoleService, models.PermissionAdmin))
+
+	for action, status := range map[string]string{
+		"suspend":    models.UserStatusSuspended,
+		"disable":    models.UserStatusDisabled,
+		"reactivate": models.UserStatusActive,
+		"delete":     models.UserStatusDeleted,
+	} {
+		mockStatusService.On("ChangeStatus", uint(7), models.UserStatusChange{Status: status, Method: models.EventMethodAdmin, ActorID: 1}).
+			Return(&models.UserStatus{ID: 7, Status: status}, nil)
+		for userID, expected := range map[uint]int{1: http.StatusOK, 2: http.StatusForbidden} {
+			token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: userID})
+			assert.NoError(t, err)
+			req := httptest.NewRequest("POST", "/admin/users/7/"+action, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, expected, resp.Code, action)
+		}
+	}
+	mockStatusService.AssertExpectations(t)
+}
+
+func TestConfigureUserDataEndpoints(t *testing.T) {
+	mockDataService := new(mocks.MockUserDataService)
+	mockDataService.On("ExportUserData", uint(2)).Return(&models.UserDataExport{}, nil)
+	mockDataService.On("ExportUserData", uint(7)).Return(&models.UserDataExport{}, nil)
+	mockDataService.On("EraseUser", uint(7), uint(1)).Return(nil)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+	authMiddleware := middlewares.TokenAuthMiddleware()
+	adminMiddleware := middlewares.RequirePermission(mockRoleService, models.PermissionAdmin)
+
+	// the admin user routes share their paths
+	router := gin.Default()
+	ConfigureUserDirectoryEndpoints(router, handlers.NewUserDirectoryHandler(new(mocks.MockUserDirectoryService)), authMiddleware, adminMiddleware)
+	ConfigureUserStatusEndpoints(router, handlers.NewUserStatusHandler(new(mocks.MockUserStatusService)), authMiddleware, adminMiddleware)
+	ConfigureUserDataEndpoints(router, handlers.NewUserDataHandler(mockDataService), authMiddleware, adminMiddleware)
+
+	tests := []struct {
+		method, path string
+		userID       uint
+		expected     int
+	}{
+		{"GET", "/me/data", 2, http.StatusOK},
+		{"GET", "/admin/users/7/data", 1, http.StatusOK},
+		{"GET", "/admin/users/7/data", 2, http.StatusForbidden},
+		{"POST", "/admin/users/7/erase", 1, http.StatusNoContent},
+		{"POST", "/admin/users/7/erase", 2, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: tt.userID})
+		assert.NoError(t, err)
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, tt.expected, resp.Code, tt.method+" "+tt.path)
+	}
+	mockDataService.AssertExpectations(t)
+}
+
+func TestConfigureUserImportEndpoints(t *testing.T) {
+	mockImportService := new(mocks.MockUserImportService)
+	mockImportService.On("ImportUsers", models.UserImportCSV, mock.Anything).
+		Return(&models.UserImportResult{Errors: []models.UserImportRowError{}}, nil)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+	authMiddleware := middlewares.TokenAuthMiddleware()
+	adminMiddleware := middlewares.RequirePermission(mockRoleService, models.PermissionAdmin)
+
+	router := gin.Default()
+	ConfigureUserStatusEndpoints(router, handlers.NewUserStatusHandler(new(mocks.MockUserStatusService)), authMiddleware, adminMiddleware)
+	ConfigureUserImportEndpoints(router, handlers.NewUserImportHandler(mockImportService), authMiddleware, adminMiddleware)
+
+	for userID, status := range map[uint]int{1: http.StatusCreated, 2: http.StatusForbidden} {
+		token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: userID})
+		assert.NoError(t, err)
+		req := httptest.NewRequest("POST", "/admin/users/import", bytes.NewBufferString("email,first_name,last_name\n"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/csv")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, status, resp.Code)
+	}
+	mockImportService.AssertExpectations(t)
+}
+
+func TestConfigureInvitationEndpoints(t *testing.T) {
+	mockInvitationService := new(mocks.MockInvitationService)
+	mockInvitationService.On("RevokeInvitation", uint(3)).Return(nil)
+	mockInvitationService.On("AcceptInvitation", models.InvitationAcceptRequest{Token: "token", Password: "correct horse"}).
+		Return(&models.User{ID: 7, Email: "jane@example.com"}, nil)
+	mockRoleService := new(mocks.MockRoleService)
+	mockRoleService.On("HasPermission", uint(1), models.PermissionAdmin).Return(true, nil)
+	mockRoleService.On("HasPermission", uint(2), models.PermissionAdmin).Return(false, nil)
+	authMiddleware := middlewares.TokenAuthMiddleware()
+	adminMiddleware := middlewares.RequirePermission(mockRoleService, models.PermissionAdmin)
+
+	router := gin.Default()
+	ConfigureInvitationEndpoints(router, handlers.NewInvitationHandler(mockInvitationService), authMiddleware, adminMiddleware)
+
+	for userID, status := range map[uint]int{1: http.StatusOK, 2: http.StatusForbidden} {
+		token, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: userID})
+		assert.NoError(t, err)
+		req := httptest.NewRequest("POST", "/admin/invitations/3/revoke", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, status, resp.Code)
+	}
+
+	// accepting needs no token, the invitation token is the credential
+	req := httptest.NewRequest("POST", "/auth/invitations/accept", bytes.NewBufferString(`{"token":"token","password":"correct horse"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	mockInvitationService.AssertExpectations(t)
+}
@@ -5,7 +5,110 @@ import (
 	"github.com/shibbirmcc/user-auth-and-permissions/handlers"
 )
 
-func ConfigureRouteEndpoints(router *gin.Engine, userHandler *handlers.UserHandler) {
-	router.POST("/auth/register", userHandler.RegisterUser)
+// ConfigureRouteEndpoints lets clients retry registrations with an Idempotency-Key, see middlewares.Idempotency
+func ConfigureRouteEndpoints(router *gin.Engine, userHandler *handlers.UserHandler, idempotencyMiddleware gin.HandlerFunc) {
+	router.POST("/auth/register", idempotencyMiddleware, userHandler.RegisterUser)
 	router.POST("/auth/login", userHandler.LoginUser)
 }
+
+func ConfigureOIDCEndpoints(router *gin.Engine, oidcHandler *handlers.OIDCHandler, authMiddleware gin.HandlerFunc) {
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	router.GET("/.well-known/jwks.json", oidcHandler.JWKS)
+	router.POST("/oauth/token", oidcHandler.Token)
+	router.GET("/userinfo", authMiddleware, oidcHandler.UserInfo)
+	router.POST("/userinfo", authMiddleware, oidcHandler.UserInfo)
+}
+
+func ConfigureTokenEndpoints(router *gin.Engine, tokenHandler *handlers.TokenHandler) {
+	router.POST("/oauth/introspect", tokenHandler.Introspect)
+	router.POST("/oauth/revoke", tokenHandler.Revoke)
+}
+
+func ConfigureFederatedEndpoints(router *gin.Engine, federatedHandler *handlers.FederatedHandler) {
+	router.GET("/auth/federated/providers", federatedHandler.Providers)
+	router.GET("/auth/federated/:provider/login", federatedHandler.Login)
+	router.GET("/auth/federated/:provider/callback", federatedHandler.Callback)
+}
+
+func ConfigureSCIMEndpoints(router *gin.Engine, scimHandler *handlers.SCIMHandler, scimAuthMiddleware gin.HandlerFunc) {
+	scim := router.Group("/scim/v2", scimAuthMiddleware)
+	scim.GET("/Users", scimHandler.ListUsers)
+	scim.POST("/Users", scimHandler.CreateUser)
+	scim.GET("/Users/:id", scimHandler.GetUser)
+	scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+	scim.PATCH("/Users/:id", scimHandler.PatchUser)
+	scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+	scim.GET("/Groups", scimHandler.ListGroups)
+	scim.POST("/Groups", scimHandler.CreateGroup)
+	scim.GET("/Groups/:id", scimHandler.GetGroup)
+	scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+	scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+	scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+}
+
+func ConfigurePasswordSetupEndpoints(router *gin.Engine, passwordSetupHandler *handlers.PasswordSetupHandler) {
+	router.POST("/auth/password/setup", passwordSetupHandler.SetupPassword)
+}
+
+// ConfigureDeadLetterEndpoints lets admins inspect and replay deliveries that ran out of attempts
+func ConfigureDeadLetterEndpoints(router *gin.Engine, deadLetterHandler *handlers.DeadLetterHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	deadLetters := router.Group("/admin/deliveries/dead-letters", authMiddleware, adminMiddleware)
+	deadLetters.GET("", deadLetterHandler.ListDeadLetters)
+	deadLetters.POST("/replay", deadLetterHandler.ReplayDeadLetters)
+	deadLetters.POST("/:id/replay", deadLetterHandler.ReplayDeadLetter)
+}
+
+// ConfigureWebhookEndpoints lets admins inspect the log of webhook delivery attempts
+func ConfigureWebhookEndpoints(router *gin.Engine, webhookAttemptHandler *handlers.WebhookAttemptHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	router.GET("/admin/webhooks/attempts", authMiddleware, adminMiddleware, webhookAttemptHandler.ListAttempts)
+}
+
+// ConfigureProfileEndpoints lets authenticated users read and change their own profile
+func ConfigureProfileEndpoints(router *gin.Engine, profileHandler *handlers.ProfileHandler, authMiddleware gin.HandlerFunc) {
+	router.GET("/me", authMiddleware, profileHandler.GetMe)
+	router.PATCH("/me", authMiddleware, profileHandler.UpdateMe)
+}
+
+// ConfigureEmailChangeEndpoints lets authenticated users move to a new email address they confirmed
+func ConfigureEmailChangeEndpoints(router *gin.Engine, emailChangeHandler *handlers.EmailChangeHandler, authMiddleware gin.HandlerFunc) {
+	router.POST("/me/email", authMiddleware, emailChangeHandler.RequestEmailChange)
+	router.POST("/auth/email/confirm", emailChangeHandler.ConfirmEmailChange)
+}
+
+// ConfigureUserDirectoryEndpoints lets admins search, list and export all users
+func ConfigureUserDirectoryEndpoints(router *gin.Engine, userDirectoryHandler *handlers.UserDirectoryHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	users := router.Group("/admin/users", authMiddleware, adminMiddleware)
+	users.GET("", userDirectoryHandler.ListUsers)
+	users.GET("/export", userDirectoryHandler.ExportUsers)
+}
+
+// ConfigureUserStatusEndpoints lets admins move users through their account lifecycle
+func ConfigureUserStatusEndpoints(router *gin.Engine, userStatusHandler *handlers.UserStatusHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	users := router.Group("/admin/users/:id", authMiddleware, adminMiddleware)
+	users.POST("/suspend", userStatusHandler.SuspendUser)
+	users.POST("/disable", userStatusHandler.DisableUser)
+	users.POST("/reactivate", userStatusHandler.ReactivateUser)
+	users.POST("/delete", userStatusHandler.DeleteUser)
+}
+
+// ConfigureUserDataEndpoints serves data access requests to users and admins, and erasure requests to admins
+func ConfigureUserDataEndpoints(router *gin.Engine, userDataHandler *handlers.UserDataHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	router.GET("/me/data", authMiddleware, userDataHandler.ExportMyData)
+	users := router.Group("/admin/users/:id", authMiddleware, adminMiddleware)
+	users.GET("/data", userDataHandler.ExportUserData)
+	users.POST("/erase", userDataHandler.EraseUser)
+}
+
+// ConfigureUserImportEndpoints lets admins register many users at once
+func ConfigureUserImportEndpoints(router *gin.Engine, userImportHandler *handlers.UserImportHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	router.POST("/admin/users/import", authMiddleware, adminMiddleware, userImportHandler.ImportUsers)
+}
+
+// ConfigureInvitationEndpoints lets admins invite users with their roles, and invitees accept the invitation
+func ConfigureInvitationEndpoints(router *gin.Engine, invitationHandler *handlers.InvitationHandler, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	invitations := router.Group("/admin/invitations", authMiddleware, adminMiddleware)
+	invitations.POST("", invitationHandler.CreateInvitation)
+	invitations.GET("", invitationHandler.ListInvitations)
+	invitations.POST("/:id/revoke", invitationHandler.RevokeInvitation)
+	router.POST("/auth/invitations/accept", invitationHandler.AcceptInvitation)
+}
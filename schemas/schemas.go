@@ -0,0 +1,10 @@
+package schemas
+
+import "embed"
+
+// Events holds the JSON Schemas of published events. events/cloudevent.json describes the envelope and
+// events/<type>.v<version>.json the data of an event type in a schema version, its $id is the dataschema
+// attribute of the events it describes.
+//
+//go:embed events
+var Events embed.FS
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+var ErrInvalidCredentials = errors.New("Invalid credentials")
+
+// Authenticator verifies credentials against one identity store and returns the matching local user
+type Authenticator interface {
+	Authenticate(email, password string) (*models.User, *models.UserDetail, error)
+}
+
+// DatabaseAuthenticator checks the password against the bcrypt hash stored in the users table
+type DatabaseAuthenticator struct {
+	dbService IDatabaseOperationService
+}
+
+func NewDatabaseAuthenticator(dbService IDatabaseOperationService) *DatabaseAuthenticator {
+	return &DatabaseAuthenticator{dbService: dbService}
+}
+
+func (a *DatabaseAuthenticator) Authenticate(email, password string) (*models.User, *models.UserDetail, error) {
+	user, err := a.dbService.FindUserByEmail(email)
+	if err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	userDetails, err := a.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, nil, errors.New("Invalid user Id")
+	}
+	return user, userDetails, nil
+}
+
+// createShadowUser creates the local row for a user whose credentials live in an external identity
+// store. The random password is never handed out, so the row cannot be used for a database login.
+// method tells the user.registered event where the user came from.
+func createShadowUser(dbService IDatabaseOperationService, events IEventPublisher, method, email string, userDetail models.UserDetail) (*models.User, *models.UserDetail, error) {
+	_, hashedPassword, err := utils.GetRandomPasswordAndHash()
+	if err != nil {
+		return nil, nil, errors.New("Error while generating temporary password and hash")
+	}
+	if userDetail.FirstName == "" {
+		userDetail.FirstName, _, _ = strings.Cut(email, "@")
+	}
+
+	user := models.User{Email: email, Password: hashedPassword, Status: models.UserStatusActive}
+	if err := dbService.CreateUser(&user, &userDetail); err != nil {
+		return nil, nil, errors.New("error while registering user")
+	}
+	userDetail.UserID = user.ID
+	publishEvent(events, models.EventUserRegistered, &user.ID, models.UserRegisteredData{
+		Email:      user.Email,
+		FirstName:  userDetail.FirstName,
+		MiddleName: userDetail.MiddleName,
+		LastName:   userDetail.LastName,
+		Method:     method,
+	})
+	return &user, &userDetail, nil
+}
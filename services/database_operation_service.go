@@ -1,14 +1,32 @@
 package services
 
 import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
 	"gorm.io/gorm"
 )
 
+const (
+	maxUserAgentLength = 512
+	uniqueViolation    = "23505"
+)
+
 type IDatabaseOperationService interface {
 	CreateUser(user *models.User, userDetail *models.UserDetail) error
+	CreateUserWithOutbox(user *models.User, userDetail *models.UserDetail, message *models.OutboxMessage) error
+	CreateUserWithPasswordSetup(user *models.User, userDetail *models.UserDetail, setupToken *models.PasswordSetupToken, message *models.OutboxMessage) error
 	FindUserByEmail(email string) (*models.User, error)
+	FindUserByID(userID uint) (*models.User, error)
 	FindUserDetailsByUserID(userID uint) (*models.UserDetail, error)
+	ListUsers(offset, limit int) ([]models.User, int64, error)
+	UpdateUser(user *models.User, userDetail *models.UserDetail) error
+	UpdateUserDetails(userDetail *models.UserDetail) error
+	UpdateUserStatus(userID uint, fromStatus, toStatus, reason string) error
+	RecordLogin(userID uint, login models.LoginMetadata) error
+	DeleteUser(userID uint) error
 }
 
 type DatabaseOperationService struct {
@@ -22,16 +40,70 @@ func NewDatabaseOperationService(db *gorm.DB) *DatabaseOperationService {
 func (s *DatabaseOperationService) CreateUser(user *models.User, userDetail *models.UserDetail) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(user).Error; err != nil {
-			return err
+			return emailTakenAs(err)
 		}
 		userDetail.UserID = user.ID
 		return tx.Create(userDetail).Error
 	})
 }
 
+// CreateUserWithOutbox stores the outbox message in the same transaction as the user, so the message
+// exists exactly when the user does
+func (s *DatabaseOperationService) CreateUserWithOutbox(user *models.User, userDetail *models.UserDetail, message *models.OutboxMessage) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return emailTakenAs(err)
+		}
+		userDetail.UserID = user.ID
+		if err := tx.Create(userDetail).Error; err != nil {
+			return err
+		}
+		message.UserID = &user.ID
+		return tx.Create(message).Error
+	})
+}
+
+// CreateUserWithPasswordSetup also stores the one-time token the outbox message links to
+func (s *DatabaseOperationService) CreateUserWithPasswordSetup(user *models.User, userDetail *models.UserDetail, setupToken *models.PasswordSetupToken, message *models.OutboxMessage) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return emailTakenAs(err)
+		}
+		userDetail.UserID = user.ID
+		if err := tx.Create(userDetail).Error; err != nil {
+			return err
+		}
+		setupToken.UserID = user.ID
+		if err := tx.Create(setupToken).Error; err != nil {
+			return err
+		}
+		message.UserID = &user.ID
+		return tx.Create(message).Error
+	})
+}
+
+// emailTakenAs returns ErrEmailTaken when creating a user failed because the email address is in use. The
+// unique index on LOWER(email) makes that the case for every address that only differs in case as well.
+func emailTakenAs(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.TableName == "users" {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// FindUserByEmail ignores the case of the address, which is unique
 func (s *DatabaseOperationService) FindUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := s.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *DatabaseOperationService) FindUserByID(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -44,3 +116,82 @@ func (s *DatabaseOperationService) FindUserDetailsByUserID(userID uint) (*models
 	}
 	return &userDetails, nil
 }
+
+// ListUsers leaves out erased users
+func (s *DatabaseOperationService) ListUsers(offset, limit int) ([]models.User, int64, error) {
+	var total int64
+	if err := s.db.Model(&models.User{}).Where("erased_at IS NULL").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if err := s.db.Where("erased_at IS NULL").Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *DatabaseOperationService) UpdateUser(user *models.User, userDetail *models.UserDetail) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// the status only changes through UpdateUserStatus, the last login through RecordLogin
+		err := tx.Omit("status", "status_reason", "status_changed_at", "last_login_at", "last_login_ip", "last_login_user_agent").
+			Save(user).Error
+		if err != nil {
+			return err
+		}
+		userDetail.UserID = user.ID
+		return tx.Save(userDetail).Error
+	})
+}
+
+// UpdateUserDetails saves the names and preferred locale of an existing user, it fails with
+// gorm.ErrRecordNotFound when the user has no details
+func (s *DatabaseOperationService) UpdateUserDetails(userDetail *models.UserDetail) error {
+	userDetail.UpdatedAt = time.Now()
+	result := s.db.Model(&models.UserDetail{}).Where("user_id = ?", userDetail.UserID).
+		Select("firstname", "middlename", "lastname", "preferred_locale", "updated_at").
+		Updates(userDetail)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateUserStatus moves the user from fromStatus to toStatus, it fails with gorm.ErrRecordNotFound when
+// the user does not exist or has another status by now
+func (s *DatabaseOperationService) UpdateUserStatus(userID uint, fromStatus, toStatus, reason string) error {
+	result := s.db.Model(&models.User{}).Where("id = ? AND status = ?", userID, fromStatus).
+		Updates(map[string]any{"status": toStatus, "status_reason": reason, "status_changed_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordLogin stores the time and client of a successful login as the user's last login. The user agent is
+// cut to the 512 characters the column holds.
+func (s *DatabaseOperationService) RecordLogin(userID uint, login models.LoginMetadata) error {
+	userAgent := login.UserAgent
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+	return s.db.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]any{
+		"last_login_at":         time.Now(),
+		"last_login_ip":         login.IPAddress,
+		"last_login_user_agent": userAgent,
+	}).Error
+}
+
+func (s *DatabaseOperationService) DeleteUser(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserDetail{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"log"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
@@ -68,6 +70,11 @@ func TestDatabaseOperationService_FindUserByEmail(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, user.Password, foundUser.Password)
 
+		// the case of the address does not matter
+		foundUser, err = DBOperationService.FindUserByEmail(strings.ToUpper(mocks.TestUserEmail))
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, foundUser.ID)
+
 		sqlDB, err := DBOperationService.db.DB()
 		if err != nil {
 			log.Printf("Failed to connect to database for migrations: %v", err)
@@ -82,6 +89,38 @@ func TestDatabaseOperationService_FindUserByEmail(t *testing.T) {
 	})
 }
 
+func TestDatabaseOperationService_FindUserByID(t *testing.T) {
+	t.Run("finds a user by id", func(t *testing.T) {
+		user := &models.User{
+			Email:    mocks.TestUserEmail,
+			Password: mocks.TestUserPasswordHash,
+		}
+		userDetails := &models.UserDetail{
+			FirstName: mocks.TestUserFirstName,
+			LastName:  mocks.TestUserLastName,
+		}
+
+		err := DBOperationService.CreateUser(user, userDetails)
+		require.NoError(t, err, "error should be nil when creating user and user details")
+
+		foundUser, err := DBOperationService.FindUserByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, foundUser.Email)
+
+		sqlDB, err := DBOperationService.db.DB()
+		if err != nil {
+			log.Printf("Failed to connect to database for migrations: %v", err)
+		}
+		tests.DeleteTestData(sqlDB)
+	})
+
+	t.Run("returns error when user not found", func(t *testing.T) {
+		_, err := DBOperationService.FindUserByID(9999)
+		assert.Error(t, err)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+}
+
 func TestDatabaseOperationService_FindUserDetailsByUserID(t *testing.T) {
 
 	t.Run("finds user details by user ID", func(t *testing.T) {
@@ -111,3 +150,162 @@ func TestDatabaseOperationService_FindUserDetailsByUserID(t *testing.T) {
 		assert.Equal(t, gorm.ErrRecordNotFound, err)
 	})
 }
+
+func TestDatabaseOperationService_UpdateAndDeleteUser(t *testing.T) {
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	userDetails := &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	require.NoError(t, DBOperationService.CreateUser(user, userDetails))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+
+	users, total, err := DBOperationService.ListUsers(0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, users, 1)
+
+	user.Email = "renamed@testmail.com"
+	require.NoError(t, DBOperationService.UpdateUser(user, &models.UserDetail{FirstName: "Renamed", LastName: mocks.TestUserLastName}))
+	foundUser, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed@testmail.com", foundUser.Email)
+	foundDetails, err := DBOperationService.FindUserDetailsByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", foundDetails.FirstName)
+
+	require.NoError(t, DBOperationService.DeleteUser(user.ID))
+	_, err = DBOperationService.FindUserByID(user.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = DBOperationService.FindUserDetailsByUserID(user.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDatabaseOperationService_UpdateUserDetails(t *testing.T) {
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	userDetails := &models.UserDetail{FirstName: mocks.TestUserFirstName, MiddleName: "Q", LastName: mocks.TestUserLastName}
+	require.NoError(t, DBOperationService.CreateUser(user, userDetails))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+
+	// empty values are written too, so a middle name can be removed
+	require.NoError(t, DBOperationService.UpdateUserDetails(&models.UserDetail{UserID: user.ID, FirstName: "Jane", LastName: "Smith", PreferredLocale: "de"}))
+	foundDetails, err := DBOperationService.FindUserDetailsByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserDetail{UserID: user.ID, FirstName: "Jane", LastName: "Smith", PreferredLocale: "de"},
+		models.UserDetail{UserID: foundDetails.UserID, FirstName: foundDetails.FirstName, MiddleName: foundDetails.MiddleName, LastName: foundDetails.LastName, PreferredLocale: foundDetails.PreferredLocale})
+	assert.True(t, foundDetails.UpdatedAt.After(foundDetails.CreatedAt))
+
+	err = DBOperationService.UpdateUserDetails(&models.UserDetail{UserID: user.ID + 1, FirstName: "Jane", LastName: "Smith"})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestDatabaseOperationService_RecordLogin(t *testing.T) {
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName}))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	created, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, created.LastLoginAt)
+
+	userAgent := strings.Repeat("ü", 600)
+	require.NoError(t, DBOperationService.RecordLogin(user.ID, models.LoginMetadata{IPAddress: "2001:db8::1", UserAgent: userAgent}))
+
+	foundUser, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, foundUser.LastLoginAt)
+	assert.Equal(t, "2001:db8::1", foundUser.LastLoginIP)
+	// the user agent is cut to the column size, a login is not a change of the user
+	assert.Equal(t, userAgent[:2*512], foundUser.LastLoginUserAgent)
+	assert.Equal(t, created.UpdatedAt, foundUser.UpdatedAt)
+
+	// saving the user does not overwrite the last login
+	foundUser.LastLoginIP = ""
+	require.NoError(t, DBOperationService.UpdateUser(foundUser, &models.UserDetail{FirstName: mocks.TestUserFirstName}))
+	foundUser, err = DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", foundUser.LastLoginIP)
+}
+
+func TestDatabaseOperationService_UpdateUserStatus(t *testing.T) {
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName}))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+
+	foundUser, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusActive, foundUser.Status)
+	assert.Nil(t, foundUser.StatusChangedAt)
+
+	require.NoError(t, DBOperationService.UpdateUserStatus(user.ID, models.UserStatusActive, models.UserStatusSuspended, "unpaid invoice"))
+	foundUser, err = DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusSuspended, foundUser.Status)
+	assert.Equal(t, "unpaid invoice", foundUser.StatusReason)
+	assert.NotNil(t, foundUser.StatusChangedAt)
+
+	// the user is no longer active, and saving a stale copy keeps the status
+	err = DBOperationService.UpdateUserStatus(user.ID, models.UserStatusActive, models.UserStatusDisabled, "")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	require.NoError(t, DBOperationService.UpdateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName}))
+	foundUser, err = DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusSuspended, foundUser.Status)
+}
+
+func TestDatabaseOperationService_CreateUserWithOutbox(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	userDetails := &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	message := &models.OutboxMessage{MessageType: models.OutboxPasswordDelivery, Payload: `{"email":"user@testmail.com"}`, NextAttemptAt: time.Now()}
+	require.NoError(t, DBOperationService.CreateUserWithOutbox(user, userDetails, message))
+	assert.NotZero(t, message.ID)
+	require.NotNil(t, message.UserID)
+	assert.Equal(t, user.ID, *message.UserID)
+	var stored models.OutboxMessage
+	require.NoError(t, DBOperationService.db.First(&stored, message.ID).Error)
+	// the database assigns an event ID when the message has none
+	assert.Len(t, stored.EventID, 36)
+
+	// a failing message rolls the user back with it
+	rolledBack := &models.User{Email: "other@testmail.com", Password: mocks.TestUserPasswordHash}
+	invalid := &models.OutboxMessage{MessageType: models.OutboxPasswordDelivery, Payload: "not json", NextAttemptAt: time.Now()}
+	assert.Error(t, DBOperationService.CreateUserWithOutbox(rolledBack, &models.UserDetail{FirstName: "Other"}, invalid))
+	_, err = DBOperationService.FindUserByEmail("other@testmail.com")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// an address in use is told apart from other failures
+	duplicate := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	another := &models.OutboxMessage{MessageType: models.OutboxPasswordDelivery, Payload: `{}`, NextAttemptAt: time.Now()}
+	assert.ErrorIs(t, DBOperationService.CreateUserWithOutbox(duplicate, &models.UserDetail{FirstName: "Other"}, another), ErrEmailTaken)
+
+	var count int64
+	require.NoError(t, DBOperationService.db.Model(&models.OutboxMessage{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDatabaseOperationService_CreateUserWithPasswordSetup(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	userDetails := &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	setupToken := &models.PasswordSetupToken{TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	message := &models.OutboxMessage{MessageType: models.OutboxPasswordSetupLink, Payload: `{}`, NextAttemptAt: time.Now()}
+	require.NoError(t, DBOperationService.CreateUserWithPasswordSetup(user, userDetails, setupToken, message))
+
+	var stored models.PasswordSetupToken
+	require.NoError(t, DBOperationService.db.Where("token_hash = ?", "hash").First(&stored).Error)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.NotZero(t, message.ID)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEmailTaken              = errors.New("email address is already in use")
+	ErrEmailUnchanged          = errors.New("new email address is the current one")
+	ErrInvalidEmailChangeToken = errors.New("Invalid or expired email change token")
+	ErrEmailChangeUnavailable  = errors.New("email changes cannot be delivered by the configured password delivery")
+)
+
+type IEmailChangeService interface {
+	RequestEmailChange(userID uint, input models.EmailChangeRequest) error
+	ConfirmEmailChange(token string) error
+}
+
+// EmailChangeService moves a user to a new email address once they proved they can receive mail there.
+// The confirmation and the notice to the current address go through the outbox like onboarding messages.
+type EmailChangeService struct {
+	db     *gorm.DB
+	config models.EmailChangeConfig
+	events IEventPublisher
+}
+
+// NewEmailChangeService publishes user.email_changed once the new address is confirmed, not when it is requested
+func NewEmailChangeService(db *gorm.DB, config models.EmailChangeConfig, events IEventPublisher) *EmailChangeService {
+	return &EmailChangeService{db: db, config: config, events: events}
+}
+
+// RequestEmailChange checks the current password and sends a token to the new address. A new request
+// replaces the pending one, the address itself stays unchanged until the token is confirmed.
+func (s *EmailChangeService) RequestEmailChange(userID uint, input models.EmailChangeRequest) error {
+	if s.config.Unavailable {
+		return ErrEmailChangeUnavailable
+	}
+	newEmail := strings.TrimSpace(input.NewEmail)
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return notFoundAs(err, ErrProfileNotFound)
+	}
+	if !utils.CheckPasswordHash(input.CurrentPassword, user.Password) {
+		return ErrInvalidCredentials
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrEmailUnchanged
+	}
+	if taken, err := emailTaken(s.db, newEmail); err != nil || taken {
+		if err != nil {
+			return err
+		}
+		return ErrEmailTaken
+	}
+	var userDetail models.UserDetail
+	if err := s.db.Where("user_id = ?", userID).First(&userDetail).Error; err != nil {
+		return notFoundAs(err, ErrProfileNotFound)
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	confirmation := models.EmailChangeConfirmation{
+		Email:         newEmail,
+		PreviousEmail: user.Email,
+		FirstName:     userDetail.FirstName,
+		MiddleName:    userDetail.MiddleName,
+		LastName:      userDetail.LastName,
+		Token:         token,
+		ExpiresAt:     time.Now().Add(s.config.TokenTTL),
+		Locale:        userDetail.PreferredLocale,
+	}
+	if s.config.ConfirmURL != "" {
+		if confirmation.ConfirmURL, err = passwordSetupURL(s.config.ConfirmURL, token); err != nil {
+			return err
+		}
+	}
+	confirmationMessage, err := newOutboxMessage(models.OutboxEmailChangeConfirmation, confirmation)
+	if err != nil {
+		return err
+	}
+	noticeMessage, err := newOutboxMessage(models.OutboxEmailChangeNotice, models.EmailChangeNotice{
+		Email:       user.Email,
+		NewEmail:    newEmail,
+		FirstName:   userDetail.FirstName,
+		MiddleName:  userDetail.MiddleName,
+		LastName:    userDetail.LastName,
+		RequestedAt: time.Now(),
+		Locale:      userDetail.PreferredLocale,
+	})
+	if err != nil {
+		return err
+	}
+	confirmationMessage.UserID = &userID
+	noticeMessage.UserID = &userID
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailChangeToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("expires_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.EmailChangeToken{
+			TokenHash: utils.HashToken(token),
+			UserID:    userID,
+			NewEmail:  newEmail,
+			ExpiresAt: confirmation.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create([]*models.OutboxMessage{confirmationMessage, noticeMessage}).Error
+	})
+}
+
+// ConfirmEmailChange swaps the address of the user the token was issued to, uses the token up and
+// revokes the access tokens that still carry the previous address
+func (s *EmailChangeService) ConfirmEmailChange(token string) error {
+	var changeToken models.EmailChangeToken
+	var previousEmail string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+			First(&changeToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidEmailChangeToken
+		}
+		if err != nil {
+			return err
+		}
+		// somebody may have registered the address since the change was requested
+		if taken, err := emailTaken(tx, changeToken.NewEmail); err != nil || taken {
+			if err != nil {
+				return err
+			}
+			return ErrEmailTaken
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, changeToken.UserID).Error; err != nil {
+			return notFoundAs(err, ErrProfileNotFound)
+		}
+		previousEmail = user.Email
+		if err := tx.Model(&user).Updates(map[string]any{"email": changeToken.NewEmail, "email_verified_at": time.Now()}).Error; err != nil {
+			return emailTakenAs(err)
+		}
+		if err := tx.Model(&changeToken).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeAccessTokenEmail(tx, user.ID, previousEmail)
+	})
+	if err != nil {
+		return err
+	}
+	publishEvent(s.events, models.EventUserEmailChanged, &changeToken.UserID, models.UserEmailChangedData{
+		Email:         changeToken.NewEmail,
+		PreviousEmail: previousEmail,
+	})
+	return nil
+}
+
+// emailTaken reports whether a user already has the address, ignoring case
+func emailTaken(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).Where("LOWER(email) = LOWER(?)", email).Count(&count).Error
+	return count > 0, err
+}
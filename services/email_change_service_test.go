@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailChangeService(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	events := NewInMemoryEventPublisher()
+	emailChangeService := NewEmailChangeService(DBOperationService.db, models.EmailChangeConfig{
+		ConfirmURL: "https://app.example.com/confirm-email",
+		TokenTTL:   time.Hour,
+	}, events)
+
+	hashedPassword, err := utils.HashPassword("current-password")
+	require.NoError(t, err)
+	user := &models.User{Email: mocks.TestUserEmail, Password: hashedPassword}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName, PreferredLocale: "de"}))
+	other := &models.User{Email: "taken@testmail.com", Password: hashedPassword}
+	require.NoError(t, DBOperationService.CreateUser(other, &models.UserDetail{FirstName: "Other"}))
+
+	assert.Equal(t, ErrInvalidCredentials, emailChangeService.RequestEmailChange(user.ID, models.EmailChangeRequest{NewEmail: "new@testmail.com", CurrentPassword: "wrong"}))
+	assert.Equal(t, ErrEmailUnchanged, emailChangeService.RequestEmailChange(user.ID, models.EmailChangeRequest{NewEmail: "USER1@testmail.com", CurrentPassword: "current-password"}))
+	assert.Equal(t, ErrEmailTaken, emailChangeService.RequestEmailChange(user.ID, models.EmailChangeRequest{NewEmail: "Taken@testmail.com", CurrentPassword: "current-password"}))
+	assert.Equal(t, ErrProfileNotFound, emailChangeService.RequestEmailChange(user.ID+100, models.EmailChangeRequest{NewEmail: "new@testmail.com", CurrentPassword: "current-password"}))
+
+	require.NoError(t, emailChangeService.RequestEmailChange(user.ID, models.EmailChangeRequest{NewEmail: "new@testmail.com", CurrentPassword: "current-password"}))
+	var messages []models.OutboxMessage
+	require.NoError(t, DBOperationService.db.Order("id").Find(&messages).Error)
+	require.Len(t, messages, 2)
+	assert.Equal(t, models.OutboxEmailChangeConfirmation, messages[0].MessageType)
+	assert.Equal(t, models.OutboxEmailChangeNotice, messages[1].MessageType)
+	var confirmation models.EmailChangeConfirmation
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Payload), &confirmation))
+	assert.Equal(t, "new@testmail.com", confirmation.Email)
+	assert.Equal(t, mocks.TestUserEmail, confirmation.PreviousEmail)
+	assert.Equal(t, "de", confirmation.Locale)
+	assert.Equal(t, "https://app.example.com/confirm-email?token="+confirmation.Token, confirmation.ConfirmURL)
+	var notice models.EmailChangeNotice
+	require.NoError(t, json.Unmarshal([]byte(messages[1].Payload), &notice))
+	assert.Equal(t, mocks.TestUserEmail, notice.Email)
+	assert.Equal(t, "new@testmail.com", notice.NewEmail)
+
+	// the address only changes on confirmation
+	unchanged, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, mocks.TestUserEmail, unchanged.Email)
+
+	// a new request replaces the pending token
+	require.NoError(t, emailChangeService.RequestEmailChange(user.ID, models.EmailChangeRequest{NewEmail: "newer@testmail.com", CurrentPassword: "current-password"}))
+	assert.Equal(t, ErrInvalidEmailChangeToken, emailChangeService.ConfirmEmailChange(confirmation.Token))
+	require.NoError(t, DBOperationService.db.Order("id").Find(&messages).Error)
+	require.Len(t, messages, 4)
+	require.NoError(t, json.Unmarshal([]byte(messages[2].Payload), &confirmation))
+
+	issuedAt := time.Now().Add(-time.Second)
+	require.NoError(t, emailChangeService.ConfirmEmailChange(confirmation.Token))
+	changed, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "newer@testmail.com", changed.Email)
+	changedEvents := events.EventsOfType(models.EventUserEmailChanged)
+	require.Len(t, changedEvents, 1)
+
+	tokenStore := NewTokenStoreService(DBOperationService.db)
+	revoked, err := tokenStore.IsAccessTokenEmailRevoked(user.ID, mocks.TestUserEmail, issuedAt)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = tokenStore.IsAccessTokenEmailRevoked(user.ID, "newer@testmail.com", issuedAt)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// a token works only once
+	assert.Equal(t, ErrInvalidEmailChangeToken, emailChangeService.ConfirmEmailChange(confirmation.Token))
+}
+
+func TestEmailChangeService_Unavailable(t *testing.T) {
+	emailChangeService := NewEmailChangeService(nil, models.EmailChangeConfig{Unavailable: true}, nil)
+
+	err := emailChangeService.RequestEmailChange(mocks.TestUserId, models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "secret"})
+
+	assert.ErrorIs(t, err, ErrEmailChangeUnavailable)
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/templates"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+const (
+	EmailCredentials        = "credentials"
+	EmailPasswordSetup      = "password_setup"
+	EmailChangeConfirmation = "email_change_confirmation"
+	EmailChangeNotice       = "email_change_notice"
+	EmailInvitation         = "invitation"
+
+	defaultLocale = "en"
+)
+
+type RenderedEmail = models.RenderedMessage
+
+type emailTemplate struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// EmailTemplates renders emails from email/<type>.subject.tmpl, email/<type>.txt.tmpl and the optional
+// email/<type>.html.tmpl. Text parts use text/template, the HTML part html/template so values are escaped.
+// Translations live in email/<locale>/ with the same file names, the templates directly in email/ are the
+// fallback for every locale.
+type EmailTemplates struct {
+	// templates holds the message types of each locale, the fallback ones under the empty locale
+	templates map[string]map[string]emailTemplate
+	// DefaultLocale is the locale of the fallback templates, tried before them for users whose locale has
+	// no translation
+	DefaultLocale string
+}
+
+// LoadEmailTemplates parses every message type found in files, so a broken template fails at startup
+func LoadEmailTemplates(files fs.FS) (*EmailTemplates, error) {
+	templates := &EmailTemplates{templates: map[string]map[string]emailTemplate{}}
+	if err := templates.loadLocale(files, "email", ""); err != nil {
+		return nil, err
+	}
+	if len(templates.templates[""]) == 0 {
+		return nil, errors.New("no email templates found")
+	}
+
+	localeSubjects, err := fs.Glob(files, "email/*/*.subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, subjectFile := range localeSubjects {
+		dir := path.Dir(subjectFile)
+		locale, err := utils.NormalizeLocale(path.Base(dir))
+		if err != nil {
+			return nil, fmt.Errorf("invalid email template directory %s: %v", dir, err)
+		}
+		if _, loaded := templates.templates[locale]; loaded {
+			continue
+		}
+		if err := templates.loadLocale(files, dir, locale); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// LoadEmailTemplatesFromEnv loads the templates from TEMPLATE_DIR, or from SMTP_TEMPLATE_DIR which it
+// replaces, and uses the built-in ones when neither is set. DEFAULT_LOCALE is the locale of the fallback
+// templates, en by default.
+func LoadEmailTemplatesFromEnv() (*EmailTemplates, error) {
+	var files fs.FS = templates.Email
+	if dir := os.Getenv("TEMPLATE_DIR"); dir != "" {
+		files = os.DirFS(dir)
+	} else if dir := os.Getenv("SMTP_TEMPLATE_DIR"); dir != "" {
+		files = os.DirFS(dir)
+	}
+	locale := defaultLocale
+	if value := os.Getenv("DEFAULT_LOCALE"); value != "" {
+		normalized, err := utils.NormalizeLocale(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT_LOCALE: %v", err)
+		}
+		locale = normalized
+	}
+
+	emailTemplates, err := LoadEmailTemplates(files)
+	if err != nil {
+		return nil, err
+	}
+	emailTemplates.DefaultLocale = locale
+	return emailTemplates, nil
+}
+
+func (t *EmailTemplates) loadLocale(files fs.FS, dir string, locale string) error {
+	subjects, err := fs.Glob(files, dir+"/*.subject.tmpl")
+	if err != nil {
+		return err
+	}
+	loadedTypes := map[string]emailTemplate{}
+	for _, subjectFile := range subjects {
+		messageType := strings.TrimSuffix(path.Base(subjectFile), ".subject.tmpl")
+		if _, fallback := t.templates[""][messageType]; locale != "" && !fallback {
+			return fmt.Errorf("email template %s for %s has no fallback in email/", messageType, locale)
+		}
+		loaded, err := loadEmailTemplate(files, dir+"/"+messageType)
+		if err != nil {
+			return fmt.Errorf("%v in %s", err, dir)
+		}
+		loadedTypes[messageType] = loaded
+	}
+	t.templates[locale] = loadedTypes
+	return nil
+}
+
+func loadEmailTemplate(files fs.FS, base string) (emailTemplate, error) {
+	var loaded emailTemplate
+	var err error
+	messageType := path.Base(base)
+	if loaded.subject, err = texttemplate.ParseFS(files, base+".subject.tmpl"); err != nil {
+		return loaded, fmt.Errorf("invalid subject template for %s: %v", messageType, err)
+	}
+	if loaded.text, err = texttemplate.ParseFS(files, base+".txt.tmpl"); err != nil {
+		return loaded, fmt.Errorf("invalid text template for %s: %v", messageType, err)
+	}
+	if _, statErr := fs.Stat(files, base+".html.tmpl"); statErr == nil {
+		if loaded.html, err = htmltemplate.ParseFS(files, base+".html.tmpl"); err != nil {
+			return loaded, fmt.Errorf("invalid html template for %s: %v", messageType, err)
+		}
+	}
+	return loaded, nil
+}
+
+// Render renders the message type in the default locale
+func (t *EmailTemplates) Render(messageType string, data any) (*RenderedEmail, error) {
+	return t.RenderLocalized(messageType, "", data)
+}
+
+// RenderLocalized renders the message type in the best match for locale: the locale itself, its language,
+// the default locale and its language, and finally the fallback templates. An empty or invalid locale
+// starts with the default one.
+func (t *EmailTemplates) RenderLocalized(messageType string, locale string, data any) (*RenderedEmail, error) {
+	usedLocale, loaded, ok := t.lookup(messageType, locale)
+	if !ok {
+		return nil, fmt.Errorf("no email template for %s", messageType)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := loaded.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := loaded.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if loaded.html != nil {
+		if err := loaded.html.Execute(&html, data); err != nil {
+			return nil, err
+		}
+	}
+	return &RenderedEmail{
+		Locale: usedLocale,
+		// a subject is a single header line
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *EmailTemplates) lookup(messageType string, locale string) (string, emailTemplate, bool) {
+	var candidates []string
+	for _, preferred := range []string{locale, t.DefaultLocale} {
+		if normalized, err := utils.NormalizeLocale(preferred); err == nil {
+			candidates = append(candidates, normalized, utils.BaseLocale(normalized))
+		}
+	}
+	for _, candidate := range append(candidates, "") {
+		if loaded, ok := t.templates[candidate][messageType]; ok {
+			if candidate == "" {
+				candidate = t.DefaultLocale
+			}
+			return candidate, loaded, true
+		}
+	}
+	return "", emailTemplate{}, false
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailTemplates_RenderTextOnly(t *testing.T) {
+	files := fstest.MapFS{
+		"email/notice.subject.tmpl": {Data: []byte("Hello\n  {{.Name}}\n")},
+		"email/notice.txt.tmpl":     {Data: []byte("Dear {{.Name}}")},
+	}
+	emailTemplates, err := LoadEmailTemplates(files)
+	require.NoError(t, err)
+
+	rendered, err := emailTemplates.Render("notice", map[string]string{"Name": "Jane"})
+
+	require.NoError(t, err)
+	assert.Equal(t, &RenderedEmail{Subject: "Hello Jane", Text: "Dear Jane"}, rendered)
+}
+
+func TestEmailTemplates_UnknownMessageType(t *testing.T) {
+	emailTemplates, err := LoadEmailTemplates(fstest.MapFS{
+		"email/welcome.subject.tmpl": {Data: []byte("Welcome")},
+		"email/welcome.txt.tmpl":     {Data: []byte("Welcome")},
+	})
+	require.NoError(t, err)
+
+	_, err = emailTemplates.Render("notice", nil)
+
+	assert.EqualError(t, err, "no email template for notice")
+}
+
+func TestLoadEmailTemplates_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"no templates", fstest.MapFS{}},
+		{"missing text", fstest.MapFS{"email/notice.subject.tmpl": {Data: []byte("Hello")}}},
+		{"broken html", fstest.MapFS{
+			"email/notice.subject.tmpl": {Data: []byte("Hello")},
+			"email/notice.txt.tmpl":     {Data: []byte("Hello")},
+			"email/notice.html.tmpl":    {Data: []byte("{{.Name")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emailTemplates, err := LoadEmailTemplates(tt.files)
+			assert.Error(t, err)
+			assert.Nil(t, emailTemplates)
+		})
+	}
+}
+
+func TestEmailTemplates_RenderLocalized(t *testing.T) {
+	emailTemplates, err := LoadEmailTemplates(fstest.MapFS{
+		"email/notice.subject.tmpl":       {Data: []byte("Hello")},
+		"email/notice.txt.tmpl":           {Data: []byte("Dear {{.Name}}")},
+		"email/de/notice.subject.tmpl":    {Data: []byte("Hallo")},
+		"email/de/notice.txt.tmpl":        {Data: []byte("Liebe {{.Name}}")},
+		"email/fr/notice.subject.tmpl":    {Data: []byte("Bonjour")},
+		"email/fr/notice.txt.tmpl":        {Data: []byte("Chère {{.Name}}")},
+		"email/pt_br/notice.subject.tmpl": {Data: []byte("Olá")},
+		"email/pt_br/notice.txt.tmpl":     {Data: []byte("Querida {{.Name}}")},
+		"email/welcome.subject.tmpl":      {Data: []byte("Welcome")},
+		"email/welcome.txt.tmpl":          {Data: []byte("Welcome")},
+		"email/pt_br/notice.html.tmpl":    {Data: []byte("<p>Querida {{.Name}}</p>")},
+	})
+	require.NoError(t, err)
+	emailTemplates.DefaultLocale = "fr"
+	data := map[string]string{"Name": "Jane"}
+
+	tests := []struct {
+		locale, messageType, expectedLocale, expectedSubject string
+	}{
+		{"de", "notice", "de", "Hallo"},
+		{"de-CH", "notice", "de", "Hallo"},
+		{"PT-br", "notice", "pt-BR", "Olá"},
+		{"es", "notice", "fr", "Bonjour"},
+		{"", "notice", "fr", "Bonjour"},
+		{"not a locale", "notice", "fr", "Bonjour"},
+		{"de", "welcome", "fr", "Welcome"},
+	}
+	for _, tt := range tests {
+		rendered, err := emailTemplates.RenderLocalized(tt.messageType, tt.locale, data)
+		require.NoError(t, err, tt.locale)
+		assert.Equal(t, tt.expectedLocale, rendered.Locale, tt.locale)
+		assert.Equal(t, tt.expectedSubject, rendered.Subject, tt.locale)
+	}
+
+	rendered, err := emailTemplates.RenderLocalized("notice", "pt-BR", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Querida Jane</p>", rendered.HTML)
+}
+
+func TestLoadEmailTemplates_InvalidTranslations(t *testing.T) {
+	fallback := fstest.MapFS{
+		"email/notice.subject.tmpl": {Data: []byte("Hello")},
+		"email/notice.txt.tmpl":     {Data: []byte("Hello")},
+	}
+	tests := []struct {
+		name          string
+		files         map[string]string
+		expectedError string
+	}{
+		{"invalid locale", map[string]string{
+			"email/german language/notice.subject.tmpl": "Hallo",
+			"email/german language/notice.txt.tmpl":     "Hallo",
+		}, "invalid email template directory email/german language: invalid locale german language"},
+		{"no fallback", map[string]string{
+			"email/de/welcome.subject.tmpl": "Willkommen",
+			"email/de/welcome.txt.tmpl":     "Willkommen",
+		}, "email template welcome for de has no fallback in email/"},
+		{"missing text", map[string]string{
+			"email/de/notice.subject.tmpl": "Hallo",
+		}, "invalid text template for notice: template: pattern matches no files: `email/de/notice.txt.tmpl` in email/de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := fstest.MapFS{}
+			for name, file := range fallback {
+				files[name] = file
+			}
+			for name, content := range tt.files {
+				files[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+			emailTemplates, err := LoadEmailTemplates(files)
+			assert.EqualError(t, err, tt.expectedError)
+			assert.Nil(t, emailTemplates)
+		})
+	}
+}
+
+func TestLoadEmailTemplatesFromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_LOCALE", "en_GB")
+	emailTemplates, err := LoadEmailTemplatesFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "en-GB", emailTemplates.DefaultLocale)
+
+	// the built-in templates come with a German translation of every message type
+	for messageType, data := range map[string]any{
+		EmailCredentials:   models.UserCredentials{FirstName: "Jana"},
+		EmailPasswordSetup: models.PasswordSetupLink{FirstName: "Jana"},
+	} {
+		rendered, err := emailTemplates.RenderLocalized(messageType, "de", data)
+		require.NoError(t, err)
+		assert.Equal(t, "de", rendered.Locale)
+		assert.Contains(t, rendered.Text, "Hallo Jana,")
+	}
+
+	t.Setenv("DEFAULT_LOCALE", "nonsense-locale-value")
+	_, err = LoadEmailTemplatesFromEnv()
+	assert.EqualError(t, err, "invalid DEFAULT_LOCALE: invalid locale nonsense-locale-value")
+}
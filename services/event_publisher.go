@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+// IEventPublisher publishes the domain events other services react to, such as user.registered or
+// role.assigned. Every service takes one as an optional dependency, a nil publisher means no events.
+type IEventPublisher interface {
+	Publish(event models.Event) error
+}
+
+// eventPublisherCloser is implemented by publishers that deliver events in the background
+type eventPublisherCloser interface {
+	Close(ctx context.Context) error
+}
+
+// CloseEventPublisher waits until the events handed to publisher were delivered, or until the context is
+// done. Publishers that deliver before Publish returns have nothing to wait for.
+func CloseEventPublisher(ctx context.Context, publisher IEventPublisher) error {
+	if closer, ok := publisher.(eventPublisherCloser); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// publishEvent is how services emit domain events once a change is made. A failure is logged and does not
+// undo the change, so consumers can miss an event but never see one for a change that did not happen.
+// Nothing is emitted when publisher is nil.
+func publishEvent(publisher IEventPublisher, eventType string, userID *uint, data any) {
+	if publisher == nil {
+		return
+	}
+	event, err := NewEvent(eventType, userID, data)
+	if err != nil {
+		log.Printf("Failed to build %s event: %v", eventType, err)
+		return
+	}
+	if err := publisher.Publish(event); err != nil {
+		log.Printf("Failed to publish %s event %s: %v", eventType, event.ID, err)
+	}
+}
+
+// defaultKafkaEventQueueSize is how many domain events wait for the Kafka writer when
+// KAFKA_EVENTS_QUEUE_SIZE is not set
+const defaultKafkaEventQueueSize = 1000
+
+// KafkaEventPublisher writes domain events to their own topic in the same format as the password deliveries
+type KafkaEventPublisher struct {
+	Producer MessageProducer
+	Topic    string
+
+	// queue holds the domain events until the writer sends them, it is closed by Close. A single writer
+	// keeps the events of a user in order.
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan models.Event
+	written chan struct{}
+}
+
+// NewKafkaEventPublisher publishes to KAFKA_EVENTS_TOPIC on KAFKA_BROKERS, with up to
+// KAFKA_EVENTS_QUEUE_SIZE events waiting to be written
+func NewKafkaEventPublisher() (*KafkaEventPublisher, error) {
+	topic := os.Getenv("KAFKA_EVENTS_TOPIC")
+	if topic == "" {
+		return nil, errors.New("no kafka events topic found in environment variable")
+	}
+	queueSize, err := positiveIntFromEnv("KAFKA_EVENTS_QUEUE_SIZE", defaultKafkaEventQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := newKafkaWriter(topic)
+	if err != nil {
+		return nil, err
+	}
+	return NewKafkaEventPublisherWithProducer(writer, topic, queueSize), nil
+}
+
+// NewKafkaEventPublisherWithProducer starts the writer that sends the queued domain events to the topic,
+// Close stops it
+func NewKafkaEventPublisherWithProducer(producer MessageProducer, topic string, queueSize int) *KafkaEventPublisher {
+	if queueSize <= 0 {
+		queueSize = defaultKafkaEventQueueSize
+	}
+	publisher := &KafkaEventPublisher{
+		Producer: producer,
+		Topic:    topic,
+		queue:    make(chan models.Event, queueSize),
+		written:  make(chan struct{}),
+	}
+	go publisher.work()
+	return publisher
+}
+
+func (p *KafkaEventPublisher) work() {
+	defer close(p.written)
+	for event := range p.queue {
+		if err := writeKafkaEvent(p.Producer, event); err != nil {
+			log.Printf("Failed to write %s event %s to kafka topic %s: %v", event.Type, event.ID, p.Topic, err)
+		}
+	}
+}
+
+// Publish queues a domain event for the writer, so that a slow or unavailable broker does not hold up the
+// request that caused the event. The event is dropped when the queue is full or the publisher was closed.
+func (p *KafkaEventPublisher) Publish(event models.Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return fmt.Errorf("the publisher for kafka topic %s is closed", p.Topic)
+	}
+	select {
+	case p.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("the queue for kafka topic %s is full, %s event %s is dropped", p.Topic, event.Type, event.ID)
+	}
+}
+
+// Close stops accepting domain events and waits until the queued ones were written, or until the context is
+// done. The producer is closed once the queue is drained.
+func (p *KafkaEventPublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.written:
+		return p.Producer.Close()
+	case <-ctx.Done():
+		return fmt.Errorf("events were still being written to kafka topic %s: %w", p.Topic, ctx.Err())
+	}
+}
+
+// MultiEventPublisher publishes every event to each of its publishers
+type MultiEventPublisher []IEventPublisher
+
+func (p MultiEventPublisher) Publish(event models.Event) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes each of the publishers
+func (p MultiEventPublisher) Close(ctx context.Context) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := CloseEventPublisher(ctx, publisher); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// InMemoryEventPublisher keeps published events in memory, it is meant for tests
+type InMemoryEventPublisher struct {
+	mu     sync.Mutex
+	events []models.Event
+}
+
+func NewInMemoryEventPublisher() *InMemoryEventPublisher {
+	return &InMemoryEventPublisher{}
+}
+
+func (p *InMemoryEventPublisher) Publish(event models.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first
+func (p *InMemoryEventPublisher) Events() []models.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]models.Event(nil), p.events...)
+}
+
+// EventsOfType returns the published events of one type, oldest first
+func (p *InMemoryEventPublisher) EventsOfType(eventType string) []models.Event {
+	var matching []models.Event
+	for _, event := range p.Events() {
+		if event.Type == eventType {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaEventPublisher_Publish(t *testing.T) {
+	mockProducer := new(mocks.MockProducer)
+	publisher := NewKafkaEventPublisherWithProducer(mockProducer, "user-events", 10)
+	var written []kafka.Message
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { written = append(written, args.Get(1).([]kafka.Message)[0]) }).
+		Return(nil).Once()
+	// a failed write is logged and does not stop the writer
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("broker down")).Once()
+
+	userID := uint(7)
+	event, err := NewEvent(models.EventRoleAssigned, &userID, models.RoleMembershipData{RoleID: 3, RoleName: "admin"})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(event))
+	require.NoError(t, publisher.Publish(event))
+	require.NoError(t, publisher.Close(context.Background()))
+
+	require.Len(t, written, 1)
+	assert.Equal(t, []byte("7"), written[0].Key)
+	var published models.Event
+	require.NoError(t, json.Unmarshal(written[0].Value, &published))
+	assert.Equal(t, event.ID, published.ID)
+	assert.JSONEq(t, `{"role_id":3,"role_name":"admin"}`, string(published.Data))
+	mockProducer.AssertExpectations(t)
+
+	assert.EqualError(t, publisher.Publish(event), "the publisher for kafka topic user-events is closed")
+}
+
+func TestKafkaEventPublisher_PublishDoesNotWaitForTheBroker(t *testing.T) {
+	mockProducer := new(mocks.MockProducer)
+	publisher := NewKafkaEventPublisherWithProducer(mockProducer, "user-events", 1)
+	release := make(chan struct{})
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).
+		Return(nil)
+
+	event, err := NewEvent(models.EventUserDeleted, nil, models.UserDeletedData{})
+	require.NoError(t, err)
+	// the writer is stuck on the first event, the second one fills the queue
+	require.NoError(t, publisher.Publish(event))
+	assert.Eventually(t, func() bool { return len(publisher.queue) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, publisher.Publish(event))
+	assert.ErrorContains(t, publisher.Publish(event), "the queue for kafka topic user-events is full")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, publisher.Close(ctx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, publisher.Close(context.Background()))
+	mockProducer.AssertNumberOfCalls(t, "WriteMessages", 2)
+}
+
+func TestNewKafkaEventPublisher_MissingTopic(t *testing.T) {
+	t.Setenv("KAFKA_EVENTS_TOPIC", "")
+	_, err := NewKafkaEventPublisher()
+	assert.EqualError(t, err, "no kafka events topic found in environment variable")
+}
+
+type failingEventPublisher struct{}
+
+func (failingEventPublisher) Publish(models.Event) error { return errors.New("unavailable") }
+
+func TestPublishEvent(t *testing.T) {
+	userID := uint(7)
+	// neither a missing nor a failing publisher affects the caller
+	publishEvent(nil, models.EventUserDeleted, &userID, models.UserDeletedData{})
+	publishEvent(failingEventPublisher{}, models.EventUserDeleted, &userID, models.UserDeletedData{})
+
+	events := NewInMemoryEventPublisher()
+	publishEvent(events, models.EventUserDeleted, &userID, models.UserDeletedData{Email: "gone@example.com"})
+	publishEvent(events, models.EventUserLoginFailed, nil, models.UserLoginFailedData{Email: "who@example.com"})
+
+	assert.Len(t, events.Events(), 2)
+	deleted := events.EventsOfType(models.EventUserDeleted)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "7", deleted[0].Subject)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const defaultEventSource = "/user-auth-and-permissions"
+
+// eventSchemaVersions holds the current data schema version of every event type. A change consumers
+// cannot ignore needs a new version and a new schemas/events/<type>.v<version>.json.
+var eventSchemaVersions = map[string]int{
+	models.EventUserCredentialsIssued:          1,
+	models.EventUserPasswordSetupRequested:     1,
+	models.EventUserEmailConfirmationRequested: 1,
+	models.EventUserEmailChangeNoticeRequested: 1,
+	models.EventUserInvitationRequested:        1,
+	models.EventUserRegistered:                 1,
+	models.EventUserUpdated:                    1,
+	models.EventUserDeleted:                    1,
+	models.EventUserLoggedIn:                   1,
+	models.EventUserLoginFailed:                1,
+	models.EventUserPasswordChanged:            1,
+	models.EventUserEmailChanged:               1,
+	models.EventUserStatusChanged:              1,
+	models.EventRoleAssigned:                   1,
+	models.EventRoleRevoked:                    1,
+}
+
+// EventDeliveryService is implemented by the password delivery services that publish whole events rather
+// than just the payload, the outbox relay prefers it so that an event keeps its ID across retries
+type EventDeliveryService interface {
+	PublishEvent(event models.Event) error
+}
+
+// EventDataSchema is the $id of the JSON Schema describing the data of an event type in a schema version
+func EventDataSchema(eventType string, version int) string {
+	return fmt.Sprintf("urn:user-auth-and-permissions:schema:%s:v%d", eventType, version)
+}
+
+// eventSource is the CloudEvents source of every published event, EVENT_SOURCE overrides the default
+func eventSource() string {
+	if source := os.Getenv("EVENT_SOURCE"); source != "" {
+		return source
+	}
+	return defaultEventSource
+}
+
+func buildEvent(eventType, id string, userID *uint, occurredAt time.Time, data []byte) (models.Event, error) {
+	version, ok := eventSchemaVersions[eventType]
+	if !ok {
+		return models.Event{}, fmt.Errorf("unknown event type: %s", eventType)
+	}
+	event := models.Event{
+		SpecVersion:     models.EventSpecVersion,
+		ID:              id,
+		Source:          eventSource(),
+		Type:            eventType,
+		Time:            occurredAt.UTC(),
+		DataContentType: "application/json",
+		DataSchema:      EventDataSchema(eventType, version),
+		SchemaVersion:   version,
+		Data:            data,
+	}
+	if userID != nil {
+		event.Subject = strconv.FormatUint(uint64(*userID), 10)
+	}
+	return event, nil
+}
+
+// NewEvent wraps data in an event with a new ID that happened now
+func NewEvent(eventType string, userID *uint, data any) (models.Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return models.Event{}, err
+	}
+	return buildEvent(eventType, uuid.NewString(), userID, time.Now(), encoded)
+}
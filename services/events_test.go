@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvent(t *testing.T) {
+	userID := uint(42)
+	event, err := NewEvent(models.EventUserCredentialsIssued, &userID, models.UserCredentials{Email: "test@example.com"})
+	require.NoError(t, err)
+
+	assert.Equal(t, models.EventSpecVersion, event.SpecVersion)
+	assert.Len(t, event.ID, 36)
+	assert.Equal(t, defaultEventSource, event.Source)
+	assert.Equal(t, "42", event.Subject)
+	assert.Equal(t, "urn:user-auth-and-permissions:schema:user.credentials_issued:v1", event.DataSchema)
+	assert.Equal(t, 1, event.SchemaVersion)
+	assert.WithinDuration(t, time.Now(), event.Time, time.Minute)
+	assert.Contains(t, string(event.Data), `"email":"test@example.com"`)
+
+	other, err := NewEvent(models.EventUserCredentialsIssued, nil, models.UserCredentials{})
+	require.NoError(t, err)
+	assert.NotEqual(t, event.ID, other.ID)
+	assert.Empty(t, other.Subject)
+
+	t.Setenv("EVENT_SOURCE", "https://auth.example.com")
+	event, err = NewEvent(models.EventUserCredentialsIssued, nil, models.UserCredentials{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", event.Source)
+
+	_, err = NewEvent("user.teleported", nil, struct{}{})
+	assert.EqualError(t, err, "unknown event type: user.teleported")
+}
+
+type jsonSchema struct {
+	ID         string `json:"$id"`
+	Required   []string
+	Properties map[string]struct {
+		Enum []string
+	}
+}
+
+func readSchema(t *testing.T, name string) jsonSchema {
+	content, err := schemas.Events.ReadFile("events/" + name)
+	require.NoError(t, err, "missing schema %s", name)
+	var schema jsonSchema
+	require.NoError(t, json.Unmarshal(content, &schema))
+	return schema
+}
+
+// every event type needs a schema for its current version that matches what is published
+func TestEventSchemas(t *testing.T) {
+	samples := map[string]any{
+		models.EventUserCredentialsIssued:          models.UserCredentials{Locale: "de", Message: &models.RenderedMessage{HTML: "<p></p>"}},
+		models.EventUserPasswordSetupRequested:     models.PasswordSetupLink{Locale: "de", Message: &models.RenderedMessage{}},
+		models.EventUserEmailConfirmationRequested: models.EmailChangeConfirmation{ConfirmURL: "https://app.example.com", Locale: "de", Message: &models.RenderedMessage{}},
+		models.EventUserEmailChangeNoticeRequested: models.EmailChangeNotice{Locale: "de", Message: &models.RenderedMessage{}},
+		models.EventUserInvitationRequested:        models.UserInvitation{AcceptURL: "https://app.example.com", Locale: "de", Message: &models.RenderedMessage{}},
+		models.EventUserEmailChanged:               models.UserEmailChangedData{},
+		models.EventUserStatusChanged:              models.UserStatusChangedData{Reason: "left the company"},
+		models.EventUserRegistered:                 models.UserRegisteredData{},
+		models.EventUserUpdated:                    models.UserUpdatedData{},
+		models.EventUserDeleted:                    models.UserDeletedData{Erased: true},
+		models.EventUserLoggedIn:                   models.UserLoggedInData{Provider: "okta"},
+		models.EventUserLoginFailed:                models.UserLoginFailedData{Reason: models.LoginFailedInvalidCredentials},
+		models.EventUserPasswordChanged:            models.UserPasswordChangedData{},
+		models.EventRoleAssigned:                   models.RoleMembershipData{},
+		models.EventRoleRevoked:                    models.RoleMembershipData{},
+	}
+	envelope := readSchema(t, "cloudevent.json")
+
+	for eventType, version := range eventSchemaVersions {
+		schema := readSchema(t, fmt.Sprintf("%s.v%d.json", eventType, version))
+		assert.Equal(t, EventDataSchema(eventType, version), schema.ID)
+		assert.Contains(t, envelope.Properties["type"].Enum, eventType)
+
+		require.Contains(t, samples, eventType, "add a sample for %s", eventType)
+		event, err := NewEvent(eventType, nil, samples[eventType])
+		require.NoError(t, err)
+		var data map[string]any
+		require.NoError(t, json.Unmarshal(event.Data, &data))
+		for _, field := range schema.Required {
+			assert.Contains(t, data, field, "%s data lacks %s", eventType, field)
+		}
+		for field := range data {
+			assert.Contains(t, schema.Properties, field, "%s schema lacks %s", eventType, field)
+		}
+
+		encoded, err := json.Marshal(event)
+		require.NoError(t, err)
+		var attributes map[string]any
+		require.NoError(t, json.Unmarshal(encoded, &attributes))
+		for _, attribute := range envelope.Required {
+			assert.Contains(t, attributes, attribute)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+)
+
+type IFederatedIdentityService interface {
+	FindFederatedIdentity(provider, subject string) (*models.FederatedIdentity, error)
+	LinkFederatedIdentity(identity *models.FederatedIdentity) error
+}
+
+type FederatedIdentityService struct {
+	db *gorm.DB
+}
+
+func NewFederatedIdentityService(db *gorm.DB) *FederatedIdentityService {
+	return &FederatedIdentityService{db: db}
+}
+
+func (s *FederatedIdentityService) FindFederatedIdentity(provider, subject string) (*models.FederatedIdentity, error) {
+	var identity models.FederatedIdentity
+	if err := s.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (s *FederatedIdentityService) LinkFederatedIdentity(identity *models.FederatedIdentity) error {
+	return s.db.Create(identity).Error
+}
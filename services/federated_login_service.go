@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrInvalidState    = errors.New("invalid or expired state")
+	ErrUnverifiedEmail = errors.New("identity provider did not return a verified email address")
+)
+
+// FederatedLoginService signs users in through upstream OpenID Connect providers. Upstream accounts are
+// linked to existing users by verified email, and users that do not exist yet get a shadow row on first login.
+type FederatedLoginService struct {
+	connectors      map[string]*OIDCConnector
+	dbService       IDatabaseOperationService
+	identityService IFederatedIdentityService
+	events          IEventPublisher
+}
+
+// NewFederatedLoginService publishes user.registered when a shadow user is created and user.logged_in on every
+// federated login
+func NewFederatedLoginService(connectors []*OIDCConnector, dbService IDatabaseOperationService, identityService IFederatedIdentityService, events IEventPublisher) *FederatedLoginService {
+	connectorsByName := make(map[string]*OIDCConnector, len(connectors))
+	for _, connector := range connectors {
+		connectorsByName[connector.Name()] = connector
+	}
+	return &FederatedLoginService{
+		connectors:      connectorsByName,
+		dbService:       dbService,
+		identityService: identityService,
+		events:          events,
+	}
+}
+
+func (s *FederatedLoginService) Providers() []string {
+	providers := make([]string, 0, len(s.connectors))
+	for name := range s.connectors {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// AuthorizationURL returns the upstream URL to redirect the browser to, and the state bound to it
+func (s *FederatedLoginService) AuthorizationURL(provider, redirectURL string) (string, string, error) {
+	connector, found := s.connectors[provider]
+	if !found {
+		return "", "", ErrUnknownProvider
+	}
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	state, err := utils.GenerateFederatedState(provider, nonce)
+	if err != nil {
+		return "", "", err
+	}
+	authURL, err := connector.AuthCodeURL(state, nonce, redirectURL)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// CompleteLogin redeems the authorization code and returns an access token for the linked local user. The
+// login is recorded with the client it came from.
+func (s *FederatedLoginService) CompleteLogin(provider, code, state, redirectURL string, login models.LoginMetadata) (string, error) {
+	connector, found := s.connectors[provider]
+	if !found {
+		return "", ErrUnknownProvider
+	}
+	stateClaims, err := utils.ParseFederatedState(state)
+	if err != nil || stateClaims.Provider != provider {
+		return "", ErrInvalidState
+	}
+
+	idTokenClaims, err := connector.Exchange(code, stateClaims.Nonce, redirectURL)
+	if err != nil {
+		log.Printf("Federated login with %s failed: %v", provider, err)
+		return "", errors.New("Could not authenticate with identity provider")
+	}
+
+	user, userDetails, err := s.resolveUser(provider, connector.MapClaims(idTokenClaims))
+	if err != nil {
+		return "", err
+	}
+	if err := checkUserActive(user); err != nil {
+		return "", err
+	}
+
+	token, err := utils.GenerateJWT(user.Email, *userDetails)
+	if err != nil {
+		log.Printf("Error generating token after federated login: %v", err)
+		return "", errors.New("Could not generate token")
+	}
+	recordLogin(s.dbService, user.ID, login)
+	publishEvent(s.events, models.EventUserLoggedIn, &user.ID, models.UserLoggedInData{
+		Email:    user.Email,
+		Method:   models.EventMethodFederated,
+		Provider: provider,
+	})
+	return token, nil
+}
+
+func (s *FederatedLoginService) resolveUser(provider string, claims models.FederatedClaims) (*models.User, *models.UserDetail, error) {
+	if claims.Subject == "" {
+		return nil, nil, errors.New("identity provider did not return a subject")
+	}
+
+	var user *models.User
+	identity, err := s.identityService.FindFederatedIdentity(provider, claims.Subject)
+	if err == nil {
+		if user, err = s.dbService.FindUserByID(identity.UserID); err != nil {
+			return nil, nil, errors.New("Linked user not found")
+		}
+	} else {
+		// Linking by email is only safe when the provider vouches for the address
+		if claims.Email == "" || !claims.EmailVerified {
+			return nil, nil, ErrUnverifiedEmail
+		}
+		user, err = s.dbService.FindUserByEmail(claims.Email)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user, _, err = createShadowUser(s.dbService, s.events, models.EventMethodFederated, claims.Email, models.UserDetail{
+				FirstName:  claims.GivenName,
+				MiddleName: claims.MiddleName,
+				LastName:   claims.FamilyName,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+		case err != nil:
+			log.Printf("Error looking up the user of %s identity %s: %v", provider, claims.Subject, err)
+			return nil, nil, errors.New("error while looking up user")
+		}
+		err = s.identityService.LinkFederatedIdentity(&models.FederatedIdentity{
+			Provider: provider,
+			Subject:  claims.Subject,
+			UserID:   user.ID,
+			Email:    claims.Email,
+		})
+		if err != nil {
+			log.Printf("Error linking %s identity to user %d: %v", provider, user.ID, err)
+			return nil, nil, errors.New("error while linking federated identity")
+		}
+	}
+
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, nil, errors.New("Invalid user Id")
+	}
+	return user, userDetails, nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testRedirectURL = "https://auth.example.com/auth/federated/stub/callback"
+
+func newTestFederatedLoginService(t *testing.T) (*FederatedLoginService, *tests.OIDCProviderStub, *mocks.MockDatabaseOperationService, *mocks.MockFederatedIdentityService) {
+	stub := tests.NewOIDCProviderStub("upstream-client", "upstream-secret")
+	t.Cleanup(stub.Close)
+	connector := NewOIDCConnector(models.FederatedProviderConfig{
+		Name:         "stub",
+		Issuer:       stub.Issuer(),
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		ClaimMapping: models.FederatedClaimMapping{
+			Email:         "email",
+			EmailVerified: "email_verified",
+			GivenName:     "given_name",
+			FamilyName:    "family_name",
+		},
+	})
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockIdentityService := new(mocks.MockFederatedIdentityService)
+	service := NewFederatedLoginService([]*OIDCConnector{connector}, mockDBService, mockIdentityService, nil)
+	return service, stub, mockDBService, mockIdentityService
+}
+
+// startFederatedLogin begins the flow and returns the state and an authorization code the stub will redeem
+func startFederatedLogin(t *testing.T, service *FederatedLoginService, stub *tests.OIDCProviderStub) (string, string) {
+	authURL, state, err := service.AuthorizationURL("stub", testRedirectURL)
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	assert.Equal(t, stub.Issuer()+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, state, query.Get("state"))
+	assert.Equal(t, testRedirectURL, query.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+	return state, stub.IssueCode(query.Get("nonce"))
+}
+
+func TestFederatedLoginService_CompleteLogin_LinkedIdentity(t *testing.T) {
+	service, stub, mockDBService, mockIdentityService := newTestFederatedLoginService(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-1"}
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-1").Return(&models.FederatedIdentity{Provider: "stub", Subject: "upstream-1", UserID: user.ID}, nil)
+	mockDBService.On("FindUserByID", user.ID).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID, FirstName: mocks.TestUserFirstName}, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
+
+	state, code := startFederatedLogin(t, service, stub)
+	token, err := service.CompleteLogin("stub", code, state, testRedirectURL, models.LoginMetadata{})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	mockDBService.AssertExpectations(t)
+	mockIdentityService.AssertExpectations(t)
+}
+
+func TestFederatedLoginService_CompleteLogin_LinksExistingUserByVerifiedEmail(t *testing.T) {
+	service, stub, mockDBService, mockIdentityService := newTestFederatedLoginService(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-1", "email": "User1@TestMail.com", "email_verified": true}
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-1").Return(nil, errors.New("record not found"))
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockIdentityService.On("LinkFederatedIdentity", &models.FederatedIdentity{Provider: "stub", Subject: "upstream-1", UserID: user.ID, Email: mocks.TestUserEmail}).Return(nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID, FirstName: mocks.TestUserFirstName}, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
+
+	state, code := startFederatedLogin(t, service, stub)
+	token, err := service.CompleteLogin("stub", code, state, testRedirectURL, models.LoginMetadata{})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	mockDBService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	mockIdentityService.AssertExpectations(t)
+}
+
+func TestFederatedLoginService_CompleteLogin_ProvisionsNewUser(t *testing.T) {
+	service, stub, mockDBService, mockIdentityService := newTestFederatedLoginService(t)
+	events := NewInMemoryEventPublisher()
+	service.events = events
+	stub.Claims = jwt.MapClaims{"sub": "upstream-2", "email": "new@example.com", "email_verified": "true", "given_name": "Jane", "family_name": "Roe"}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-2").Return(nil, errors.New("record not found"))
+	mockDBService.On("FindUserByEmail", "new@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mockDBService.On("CreateUser", mock.MatchedBy(func(user *models.User) bool {
+		return user.Email == "new@example.com" && user.Password != ""
+	}), mock.MatchedBy(func(userDetail *models.UserDetail) bool {
+		return userDetail.FirstName == "Jane" && userDetail.LastName == "Roe"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.User).ID = 7
+	}).Return(nil)
+	mockIdentityService.On("LinkFederatedIdentity", mock.MatchedBy(func(identity *models.FederatedIdentity) bool {
+		return identity.UserID == 7 && identity.Subject == "upstream-2"
+	})).Return(nil)
+	mockDBService.On("FindUserDetailsByUserID", uint(7)).Return(&models.UserDetail{UserID: 7, FirstName: "Jane", LastName: "Roe"}, nil)
+	mockDBService.On("RecordLogin", uint(7), models.LoginMetadata{}).Return(nil)
+
+	state, code := startFederatedLogin(t, service, stub)
+	token, err := service.CompleteLogin("stub", code, state, testRedirectURL, models.LoginMetadata{})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	mockDBService.AssertExpectations(t)
+	mockIdentityService.AssertExpectations(t)
+	published := events.Events()
+	require.Len(t, published, 2)
+	assert.Equal(t, models.EventUserRegistered, published[0].Type)
+	assert.Contains(t, string(published[0].Data), `"method":"federated"`)
+	assert.Equal(t, models.EventUserLoggedIn, published[1].Type)
+	assert.JSONEq(t, `{"email":"new@example.com","method":"federated","provider":"stub"}`, string(published[1].Data))
+}
+
+func TestFederatedLoginService_CompleteLogin_LookupFailure(t *testing.T) {
+	service, stub, mockDBService, mockIdentityService := newTestFederatedLoginService(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-4", "email": "jane@example.com", "email_verified": true}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-4").Return(nil, gorm.ErrRecordNotFound)
+	mockDBService.On("FindUserByEmail", "jane@example.com").Return(nil, errors.New("connection refused"))
+
+	state, code := startFederatedLogin(t, service, stub)
+	token, err := service.CompleteLogin("stub", code, state, testRedirectURL, models.LoginMetadata{})
+
+	assert.Error(t, err)
+	assert.Empty(t, token)
+	mockDBService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	mockIdentityService.AssertNotCalled(t, "LinkFederatedIdentity", mock.Anything)
+}
+
+func TestFederatedLoginService_CompleteLogin_UnverifiedEmail(t *testing.T) {
+	service, stub, _, mockIdentityService := newTestFederatedLoginService(t)
+	stub.Claims = jwt.MapClaims{"sub": "upstream-3", "email": "victim@example.com", "email_verified": false}
+	mockIdentityService.On("FindFederatedIdentity", "stub", "upstream-3").Return(nil, errors.New("record not found"))
+
+	state, code := startFederatedLogin(t, service, stub)
+	token, err := service.CompleteLogin("stub", code, state, testRedirectURL, models.LoginMetadata{})
+
+	assert.ErrorIs(t, err, ErrUnverifiedEmail)
+	assert.Empty(t, token)
+	mockIdentityService.AssertNotCalled(t, "LinkFederatedIdentity", mock.Anything)
+}
+
+func TestFederatedLoginService_CompleteLogin_InvalidState(t *testing.T) {
+	service, stub, _, _ := newTestFederatedLoginService(t)
+	_, code := startFederatedLogin(t, service, stub)
+
+	token, err := service.CompleteLogin("stub", code, "tampered", testRedirectURL, models.LoginMetadata{})
+
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.Empty(t, token)
+}
+
+func TestFederatedLoginService_CompleteLogin_NonceMismatch(t *testing.T) {
+	service, stub, _, _ := newTestFederatedLoginService(t)
+	state, _ := startFederatedLogin(t, service, stub)
+
+	token, err := service.CompleteLogin("stub", stub.IssueCode("another-nonce"), state, testRedirectURL, models.LoginMetadata{})
+
+	assert.EqualError(t, err, "Could not authenticate with identity provider")
+	assert.Empty(t, token)
+}
+
+func TestFederatedLoginService_UnknownProvider(t *testing.T) {
+	service, _, _, _ := newTestFederatedLoginService(t)
+
+	_, _, err := service.AuthorizationURL("unknown", testRedirectURL)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	assert.Equal(t, []string{"stub"}, service.Providers())
+}
+
+func TestOIDCConnector_MapClaims_CustomMapping(t *testing.T) {
+	connector := NewOIDCConnector(models.FederatedProviderConfig{
+		Name: "corp",
+		ClaimMapping: models.FederatedClaimMapping{
+			Email:         "upn",
+			EmailVerified: "email_verified",
+			GivenName:     "first",
+			FamilyName:    "last",
+		},
+	})
+
+	claims := connector.MapClaims(jwt.MapClaims{"sub": "abc", "upn": " Jane@Corp.example ", "first": "Jane", "last": "Roe"})
+
+	assert.Equal(t, models.FederatedClaims{Subject: "abc", Email: "jane@corp.example", GivenName: "Jane", FamilyName: "Roe"}, claims)
+}
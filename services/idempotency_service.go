@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyLockTimeout is how long a request may hold its key before a retry takes the key over, in case
+// the service stopped before the request completed
+const idempotencyLockTimeout = time.Minute
+
+type IIdempotencyService interface {
+	Reserve(scope, key, requestHash string) (*models.IdempotencyKey, error)
+	Complete(scope, key string, statusCode int, contentType string, body []byte) error
+	Release(scope, key string) error
+}
+
+// IdempotencyService stores the responses of requests sent with an Idempotency-Key for ttl
+type IdempotencyService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{db: db, ttl: ttl}
+}
+
+// Reserve claims the key for a request with the hash. It returns nil when the key was free, otherwise the key
+// as stored by the request that claimed it first. Expired keys are forgotten on the way.
+func (s *IdempotencyService) Reserve(scope, key, requestHash string) (*models.IdempotencyKey, error) {
+	now := time.Now()
+	err := s.db.Where("expires_at <= ? OR (status_code = 0 AND created_at <= ?)", now, now.Add(-idempotencyLockTimeout)).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// the first request may release the key between the insert and the lookup, the next attempt claims it
+	for {
+		reserved := models.IdempotencyKey{Scope: scope, Key: key, RequestHash: requestHash, CreatedAt: now, ExpiresAt: now.Add(s.ttl)}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reserved)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing models.IdempotencyKey
+		err := s.db.Where("scope = ? AND idempotency_key = ?", scope, key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+}
+
+// Complete stores the response to the request that reserved the key
+func (s *IdempotencyService) Complete(scope, key string, statusCode int, contentType string, body []byte) error {
+	return s.db.Model(&models.IdempotencyKey{}).
+		Where("scope = ? AND idempotency_key = ?", scope, key).
+		Updates(map[string]any{"status_code": statusCode, "content_type": contentType, "body": body}).Error
+}
+
+// Release frees a key whose request did not complete, so that a retry runs it again
+func (s *IdempotencyService) Release(scope, key string) error {
+	return s.db.Where("scope = ? AND idempotency_key = ? AND status_code = 0", scope, key).
+		Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyService(t *testing.T) {
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.IdempotencyKey{})
+	idempotencyService := NewIdempotencyService(DBOperationService.db, time.Hour)
+	const scope = "POST /auth/register"
+
+	stored, err := idempotencyService.Reserve(scope, "key-1", "hash-1")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// a retry finds the running request
+	stored, err = idempotencyService.Reserve(scope, "key-1", "hash-1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Zero(t, stored.StatusCode)
+
+	// keys are scoped to the route
+	stored, err = idempotencyService.Reserve("POST /other", "key-1", "hash-1")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	require.NoError(t, idempotencyService.Complete(scope, "key-1", http.StatusOK, "application/json", []byte(`{"ok":true}`)))
+	stored, err = idempotencyService.Reserve(scope, "key-1", "hash-2")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "hash-1", stored.RequestHash)
+	assert.Equal(t, http.StatusOK, stored.StatusCode)
+	assert.Equal(t, "application/json", stored.ContentType)
+	assert.Equal(t, `{"ok":true}`, string(stored.Body))
+	// completed keys are not released
+	require.NoError(t, idempotencyService.Release(scope, "key-1"))
+	stored, err = idempotencyService.Reserve(scope, "key-1", "hash-1")
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+
+	t.Run("released keys are free again", func(t *testing.T) {
+		_, err := idempotencyService.Reserve(scope, "key-2", "hash-1")
+		require.NoError(t, err)
+		require.NoError(t, idempotencyService.Release(scope, "key-2"))
+
+		stored, err := idempotencyService.Reserve(scope, "key-2", "hash-2")
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("expired and abandoned keys are free again", func(t *testing.T) {
+		require.NoError(t, idempotencyService.Complete(scope, "key-2", http.StatusOK, "application/json", nil))
+		require.NoError(t, DBOperationService.db.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "key-2").
+			Update("expires_at", time.Now().Add(-time.Second)).Error)
+		_, err := idempotencyService.Reserve(scope, "key-3", "hash-1")
+		require.NoError(t, err)
+		require.NoError(t, DBOperationService.db.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "key-3").
+			Update("created_at", time.Now().Add(-2*idempotencyLockTimeout)).Error)
+
+		for _, key := range []string{"key-2", "key-3"} {
+			stored, err := idempotencyService.Reserve(scope, key, "hash-3")
+			require.NoError(t, err)
+			assert.Nil(t, stored, key)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidInvitation      = errors.New("invalid invitation")
+	ErrInvalidInvitationToken = errors.New("Invalid or expired invitation")
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationNotPending   = errors.New("invitation is no longer pending")
+	ErrInvitationUnavailable  = errors.New("invitations cannot be delivered by the configured password delivery")
+)
+
+type IInvitationService interface {
+	CreateInvitation(inviterID uint, input models.InvitationRequest) (*models.InvitationSummary, error)
+	ListInvitations(afterID uint64, limit int, status string) ([]models.InvitationSummary, error)
+	RevokeInvitation(invitationID uint) error
+	AcceptInvitation(input models.InvitationAcceptRequest) (*models.User, error)
+}
+
+// InvitationService lets admins invite users with their roles chosen up front. The invitation is delivered
+// through the outbox like a setup link, and accepting it creates the user with the password they choose.
+type InvitationService struct {
+	db     *gorm.DB
+	config models.InvitationConfig
+	events IEventPublisher
+}
+
+// NewInvitationService publishes user.registered and role.assigned when an invitation is accepted, sending one
+// publishes nothing
+func NewInvitationService(db *gorm.DB, config models.InvitationConfig, events IEventPublisher) *InvitationService {
+	return &InvitationService{db: db, config: config, events: events}
+}
+
+// CreateInvitation sends an invitation to the address, which must not belong to a user yet. A pending
+// invitation to the same address is revoked, so only the latest one can be accepted.
+func (s *InvitationService) CreateInvitation(inviterID uint, input models.InvitationRequest) (*models.InvitationSummary, error) {
+	if s.config.Unavailable {
+		return nil, ErrInvitationUnavailable
+	}
+	invitation := models.Invitation{Email: strings.TrimSpace(input.Email)}
+	if inviterID != 0 {
+		invitation.InvitedBy = &inviterID
+	}
+	var err error
+	if invitation.FirstName, err = validName("first_name", input.FirstName, false, ErrInvalidInvitation); err != nil {
+		return nil, err
+	}
+	if invitation.MiddleName, err = validName("middle_name", input.MiddleName, false, ErrInvalidInvitation); err != nil {
+		return nil, err
+	}
+	if invitation.LastName, err = validName("last_name", input.LastName, false, ErrInvalidInvitation); err != nil {
+		return nil, err
+	}
+	if invitation.PreferredLocale, err = validInvitationLocale(input.PreferredLocale); err != nil {
+		return nil, err
+	}
+	roles, err := s.findRoles(input.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if taken, err := emailTaken(s.db, invitation.Email); err != nil || taken {
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrEmailTaken
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation.TokenHash = utils.HashToken(token)
+	invitation.ExpiresAt = now.Add(s.config.TokenTTL)
+	message := models.UserInvitation{
+		Email:      invitation.Email,
+		FirstName:  invitation.FirstName,
+		MiddleName: invitation.MiddleName,
+		LastName:   invitation.LastName,
+		InvitedBy:  s.inviterName(inviterID),
+		Roles:      roleNames(roles),
+		Token:      token,
+		ExpiresAt:  invitation.ExpiresAt,
+		Locale:     invitation.PreferredLocale,
+	}
+	if s.config.AcceptURL != "" {
+		if message.AcceptURL, err = passwordSetupURL(s.config.AcceptURL, token); err != nil {
+			return nil, err
+		}
+	}
+	outboxMessage, err := newOutboxMessage(models.OutboxInvitation, message)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Invitation{}).
+			Where("LOWER(email) = LOWER(?) AND accepted_at IS NULL AND revoked_at IS NULL", invitation.Email).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			invitationRoles := make([]models.InvitationRole, 0, len(roles))
+			for _, role := range roles {
+				invitationRoles = append(invitationRoles, models.InvitationRole{InvitationID: invitation.ID, RoleID: role.ID})
+			}
+			if err := tx.Create(&invitationRoles).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(outboxMessage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary := invitationSummary(invitation, message.Roles, now)
+	return &summary, nil
+}
+
+// ListInvitations pages through the invitations by ascending ID, only those with the status unless it is
+// empty
+func (s *InvitationService) ListInvitations(afterID uint64, limit int, status string) ([]models.InvitationSummary, error) {
+	now := time.Now()
+	query := s.db.Where("id > ?", afterID)
+	switch status {
+	case "":
+	case models.InvitationPending:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case models.InvitationAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InvitationRevoked:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NOT NULL")
+	case models.InvitationExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidInvitation, status)
+	}
+	var invitations []models.Invitation
+	if err := query.Order("id").Limit(limit).Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(invitations))
+	for _, invitation := range invitations {
+		ids = append(ids, invitation.ID)
+	}
+	var invitationRoles []struct {
+		InvitationID uint
+		RoleName     string
+	}
+	err := s.db.Table("invitation_roles").Select("invitation_roles.invitation_id, roles.role_name").
+		Joins("JOIN roles ON roles.id = invitation_roles.role_id").
+		Where("invitation_roles.invitation_id IN ?", ids).Order("roles.role_name").Scan(&invitationRoles).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := map[uint][]string{}
+	for _, invitationRole := range invitationRoles {
+		roles[invitationRole.InvitationID] = append(roles[invitationRole.InvitationID], invitationRole.RoleName)
+	}
+
+	summaries := make([]models.InvitationSummary, 0, len(invitations))
+	for _, invitation := range invitations {
+		summaries = append(summaries, invitationSummary(invitation, roles[invitation.ID], now))
+	}
+	return summaries, nil
+}
+
+// RevokeInvitation keeps a pending invitation from being accepted
+func (s *InvitationService) RevokeInvitation(invitationID uint) error {
+	result := s.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(&models.Invitation{}).Where("id = ?", invitationID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvitationNotFound
+	}
+	return ErrInvitationNotPending
+}
+
+// AcceptInvitation creates the active user of the invitation with the chosen password and the roles of the
+// invitation, and uses the invitation up
+func (s *InvitationService) AcceptInvitation(input models.InvitationAcceptRequest) (*models.User, error) {
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	var userDetail models.UserDetail
+	var roles []models.Role
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", utils.HashToken(input.Token), time.Now()).
+			First(&invitation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidInvitationToken
+		}
+		if err != nil {
+			return err
+		}
+
+		userDetail.PreferredLocale = invitation.PreferredLocale
+		if locale := strings.TrimSpace(input.PreferredLocale); locale != "" {
+			if userDetail.PreferredLocale, err = validInvitationLocale(locale); err != nil {
+				return err
+			}
+		}
+		for _, name := range []struct {
+			field              string
+			chosen, invitation string
+			value              *string
+			required           bool
+		}{
+			{"first_name", input.FirstName, invitation.FirstName, &userDetail.FirstName, true},
+			{"middle_name", input.MiddleName, invitation.MiddleName, &userDetail.MiddleName, false},
+			{"last_name", input.LastName, invitation.LastName, &userDetail.LastName, true},
+		} {
+			chosen := strings.TrimSpace(name.chosen)
+			if chosen == "" {
+				chosen = name.invitation
+			}
+			if *name.value, err = validName(name.field, chosen, name.required, ErrInvalidInvitation); err != nil {
+				return err
+			}
+		}
+		// somebody may have registered the address since the invitation was sent
+		if taken, err := emailTaken(tx, invitation.Email); err != nil || taken {
+			if err != nil {
+				return err
+			}
+			return ErrEmailTaken
+		}
+
+		// the invitation was sent to the address, accepting it proves the invitee controls it
+		now := time.Now()
+		user = models.User{Email: invitation.Email, Password: hashedPassword, Status: models.UserStatusActive, EmailVerifiedAt: &now}
+		if err := tx.Create(&user).Error; err != nil {
+			return emailTakenAs(err)
+		}
+		userDetail.UserID = user.ID
+		if err := tx.Create(&userDetail).Error; err != nil {
+			return err
+		}
+		err = tx.Joins("JOIN invitation_roles ON invitation_roles.role_id = roles.id").
+			Where("invitation_roles.invitation_id = ?", invitation.ID).Order("roles.id").Find(&roles).Error
+		if err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			userRoles := make([]models.UserRole, 0, len(roles))
+			for _, role := range roles {
+				userRoles = append(userRoles, models.UserRole{UserID: user.ID, RoleID: role.ID})
+			}
+			if err := tx.Create(&userRoles).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&invitation).Updates(map[string]any{"accepted_at": time.Now(), "user_id": user.ID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publishRegistered(s.events, &user, &userDetail, models.EventMethodInvitation)
+	for _, role := range roles {
+		publishEvent(s.events, models.EventRoleAssigned, &user.ID, models.RoleMembershipData{RoleID: role.ID, RoleName: role.RoleName})
+	}
+	return &user, nil
+}
+
+// findRoles looks up the roles by name, every one of them must exist
+func (s *InvitationService) findRoles(names []string) ([]models.Role, error) {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	names = slices.Compact(names)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var roles []models.Role
+	if err := s.db.Where("role_name IN ?", names).Order("role_name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		if i >= len(roles) || roles[i].RoleName != name {
+			return nil, fmt.Errorf("%w: role %s does not exist", ErrInvalidInvitation, name)
+		}
+	}
+	return roles, nil
+}
+
+// inviterName is the full name of the admin, or their email address when the name is blank
+func (s *InvitationService) inviterName(inviterID uint) string {
+	var inviter struct {
+		Email     string
+		FirstName string
+		LastName  string
+	}
+	err := s.db.Table("users").Select("users.email, user_details.firstname AS first_name, user_details.lastname AS last_name").
+		Joins("LEFT JOIN user_details ON user_details.user_id = users.id").
+		Where("users.id = ?", inviterID).Take(&inviter).Error
+	if err != nil {
+		return ""
+	}
+	if name := strings.TrimSpace(inviter.FirstName + " " + inviter.LastName); name != "" {
+		return name
+	}
+	return inviter.Email
+}
+
+func validInvitationLocale(locale string) (string, error) {
+	if locale = strings.TrimSpace(locale); locale == "" {
+		return "", nil
+	}
+	normalized, err := utils.NormalizeLocale(locale)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidInvitation, err)
+	}
+	return normalized, nil
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.RoleName)
+	}
+	return names
+}
+
+func invitationSummary(invitation models.Invitation, roles []string, now time.Time) models.InvitationSummary {
+	if roles == nil {
+		roles = []string{}
+	}
+	return models.InvitationSummary{
+		ID:              invitation.ID,
+		Email:           invitation.Email,
+		FirstName:       invitation.FirstName,
+		MiddleName:      invitation.MiddleName,
+		LastName:        invitation.LastName,
+		PreferredLocale: invitation.PreferredLocale,
+		Roles:           roles,
+		InvitedBy:       invitation.InvitedBy,
+		Status:          invitation.Status(now),
+		ExpiresAt:       invitation.ExpiresAt,
+		AcceptedAt:      invitation.AcceptedAt,
+		RevokedAt:       invitation.RevokedAt,
+		UserID:          invitation.UserID,
+		CreatedAt:       invitation.CreatedAt,
+	}
+}
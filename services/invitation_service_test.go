@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitationService(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	db := DBOperationService.db
+	defer tests.DeleteTestData(sqlDB)
+	defer db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	defer db.Where("1 = 1").Delete(&models.Invitation{})
+	events := NewInMemoryEventPublisher()
+	invitationService := NewInvitationService(db, models.InvitationConfig{
+		AcceptURL: "https://app.example.com/invitation",
+		TokenTTL:  time.Hour,
+	}, events)
+
+	admin := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(admin, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}))
+	var adminRole models.Role
+	require.NoError(t, db.Where("role_name = ?", "admin").First(&adminRole).Error)
+
+	// sentToken reads the token of the latest invitation from the outbox
+	sentToken := func(t *testing.T) models.UserInvitation {
+		var message models.OutboxMessage
+		require.NoError(t, db.Where("message_type = ?", models.OutboxInvitation).Order("id DESC").First(&message).Error)
+		var invitation models.UserInvitation
+		require.NoError(t, json.Unmarshal([]byte(message.Payload), &invitation))
+		return invitation
+	}
+
+	t.Run("rejects invalid invitations", func(t *testing.T) {
+		_, err := invitationService.CreateInvitation(admin.ID, models.InvitationRequest{Email: "jane@testmail.com", Roles: []string{"missing"}})
+		assert.ErrorIs(t, err, ErrInvalidInvitation)
+		_, err = invitationService.CreateInvitation(admin.ID, models.InvitationRequest{Email: "jane@testmail.com", PreferredLocale: "not a locale!"})
+		assert.ErrorIs(t, err, ErrInvalidInvitation)
+		_, err = invitationService.CreateInvitation(admin.ID, models.InvitationRequest{Email: "USER1@testmail.com"})
+		assert.Equal(t, ErrEmailTaken, err)
+	})
+
+	t.Run("invites and accepts", func(t *testing.T) {
+		first, err := invitationService.CreateInvitation(admin.ID, models.InvitationRequest{Email: "jane@testmail.com", FirstName: "Jane"})
+		require.NoError(t, err)
+		firstToken := sentToken(t).Token
+
+		invitation, err := invitationService.CreateInvitation(admin.ID, models.InvitationRequest{
+			Email: "Jane@testmail.com", FirstName: "Jane", LastName: "Doe", PreferredLocale: "de-de", Roles: []string{"admin", "admin"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, models.InvitationPending, invitation.Status)
+		assert.Equal(t, []string{"admin"}, invitation.Roles)
+		assert.Equal(t, "de-DE", invitation.PreferredLocale)
+		require.NotNil(t, invitation.InvitedBy)
+		assert.Equal(t, admin.ID, *invitation.InvitedBy)
+
+		sent := sentToken(t)
+		assert.Equal(t, "Jane@testmail.com", sent.Email)
+		assert.Equal(t, mocks.TestUserFirstName+" "+mocks.TestUserLastName, sent.InvitedBy)
+		assert.Equal(t, []string{"admin"}, sent.Roles)
+		assert.Equal(t, "https://app.example.com/invitation?token="+sent.Token, sent.AcceptURL)
+		assert.Equal(t, "de-DE", sent.Locale)
+
+		// the newer invitation replaces the first one
+		pending, err := invitationService.ListInvitations(0, 10, models.InvitationPending)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, invitation.ID, pending[0].ID)
+		revoked, err := invitationService.ListInvitations(0, 10, models.InvitationRevoked)
+		require.NoError(t, err)
+		require.Len(t, revoked, 1)
+		assert.Equal(t, first.ID, revoked[0].ID)
+		_, err = invitationService.AcceptInvitation(models.InvitationAcceptRequest{Token: firstToken, Password: "correct horse"})
+		assert.Equal(t, ErrInvalidInvitationToken, err)
+
+		user, err := invitationService.AcceptInvitation(models.InvitationAcceptRequest{Token: sent.Token, Password: "correct horse", FirstName: "Janet"})
+		require.NoError(t, err)
+		assert.Equal(t, "Jane@testmail.com", user.Email)
+		assert.Equal(t, models.UserStatusActive, user.Status)
+		assert.True(t, utils.CheckPasswordHash("correct horse", user.Password))
+		var userDetail models.UserDetail
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&userDetail).Error)
+		assert.Equal(t, "Janet", userDetail.FirstName)
+		assert.Equal(t, "Doe", userDetail.LastName)
+		assert.Equal(t, "de-DE", userDetail.PreferredLocale)
+		var roles []string
+		require.NoError(t, db.Table("user_roles").Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("user_roles.user_id = ?", user.ID).Pluck("roles.role_name", &roles).Error)
+		assert.Equal(t, []string{"admin"}, roles)
+
+		registered := events.EventsOfType(models.EventUserRegistered)
+		require.Len(t, registered, 1)
+		assert.JSONEq(t, `{"email":"Jane@testmail.com","first_name":"Janet","middle_name":"","last_name":"Doe","method":"invitation"}`, string(registered[0].Data))
+		assigned := events.EventsOfType(models.EventRoleAssigned)
+		require.Len(t, assigned, 1)
+		expected, err := json.Marshal(models.RoleMembershipData{RoleID: adminRole.ID, RoleName: "admin"})
+		require.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(assigned[0].Data))
+
+		accepted, err := invitationService.ListInvitations(0, 10, models.InvitationAccepted)
+		require.NoError(t, err)
+		require.Len(t, accepted, 1)
+		require.NotNil(t, accepted[0].UserID)
+		assert.Equal(t, user.ID, *accepted[0].UserID)
+
+		// the token is single use
+		_, err = invitationService.AcceptInvitation(models.InvitationAcceptRequest{Token: sent.Token, Password: "correct horse"})
+		assert.Equal(t, ErrInvalidInvitationToken, err)
+		assert.Equal(t, ErrInvitationNotPending, invitationService.RevokeInvitation(invitation.ID))
+	})
+
+	t.Run("accepting needs the names", func(t *testing.T) {
+		_, err := invitationService.CreateInvitation(0, models.InvitationRequest{Email: "nameless@testmail.com"})
+		require.NoError(t, err)
+		sent := sentToken(t)
+		assert.Empty(t, sent.InvitedBy)
+
+		_, err = invitationService.AcceptInvitation(models.InvitationAcceptRequest{Token: sent.Token, Password: "correct horse"})
+		assert.ErrorIs(t, err, ErrInvalidInvitation)
+	})
+
+	t.Run("revokes", func(t *testing.T) {
+		invitation, err := invitationService.CreateInvitation(admin.ID, models.InvitationRequest{Email: "revoked@testmail.com"})
+		require.NoError(t, err)
+		sent := sentToken(t)
+
+		require.NoError(t, invitationService.RevokeInvitation(invitation.ID))
+		_, err = invitationService.AcceptInvitation(models.InvitationAcceptRequest{Token: sent.Token, Password: "correct horse", FirstName: "Rae", LastName: "Voke"})
+		assert.Equal(t, ErrInvalidInvitationToken, err)
+		assert.Equal(t, ErrInvitationNotPending, invitationService.RevokeInvitation(invitation.ID))
+		assert.Equal(t, ErrInvitationNotFound, invitationService.RevokeInvitation(invitation.ID+100))
+	})
+
+	t.Run("lists pages", func(t *testing.T) {
+		all, err := invitationService.ListInvitations(0, 10, "")
+		require.NoError(t, err)
+		require.Len(t, all, 4)
+		page, err := invitationService.ListInvitations(uint64(all[1].ID), 10, "")
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+		assert.NotNil(t, page[0].Roles)
+
+		_, err = invitationService.ListInvitations(0, 10, "lost")
+		assert.ErrorIs(t, err, ErrInvalidInvitation)
+	})
+}
+
+func TestInvitationService_Unavailable(t *testing.T) {
+	invitationService := NewInvitationService(nil, models.InvitationConfig{Unavailable: true}, nil)
+
+	invitation, err := invitationService.CreateInvitation(1, models.InvitationRequest{Email: "jane@example.com"})
+
+	assert.Nil(t, invitation)
+	assert.ErrorIs(t, err, ErrInvitationUnavailable)
+}
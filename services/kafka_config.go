@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const (
+	defaultKafkaBatchTimeout = 10 * time.Millisecond
+	defaultKafkaWriteTimeout = 10 * time.Second
+	defaultKafkaDialTimeout  = 10 * time.Second
+)
+
+// KafkaConfig is the producer configuration shared by the password delivery and the domain events
+type KafkaConfig struct {
+	Brokers []string
+	// TLS is nil for plaintext connections
+	TLS *tls.Config
+	// SASL is nil when the brokers do not require authentication
+	SASL         sasl.Mechanism
+	RequiredAcks kafka.RequiredAcks
+	// Compression is zero for uncompressed batches
+	Compression kafka.Compression
+	// BatchTimeout is how long the writer waits for more messages before sending a batch. The kafka-go
+	// default of one second would delay every synchronous write.
+	BatchTimeout time.Duration
+	WriteTimeout time.Duration
+	DialTimeout  time.Duration
+}
+
+func kafkaBrokersFromEnv() []string {
+	var brokers []string
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return brokers
+}
+
+func kafkaTLSFromEnv() (*tls.Config, error) {
+	caFile := os.Getenv("KAFKA_TLS_CA_FILE")
+	certFile := os.Getenv("KAFKA_TLS_CERT_FILE")
+	keyFile := os.Getenv("KAFKA_TLS_KEY_FILE")
+	if !strings.EqualFold(os.Getenv("KAFKA_TLS"), "true") && caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: os.Getenv("KAFKA_TLS_SERVER_NAME")}
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read KAFKA_TLS_CA_FILE: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("KAFKA_TLS_CA_FILE contains no PEM certificates")
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+func kafkaSASLFromEnv() (sasl.Mechanism, error) {
+	mechanism := strings.ToUpper(strings.TrimSpace(os.Getenv("KAFKA_SASL_MECHANISM")))
+	if mechanism == "" {
+		return nil, nil
+	}
+	username := os.Getenv("KAFKA_SASL_USERNAME")
+	password := os.Getenv("KAFKA_SASL_PASSWORD")
+	if username == "" || password == "" {
+		return nil, errors.New("KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required with KAFKA_SASL_MECHANISM")
+	}
+	switch mechanism {
+	case "PLAIN":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("unsupported KAFKA_SASL_MECHANISM: %s", mechanism)
+	}
+}
+
+func kafkaAcksFromEnv() (kafka.RequiredAcks, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("KAFKA_ACKS"))) {
+	case "", "all", "-1":
+		return kafka.RequireAll, nil
+	case "1", "leader":
+		return kafka.RequireOne, nil
+	case "0", "none":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("invalid KAFKA_ACKS: %s", os.Getenv("KAFKA_ACKS"))
+	}
+}
+
+func kafkaCompressionFromEnv() (kafka.Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("KAFKA_COMPRESSION"))) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("invalid KAFKA_COMPRESSION: %s", os.Getenv("KAFKA_COMPRESSION"))
+	}
+}
+
+// KafkaConfigFromEnv reads KAFKA_BROKERS, the TLS settings KAFKA_TLS, KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE,
+// KAFKA_TLS_KEY_FILE and KAFKA_TLS_SERVER_NAME, the SASL settings KAFKA_SASL_MECHANISM, KAFKA_SASL_USERNAME and
+// KAFKA_SASL_PASSWORD, and KAFKA_ACKS, KAFKA_COMPRESSION, KAFKA_BATCH_TIMEOUT, KAFKA_WRITE_TIMEOUT and
+// KAFKA_DIAL_TIMEOUT
+func KafkaConfigFromEnv() (KafkaConfig, error) {
+	config := KafkaConfig{Brokers: kafkaBrokersFromEnv()}
+	if len(config.Brokers) == 0 {
+		return config, errors.New("no kafka brokers found in environment variable")
+	}
+	var err error
+	if config.TLS, err = kafkaTLSFromEnv(); err != nil {
+		return config, err
+	}
+	if config.SASL, err = kafkaSASLFromEnv(); err != nil {
+		return config, err
+	}
+	if config.SASL != nil && config.TLS == nil {
+		log.Printf("KAFKA_SASL_MECHANISM is set without TLS, credentials are sent to the brokers unencrypted")
+	}
+	if config.RequiredAcks, err = kafkaAcksFromEnv(); err != nil {
+		return config, err
+	}
+	if config.Compression, err = kafkaCompressionFromEnv(); err != nil {
+		return config, err
+	}
+	if config.BatchTimeout, err = durationFromEnv("KAFKA_BATCH_TIMEOUT", defaultKafkaBatchTimeout); err != nil {
+		return config, err
+	}
+	if config.WriteTimeout, err = durationFromEnv("KAFKA_WRITE_TIMEOUT", defaultKafkaWriteTimeout); err != nil {
+		return config, err
+	}
+	if config.DialTimeout, err = durationFromEnv("KAFKA_DIAL_TIMEOUT", defaultKafkaDialTimeout); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Writer returns a writer for the topic on all brokers. Messages with the same key, the user ID, go to the
+// same partition.
+func (c KafkaConfig) Writer(topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(c.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: c.RequiredAcks,
+		Compression:  c.Compression,
+		BatchTimeout: c.BatchTimeout,
+		WriteTimeout: c.WriteTimeout,
+		Transport: &kafka.Transport{
+			TLS:         c.TLS,
+			SASL:        c.SASL,
+			DialTimeout: c.DialTimeout,
+		},
+	}
+}
+
+// CheckConnection succeeds when at least one broker accepts a connection, including the TLS handshake
+// and SASL authentication
+func (c KafkaConfig) CheckConnection() error {
+	dialer := &kafka.Dialer{Timeout: c.DialTimeout, TLS: c.TLS, SASLMechanism: c.SASL}
+	var errs []error
+	for _, broker := range c.Brokers {
+		conn, err := dialer.DialContext(context.Background(), "tcp", broker)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %v", broker, err))
+	}
+	return fmt.Errorf("failed to connect to kafka broker: %v", errors.Join(errs...))
+}
+
+// newKafkaWriter reads the producer configuration from the environment and fails early when no broker is reachable
+func newKafkaWriter(topic string) (*kafka.Writer, error) {
+	config, err := KafkaConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.CheckConnection(); err != nil {
+		return nil, err
+	}
+	return config.Writer(topic), nil
+}
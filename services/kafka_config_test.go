@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a self signed certificate and its key as PEM files and returns their paths
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestKafkaConfigFromEnv_Defaults(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", " broker-1:9092, ,broker-2:9092 ")
+
+	config, err := KafkaConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broker-1:9092", "broker-2:9092"}, config.Brokers)
+	assert.Nil(t, config.TLS)
+	assert.Nil(t, config.SASL)
+	assert.Equal(t, kafka.RequireAll, config.RequiredAcks)
+	assert.Equal(t, kafka.Compression(0), config.Compression)
+	assert.Equal(t, defaultKafkaBatchTimeout, config.BatchTimeout)
+	assert.Equal(t, defaultKafkaWriteTimeout, config.WriteTimeout)
+	assert.Equal(t, defaultKafkaDialTimeout, config.DialTimeout)
+}
+
+func TestKafkaConfigFromEnv_MissingBrokers(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", " , ")
+
+	_, err := KafkaConfigFromEnv()
+	assert.EqualError(t, err, "no kafka brokers found in environment variable")
+}
+
+func TestKafkaConfigFromEnv_ProducerSettings(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "broker-1:9092")
+	t.Setenv("KAFKA_ACKS", "1")
+	t.Setenv("KAFKA_COMPRESSION", "zstd")
+	t.Setenv("KAFKA_BATCH_TIMEOUT", "50ms")
+	t.Setenv("KAFKA_WRITE_TIMEOUT", "30s")
+	t.Setenv("KAFKA_DIAL_TIMEOUT", "5s")
+
+	config, err := KafkaConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, kafka.RequireOne, config.RequiredAcks)
+	assert.Equal(t, kafka.Zstd, config.Compression)
+	assert.Equal(t, 50*time.Millisecond, config.BatchTimeout)
+	assert.Equal(t, 30*time.Second, config.WriteTimeout)
+	assert.Equal(t, 5*time.Second, config.DialTimeout)
+}
+
+func TestKafkaConfigFromEnv_InvalidSettings(t *testing.T) {
+	for key, value := range map[string]string{
+		"KAFKA_ACKS":           "2",
+		"KAFKA_COMPRESSION":    "brotli",
+		"KAFKA_BATCH_TIMEOUT":  "soon",
+		"KAFKA_WRITE_TIMEOUT":  "-1s",
+		"KAFKA_SASL_MECHANISM": "GSSAPI",
+		"KAFKA_TLS_CA_FILE":    "/nonexistent/ca.pem",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv("KAFKA_BROKERS", "broker-1:9092")
+			t.Setenv("KAFKA_SASL_USERNAME", "producer")
+			t.Setenv("KAFKA_SASL_PASSWORD", "secret")
+			t.Setenv(key, value)
+
+			_, err := KafkaConfigFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKafkaConfigFromEnv_TLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	t.Setenv("KAFKA_BROKERS", "broker-1:9093")
+	t.Setenv("KAFKA_TLS_CA_FILE", certFile)
+	t.Setenv("KAFKA_TLS_CERT_FILE", certFile)
+	t.Setenv("KAFKA_TLS_KEY_FILE", keyFile)
+	t.Setenv("KAFKA_TLS_SERVER_NAME", "kafka.internal")
+
+	config, err := KafkaConfigFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, config.TLS)
+	assert.NotNil(t, config.TLS.RootCAs)
+	assert.Len(t, config.TLS.Certificates, 1)
+	assert.Equal(t, "kafka.internal", config.TLS.ServerName)
+}
+
+func TestKafkaConfigFromEnv_TLSWithSystemRoots(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "broker-1:9093")
+	t.Setenv("KAFKA_TLS", "true")
+
+	config, err := KafkaConfigFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, config.TLS)
+	assert.Nil(t, config.TLS.RootCAs)
+	assert.Empty(t, config.TLS.Certificates)
+}
+
+func TestKafkaConfigFromEnv_TLSCertificateWithoutKey(t *testing.T) {
+	certFile, _ := writeTestCertificate(t)
+	t.Setenv("KAFKA_BROKERS", "broker-1:9093")
+	t.Setenv("KAFKA_TLS_CERT_FILE", certFile)
+
+	_, err := KafkaConfigFromEnv()
+	assert.EqualError(t, err, "KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE must be set together")
+}
+
+func TestKafkaConfigFromEnv_SASL(t *testing.T) {
+	for mechanism, name := range map[string]string{
+		"plain":         "PLAIN",
+		"SCRAM-SHA-256": "SCRAM-SHA-256",
+		"scram-sha-512": "SCRAM-SHA-512",
+	} {
+		t.Run(mechanism, func(t *testing.T) {
+			t.Setenv("KAFKA_BROKERS", "broker-1:9093")
+			t.Setenv("KAFKA_SASL_MECHANISM", mechanism)
+			t.Setenv("KAFKA_SASL_USERNAME", "producer")
+			t.Setenv("KAFKA_SASL_PASSWORD", "secret")
+
+			config, err := KafkaConfigFromEnv()
+			require.NoError(t, err)
+			require.NotNil(t, config.SASL)
+			assert.Equal(t, name, config.SASL.Name())
+		})
+	}
+}
+
+func TestKafkaConfigFromEnv_SASLMissingCredentials(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "broker-1:9093")
+	t.Setenv("KAFKA_SASL_MECHANISM", "PLAIN")
+
+	_, err := KafkaConfigFromEnv()
+	assert.EqualError(t, err, "KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required with KAFKA_SASL_MECHANISM")
+}
+
+func TestKafkaConfig_Writer(t *testing.T) {
+	mechanism := plain.Mechanism{Username: "producer", Password: "secret"}
+	config := KafkaConfig{
+		Brokers:      []string{"broker-1:9092", "broker-2:9092"},
+		SASL:         mechanism,
+		RequiredAcks: kafka.RequireAll,
+		Compression:  kafka.Gzip,
+		BatchTimeout: 20 * time.Millisecond,
+		WriteTimeout: 15 * time.Second,
+		DialTimeout:  3 * time.Second,
+	}
+
+	writer := config.Writer("events")
+	assert.Equal(t, "events", writer.Topic)
+	assert.Equal(t, "broker-1:9092,broker-2:9092", writer.Addr.String())
+	assert.IsType(t, &kafka.Hash{}, writer.Balancer)
+	assert.Equal(t, kafka.RequireAll, writer.RequiredAcks)
+	assert.Equal(t, kafka.Gzip, writer.Compression)
+	assert.Equal(t, 20*time.Millisecond, writer.BatchTimeout)
+	assert.Equal(t, 15*time.Second, writer.WriteTimeout)
+	transport, ok := writer.Transport.(*kafka.Transport)
+	require.True(t, ok)
+	assert.Equal(t, mechanism, transport.SASL)
+	assert.Equal(t, 3*time.Second, transport.DialTimeout)
+}
+
+func TestKafkaConfig_CheckConnection_AllBrokersUnreachable(t *testing.T) {
+	config := KafkaConfig{Brokers: []string{"127.0.0.1:1", "127.0.0.1:2"}, DialTimeout: time.Second}
+
+	err := config.CheckConnection()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to kafka broker")
+	assert.Contains(t, err.Error(), "127.0.0.1:1")
+	assert.Contains(t, err.Error(), "127.0.0.1:2")
+}
+
+func TestKafkaConfig_CheckConnection_FallsBackToNextBroker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	config := KafkaConfig{Brokers: []string{"127.0.0.1:1", listener.Addr().String()}, DialTimeout: time.Second}
+
+	assert.NoError(t, config.CheckConnection())
+}
@@ -2,14 +2,18 @@ package services
 
 import (
 	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/segmentio/kafka-go"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type MessageProducer interface {
@@ -20,52 +24,137 @@ type MessageProducer interface {
 type KafkaPasswordDeliveryService struct {
 	Producer MessageProducer
 	Topic    string
+	// PublicKey belongs to the consumer of Topic, when set every payload is envelope encrypted for it
+	PublicKey *rsa.PublicKey
+}
+
+// topicPublicKey loads the consumer key configured for the topic in KAFKA_TOPIC_PUBLIC_KEYS, a comma
+// separated list of topic=path_to_pem entries. It returns nil when the topic has no key.
+func topicPublicKey(topic string) (*rsa.PublicKey, error) {
+	for _, entry := range strings.Split(os.Getenv("KAFKA_TOPIC_PUBLIC_KEYS"), ",") {
+		name, path, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || strings.TrimSpace(name) != topic {
+			continue
+		}
+		pemBytes, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key for kafka topic %s: %v", topic, err)
+		}
+		publicKey, err := utils.ParseRSAPublicKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key for kafka topic %s: %v", topic, err)
+		}
+		return publicKey, nil
+	}
+	return nil, nil
 }
 
 func NewKafkaPasswordDeliveryService() (*KafkaPasswordDeliveryService, error) {
-	brokers := strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
-	if len(brokers) == 0 || brokers[0] == "" {
+	if os.Getenv("KAFKA_BROKERS") == "" {
 		return nil, errors.New("no kafka brokers found in environment variable")
 	}
-	fmt.Printf("brokers: %v\n", brokers)
 	topic := os.Getenv("KAFKA_TOPIC")
 	if topic == "" {
 		return nil, errors.New("no kafka topic found in environment variable")
 	}
-	fmt.Printf("topic: %s\n", topic)
-
-	conn, err := kafka.Dial("tcp", brokers[0])
+	publicKey, err := topicPublicKey(topic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to kafka broker: %v", err)
+		return nil, err
 	}
-	conn.Close()
+	if publicKey == nil {
+		// credentials are only published readable to anyone with access to the topic when asked for
+		if !strings.EqualFold(os.Getenv("KAFKA_ALLOW_PLAINTEXT_PASSWORDS"), "true") {
+			return nil, fmt.Errorf("no public key configured for kafka topic %s in KAFKA_TOPIC_PUBLIC_KEYS, set KAFKA_ALLOW_PLAINTEXT_PASSWORDS=true to publish passwords in plaintext", topic)
+		}
+		log.Printf("No public key configured for kafka topic %s in KAFKA_TOPIC_PUBLIC_KEYS, passwords are published in plaintext", topic)
+	}
+	log.Printf("Password deliveries are published to kafka topic %s", topic)
 
-	writer := &kafka.Writer{
-		Addr:     kafka.TCP(brokers[0]),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+	writer, err := newKafkaWriter(topic)
+	if err != nil {
+		return nil, err
 	}
 
 	return &KafkaPasswordDeliveryService{
-		Producer: writer,
-		Topic:    topic,
+		Producer:  writer,
+		Topic:     topic,
+		PublicKey: publicKey,
 	}, nil
 }
 
-func (s *KafkaPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
-	message, err := json.Marshal(credentials)
+// eventHeaders repeat the event attributes as CloudEvents Kafka headers, so consumers can route and
+// filter messages without parsing them
+func eventHeaders(event models.Event) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: "content-type", Value: []byte(models.EventContentType)},
+		{Key: "ce_specversion", Value: []byte(event.SpecVersion)},
+		{Key: "ce_id", Value: []byte(event.ID)},
+		{Key: "ce_source", Value: []byte(event.Source)},
+		{Key: "ce_type", Value: []byte(event.Type)},
+		{Key: "ce_time", Value: []byte(event.Time.Format(time.RFC3339Nano))},
+		{Key: "ce_dataschema", Value: []byte(event.DataSchema)},
+		{Key: "ce_schemaversion", Value: []byte(strconv.Itoa(event.SchemaVersion))},
+	}
+	if event.Subject != "" {
+		headers = append(headers, kafka.Header{Key: "ce_subject", Value: []byte(event.Subject)})
+	}
+	return headers
+}
+
+// writeKafkaEvent writes the event in the CloudEvents structured format, keyed by the user ID so that the
+// events of a user stay in order on one partition
+func writeKafkaEvent(producer MessageProducer, event models.Event) error {
+	value, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
+	message := kafka.Message{Value: value, Headers: eventHeaders(event)}
+	if event.Subject != "" {
+		message.Key = []byte(event.Subject)
+	}
+	return producer.WriteMessages(context.Background(), message)
+}
 
-	err = s.Producer.WriteMessages(context.Background(), kafka.Message{
-		Value: message,
-	})
-	if err != nil {
+// PublishEvent writes the event to the topic. When the topic has a consumer key only the data is envelope
+// encrypted, the attributes stay readable.
+func (s *KafkaPasswordDeliveryService) PublishEvent(event models.Event) error {
+	if s.PublicKey != nil {
+		sealed, err := utils.EncryptEnvelope(s.PublicKey, event.Data)
+		if err != nil {
+			return err
+		}
+		event.Data = sealed
+		event.DataContentType = utils.EnvelopeContentType
+	}
+	if err := writeKafkaEvent(s.Producer, event); err != nil {
 		log.Printf("Failed to send message to Kafka: %v", err)
 		return err
 	}
 
-	log.Printf("Password sent to Kafka topic %s for user %s", s.Topic, credentials.Email)
+	log.Printf("Event %s of type %s sent to Kafka topic %s", event.ID, event.Type, s.Topic)
 	return nil
 }
+
+// eventUserID is the user an event is keyed by, nil for notifications that were not given one
+func eventUserID(userID uint) *uint {
+	if userID == 0 {
+		return nil
+	}
+	return &userID
+}
+
+func (s *KafkaPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
+	event, err := NewEvent(models.EventUserCredentialsIssued, eventUserID(credentials.UserID), credentials)
+	if err != nil {
+		return err
+	}
+	return s.PublishEvent(event)
+}
+
+func (s *KafkaPasswordDeliveryService) SendSetupLink(link models.PasswordSetupLink) error {
+	event, err := NewEvent(models.EventUserPasswordSetupRequested, eventUserID(link.UserID), link)
+	if err != nil {
+		return err
+	}
+	return s.PublishEvent(event)
+}
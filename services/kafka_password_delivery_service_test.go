@@ -1,7 +1,11 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"github.com/segmentio/kafka-go"
 	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
@@ -10,7 +14,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/require"
 )
 
 func TestKafkaPasswordDeliveryService_SendPassword_Success(t *testing.T) {
@@ -24,11 +33,16 @@ func TestKafkaPasswordDeliveryService_SendPassword_Success(t *testing.T) {
 		if len(msgs) != 1 {
 			return false
 		}
+		var event models.Event
 		var credentials models.UserCredentials
-		err := json.Unmarshal(msgs[0].Value, &credentials)
-		return err == nil &&
+		err := json.Unmarshal(msgs[0].Value, &event)
+		if err != nil || json.Unmarshal(event.Data, &credentials) != nil {
+			return false
+		}
+		return event.Type == models.EventUserCredentialsIssued &&
 			credentials.Email == "test@example.com" &&
-			credentials.Password == "securePassword123"
+			credentials.Password == "securePassword123" &&
+			string(msgs[0].Key) == "42"
 	})).Return(nil)
 
 	credentials := models.UserCredentials{
@@ -37,6 +51,7 @@ func TestKafkaPasswordDeliveryService_SendPassword_Success(t *testing.T) {
 		MiddleName: "M",
 		LastName:   "Doe",
 		Password:   "securePassword123",
+		UserID:     42,
 	}
 	err := service.SendPassword(credentials)
 	assert.NoError(t, err)
@@ -69,6 +84,7 @@ func TestKafkaPasswordDeliveryService_SendPassword_WriteError(t *testing.T) {
 func TestNewKafkaPasswordDeliveryService_Success_WithTestcontainers(t *testing.T) {
 	tearDownKafkaContainer := tests.SetupKafkaContainer()
 	defer tearDownKafkaContainer()
+	t.Setenv("KAFKA_ALLOW_PLAINTEXT_PASSWORDS", "true")
 	service, err := NewKafkaPasswordDeliveryService()
 
 	assert.NoError(t, err)
@@ -98,7 +114,19 @@ func TestNewKafkaPasswordDeliveryService_MissingTopic(t *testing.T) {
 	assert.EqualError(t, err, "no kafka topic found in environment variable")
 }
 
+func TestNewKafkaPasswordDeliveryService_MissingPublicKey(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "invalid:9092")
+	t.Setenv("KAFKA_TOPIC", "test-topic")
+	t.Setenv("KAFKA_TOPIC_PUBLIC_KEYS", "")
+	t.Setenv("KAFKA_ALLOW_PLAINTEXT_PASSWORDS", "")
+	service, err := NewKafkaPasswordDeliveryService()
+
+	assert.Nil(t, service)
+	assert.EqualError(t, err, "no public key configured for kafka topic test-topic in KAFKA_TOPIC_PUBLIC_KEYS, set KAFKA_ALLOW_PLAINTEXT_PASSWORDS=true to publish passwords in plaintext")
+}
+
 func TestNewKafkaPasswordDeliveryService_BrokerConnectionFailure(t *testing.T) {
+	t.Setenv("KAFKA_ALLOW_PLAINTEXT_PASSWORDS", "true")
 	os.Setenv("KAFKA_BROKERS", "invalid:9092")
 	os.Setenv("KAFKA_TOPIC", "test-topic")
 	defer os.Unsetenv("KAFKA_BROKERS")
@@ -108,3 +136,108 @@ func TestNewKafkaPasswordDeliveryService_BrokerConnectionFailure(t *testing.T) {
 	assert.Nil(t, service)
 	assert.Contains(t, err.Error(), "failed to connect to kafka broker")
 }
+
+func TestKafkaPasswordDeliveryService_SendPassword_Encrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	mockProducer := new(mocks.MockProducer)
+	service := &KafkaPasswordDeliveryService{Producer: mockProducer, Topic: "test-topic", PublicKey: &privateKey.PublicKey}
+
+	var written kafka.Message
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { written = args.Get(1).([]kafka.Message)[0] }).
+		Return(nil)
+
+	credentials := models.UserCredentials{Email: "test@example.com", Password: "securePassword123"}
+	require.NoError(t, service.SendPassword(credentials))
+
+	assert.NotContains(t, string(written.Value), "securePassword123")
+	var event models.Event
+	require.NoError(t, json.Unmarshal(written.Value, &event))
+	assert.Equal(t, models.EventUserCredentialsIssued, event.Type)
+	assert.Equal(t, utils.EnvelopeContentType, event.DataContentType)
+	opened, err := utils.DecryptEnvelope(privateKey, event.Data)
+	require.NoError(t, err)
+	var delivered models.UserCredentials
+	require.NoError(t, json.Unmarshal(opened, &delivered))
+	assert.Equal(t, credentials, delivered)
+}
+
+func TestKafkaPasswordDeliveryService_SendSetupLink(t *testing.T) {
+	mockProducer := new(mocks.MockProducer)
+	service := &KafkaPasswordDeliveryService{Producer: mockProducer, Topic: "test-topic"}
+
+	mockProducer.On("WriteMessages", mock.Anything, mock.MatchedBy(func(msgs []kafka.Message) bool {
+		var event models.Event
+		var fields map[string]any
+		if json.Unmarshal(msgs[0].Value, &event) != nil || json.Unmarshal(event.Data, &fields) != nil {
+			return false
+		}
+		_, hasPassword := fields["password"]
+		return event.Type == models.EventUserPasswordSetupRequested && fields["token"] == "setup-token" && !hasPassword &&
+			string(msgs[0].Key) == "42"
+	})).Return(nil)
+
+	err := service.SendSetupLink(models.PasswordSetupLink{
+		UserID:    42,
+		Email:     "test@example.com",
+		SetupURL:  "https://app.example.com/set-password?token=setup-token",
+		Token:     "setup-token",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	mockProducer.AssertExpectations(t)
+}
+
+func TestTopicPublicKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "consumer.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	t.Setenv("KAFKA_TOPIC_PUBLIC_KEYS", "audit=/nonexistent.pem, credentials="+keyFile)
+	publicKey, err := topicPublicKey("credentials")
+	require.NoError(t, err)
+	assert.True(t, privateKey.PublicKey.Equal(publicKey))
+
+	publicKey, err = topicPublicKey("other")
+	assert.NoError(t, err)
+	assert.Nil(t, publicKey)
+
+	_, err = topicPublicKey("audit")
+	assert.Error(t, err)
+}
+
+func TestKafkaPasswordDeliveryService_PublishEvent(t *testing.T) {
+	mockProducer := new(mocks.MockProducer)
+	service := &KafkaPasswordDeliveryService{Producer: mockProducer, Topic: "test-topic"}
+
+	var written kafka.Message
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { written = args.Get(1).([]kafka.Message)[0] }).
+		Return(nil)
+
+	userID := uint(42)
+	event, err := NewEvent(models.EventUserCredentialsIssued, &userID, models.UserCredentials{Email: "test@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, service.PublishEvent(event))
+
+	assert.Equal(t, []byte("42"), written.Key)
+	headers := map[string]string{}
+	for _, header := range written.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, models.EventContentType, headers["content-type"])
+	assert.Equal(t, event.ID, headers["ce_id"])
+	assert.Equal(t, models.EventUserCredentialsIssued, headers["ce_type"])
+	assert.Equal(t, "42", headers["ce_subject"])
+	assert.Equal(t, "1", headers["ce_schemaversion"])
+
+	var published models.Event
+	require.NoError(t, json.Unmarshal(written.Value, &published))
+	assert.Equal(t, event.ID, published.ID)
+	assert.Equal(t, "42", published.Subject)
+	assert.JSONEq(t, string(event.Data), string(published.Data))
+}
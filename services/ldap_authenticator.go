@@ -0,0 +1,184 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+)
+
+// LDAPConn is the part of an LDAP connection the authenticator relies on
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	StartTLS(config *tls.Config) error
+	Close() error
+}
+
+type LDAPDialer func(url string) (LDAPConn, error)
+
+func dialLDAP(url string) (LDAPConn, error) {
+	return ldap.DialURL(url, ldap.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}))
+}
+
+// LDAPAuthenticator authenticates against an LDAP or Active Directory server. The user entry is
+// looked up with the service account, then the password is checked by binding as that entry.
+// A shadow users row is created on first login and the user's groups are mapped onto roles.
+type LDAPAuthenticator struct {
+	config      models.LDAPConfig
+	dbService   IDatabaseOperationService
+	roleService IRoleService
+	events      IEventPublisher
+	dial        LDAPDialer
+}
+
+func NewLDAPAuthenticator(config models.LDAPConfig, dbService IDatabaseOperationService, roleService IRoleService, events IEventPublisher) *LDAPAuthenticator {
+	return NewLDAPAuthenticatorWithDialer(config, dbService, roleService, events, dialLDAP)
+}
+
+func NewLDAPAuthenticatorWithDialer(config models.LDAPConfig, dbService IDatabaseOperationService, roleService IRoleService, events IEventPublisher, dial LDAPDialer) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		config:      config,
+		dbService:   dbService,
+		roleService: roleService,
+		events:      events,
+		dial:        dial,
+	}
+}
+
+func (a *LDAPAuthenticator) Authenticate(email, password string) (*models.User, *models.UserDetail, error) {
+	// An empty password would turn the user bind into an unauthenticated bind, which always succeeds
+	if email == "" || password == "" {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		log.Printf("Error connecting to LDAP server: %v", err)
+		return nil, nil, errors.New("LDAP server unavailable")
+	}
+	defer conn.Close()
+
+	entry, err := a.findEntry(conn, email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	directoryEmail := strings.ToLower(strings.TrimSpace(entry.GetAttributeValue(a.config.EmailAttribute)))
+	if directoryEmail == "" {
+		directoryEmail = strings.ToLower(email)
+	}
+	user, userDetails, err := a.findOrCreateShadowUser(directoryEmail, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.syncRoles(user.ID, entry.GetAttributeValues(a.config.GroupAttribute)); err != nil {
+		log.Printf("Error syncing LDAP roles for user %d: %v", user.ID, err)
+		return nil, nil, errors.New("error while syncing roles")
+	}
+	return user, userDetails, nil
+}
+
+func (a *LDAPAuthenticator) connect() (LDAPConn, error) {
+	conn, err := a.dial(a.config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.StartTLS {
+		serverName := a.config.URL
+		if parsed, err := url.Parse(a.config.URL); err == nil {
+			serverName = parsed.Hostname()
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("service account bind failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (a *LDAPAuthenticator) findEntry(conn LDAPConn, email string) (*ldap.Entry, error) {
+	searchRequest := ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, 10, false,
+		fmt.Sprintf(a.config.UserFilter, ldap.EscapeFilter(email)),
+		[]string{a.config.EmailAttribute, a.config.GivenNameAttribute, a.config.FamilyNameAttribute, a.config.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(searchRequest)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		log.Printf("LDAP search for %s failed: %v", email, err)
+		return nil, ErrInvalidCredentials
+	}
+	// Refuse ambiguous matches rather than guessing which entry the password belongs to
+	if result == nil || len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+func (a *LDAPAuthenticator) findOrCreateShadowUser(email string, entry *ldap.Entry) (*models.User, *models.UserDetail, error) {
+	user, err := a.dbService.FindUserByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error looking up the user of LDAP entry %s: %v", entry.DN, err)
+		return nil, nil, errors.New("error while looking up user")
+	}
+	if err != nil {
+		return createShadowUser(a.dbService, a.events, models.EventMethodLDAP, email, models.UserDetail{
+			FirstName: entry.GetAttributeValue(a.config.GivenNameAttribute),
+			LastName:  entry.GetAttributeValue(a.config.FamilyNameAttribute),
+		})
+	}
+	userDetails, err := a.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, nil, errors.New("Invalid user Id")
+	}
+	return user, userDetails, nil
+}
+
+// syncRoles grants the roles mapped from the user's groups and revokes mapped roles the user has
+// lost. Roles that are not part of the mapping are managed locally and left untouched.
+func (a *LDAPAuthenticator) syncRoles(userID uint, groups []string) error {
+	if len(a.config.GroupRoles) == 0 {
+		return nil
+	}
+	grantedSet := map[string]bool{}
+	for _, group := range groups {
+		if role, found := a.config.GroupRoles[strings.ToLower(group)]; found {
+			grantedSet[role] = true
+		}
+	}
+	managedSet := map[string]bool{}
+	for _, role := range a.config.GroupRoles {
+		managedSet[role] = true
+	}
+	return a.roleService.SyncUserRoles(userID, sortedKeys(grantedSet), sortedKeys(managedSet))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
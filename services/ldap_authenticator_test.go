@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testServiceDN = "CN=svc-auth,OU=Service,DC=corp,DC=example"
+	testUserDN    = "CN=Jane Roe,OU=People,DC=corp,DC=example"
+	testAdminsDN  = "CN=Admins,OU=Groups,DC=corp,DC=example"
+)
+
+// fakeLDAPConn is an in-memory directory holding the entries and their passwords by DN
+type fakeLDAPConn struct {
+	passwords map[string]string
+	entries   []*ldap.Entry
+	filters   []string
+	closed    bool
+}
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	if expected, found := c.passwords[username]; found && expected == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeLDAPConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.filters = append(c.filters, searchRequest.Filter)
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *fakeLDAPConn) StartTLS(config *tls.Config) error {
+	return nil
+}
+
+func (c *fakeLDAPConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newTestLDAPAuthenticator(conn *fakeLDAPConn) (*LDAPAuthenticator, *mocks.MockDatabaseOperationService, *mocks.MockRoleService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockRoleService := new(mocks.MockRoleService)
+	config := models.LDAPConfig{
+		URL:                 "ldap://dc.corp.example",
+		BindDN:              testServiceDN,
+		BindPassword:        "service-secret",
+		BaseDN:              "DC=corp,DC=example",
+		UserFilter:          "(&(objectClass=user)(userPrincipalName=%s))",
+		EmailAttribute:      "mail",
+		GivenNameAttribute:  "givenName",
+		FamilyNameAttribute: "sn",
+		GroupAttribute:      "memberOf",
+		GroupRoles: map[string]string{
+			"cn=admins,ou=groups,dc=corp,dc=example":  "admin",
+			"cn=viewers,ou=groups,dc=corp,dc=example": "viewer",
+		},
+	}
+	authenticator := NewLDAPAuthenticatorWithDialer(config, mockDBService, mockRoleService, nil, func(url string) (LDAPConn, error) {
+		return conn, nil
+	})
+	return authenticator, mockDBService, mockRoleService
+}
+
+func newTestDirectory() *fakeLDAPConn {
+	return &fakeLDAPConn{
+		passwords: map[string]string{testServiceDN: "service-secret", testUserDN: "ad-password"},
+		entries: []*ldap.Entry{ldap.NewEntry(testUserDN, map[string][]string{
+			"mail":      {"Jane.Roe@corp.example"},
+			"givenName": {"Jane"},
+			"sn":        {"Roe"},
+			"memberOf":  {testAdminsDN, "CN=Unmapped,OU=Groups,DC=corp,DC=example"},
+		})},
+	}
+}
+
+func TestLDAPAuthenticator_Authenticate_CreatesShadowUser(t *testing.T) {
+	conn := newTestDirectory()
+	authenticator, mockDBService, mockRoleService := newTestLDAPAuthenticator(conn)
+	mockDBService.On("FindUserByEmail", "jane.roe@corp.example").Return(nil, gorm.ErrRecordNotFound)
+	mockDBService.On("CreateUser", mock.MatchedBy(func(user *models.User) bool {
+		return user.Email == "jane.roe@corp.example" && user.Password != ""
+	}), mock.MatchedBy(func(userDetail *models.UserDetail) bool {
+		return userDetail.FirstName == "Jane" && userDetail.LastName == "Roe"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.User).ID = 9
+	}).Return(nil)
+	mockRoleService.On("SyncUserRoles", uint(9), []string{"admin"}, []string{"admin", "viewer"}).Return(nil)
+
+	user, userDetails, err := authenticator.Authenticate("jane.roe@corp.example", "ad-password")
+
+	require.NoError(t, err)
+	assert.Equal(t, uint(9), user.ID)
+	assert.Equal(t, uint(9), userDetails.UserID)
+	assert.Equal(t, []string{"(&(objectClass=user)(userPrincipalName=jane.roe@corp.example))"}, conn.filters)
+	assert.True(t, conn.closed)
+	mockDBService.AssertExpectations(t)
+	mockRoleService.AssertExpectations(t)
+}
+
+func TestLDAPAuthenticator_Authenticate_ExistingShadowUser(t *testing.T) {
+	authenticator, mockDBService, mockRoleService := newTestLDAPAuthenticator(newTestDirectory())
+	user := &models.User{ID: mocks.TestUserId, Email: "jane.roe@corp.example"}
+	mockDBService.On("FindUserByEmail", "jane.roe@corp.example").Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID, FirstName: "Jane"}, nil)
+	mockRoleService.On("SyncUserRoles", user.ID, []string{"admin"}, []string{"admin", "viewer"}).Return(nil)
+
+	result, _, err := authenticator.Authenticate("jane.roe@corp.example", "ad-password")
+
+	require.NoError(t, err)
+	assert.Equal(t, user, result)
+	mockDBService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestLDAPAuthenticator_Authenticate_LookupFailure(t *testing.T) {
+	authenticator, mockDBService, _ := newTestLDAPAuthenticator(newTestDirectory())
+	mockDBService.On("FindUserByEmail", "jane.roe@corp.example").Return(nil, errors.New("connection refused"))
+
+	user, _, err := authenticator.Authenticate("jane.roe@corp.example", "ad-password")
+
+	assert.Nil(t, user)
+	assert.Error(t, err)
+	mockDBService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestLDAPAuthenticator_Authenticate_WrongPassword(t *testing.T) {
+	authenticator, mockDBService, _ := newTestLDAPAuthenticator(newTestDirectory())
+
+	user, _, err := authenticator.Authenticate("jane.roe@corp.example", "wrong")
+
+	assert.Nil(t, user)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	mockDBService.AssertNotCalled(t, "FindUserByEmail", mock.Anything)
+}
+
+func TestLDAPAuthenticator_Authenticate_EmptyPassword(t *testing.T) {
+	conn := newTestDirectory()
+	authenticator, _, _ := newTestLDAPAuthenticator(conn)
+
+	_, _, err := authenticator.Authenticate("jane.roe@corp.example", "")
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Empty(t, conn.filters)
+}
+
+func TestLDAPAuthenticator_Authenticate_UnknownUser(t *testing.T) {
+	conn := newTestDirectory()
+	conn.entries = nil
+	authenticator, _, _ := newTestLDAPAuthenticator(conn)
+
+	_, _, err := authenticator.Authenticate("nobody@corp.example", "ad-password")
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestLDAPAuthenticator_Authenticate_EscapesFilter(t *testing.T) {
+	conn := newTestDirectory()
+	conn.entries = nil
+	authenticator, _, _ := newTestLDAPAuthenticator(conn)
+
+	_, _, _ = authenticator.Authenticate("*)(objectClass=*", "ad-password")
+
+	require.Len(t, conn.filters, 1)
+	assert.Equal(t, `(&(objectClass=user)(userPrincipalName=\2a\29\28objectClass=\2a))`, conn.filters[0])
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IOAuthClientService interface {
+	SaveClient(clientID, clientSecret, clientName string) error
+	AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error)
+}
+
+type OAuthClientService struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientService(db *gorm.DB) *OAuthClientService {
+	return &OAuthClientService{db: db}
+}
+
+// SaveClient creates the client or rotates its secret if it is already registered
+func (s *OAuthClientService) SaveClient(clientID, clientSecret, clientName string) error {
+	hashedSecret, err := utils.HashPassword(clientSecret)
+	if err != nil {
+		return err
+	}
+	client := models.OAuthClient{ClientID: clientID, ClientSecret: hashedSecret, ClientName: clientName}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"client_secret", "client_name"}),
+	}).Create(&client).Error
+}
+
+func (s *OAuthClientService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := s.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, errors.New("Invalid client")
+	}
+	if !utils.CheckPasswordHash(clientSecret, client.ClientSecret) {
+		return nil, errors.New("Invalid client")
+	}
+	return &client, nil
+}
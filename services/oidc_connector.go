@@ -0,0 +1,218 @@
+package services
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+// OIDCConnector performs the authorization code flow against an upstream OpenID Connect provider
+type OIDCConnector struct {
+	config     models.FederatedProviderConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *models.OpenIDConfiguration
+	keys      map[string]*rsa.PublicKey
+}
+
+func NewOIDCConnector(config models.FederatedProviderConfig) *OIDCConnector {
+	return &OIDCConnector{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       map[string]*rsa.PublicKey{},
+	}
+}
+
+func (c *OIDCConnector) Name() string {
+	return c.config.Name
+}
+
+func (c *OIDCConnector) redirectURL(fallback string) string {
+	if c.config.RedirectURL != "" {
+		return c.config.RedirectURL
+	}
+	return fallback
+}
+
+func (c *OIDCConnector) getJSON(endpoint string, target any) error {
+	response, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", response.StatusCode, endpoint)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// Discover fetches and caches the provider's discovery document
+func (c *OIDCConnector) Discover() (*models.OpenIDConfiguration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+	var discovery models.OpenIDConfiguration
+	if err := c.getJSON(c.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("error fetching discovery document for %s: %w", c.config.Name, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != c.config.Issuer {
+		return nil, fmt.Errorf("discovery issuer %s does not match configured issuer %s", discovery.Issuer, c.config.Issuer)
+	}
+	c.discovery = &discovery
+	return c.discovery, nil
+}
+
+func (c *OIDCConnector) AuthCodeURL(state, nonce, redirectURL string) (string, error) {
+	discovery, err := c.Discover()
+	if err != nil {
+		return "", err
+	}
+	if discovery.AuthorizationEndpoint == "" {
+		return "", errors.New("provider does not publish an authorization endpoint")
+	}
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {c.config.ClientID},
+		"redirect_uri":  {c.redirectURL(redirectURL)},
+		"scope":         {strings.Join(c.config.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the verified ID token claims
+func (c *OIDCConnector) Exchange(code, nonce, redirectURL string) (jwt.MapClaims, error) {
+	discovery, err := c.Discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.redirectURL(redirectURL)},
+	}
+	request, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error redeeming authorization code: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", response.StatusCode)
+	}
+	var tokens models.FederatedTokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("error decoding token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response did not include an id_token")
+	}
+	return c.VerifyIDToken(tokens.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an upstream ID token
+func (c *OIDCConnector) VerifyIDToken(rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return c.publicKey(keyID)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(c.config.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// publicKey returns the signing key with the given id, refreshing the key set once on a miss
+// so that upstream key rotation is picked up without a restart
+func (c *OIDCConnector) publicKey(keyID string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	key, found := c.keys[keyID]
+	c.mu.Unlock()
+	if found {
+		return key, nil
+	}
+
+	discovery, err := c.Discover()
+	if err != nil {
+		return nil, err
+	}
+	var keySet models.JSONWebKeySet
+	if err := c.getJSON(discovery.JwksURI, &keySet); err != nil {
+		return nil, fmt.Errorf("error fetching signing keys: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if publicKey, err := utils.RSAPublicKeyFromJWK(jwk); err == nil {
+			keys[jwk.KeyID] = publicKey
+		}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	if key, found := keys[keyID]; found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", keyID)
+}
+
+// MapClaims extracts user attributes from the ID token using the provider's claim mapping
+func (c *OIDCConnector) MapClaims(claims jwt.MapClaims) models.FederatedClaims {
+	mapping := c.config.ClaimMapping
+	stringClaim := func(name string) string {
+		value, _ := claims[name].(string)
+		return strings.TrimSpace(value)
+	}
+	emailVerified := false
+	switch value := claims[mapping.EmailVerified].(type) {
+	case bool:
+		emailVerified = value
+	case string:
+		emailVerified = strings.EqualFold(value, "true")
+	}
+	return models.FederatedClaims{
+		Subject:       stringClaim("sub"),
+		Email:         strings.ToLower(stringClaim(mapping.Email)),
+		EmailVerified: emailVerified,
+		GivenName:     stringClaim(mapping.GivenName),
+		MiddleName:    stringClaim(mapping.MiddleName),
+		FamilyName:    stringClaim(mapping.FamilyName),
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+	ScopeOpenID           = "openid"
+	RefreshTokenLifetime  = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+	ErrUnauthorizedClient   = errors.New("unauthorized_client")
+)
+
+type OIDCService struct {
+	loginService  *UserLoginService
+	dbService     IDatabaseOperationService
+	clientService IOAuthClientService
+	tokenStore    ITokenStoreService
+}
+
+func NewOIDCService(loginService *UserLoginService, dbService IDatabaseOperationService, clientService IOAuthClientService, tokenStore ITokenStoreService) *OIDCService {
+	return &OIDCService{
+		loginService:  loginService,
+		dbService:     dbService,
+		clientService: clientService,
+		tokenStore:    tokenStore,
+	}
+}
+
+// Discovery describes the provider. There is no authorization endpoint, tokens are only issued by the token
+// endpoint, so no response types are advertised.
+func (s *OIDCService) Discovery(issuer string) models.OpenIDConfiguration {
+	return models.OpenIDConfiguration{
+		Issuer:                            issuer,
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JwksURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenID, "profile", "email"},
+		ResponseTypesSupported:            []string{},
+		GrantTypesSupported:               []string{GrantTypePassword, GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "nonce", "azp",
+			"name", "given_name", "middle_name", "family_name", "email", "email_verified",
+		},
+	}
+}
+
+func (s *OIDCService) KeySet() (models.JSONWebKeySet, error) {
+	return utils.GetJSONWebKeySet()
+}
+
+// IssueToken handles the token endpoint. An ID token is only included when the openid scope is requested.
+func (s *OIDCService) IssueToken(issuer string, input models.TokenRequest) (*models.TokenResponse, error) {
+	if _, err := s.clientService.AuthenticateClient(input.ClientID, input.ClientSecret); err != nil {
+		return nil, ErrInvalidClient
+	}
+	switch input.GrantType {
+	case GrantTypePassword:
+		return s.passwordGrant(issuer, input)
+	case GrantTypeRefreshToken:
+		return s.refreshTokenGrant(issuer, input)
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+func (s *OIDCService) passwordGrant(issuer string, input models.TokenRequest) (*models.TokenResponse, error) {
+	if input.Username == "" || input.Password == "" {
+		return nil, ErrInvalidRequest
+	}
+	user, userDetails, err := s.loginService.Authenticate(models.LoginRequest{Email: input.Username, Password: input.Password, Metadata: input.Metadata})
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	return s.issueTokens(issuer, input.ClientID, input.Scope, input.Nonce, user, userDetails)
+}
+
+// refreshTokenGrant rotates the refresh token: the presented token is revoked and a new one issued
+func (s *OIDCService) refreshTokenGrant(issuer string, input models.TokenRequest) (*models.TokenResponse, error) {
+	if input.RefreshToken == "" {
+		return nil, ErrInvalidRequest
+	}
+	tokenHash := utils.HashToken(input.RefreshToken)
+	refreshToken, err := s.tokenStore.FindRefreshToken(tokenHash)
+	if err != nil || refreshToken.ClientID != input.ClientID || !isRefreshTokenActive(refreshToken) {
+		return nil, ErrInvalidGrant
+	}
+	user, err := s.dbService.FindUserByID(refreshToken.UserID)
+	if err != nil || checkUserActive(user) != nil {
+		return nil, ErrInvalidGrant
+	}
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	if err := s.tokenStore.RevokeRefreshToken(tokenHash); err != nil {
+		log.Printf("Error revoking rotated refresh token: %v", err)
+		return nil, errors.New("Could not generate token")
+	}
+	return s.issueTokens(issuer, input.ClientID, refreshToken.Scope, input.Nonce, user, userDetails)
+}
+
+func (s *OIDCService) issueTokens(issuer, clientID, scope, nonce string, user *models.User, userDetails *models.UserDetail) (*models.TokenResponse, error) {
+	accessToken, err := utils.GenerateClientJWT(user.Email, *userDetails, clientID)
+	if err != nil {
+		log.Printf("Error generating access token: %v", err)
+		return nil, errors.New("Could not generate token")
+	}
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		return nil, errors.New("Could not generate token")
+	}
+	err = s.tokenStore.CreateRefreshToken(&models.RefreshToken{
+		TokenHash: utils.HashToken(refreshToken),
+		UserID:    user.ID,
+		ClientID:  clientID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(RefreshTokenLifetime),
+	})
+	if err != nil {
+		log.Printf("Error storing refresh token: %v", err)
+		return nil, errors.New("Could not generate token")
+	}
+
+	response := &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(utils.AccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}
+	if slices.Contains(strings.Fields(scope), ScopeOpenID) {
+		idToken, err := utils.GenerateIDToken(issuer, clientID, nonce, user.Email, user.EmailVerifiedAt != nil, *userDetails)
+		if err != nil {
+			log.Printf("Error generating ID token: %v", err)
+			return nil, errors.New("Could not generate token")
+		}
+		response.IDToken = idToken
+	}
+	return response, nil
+}
+
+func isRefreshTokenActive(refreshToken *models.RefreshToken) bool {
+	return refreshToken.RevokedAt == nil && time.Now().Before(refreshToken.ExpiresAt)
+}
+
+func (s *OIDCService) UserInfo(email string) (*models.UserInfo, error) {
+	user, err := s.dbService.FindUserByEmail(email)
+	if err != nil {
+		return nil, errors.New("User not found")
+	}
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, errors.New("User not found")
+	}
+	return &models.UserInfo{
+		Subject:       strconv.FormatUint(uint64(user.ID), 10),
+		ProfileClaims: utils.BuildProfileClaims(user.Email, user.EmailVerifiedAt != nil, *userDetails),
+	}, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOIDCService() (*OIDCService, *mocks.MockDatabaseOperationService, *mocks.MockOAuthClientService, *mocks.MockTokenStoreService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	loginService := NewUserLoginService(mockDBService)
+	return NewOIDCService(loginService, mockDBService, mockClientService, mockTokenStore), mockDBService, mockClientService, mockTokenStore
+}
+
+func TestOIDCService_IssueToken_WithOpenIDScope(t *testing.T) {
+	service, mockDBService, mockClientService, mockTokenStore := newTestOIDCService()
+
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash, Status: models.UserStatusActive}
+	userDetails := &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+	loginMetadata := models.LoginMetadata{IPAddress: "203.0.113.7", UserAgent: "test-client/1.0"}
+	mockDBService.On("RecordLogin", user.ID, loginMetadata).Return(nil)
+	mockTokenStore.On("CreateRefreshToken", mock.MatchedBy(func(refreshToken *models.RefreshToken) bool {
+		return refreshToken.UserID == user.ID && refreshToken.ClientID == mocks.TestClientID && refreshToken.Scope == "openid email"
+	})).Return(nil)
+
+	response, err := service.IssueToken("https://auth.example.com", models.TokenRequest{
+		GrantType:    GrantTypePassword,
+		Username:     mocks.TestUserEmail,
+		Password:     mocks.TestUserPassword,
+		Scope:        "openid email",
+		ClientID:     mocks.TestClientID,
+		ClientSecret: mocks.TestClientSecret,
+		Metadata:     loginMetadata,
+	})
+
+	assert.NoError(t, err)
+	accessClaims, err := utils.ParseJWT(response.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, mocks.TestClientID, accessClaims.ClientID)
+	assert.NotEmpty(t, response.IDToken)
+	assert.NotEmpty(t, response.RefreshToken)
+	assert.Equal(t, "Bearer", response.TokenType)
+	mockDBService.AssertExpectations(t)
+	mockClientService.AssertExpectations(t)
+	mockTokenStore.AssertExpectations(t)
+}
+
+func TestOIDCService_IssueToken_InvalidClient(t *testing.T) {
+	service, _, mockClientService, _ := newTestOIDCService()
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, "wrong").Return(nil, errors.New("Invalid client"))
+
+	response, err := service.IssueToken("https://auth.example.com", models.TokenRequest{
+		GrantType:    GrantTypePassword,
+		ClientID:     mocks.TestClientID,
+		ClientSecret: "wrong",
+	})
+
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func TestOIDCService_IssueToken_UnsupportedGrantType(t *testing.T) {
+	service, _, mockClientService, _ := newTestOIDCService()
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+
+	response, err := service.IssueToken("https://auth.example.com", models.TokenRequest{
+		GrantType:    "authorization_code",
+		ClientID:     mocks.TestClientID,
+		ClientSecret: mocks.TestClientSecret,
+	})
+
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, ErrUnsupportedGrantType)
+}
+
+func TestOIDCService_IssueToken_RefreshTokenGrant(t *testing.T) {
+	service, mockDBService, mockClientService, mockTokenStore := newTestOIDCService()
+
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+	userDetails := &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	storedToken := &models.RefreshToken{UserID: user.ID, ClientID: mocks.TestClientID, Scope: "email", ExpiresAt: time.Now().Add(time.Hour)}
+	tokenHash := utils.HashToken("old-refresh-token")
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+	mockTokenStore.On("FindRefreshToken", tokenHash).Return(storedToken, nil)
+	mockDBService.On("FindUserByID", user.ID).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+	mockTokenStore.On("RevokeRefreshToken", tokenHash).Return(nil)
+	mockTokenStore.On("CreateRefreshToken", mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	response, err := service.IssueToken("https://auth.example.com", models.TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		RefreshToken: "old-refresh-token",
+		ClientID:     mocks.TestClientID,
+		ClientSecret: mocks.TestClientSecret,
+	})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.NotEqual(t, "old-refresh-token", response.RefreshToken)
+	assert.Empty(t, response.IDToken, "no id token without the openid scope")
+	mockTokenStore.AssertExpectations(t)
+}
+
+func TestOIDCService_IssueToken_RefreshTokenFromAnotherClient(t *testing.T) {
+	service, _, mockClientService, mockTokenStore := newTestOIDCService()
+
+	storedToken := &models.RefreshToken{UserID: mocks.TestUserId, ClientID: "other-client", ExpiresAt: time.Now().Add(time.Hour)}
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+	mockTokenStore.On("FindRefreshToken", utils.HashToken("stolen-refresh-token")).Return(storedToken, nil)
+
+	response, err := service.IssueToken("https://auth.example.com", models.TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		RefreshToken: "stolen-refresh-token",
+		ClientID:     mocks.TestClientID,
+		ClientSecret: mocks.TestClientSecret,
+	})
+
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, ErrInvalidGrant)
+	mockTokenStore.AssertNotCalled(t, "RevokeRefreshToken", mock.Anything)
+}
+
+func TestOIDCService_UserInfo(t *testing.T) {
+	service, mockDBService, _, _ := newTestOIDCService()
+
+	verifiedAt := time.Now()
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive, EmailVerifiedAt: &verifiedAt}
+	userDetails := &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
+
+	userInfo, err := service.UserInfo(mocks.TestUserEmail)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1", userInfo.Subject)
+	assert.Equal(t, mocks.TestUserFirstName, userInfo.GivenName)
+	assert.Equal(t, mocks.TestUserLastName, userInfo.FamilyName)
+	assert.Equal(t, mocks.TestUserEmail, userInfo.Email)
+	assert.True(t, userInfo.EmailVerified)
+}
+
+func TestOIDCService_UserInfo_UnverifiedEmail(t *testing.T) {
+	service, mockDBService, _, _ := newTestOIDCService()
+
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusPendingVerification}
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: mocks.TestUserId}, nil)
+
+	userInfo, err := service.UserInfo(mocks.TestUserEmail)
+
+	assert.NoError(t, err)
+	assert.False(t, userInfo.EmailVerified)
+}
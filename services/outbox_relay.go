@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const (
+	defaultOutboxPollInterval = 5 * time.Second
+	defaultOutboxBatchSize    = 50
+	defaultOutboxMaxAttempts  = 10
+	defaultOutboxBackoffBase  = 5 * time.Second
+	defaultOutboxBackoffMax   = 30 * time.Minute
+	// outboxClaimLease bounds how long a relay may take to publish a claimed batch before another relay
+	// picks the messages up again
+	outboxClaimLease = 5 * time.Minute
+)
+
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how often a message is tried before it is marked as failed
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// OutboxRelay publishes outbox messages to the password delivery service, as events when it implements
+// EventDeliveryService. Setup links, email changes and invitations otherwise need a delivery service that
+// can send them. A message that cannot be published is retried with exponential backoff until it runs out
+// of attempts. With templates, the subject and body are rendered in the user's locale and added to the
+// payload before it is handed to the delivery service.
+type OutboxRelay struct {
+	outboxService           IOutboxService
+	passwordDeliveryService PasswordDeliveryService
+	config                  OutboxRelayConfig
+	templates               *EmailTemplates
+}
+
+func positiveIntFromEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number", key)
+	}
+	return number, nil
+}
+
+// OutboxRelayConfigFromEnv reads OUTBOX_POLL_INTERVAL, OUTBOX_BATCH_SIZE, OUTBOX_MAX_ATTEMPTS,
+// OUTBOX_BACKOFF_BASE and OUTBOX_BACKOFF_MAX
+func OutboxRelayConfigFromEnv() (OutboxRelayConfig, error) {
+	var config OutboxRelayConfig
+	var err error
+	if config.PollInterval, err = durationFromEnv("OUTBOX_POLL_INTERVAL", defaultOutboxPollInterval); err != nil {
+		return config, err
+	}
+	if config.BatchSize, err = positiveIntFromEnv("OUTBOX_BATCH_SIZE", defaultOutboxBatchSize); err != nil {
+		return config, err
+	}
+	if config.MaxAttempts, err = positiveIntFromEnv("OUTBOX_MAX_ATTEMPTS", defaultOutboxMaxAttempts); err != nil {
+		return config, err
+	}
+	if config.BackoffBase, err = durationFromEnv("OUTBOX_BACKOFF_BASE", defaultOutboxBackoffBase); err != nil {
+		return config, err
+	}
+	if config.BackoffMax, err = durationFromEnv("OUTBOX_BACKOFF_MAX", defaultOutboxBackoffMax); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// NewOutboxRelay uses the defaults for every setting left at zero in config
+func NewOutboxRelay(outboxService IOutboxService, passwordDeliveryService PasswordDeliveryService, config OutboxRelayConfig) *OutboxRelay {
+	return NewOutboxRelayWithTemplates(outboxService, passwordDeliveryService, config, nil)
+}
+
+// NewOutboxRelayWithTemplates renders every message with templates, which may be nil to pass payloads on as
+// they are
+func NewOutboxRelayWithTemplates(outboxService IOutboxService, passwordDeliveryService PasswordDeliveryService, config OutboxRelayConfig, templates *EmailTemplates) *OutboxRelay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultOutboxPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultOutboxBatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaultOutboxBackoffBase
+	}
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = defaultOutboxBackoffMax
+	}
+	if config.BackoffMax < config.BackoffBase {
+		config.BackoffMax = config.BackoffBase
+	}
+	return &OutboxRelay{
+		outboxService:           outboxService,
+		passwordDeliveryService: passwordDeliveryService,
+		config:                  config,
+		templates:               templates,
+	}
+}
+
+// Backoff is the delay before the next try of a message that failed its attempts-th try
+func (r *OutboxRelay) Backoff(attempts int) time.Duration {
+	delay := r.config.BackoffBase
+	for i := 1; i < attempts && delay < r.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > r.config.BackoffMax {
+		return r.config.BackoffMax
+	}
+	return delay
+}
+
+// RelayOnce publishes one batch of due messages and returns how many were published
+func (r *OutboxRelay) RelayOnce() (int, error) {
+	messages, err := r.outboxService.ClaimDue(r.config.BatchSize, outboxClaimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, message := range messages {
+		if err := r.publish(message); err != nil {
+			r.handleFailure(message, err)
+			continue
+		}
+		if err := r.outboxService.MarkPublished(message.ID); err != nil {
+			log.Printf("Failed to remove published outbox message %d: %v", message.ID, err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+var (
+	errUnknownOutboxMessage = errors.New("unknown outbox message type")
+	// errUnrenderableOutboxMessage does not go away with a retry, the templates do not fit the payload
+	errUnrenderableOutboxMessage = errors.New("outbox message cannot be rendered")
+	// errUndeliverableOutboxMessage does not go away with a retry either, the delivery service has no way to
+	// send the type
+	errUndeliverableOutboxMessage = errors.New("the password delivery service cannot send the message")
+)
+
+// outboxMessageType describes how messages of a type are published: as eventType to an
+// EventDeliveryService, otherwise by send when canSend allows it. The payload is rendered from the template
+// first.
+type outboxMessageType struct {
+	eventType string
+	template  string
+	payload   func() models.Notification
+	canSend   func(service PasswordDeliveryService) bool
+	send      func(service PasswordDeliveryService, payload models.Notification) error
+}
+
+// deliveryServiceIs reports whether the delivery service implements T
+func deliveryServiceIs[T any](service PasswordDeliveryService) bool {
+	_, ok := service.(T)
+	return ok
+}
+
+func sendEmailChange(service PasswordDeliveryService, payload models.Notification) error {
+	emailChangeDeliveryService := service.(EmailChangeDeliveryService)
+	switch payload := payload.(type) {
+	case *models.EmailChangeConfirmation:
+		return emailChangeDeliveryService.SendEmailChangeConfirmation(*payload)
+	default:
+		return emailChangeDeliveryService.SendEmailChangeNotice(*payload.(*models.EmailChangeNotice))
+	}
+}
+
+var outboxMessageTypes = map[string]outboxMessageType{
+	models.OutboxPasswordDelivery: {
+		eventType: models.EventUserCredentialsIssued,
+		template:  EmailCredentials,
+		payload:   func() models.Notification { return &models.UserCredentials{} },
+		canSend:   deliveryServiceIs[PasswordDeliveryService],
+		send: func(service PasswordDeliveryService, payload models.Notification) error {
+			return service.SendPassword(*payload.(*models.UserCredentials))
+		},
+	},
+	models.OutboxPasswordSetupLink: {
+		eventType: models.EventUserPasswordSetupRequested,
+		template:  EmailPasswordSetup,
+		payload:   func() models.Notification { return &models.PasswordSetupLink{} },
+		canSend:   deliveryServiceIs[SetupLinkDeliveryService],
+		send: func(service PasswordDeliveryService, payload models.Notification) error {
+			return service.(SetupLinkDeliveryService).SendSetupLink(*payload.(*models.PasswordSetupLink))
+		},
+	},
+	models.OutboxEmailChangeConfirmation: {
+		eventType: models.EventUserEmailConfirmationRequested,
+		template:  EmailChangeConfirmation,
+		payload:   func() models.Notification { return &models.EmailChangeConfirmation{} },
+		canSend:   deliveryServiceIs[EmailChangeDeliveryService],
+		send:      sendEmailChange,
+	},
+	models.OutboxEmailChangeNotice: {
+		eventType: models.EventUserEmailChangeNoticeRequested,
+		template:  EmailChangeNotice,
+		payload:   func() models.Notification { return &models.EmailChangeNotice{} },
+		canSend:   deliveryServiceIs[EmailChangeDeliveryService],
+		send:      sendEmailChange,
+	},
+	models.OutboxInvitation: {
+		eventType: models.EventUserInvitationRequested,
+		template:  EmailInvitation,
+		payload:   func() models.Notification { return &models.UserInvitation{} },
+		canSend:   deliveryServiceIs[InvitationDeliveryService],
+		send: func(service PasswordDeliveryService, payload models.Notification) error {
+			return service.(InvitationDeliveryService).SendInvitation(*payload.(*models.UserInvitation))
+		},
+	},
+}
+
+// CanSendOutboxMessage reports whether the relay can publish messages of messageType to the delivery
+// service. An EventDeliveryService takes every type as an event.
+func CanSendOutboxMessage(service PasswordDeliveryService, messageType string) bool {
+	outboxType, known := outboxMessageTypes[messageType]
+	if !known || service == nil {
+		return false
+	}
+	return deliveryServiceIs[EventDeliveryService](service) || outboxType.canSend(service)
+}
+
+func (r *OutboxRelay) publish(message models.OutboxMessage) error {
+	messageType, known := outboxMessageTypes[message.MessageType]
+	if !known {
+		return fmt.Errorf("%w: %s", errUnknownOutboxMessage, message.MessageType)
+	}
+	if r.passwordDeliveryService == nil {
+		return errors.New("no password delivery service is configured")
+	}
+	if !CanSendOutboxMessage(r.passwordDeliveryService, message.MessageType) {
+		return fmt.Errorf("%w: no support for %s messages", errUndeliverableOutboxMessage, message.MessageType)
+	}
+	payload := []byte(message.Payload)
+	if r.templates != nil {
+		rendered, err := r.render(messageType, payload)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnrenderableOutboxMessage, err)
+		}
+		payload = rendered
+	}
+	if eventDeliveryService, ok := r.passwordDeliveryService.(EventDeliveryService); ok {
+		event, err := buildEvent(messageType.eventType, message.EventID, message.UserID, message.CreatedAt, payload)
+		if err != nil {
+			return err
+		}
+		return eventDeliveryService.PublishEvent(event)
+	}
+
+	notification := messageType.payload()
+	if err := json.Unmarshal(payload, notification); err != nil {
+		return err
+	}
+	if message.UserID != nil {
+		switch notification := notification.(type) {
+		case *models.UserCredentials:
+			notification.UserID = *message.UserID
+		case *models.PasswordSetupLink:
+			notification.UserID = *message.UserID
+		}
+	}
+	return messageType.send(r.passwordDeliveryService, notification)
+}
+
+// render adds the message rendered in the locale of the payload to it
+func (r *OutboxRelay) render(messageType outboxMessageType, payload []byte) ([]byte, error) {
+	notification := messageType.payload()
+	if err := json.Unmarshal(payload, notification); err != nil {
+		return nil, err
+	}
+	rendered, err := r.templates.RenderLocalized(messageType.template, notification.NotificationLocale(), notification)
+	if err != nil {
+		return nil, err
+	}
+	notification.SetMessage(rendered)
+	return json.Marshal(notification)
+}
+
+func (r *OutboxRelay) handleFailure(message models.OutboxMessage, err error) {
+	if message.Attempts >= r.config.MaxAttempts || errors.Is(err, errUnknownOutboxMessage) || errors.Is(err, errUnrenderableOutboxMessage) ||
+		errors.Is(err, errUndeliverableOutboxMessage) {
+		log.Printf("Giving up on outbox message %d after %d attempts: %v", message.ID, message.Attempts, err)
+		if markErr := r.outboxService.MarkFailed(message.ID, err.Error()); markErr != nil {
+			log.Printf("Failed to mark outbox message %d as failed: %v", message.ID, markErr)
+		}
+		return
+	}
+
+	delay := r.Backoff(message.Attempts)
+	log.Printf("Outbox message %d failed attempt %d, retrying in %s: %v", message.ID, message.Attempts, delay, err)
+	if markErr := r.outboxService.MarkRetry(message.ID, err.Error(), time.Now().Add(delay)); markErr != nil {
+		log.Printf("Failed to reschedule outbox message %d: %v", message.ID, markErr)
+	}
+}
+
+// Start relays due messages every poll interval until the context is cancelled
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.RelayOnce(); err != nil {
+					log.Printf("Failed to relay outbox messages: %v", err)
+				}
+			}
+		}
+	}()
+}
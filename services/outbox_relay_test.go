@@ -0,0 +1,327 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testOutboxRelayConfig = OutboxRelayConfig{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	BackoffBase:  time.Second,
+	BackoffMax:   5 * time.Second,
+}
+
+func passwordDeliveryMessage(id uint64, attempts int) models.OutboxMessage {
+	return models.OutboxMessage{
+		ID:          id,
+		MessageType: models.OutboxPasswordDelivery,
+		Payload:     `{"email":"test@example.com","password":"secret"}`,
+		Attempts:    attempts,
+	}
+}
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	relay := NewOutboxRelay(nil, nil, testOutboxRelayConfig)
+
+	assert.Equal(t, time.Second, relay.Backoff(1))
+	assert.Equal(t, 2*time.Second, relay.Backoff(2))
+	assert.Equal(t, 4*time.Second, relay.Backoff(3))
+	assert.Equal(t, 5*time.Second, relay.Backoff(4))
+	assert.Equal(t, 5*time.Second, relay.Backoff(100))
+}
+
+func TestOutboxRelay_RelayOncePublishes(t *testing.T) {
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{passwordDeliveryMessage(1, 1), passwordDeliveryMessage(2, 1)}, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	outboxService.On("MarkPublished", uint64(2)).Return(nil)
+	relay := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{}, testOutboxRelayConfig)
+
+	published, err := relay.RelayOnce()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	outboxService.AssertExpectations(t)
+}
+
+func TestOutboxRelay_RelayOnceSchedulesRetry(t *testing.T) {
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{passwordDeliveryMessage(1, 2)}, nil)
+	outboxService.On("MarkRetry", uint64(1), "mock error: failed to send password", mock.MatchedBy(func(next time.Time) bool {
+		delay := time.Until(next)
+		return delay > time.Second && delay <= 2*time.Second
+	})).Return(nil)
+	relay := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{ShouldFail: true}, testOutboxRelayConfig)
+
+	published, err := relay.RelayOnce()
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	outboxService.AssertExpectations(t)
+	outboxService.AssertNotCalled(t, "MarkPublished", mock.Anything)
+}
+
+func TestOutboxRelay_RelayOnceGivesUp(t *testing.T) {
+	tests := []struct {
+		name          string
+		message       models.OutboxMessage
+		expectedError string
+	}{
+		{"out of attempts", passwordDeliveryMessage(1, 3), "mock error: failed to send password"},
+		{"unknown type", models.OutboxMessage{ID: 1, MessageType: "fax", Payload: "{}", Attempts: 1}, "unknown outbox message type: fax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outboxService := new(mocks.MockOutboxService)
+			outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{tt.message}, nil)
+			outboxService.On("MarkFailed", uint64(1), tt.expectedError).Return(nil)
+			relay := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{ShouldFail: true}, testOutboxRelayConfig)
+
+			_, err := relay.RelayOnce()
+
+			require.NoError(t, err)
+			outboxService.AssertExpectations(t)
+			outboxService.AssertNotCalled(t, "MarkRetry", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOutboxRelay_WithoutPasswordDeliveryService(t *testing.T) {
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{passwordDeliveryMessage(1, 1)}, nil)
+	outboxService.On("MarkRetry", uint64(1), "no password delivery service is configured", mock.Anything).Return(nil)
+	relay := NewOutboxRelay(outboxService, nil, testOutboxRelayConfig)
+
+	_, err := relay.RelayOnce()
+
+	require.NoError(t, err)
+	outboxService.AssertExpectations(t)
+}
+
+func TestOutboxRelayConfigFromEnv(t *testing.T) {
+	t.Setenv("OUTBOX_POLL_INTERVAL", "2s")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "4")
+
+	config, err := OutboxRelayConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.Equal(t, OutboxRelayConfig{
+		PollInterval: 2 * time.Second,
+		BatchSize:    defaultOutboxBatchSize,
+		MaxAttempts:  4,
+		BackoffBase:  defaultOutboxBackoffBase,
+		BackoffMax:   defaultOutboxBackoffMax,
+	}, config)
+
+	t.Setenv("OUTBOX_BATCH_SIZE", "0")
+	_, err = OutboxRelayConfigFromEnv()
+	assert.EqualError(t, err, "OUTBOX_BATCH_SIZE must be a positive number")
+}
+
+func TestOutboxRelay_RelayOncePublishesSetupLink(t *testing.T) {
+	message := models.OutboxMessage{ID: 1, MessageType: models.OutboxPasswordSetupLink, Payload: `{"email":"test@example.com","token":"abc"}`, Attempts: 1}
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{message}, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	published, err := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	outboxService.AssertExpectations(t)
+
+	// the redis delivery has no way to send links, retrying would not change that
+	outboxService = new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{message}, nil)
+	outboxService.On("MarkFailed", uint64(1), "the password delivery service cannot send the message: no support for password_setup_link messages").Return(nil)
+	_, err = NewOutboxRelay(outboxService, &RedisPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	outboxService.AssertExpectations(t)
+}
+
+func TestOutboxRelay_RelayOncePublishesEmailChange(t *testing.T) {
+	messages := []models.OutboxMessage{
+		{ID: 1, MessageType: models.OutboxEmailChangeConfirmation, Payload: `{"email":"new@example.com","previous_email":"old@example.com","token":"abc"}`, Attempts: 1},
+		{ID: 2, MessageType: models.OutboxEmailChangeNotice, Payload: `{"email":"old@example.com","new_email":"new@example.com"}`, Attempts: 1},
+	}
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return(messages, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	outboxService.On("MarkPublished", uint64(2)).Return(nil)
+	published, err := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	outboxService.AssertExpectations(t)
+
+	outboxService = new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return(messages, nil)
+	outboxService.On("MarkFailed", uint64(1), "the password delivery service cannot send the message: no support for email_change_confirmation messages").Return(nil)
+	outboxService.On("MarkFailed", uint64(2), "the password delivery service cannot send the message: no support for email_change_notice messages").Return(nil)
+	_, err = NewOutboxRelay(outboxService, &RedisPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	outboxService.AssertExpectations(t)
+}
+
+func TestOutboxRelay_RelayOncePublishesInvitation(t *testing.T) {
+	messages := []models.OutboxMessage{
+		{ID: 1, MessageType: models.OutboxInvitation, Payload: `{"email":"jane@example.com","invited_by":"John Doe","roles":["admin"],"token":"abc"}`, Attempts: 1},
+	}
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return(messages, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	published, err := NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	outboxService.AssertExpectations(t)
+
+	outboxService = new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return(messages, nil)
+	outboxService.On("MarkFailed", uint64(1), "the password delivery service cannot send the message: no support for invitation messages").Return(nil)
+	_, err = NewOutboxRelay(outboxService, &RedisPasswordDeliveryService{}, testOutboxRelayConfig).RelayOnce()
+	require.NoError(t, err)
+	outboxService.AssertExpectations(t)
+}
+
+func TestCanSendOutboxMessage(t *testing.T) {
+	for _, messageType := range []string{
+		models.OutboxPasswordDelivery, models.OutboxPasswordSetupLink, models.OutboxEmailChangeConfirmation,
+		models.OutboxEmailChangeNotice, models.OutboxInvitation,
+	} {
+		assert.True(t, CanSendOutboxMessage(&mocks.MockPasswordDeliveryService{}, messageType), messageType)
+		assert.True(t, CanSendOutboxMessage(&KafkaPasswordDeliveryService{}, messageType), messageType)
+		assert.False(t, CanSendOutboxMessage(nil, messageType), messageType)
+	}
+	assert.True(t, CanSendOutboxMessage(&RedisPasswordDeliveryService{}, models.OutboxPasswordDelivery))
+	assert.False(t, CanSendOutboxMessage(&RedisPasswordDeliveryService{}, models.OutboxPasswordSetupLink))
+	assert.False(t, CanSendOutboxMessage(&PostgresPasswordDeliveryService{}, models.OutboxPasswordSetupLink))
+	assert.False(t, CanSendOutboxMessage(&mocks.MockPasswordDeliveryService{}, "unknown"))
+}
+
+func TestOutboxRelay_RelayOncePublishesEvent(t *testing.T) {
+	userID := uint(42)
+	createdAt := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	message := passwordDeliveryMessage(1, 2)
+	message.EventID = "6f1c2a8e-5d4b-4c3a-9e1f-0a2b3c4d5e6f"
+	message.UserID = &userID
+	message.CreatedAt = createdAt
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{message}, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	mockProducer := new(mocks.MockProducer)
+	var written kafka.Message
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { written = args.Get(1).([]kafka.Message)[0] }).
+		Return(nil)
+	deliveryService := &KafkaPasswordDeliveryService{Producer: mockProducer, Topic: "test-topic"}
+
+	published, err := NewOutboxRelay(outboxService, deliveryService, testOutboxRelayConfig).RelayOnce()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	var event models.Event
+	require.NoError(t, json.Unmarshal(written.Value, &event))
+	// a retried message is published with the same event ID
+	assert.Equal(t, message.EventID, event.ID)
+	assert.Equal(t, models.EventUserCredentialsIssued, event.Type)
+	assert.Equal(t, "42", event.Subject)
+	assert.True(t, createdAt.Equal(event.Time))
+	assert.JSONEq(t, message.Payload, string(event.Data))
+	outboxService.AssertExpectations(t)
+}
+
+type recordingPasswordDeliveryService struct {
+	mocks.MockPasswordDeliveryService
+	credentials []models.UserCredentials
+}
+
+func (s *recordingPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
+	s.credentials = append(s.credentials, credentials)
+	return nil
+}
+
+func TestOutboxRelay_RelayOnceSendsUserID(t *testing.T) {
+	userID := uint(42)
+	message := passwordDeliveryMessage(1, 1)
+	message.UserID = &userID
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{message}, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	deliveryService := &recordingPasswordDeliveryService{}
+
+	_, err := NewOutboxRelay(outboxService, deliveryService, testOutboxRelayConfig).RelayOnce()
+
+	require.NoError(t, err)
+	require.Len(t, deliveryService.credentials, 1)
+	assert.Equal(t, userID, deliveryService.credentials[0].UserID)
+	assert.Equal(t, "secret", deliveryService.credentials[0].Password)
+}
+
+func TestOutboxRelay_RelayOnceRendersMessage(t *testing.T) {
+	emailTemplates, err := LoadEmailTemplates(templates.Email)
+	require.NoError(t, err)
+	emailTemplates.DefaultLocale = "en"
+	message := passwordDeliveryMessage(1, 1)
+	message.Payload = `{"email":"test@example.com","first_name":"Jana","password":"secret","locale":"de-AT"}`
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{message}, nil)
+	outboxService.On("MarkPublished", uint64(1)).Return(nil)
+	mockProducer := new(mocks.MockProducer)
+	var written kafka.Message
+	mockProducer.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { written = args.Get(1).([]kafka.Message)[0] }).
+		Return(nil)
+	deliveryService := &KafkaPasswordDeliveryService{Producer: mockProducer, Topic: "test-topic"}
+
+	published, err := NewOutboxRelayWithTemplates(outboxService, deliveryService, testOutboxRelayConfig, emailTemplates).RelayOnce()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	var event models.Event
+	require.NoError(t, json.Unmarshal(written.Value, &event))
+	var credentials models.UserCredentials
+	require.NoError(t, json.Unmarshal(event.Data, &credentials))
+	require.NotNil(t, credentials.Message)
+	// there is no de-AT translation, so the German one is used
+	assert.Equal(t, "de", credentials.Message.Locale)
+	assert.Equal(t, "Ihr Konto wurde erstellt", credentials.Message.Subject)
+	assert.Contains(t, credentials.Message.Text, "Hallo Jana,")
+	assert.Contains(t, credentials.Message.HTML, "<code>secret</code>")
+	outboxService.AssertExpectations(t)
+}
+
+func TestOutboxRelay_RelayOnceGivesUpOnUnrenderableMessage(t *testing.T) {
+	emailTemplates, err := LoadEmailTemplates(fstest.MapFS{
+		"email/credentials.subject.tmpl": {Data: []byte("Welcome")},
+		"email/credentials.txt.tmpl":     {Data: []byte("Hello {{.Nickname}}")},
+	})
+	require.NoError(t, err)
+
+	outboxService := new(mocks.MockOutboxService)
+	outboxService.On("ClaimDue", 10, outboxClaimLease).Return([]models.OutboxMessage{passwordDeliveryMessage(1, 1)}, nil)
+	outboxService.On("MarkFailed", uint64(1), mock.MatchedBy(func(reason string) bool {
+		return strings.HasPrefix(reason, "outbox message cannot be rendered: ")
+	})).Return(nil)
+
+	_, err = NewOutboxRelayWithTemplates(outboxService, &mocks.MockPasswordDeliveryService{}, testOutboxRelayConfig, emailTemplates).RelayOnce()
+
+	require.NoError(t, err)
+	outboxService.AssertExpectations(t)
+	outboxService.AssertNotCalled(t, "MarkRetry", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IOutboxService interface {
+	ClaimDue(limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	MarkPublished(id uint64) error
+	MarkRetry(id uint64, lastError string, nextAttemptAt time.Time) error
+	MarkFailed(id uint64, lastError string) error
+	ListDeadLetters(afterID uint64, limit int, messageType string) ([]models.DeadLetter, error)
+	ReplayDeadLetter(id uint64) error
+	ReplayDeadLetters(messageType string) (int64, error)
+}
+
+const defaultDeadLetterRetention = 30 * 24 * time.Hour
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrDeadLetterStale    = errors.New("dead letter is no longer needed, its credentials were used or replaced")
+)
+
+// credentialMessageTypes carry a password or a token. Their credentials are removed from the payload when
+// they fail, replaying them issues new ones.
+var credentialMessageTypes = []string{
+	models.OutboxPasswordDelivery,
+	models.OutboxPasswordSetupLink,
+	models.OutboxEmailChangeConfirmation,
+	models.OutboxInvitation,
+}
+
+// OutboxDeadLetterRetentionFromEnv reads OUTBOX_DEAD_LETTER_RETENTION, 30 days by default
+func OutboxDeadLetterRetentionFromEnv() (time.Duration, error) {
+	return durationFromEnv("OUTBOX_DEAD_LETTER_RETENTION", defaultDeadLetterRetention)
+}
+
+type OutboxService struct {
+	db      *gorm.DB
+	reissue models.CredentialReissueConfig
+}
+
+func NewOutboxService(db *gorm.DB) *OutboxService {
+	return NewOutboxServiceWithReissue(db, models.CredentialReissueConfig{})
+}
+
+// NewOutboxServiceWithReissue issues the credentials of replayed dead letters again with the links and
+// lifetimes of reissue
+func NewOutboxServiceWithReissue(db *gorm.DB, reissue models.CredentialReissueConfig) *OutboxService {
+	return &OutboxService{db: db, reissue: reissue}
+}
+
+// ClaimDue returns up to limit messages whose next attempt is due and pushes their next attempt back by
+// the lease, so that another relay does not pick them up while they are being published
+func (s *OutboxService) ClaimDue(limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("failed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		err = tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+			"next_attempt_at": now.Add(lease),
+			"attempts":        gorm.Expr("attempts + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			messages[i].Attempts++
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// MarkPublished deletes the message, which also removes any credentials in its payload
+func (s *OutboxService) MarkPublished(id uint64) error {
+	return s.db.Delete(&models.OutboxMessage{}, id).Error
+}
+
+func (s *OutboxService) MarkRetry(id uint64, lastError string, nextAttemptAt time.Time) error {
+	return s.db.Model(&models.OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}).Error
+}
+
+// MarkFailed stops retrying the message and keeps it for inspection. The password, token and the links
+// carrying the token are removed from the payload, nothing will deliver them anymore.
+func (s *OutboxService) MarkFailed(id uint64, lastError string) error {
+	return s.db.Model(&models.OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"payload":    gorm.Expr("payload - 'password' - 'token' - 'setup_url' - 'confirm_url' - 'accept_url'"),
+		"last_error": lastError,
+		"failed_at":  time.Now(),
+	}).Error
+}
+
+// ListDeadLetters returns up to limit failed messages with an ID above afterID, oldest first. An empty
+// messageType matches every type.
+func (s *OutboxService) ListDeadLetters(afterID uint64, limit int, messageType string) ([]models.DeadLetter, error) {
+	query := s.db.Model(&models.OutboxMessage{}).
+		Select("id, event_id, user_id, message_type, attempts, last_error, created_at, failed_at").
+		Where("failed_at IS NOT NULL AND id > ?", afterID)
+	if messageType != "" {
+		query = query.Where("message_type = ?", messageType)
+	}
+	deadLetters := []models.DeadLetter{}
+	err := query.Order("id").Limit(limit).Find(&deadLetters).Error
+	return deadLetters, err
+}
+
+func replayDeadLetters(query *gorm.DB) *gorm.DB {
+	return query.Model(&models.OutboxMessage{}).
+		Where("failed_at IS NOT NULL AND message_type NOT IN ?", credentialMessageTypes).
+		Updates(map[string]any{
+			"failed_at":       nil,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+}
+
+// ReplayDeadLetter hands a failed message back to the relay with a fresh set of attempts. Its event ID is
+// kept, so consumers that did receive an earlier attempt can drop the duplicate. A message that carried
+// credentials is replaced by one with new credentials instead, see reissueDeadLetter.
+func (s *OutboxService) ReplayDeadLetter(id uint64) error {
+	result := replayDeadLetters(s.db.Where("id = ?", id))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.reissueDeadLetter(tx, id)
+	})
+}
+
+// ReplayDeadLetters replays every failed message of messageType, or of any type when it is empty, and
+// returns how many were replayed. Messages whose credentials are no longer needed are skipped.
+func (s *OutboxService) ReplayDeadLetters(messageType string) (int64, error) {
+	query := s.db
+	if messageType != "" {
+		query = query.Where("message_type = ?", messageType)
+	}
+	result := replayDeadLetters(query)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	replayed := result.RowsAffected
+
+	var ids []uint64
+	query = s.db.Model(&models.OutboxMessage{}).Where("failed_at IS NOT NULL AND message_type IN ?", credentialMessageTypes)
+	if messageType != "" {
+		query = query.Where("message_type = ?", messageType)
+	}
+	if err := query.Order("id").Pluck("id", &ids).Error; err != nil {
+		return replayed, err
+	}
+	for _, id := range ids {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.reissueDeadLetter(tx, id)
+		})
+		if errors.Is(err, ErrDeadLetterStale) || errors.Is(err, ErrDeadLetterNotFound) {
+			continue
+		}
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// reissueDeadLetter replaces a failed message that carried credentials with a new message, which carries
+// new credentials the way the original ones were issued. The redacted ones can never be delivered, so they
+// are revoked. It fails with ErrDeadLetterStale when the credentials are no longer needed, for example
+// because the invitation was accepted in the meantime.
+func (s *OutboxService) reissueDeadLetter(tx *gorm.DB, id uint64) error {
+	var deadLetter models.OutboxMessage
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND failed_at IS NOT NULL AND message_type IN ?", id, credentialMessageTypes).
+		First(&deadLetter).Error
+	if err != nil {
+		return notFoundAs(err, ErrDeadLetterNotFound)
+	}
+
+	var message *models.OutboxMessage
+	switch deadLetter.MessageType {
+	case models.OutboxPasswordDelivery:
+		message, err = s.reissuePassword(tx, deadLetter)
+	case models.OutboxPasswordSetupLink:
+		message, err = s.reissueSetupLink(tx, deadLetter)
+	case models.OutboxEmailChangeConfirmation:
+		message, err = s.reissueEmailChangeConfirmation(tx, deadLetter)
+	default:
+		message, err = s.reissueInvitation(tx, deadLetter)
+	}
+	if err != nil {
+		return err
+	}
+	message.UserID = deadLetter.UserID
+	if err := tx.Create(message).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&deadLetter).Error
+}
+
+// deadLetterUser is the user a dead letter was sent to, ErrDeadLetterStale once they are deleted or erased
+func deadLetterUser(tx *gorm.DB, deadLetter models.OutboxMessage) (*models.User, error) {
+	if deadLetter.UserID == nil {
+		return nil, ErrDeadLetterStale
+	}
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("erased_at IS NULL").First(&user, *deadLetter.UserID).Error; err != nil {
+		return nil, notFoundAs(err, ErrDeadLetterStale)
+	}
+	return &user, nil
+}
+
+// reissuePassword sets a new temporary password on the user, nobody has seen the one that failed
+func (s *OutboxService) reissuePassword(tx *gorm.DB, deadLetter models.OutboxMessage) (*models.OutboxMessage, error) {
+	user, err := deadLetterUser(tx, deadLetter)
+	if err != nil {
+		return nil, err
+	}
+	var credentials models.UserCredentials
+	if err := json.Unmarshal([]byte(deadLetter.Payload), &credentials); err != nil {
+		return nil, err
+	}
+	password, hashedPassword, err := utils.GetRandomPasswordAndHash()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(user).Update("password", hashedPassword).Error; err != nil {
+		return nil, err
+	}
+	credentials.Email = user.Email
+	credentials.Password = password
+	return newOutboxMessage(models.OutboxPasswordDelivery, credentials)
+}
+
+// reissueSetupLink replaces the pending setup tokens of the user, unless they already chose a password
+func (s *OutboxService) reissueSetupLink(tx *gorm.DB, deadLetter models.OutboxMessage) (*models.OutboxMessage, error) {
+	user, err := deadLetterUser(tx, deadLetter)
+	if err != nil {
+		return nil, err
+	}
+	var used int64
+	if err := tx.Model(&models.PasswordSetupToken{}).Where("user_id = ? AND used_at IS NOT NULL", user.ID).Count(&used).Error; err != nil {
+		return nil, err
+	}
+	if used > 0 {
+		return nil, ErrDeadLetterStale
+	}
+	var link models.PasswordSetupLink
+	if err := json.Unmarshal([]byte(deadLetter.Payload), &link); err != nil {
+		return nil, err
+	}
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	if link.SetupURL, err = passwordSetupURL(s.reissue.Onboarding.SetupURL, token); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	link.Email = user.Email
+	link.Token = token
+	link.ExpiresAt = now.Add(s.reissue.Onboarding.TokenTTL)
+
+	if err := tx.Model(&models.PasswordSetupToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).Update("expires_at", now).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.PasswordSetupToken{TokenHash: utils.HashToken(token), UserID: user.ID, ExpiresAt: link.ExpiresAt}).Error; err != nil {
+		return nil, err
+	}
+	return newOutboxMessage(models.OutboxPasswordSetupLink, link)
+}
+
+// reissueEmailChangeConfirmation replaces the token of the change, as long as it is the latest one the user
+// requested and it was not confirmed
+func (s *OutboxService) reissueEmailChangeConfirmation(tx *gorm.DB, deadLetter models.OutboxMessage) (*models.OutboxMessage, error) {
+	user, err := deadLetterUser(tx, deadLetter)
+	if err != nil {
+		return nil, err
+	}
+	var confirmation models.EmailChangeConfirmation
+	if err := json.Unmarshal([]byte(deadLetter.Payload), &confirmation); err != nil {
+		return nil, err
+	}
+	var pending models.EmailChangeToken
+	if err := tx.Where("user_id = ?", user.ID).Order("id DESC").First(&pending).Error; err != nil {
+		return nil, notFoundAs(err, ErrDeadLetterStale)
+	}
+	if pending.UsedAt != nil || !strings.EqualFold(pending.NewEmail, confirmation.Email) {
+		return nil, ErrDeadLetterStale
+	}
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	confirmation.Token = token
+	confirmation.ExpiresAt = now.Add(s.reissue.EmailChange.TokenTTL)
+	if s.reissue.EmailChange.ConfirmURL != "" {
+		if confirmation.ConfirmURL, err = passwordSetupURL(s.reissue.EmailChange.ConfirmURL, token); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Model(&pending).Update("expires_at", now).Error; err != nil {
+		return nil, err
+	}
+	err = tx.Create(&models.EmailChangeToken{
+		TokenHash: utils.HashToken(token),
+		UserID:    user.ID,
+		NewEmail:  pending.NewEmail,
+		ExpiresAt: confirmation.ExpiresAt,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return newOutboxMessage(models.OutboxEmailChangeConfirmation, confirmation)
+}
+
+// reissueInvitation gives the latest invitation to the address a new token, unless it was accepted or
+// revoked
+func (s *OutboxService) reissueInvitation(tx *gorm.DB, deadLetter models.OutboxMessage) (*models.OutboxMessage, error) {
+	var message models.UserInvitation
+	if err := json.Unmarshal([]byte(deadLetter.Payload), &message); err != nil {
+		return nil, err
+	}
+	var invitation models.Invitation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("LOWER(email) = LOWER(?) AND accepted_at IS NULL AND revoked_at IS NULL", message.Email).
+		Order("id DESC").
+		First(&invitation).Error
+	if err != nil {
+		return nil, notFoundAs(err, ErrDeadLetterStale)
+	}
+	if taken, err := emailTaken(tx, invitation.Email); err != nil || taken {
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrDeadLetterStale
+	}
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	message.Token = token
+	message.ExpiresAt = time.Now().Add(s.reissue.Invitation.TokenTTL)
+	if s.reissue.Invitation.AcceptURL != "" {
+		if message.AcceptURL, err = passwordSetupURL(s.reissue.Invitation.AcceptURL, token); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Model(&invitation).Updates(map[string]any{
+		"token_hash": utils.HashToken(token),
+		"expires_at": message.ExpiresAt,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return newOutboxMessage(models.OutboxInvitation, message)
+}
+
+// PurgeDeadLetters deletes the messages that failed longer than retention ago and returns how many were
+// deleted
+func (s *OutboxService) PurgeDeadLetters(retention time.Duration) (int64, error) {
+	result := s.db.Where("failed_at < ?", time.Now().Add(-retention)).Delete(&models.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// StartRetention purges dead letters past the retention, 30 days when it is zero, every interval until the
+// context is cancelled
+func (s *OutboxService) StartRetention(ctx context.Context, interval, retention time.Duration) {
+	if retention <= 0 {
+		retention = defaultDeadLetterRetention
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if purged, err := s.PurgeDeadLetters(retention); err != nil {
+					log.Printf("Failed to purge outbox dead letters: %v", err)
+				} else if purged > 0 {
+					log.Printf("Purged %d outbox dead letters", purged)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxService_ClaimRetryAndPublish(t *testing.T) {
+	outboxService := NewOutboxService(DBOperationService.db)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	due := models.OutboxMessage{MessageType: models.OutboxPasswordDelivery, Payload: `{}`, NextAttemptAt: time.Now().Add(-time.Second)}
+	later := models.OutboxMessage{MessageType: models.OutboxPasswordDelivery, Payload: `{}`, NextAttemptAt: time.Now().Add(time.Hour)}
+	require.NoError(t, DBOperationService.db.Create(&due).Error)
+	require.NoError(t, DBOperationService.db.Create(&later).Error)
+
+	claimed, err := outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.ID, claimed[0].ID)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	// the lease hides the claimed message from other relays
+	claimed, err = outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	require.NoError(t, outboxService.MarkRetry(due.ID, "broker down", time.Now().Add(-time.Second)))
+	claimed, err = outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 2, claimed[0].Attempts)
+	assert.Equal(t, "broker down", *claimed[0].LastError)
+
+	require.NoError(t, outboxService.MarkPublished(due.ID))
+	var count int64
+	require.NoError(t, DBOperationService.db.Model(&models.OutboxMessage{}).Where("id = ?", due.ID).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestOutboxService_MarkFailed(t *testing.T) {
+	outboxService := NewOutboxService(DBOperationService.db)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	message := models.OutboxMessage{
+		MessageType:   models.OutboxPasswordSetupLink,
+		Payload:       `{"email":"user@example.com","password":"Temp-Pass-1","token":"raw-token","setup_url":"https://example.com/setup?token=raw-token"}`,
+		NextAttemptAt: time.Now().Add(-time.Second),
+	}
+	require.NoError(t, DBOperationService.db.Create(&message).Error)
+	require.NoError(t, outboxService.MarkFailed(message.ID, "rejected"))
+
+	claimed, err := outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	var failed models.OutboxMessage
+	require.NoError(t, DBOperationService.db.First(&failed, message.ID).Error)
+	assert.NotNil(t, failed.FailedAt)
+	assert.Equal(t, "rejected", *failed.LastError)
+	assert.JSONEq(t, `{"email":"user@example.com"}`, failed.Payload)
+	assert.NotContains(t, failed.Payload, "Temp-Pass-1")
+	assert.NotContains(t, failed.Payload, "raw-token")
+}
+
+func TestOutboxService_PurgeDeadLetters(t *testing.T) {
+	outboxService := NewOutboxService(DBOperationService.db)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	var ids []uint64
+	for _, failedAt := range []time.Time{time.Now().Add(-48 * time.Hour), time.Now()} {
+		message := models.OutboxMessage{MessageType: models.OutboxEmailChangeNotice, Payload: `{}`, NextAttemptAt: time.Now(), FailedAt: &failedAt}
+		require.NoError(t, DBOperationService.db.Create(&message).Error)
+		ids = append(ids, message.ID)
+	}
+	pending := models.OutboxMessage{MessageType: models.OutboxEmailChangeNotice, Payload: `{}`, NextAttemptAt: time.Now().Add(-48 * time.Hour)}
+	require.NoError(t, DBOperationService.db.Create(&pending).Error)
+
+	purged, err := outboxService.PurgeDeadLetters(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var remaining []uint64
+	require.NoError(t, DBOperationService.db.Model(&models.OutboxMessage{}).Order("id").Pluck("id", &remaining).Error)
+	assert.Equal(t, []uint64{ids[1], pending.ID}, remaining)
+}
+
+func TestOutboxService_DeadLetters(t *testing.T) {
+	outboxService := NewOutboxService(DBOperationService.db)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+
+	var ids []uint64
+	for _, messageType := range []string{models.OutboxEmailChangeNotice, models.OutboxPasswordSetupLink, models.OutboxEmailChangeNotice} {
+		message := models.OutboxMessage{MessageType: messageType, Payload: `{"email":"user@example.com"}`, NextAttemptAt: time.Now()}
+		require.NoError(t, DBOperationService.db.Create(&message).Error)
+		require.NoError(t, outboxService.MarkFailed(message.ID, "rejected"))
+		ids = append(ids, message.ID)
+	}
+	pending := models.OutboxMessage{MessageType: models.OutboxEmailChangeNotice, Payload: `{}`, NextAttemptAt: time.Now().Add(time.Hour)}
+	require.NoError(t, DBOperationService.db.Create(&pending).Error)
+
+	deadLetters, err := outboxService.ListDeadLetters(0, 2, "")
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 2)
+	assert.Equal(t, ids[0], deadLetters[0].ID)
+	assert.Equal(t, "rejected", *deadLetters[0].LastError)
+	assert.False(t, deadLetters[0].FailedAt.IsZero())
+
+	deadLetters, err = outboxService.ListDeadLetters(deadLetters[1].ID, 2, "")
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, ids[2], deadLetters[0].ID)
+
+	deadLetters, err = outboxService.ListDeadLetters(0, 10, models.OutboxPasswordSetupLink)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, ids[1], deadLetters[0].ID)
+
+	require.NoError(t, outboxService.ReplayDeadLetter(ids[0]))
+	assert.ErrorIs(t, outboxService.ReplayDeadLetter(ids[0]), ErrDeadLetterNotFound)
+	assert.ErrorIs(t, outboxService.ReplayDeadLetter(pending.ID), ErrDeadLetterNotFound)
+	// the setup link lost its token when it failed, and has no user to issue a new one to
+	assert.ErrorIs(t, outboxService.ReplayDeadLetter(ids[1]), ErrDeadLetterStale)
+	claimed, err := outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, ids[0], claimed[0].ID)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	replayed, err := outboxService.ReplayDeadLetters("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), replayed)
+	deadLetters, err = outboxService.ListDeadLetters(0, 10, "")
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, ids[1], deadLetters[0].ID)
+}
+
+func TestOutboxService_ReplayPasswordDeliveryIssuesNewPassword(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	outboxService := NewOutboxService(DBOperationService.db)
+	relayConfig := OutboxRelayConfig{BatchSize: 10, MaxAttempts: 1}
+
+	registration, err := newRegistration(models.UserRegitrationRequest{
+		Email: mocks.TestUserEmail, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName,
+	}, models.OnboardingConfig{Mode: models.OnboardingPassword})
+	require.NoError(t, err)
+	require.NoError(t, DBOperationService.CreateUserWithOutbox(&registration.user, &registration.userDetail, registration.message))
+	var lost models.UserCredentials
+	require.NoError(t, json.Unmarshal([]byte(registration.message.Payload), &lost))
+
+	// the only attempt fails, the password is redacted with the dead letter
+	_, err = NewOutboxRelay(outboxService, &mocks.MockPasswordDeliveryService{ShouldFail: true}, relayConfig).RelayOnce()
+	require.NoError(t, err)
+	deadLetters, err := outboxService.ListDeadLetters(0, 10, "")
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+
+	require.NoError(t, outboxService.ReplayDeadLetter(deadLetters[0].ID))
+	assert.ErrorIs(t, outboxService.ReplayDeadLetter(deadLetters[0].ID), ErrDeadLetterNotFound)
+
+	deliveryService := &recordingPasswordDeliveryService{}
+	published, err := NewOutboxRelay(outboxService, deliveryService, relayConfig).RelayOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	require.Len(t, deliveryService.credentials, 1)
+	delivered := deliveryService.credentials[0]
+	assert.Equal(t, registration.user.ID, delivered.UserID)
+	assert.Equal(t, mocks.TestUserEmail, delivered.Email)
+	assert.Equal(t, mocks.TestUserFirstName, delivered.FirstName)
+	assert.NotEmpty(t, delivered.Password)
+	assert.NotEqual(t, lost.Password, delivered.Password)
+
+	// the user logs in with the new password, the lost one no longer works
+	user, err := DBOperationService.FindUserByID(registration.user.ID)
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPasswordHash(delivered.Password, user.Password))
+	assert.False(t, utils.CheckPasswordHash(lost.Password, user.Password))
+
+	deadLetters, err = outboxService.ListDeadLetters(0, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestOutboxService_ReplayDeadLettersIssuesNewCredentials(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.PasswordSetupToken{})
+	outboxService := NewOutboxServiceWithReissue(DBOperationService.db, models.CredentialReissueConfig{
+		Onboarding: models.OnboardingConfig{SetupURL: "https://app.example.com/set-password", TokenTTL: time.Hour},
+	})
+
+	registration, err := newRegistration(models.UserRegitrationRequest{
+		Email: mocks.TestUserEmail, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName,
+	}, models.OnboardingConfig{Mode: models.OnboardingSetupLink, SetupURL: "https://app.example.com/set-password", TokenTTL: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, DBOperationService.CreateUserWithPasswordSetup(&registration.user, &registration.userDetail, registration.setupToken, registration.message))
+	var lost models.PasswordSetupLink
+	require.NoError(t, json.Unmarshal([]byte(registration.message.Payload), &lost))
+	require.NoError(t, outboxService.MarkFailed(registration.message.ID, "rejected"))
+
+	replayed, err := outboxService.ReplayDeadLetters(models.OutboxPasswordSetupLink)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), replayed)
+
+	claimed, err := outboxService.ClaimDue(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.NotEqual(t, registration.message.EventID, claimed[0].EventID)
+	var link models.PasswordSetupLink
+	require.NoError(t, json.Unmarshal([]byte(claimed[0].Payload), &link))
+	assert.Equal(t, "https://app.example.com/set-password?token="+link.Token, link.SetupURL)
+
+	// only the new token sets the password
+	passwordSetupService := NewPasswordSetupService(DBOperationService.db, nil)
+	assert.ErrorIs(t, passwordSetupService.CompletePasswordSetup(lost.Token, "correct horse"), ErrInvalidSetupToken)
+	require.NoError(t, passwordSetupService.CompletePasswordSetup(link.Token, "correct horse"))
+
+	// once the password is chosen a failed link is not issued again
+	require.NoError(t, outboxService.MarkFailed(claimed[0].ID, "rejected"))
+	assert.ErrorIs(t, outboxService.ReplayDeadLetter(claimed[0].ID), ErrDeadLetterStale)
+}
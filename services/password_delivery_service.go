@@ -8,6 +8,8 @@ const (
 	POSTGRESQL  PasswordDeliveryType = "POSTGRESQL"
 	REDIS       PasswordDeliveryType = "REDIS"
 	KAFKA_TOPIC PasswordDeliveryType = "KAFKA_TOPIC"
+	SMTP        PasswordDeliveryType = "SMTP"
+	WEBHOOK     PasswordDeliveryType = "WEBHOOK"
 )
 
 func (pst PasswordDeliveryType) String() string {
@@ -18,6 +20,10 @@ func (pst PasswordDeliveryType) String() string {
 		return "REDIS"
 	case KAFKA_TOPIC:
 		return "KAFKA_TOPIC"
+	case SMTP:
+		return "SMTP"
+	case WEBHOOK:
+		return "WEBHOOK"
 	default:
 		return ""
 	}
@@ -26,3 +32,22 @@ func (pst PasswordDeliveryType) String() string {
 type PasswordDeliveryService interface {
 	SendPassword(credentials models.UserCredentials) error
 }
+
+// SetupLinkDeliveryService is implemented by the password delivery services that can also send the
+// one-time link a new user sets their password with
+type SetupLinkDeliveryService interface {
+	SendSetupLink(link models.PasswordSetupLink) error
+}
+
+// EmailChangeDeliveryService is implemented by the password delivery services that can also send the
+// confirmation of a new email address and the notice to the previous one
+type EmailChangeDeliveryService interface {
+	SendEmailChangeConfirmation(confirmation models.EmailChangeConfirmation) error
+	SendEmailChangeNotice(notice models.EmailChangeNotice) error
+}
+
+// InvitationDeliveryService is implemented by the password delivery services that can also send the
+// invitation to create an account
+type InvitationDeliveryService interface {
+	SendInvitation(invitation models.UserInvitation) error
+}
@@ -15,6 +15,7 @@ func TestPasswordDeliveryType_String(t *testing.T) {
 		{"PostgreSQL type", POSTGRESQL, "POSTGRESQL"},
 		{"Redis type", REDIS, "REDIS"},
 		{"Kafka topic type", KAFKA_TOPIC, "KAFKA_TOPIC"},
+		{"SMTP type", SMTP, "SMTP"},
 		{"Unknown type", PasswordDeliveryType("UNKNOWN"), ""},
 	}
 
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidSetupToken = errors.New("Invalid or expired password setup token")
+
+type IPasswordSetupService interface {
+	CompletePasswordSetup(token, password string) error
+}
+
+type PasswordSetupService struct {
+	db     *gorm.DB
+	events IEventPublisher
+}
+
+// NewPasswordSetupService publishes user.password_changed when a setup link is redeemed, and user.status_changed
+// when that activates a pending user
+func NewPasswordSetupService(db *gorm.DB, events IEventPublisher) *PasswordSetupService {
+	return &PasswordSetupService{db: db, events: events}
+}
+
+// CompletePasswordSetup sets the password of the user the token was issued to and uses the token up. A
+// user pending verification becomes active with it.
+func (s *PasswordSetupService) CompletePasswordSetup(token, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	var setupToken models.PasswordSetupToken
+	var user models.User
+	var activated bool
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).
+			First(&setupToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidSetupToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, setupToken.UserID).Error; err != nil {
+			return err
+		}
+		// the link was sent to the address, redeeming it proves the user controls it
+		if err := tx.Model(&user).Updates(map[string]any{"password": hashedPassword, "email_verified_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if activated = user.Status == models.UserStatusPendingVerification; activated {
+			if err := tx.Model(&user).Updates(map[string]any{"status": models.UserStatusActive, "status_changed_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&setupToken).Update("used_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+	publishEvent(s.events, models.EventUserPasswordChanged, &setupToken.UserID, models.UserPasswordChangedData{Method: models.EventMethodPasswordSetup})
+	if activated {
+		publishEvent(s.events, models.EventUserStatusChanged, &setupToken.UserID, models.UserStatusChangedData{
+			Email:          user.Email,
+			Status:         models.UserStatusActive,
+			PreviousStatus: models.UserStatusPendingVerification,
+			Method:         models.EventMethodPasswordSetup,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordSetupService_CompletePasswordSetup(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	events := NewInMemoryEventPublisher()
+	setupService := NewPasswordSetupService(DBOperationService.db, events)
+
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash, Status: models.UserStatusPendingVerification}
+	userDetails := &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+	require.NoError(t, DBOperationService.CreateUser(user, userDetails))
+	valid := models.PasswordSetupToken{TokenHash: utils.HashToken("valid-token"), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	expired := models.PasswordSetupToken{TokenHash: utils.HashToken("expired-token"), UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, DBOperationService.db.Create(&valid).Error)
+	require.NoError(t, DBOperationService.db.Create(&expired).Error)
+
+	assert.Equal(t, ErrInvalidSetupToken, setupService.CompletePasswordSetup("expired-token", "chosen-password"))
+	assert.Equal(t, ErrInvalidSetupToken, setupService.CompletePasswordSetup("unknown-token", "chosen-password"))
+
+	require.NoError(t, setupService.CompletePasswordSetup("valid-token", "chosen-password"))
+	updated, err := DBOperationService.FindUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPasswordHash("chosen-password", updated.Password))
+	require.Len(t, events.EventsOfType(models.EventUserPasswordChanged), 1)
+	// choosing a password verifies the address the link was sent to
+	assert.Equal(t, models.UserStatusActive, updated.Status)
+	statusChanged := events.EventsOfType(models.EventUserStatusChanged)
+	require.Len(t, statusChanged, 1)
+	assert.Contains(t, string(statusChanged[0].Data), `"method":"password_setup"`)
+
+	// a token works only once
+	assert.Equal(t, ErrInvalidSetupToken, setupService.CompletePasswordSetup("valid-token", "another-password"))
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultAcknowledgedRetention   = 24 * time.Hour
+	defaultUnacknowledgedRetention = 7 * 24 * time.Hour
+)
+
+var ErrDeliveryNotClaimed = errors.New("delivery is not claimed by this consumer")
+
+// PostgresPasswordDeliveryService writes credential notifications to the password_deliveries table.
+// Consumers claim pending rows with SELECT ... FOR UPDATE SKIP LOCKED so that several of them can poll
+// concurrently, and acknowledge them once delivered. A claim that is not acknowledged before it
+// expires makes the row available again.
+type PostgresPasswordDeliveryService struct {
+	db                      *gorm.DB
+	acknowledgedRetention   time.Duration
+	unacknowledgedRetention time.Duration
+}
+
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 24h", key)
+	}
+	return duration, nil
+}
+
+// NewPostgresPasswordDeliveryService reads the retention policy from PASSWORD_DELIVERY_ACKED_RETENTION
+// and PASSWORD_DELIVERY_UNACKED_RETENTION
+func NewPostgresPasswordDeliveryService(db *gorm.DB) (*PostgresPasswordDeliveryService, error) {
+	if db == nil {
+		return nil, errors.New("database connection is required for postgres password delivery")
+	}
+	acknowledgedRetention, err := durationFromEnv("PASSWORD_DELIVERY_ACKED_RETENTION", defaultAcknowledgedRetention)
+	if err != nil {
+		return nil, err
+	}
+	unacknowledgedRetention, err := durationFromEnv("PASSWORD_DELIVERY_UNACKED_RETENTION", defaultUnacknowledgedRetention)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresPasswordDeliveryService{
+		db:                      db,
+		acknowledgedRetention:   acknowledgedRetention,
+		unacknowledgedRetention: unacknowledgedRetention,
+	}, nil
+}
+
+func (s *PostgresPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
+	payload, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	delivery := models.PasswordDelivery{Email: credentials.Email, Payload: string(payload)}
+	if err := s.db.Create(&delivery).Error; err != nil {
+		log.Printf("Failed to queue password delivery: %v", err)
+		return err
+	}
+	log.Printf("Password queued in password_deliveries for user %s", credentials.Email)
+	return nil
+}
+
+// ClaimDeliveries locks up to limit pending deliveries for the consumer until the visibility timeout passes
+func (s *PostgresPasswordDeliveryService) ClaimDeliveries(consumer string, limit int, visibility time.Duration) ([]models.PasswordDelivery, error) {
+	var deliveries []models.PasswordDelivery
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("acknowledged_at IS NULL AND (claim_expires_at IS NULL OR claim_expires_at < ?)", now).
+			Order("id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		expiresAt := now.Add(visibility)
+		err = tx.Model(&models.PasswordDelivery{}).Where("id IN ?", ids).Updates(map[string]any{
+			"claimed_by":       consumer,
+			"claimed_at":       now,
+			"claim_expires_at": expiresAt,
+			"attempts":         gorm.Expr("attempts + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		for i := range deliveries {
+			deliveries[i].ClaimedBy = &consumer
+			deliveries[i].ClaimedAt = &now
+			deliveries[i].ClaimExpiresAt = &expiresAt
+			deliveries[i].Attempts++
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// AcknowledgeDelivery marks a claimed delivery as done and clears the credentials from the row
+func (s *PostgresPasswordDeliveryService) AcknowledgeDelivery(id uint64, consumer string) error {
+	result := s.db.Model(&models.PasswordDelivery{}).
+		Where("id = ? AND claimed_by = ? AND acknowledged_at IS NULL", id, consumer).
+		Updates(map[string]any{
+			"acknowledged_at": time.Now(),
+			"payload":         gorm.Expr("'{}'::jsonb"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeliveryNotClaimed
+	}
+	return nil
+}
+
+// PurgeExpired applies the retention policy and returns the number of deleted rows
+func (s *PostgresPasswordDeliveryService) PurgeExpired() (int64, error) {
+	now := time.Now()
+	result := s.db.Where(
+		"(acknowledged_at IS NOT NULL AND acknowledged_at < ?) OR (acknowledged_at IS NULL AND created_at < ?)",
+		now.Add(-s.acknowledgedRetention), now.Add(-s.unacknowledgedRetention),
+	).Delete(&models.PasswordDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// StartRetention purges expired deliveries every interval until the context is cancelled
+func (s *PostgresPasswordDeliveryService) StartRetention(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if purged, err := s.PurgeExpired(); err != nil {
+					log.Printf("Failed to purge password deliveries: %v", err)
+				} else if purged > 0 {
+					log.Printf("Purged %d password deliveries", purged)
+				}
+			}
+		}
+	}()
+}
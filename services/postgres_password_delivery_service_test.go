@@ -0,0 +1,94 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPostgresDeliveryService(t *testing.T) *PostgresPasswordDeliveryService {
+	deliveryService, err := NewPostgresPasswordDeliveryService(DBOperationService.db)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		DBOperationService.db.Exec("DELETE FROM password_deliveries")
+	})
+	return deliveryService
+}
+
+func TestPostgresPasswordDeliveryService_ClaimAndAcknowledge(t *testing.T) {
+	deliveryService := newTestPostgresDeliveryService(t)
+	require.NoError(t, deliveryService.SendPassword(models.UserCredentials{Email: "first@example.com", FirstName: "First", Password: "secret-1"}))
+	require.NoError(t, deliveryService.SendPassword(models.UserCredentials{Email: "second@example.com", FirstName: "Second", Password: "secret-2"}))
+
+	claimed, err := deliveryService.ClaimDeliveries("mailer-a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, "first@example.com", claimed[0].Email)
+	assert.Contains(t, claimed[0].Payload, "secret-1")
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	// Claimed rows are invisible to other consumers until the claim expires
+	others, err := deliveryService.ClaimDeliveries("mailer-b", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	assert.ErrorIs(t, deliveryService.AcknowledgeDelivery(claimed[0].ID, "mailer-b"), ErrDeliveryNotClaimed)
+	require.NoError(t, deliveryService.AcknowledgeDelivery(claimed[0].ID, "mailer-a"))
+	assert.ErrorIs(t, deliveryService.AcknowledgeDelivery(claimed[0].ID, "mailer-a"), ErrDeliveryNotClaimed)
+
+	var acknowledged models.PasswordDelivery
+	require.NoError(t, DBOperationService.db.First(&acknowledged, claimed[0].ID).Error)
+	assert.NotNil(t, acknowledged.AcknowledgedAt)
+	assert.NotContains(t, acknowledged.Payload, "secret-1")
+}
+
+func TestPostgresPasswordDeliveryService_ExpiredClaimIsRedelivered(t *testing.T) {
+	deliveryService := newTestPostgresDeliveryService(t)
+	require.NoError(t, deliveryService.SendPassword(models.UserCredentials{Email: "first@example.com", Password: "secret-1"}))
+
+	claimed, err := deliveryService.ClaimDeliveries("mailer-a", 1, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	time.Sleep(10 * time.Millisecond)
+
+	reclaimed, err := deliveryService.ClaimDeliveries("mailer-b", 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, reclaimed, 1)
+	assert.Equal(t, claimed[0].ID, reclaimed[0].ID)
+	assert.Equal(t, 2, reclaimed[0].Attempts)
+	assert.ErrorIs(t, deliveryService.AcknowledgeDelivery(claimed[0].ID, "mailer-a"), ErrDeliveryNotClaimed)
+}
+
+func TestPostgresPasswordDeliveryService_PurgeExpired(t *testing.T) {
+	deliveryService := newTestPostgresDeliveryService(t)
+	deliveryService.acknowledgedRetention = time.Hour
+	deliveryService.unacknowledgedRetention = 2 * time.Hour
+	now := time.Now()
+	old := now.Add(-3 * time.Hour)
+	require.NoError(t, DBOperationService.db.Create(&[]models.PasswordDelivery{
+		{Email: "acked-old@example.com", Payload: "{}", CreatedAt: old, AcknowledgedAt: &old},
+		{Email: "acked-new@example.com", Payload: "{}", CreatedAt: old, AcknowledgedAt: &now},
+		{Email: "pending-old@example.com", Payload: "{}", CreatedAt: old},
+		{Email: "pending-new@example.com", Payload: "{}", CreatedAt: now},
+	}).Error)
+
+	purged, err := deliveryService.PurgeExpired()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	var remaining []string
+	require.NoError(t, DBOperationService.db.Model(&models.PasswordDelivery{}).Order("email").Pluck("email", &remaining).Error)
+	assert.Equal(t, []string{"acked-new@example.com", "pending-new@example.com"}, remaining)
+}
+
+func TestNewPostgresPasswordDeliveryService_InvalidRetention(t *testing.T) {
+	t.Setenv("PASSWORD_DELIVERY_ACKED_RETENTION", "forever")
+
+	deliveryService, err := NewPostgresPasswordDeliveryService(DBOperationService.db)
+
+	assert.Nil(t, deliveryService)
+	assert.EqualError(t, err, "PASSWORD_DELIVERY_ACKED_RETENTION must be a positive duration such as 24h")
+}
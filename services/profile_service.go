@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+)
+
+const maxNameLength = 100
+
+var (
+	ErrProfileNotFound = errors.New("user not found")
+	ErrInvalidProfile  = errors.New("invalid profile")
+)
+
+type IProfileService interface {
+	GetProfile(userID uint) (*models.UserProfile, error)
+	UpdateProfile(userID uint, input models.UpdateProfileRequest) (*models.UserProfile, error)
+}
+
+// ProfileService lets users read and change their own details
+type ProfileService struct {
+	dbService   IDatabaseOperationService
+	roleService IRoleService
+	events      IEventPublisher
+}
+
+// NewProfileService publishes user.updated after a user edits their own details
+func NewProfileService(dbService IDatabaseOperationService, roleService IRoleService, events IEventPublisher) *ProfileService {
+	return &ProfileService{dbService: dbService, roleService: roleService, events: events}
+}
+
+func (s *ProfileService) GetProfile(userID uint) (*models.UserProfile, error) {
+	user, err := s.dbService.FindUserByID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	userDetail, err := s.dbService.FindUserDetailsByUserID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	roles, err := s.roleService.FindRolesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := &models.UserProfile{
+		UserDetail:         *userDetail,
+		Email:              user.Email,
+		Roles:              []string{},
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          latest(user.UpdatedAt, userDetail.UpdatedAt),
+		LastLoginAt:        user.LastLoginAt,
+		LastLoginIP:        user.LastLoginIP,
+		LastLoginUserAgent: user.LastLoginUserAgent,
+	}
+	for _, role := range roles {
+		profile.Roles = append(profile.Roles, role.RoleName)
+	}
+	return profile, nil
+}
+
+// UpdateProfile applies the fields present in input and returns the updated profile. Invalid values fail
+// with ErrInvalidProfile before anything is changed.
+func (s *ProfileService) UpdateProfile(userID uint, input models.UpdateProfileRequest) (*models.UserProfile, error) {
+	if input.FirstName == nil && input.MiddleName == nil && input.LastName == nil && input.PreferredLocale == nil {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidProfile)
+	}
+	profile, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userDetail := profile.UserDetail
+	if input.FirstName != nil {
+		if userDetail.FirstName, err = validName("first_name", *input.FirstName, true, ErrInvalidProfile); err != nil {
+			return nil, err
+		}
+	}
+	if input.MiddleName != nil {
+		if userDetail.MiddleName, err = validName("middle_name", *input.MiddleName, false, ErrInvalidProfile); err != nil {
+			return nil, err
+		}
+	}
+	if input.LastName != nil {
+		if userDetail.LastName, err = validName("last_name", *input.LastName, true, ErrInvalidProfile); err != nil {
+			return nil, err
+		}
+	}
+	if input.PreferredLocale != nil {
+		userDetail.PreferredLocale = ""
+		if locale := strings.TrimSpace(*input.PreferredLocale); locale != "" {
+			if userDetail.PreferredLocale, err = utils.NormalizeLocale(locale); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+			}
+		}
+	}
+
+	if err := s.dbService.UpdateUserDetails(&userDetail); err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	profile.UserDetail = userDetail
+	profile.UpdatedAt = latest(profile.UpdatedAt, userDetail.UpdatedAt)
+	publishEvent(s.events, models.EventUserUpdated, &userID, models.UserUpdatedData{
+		Email:      profile.Email,
+		FirstName:  userDetail.FirstName,
+		MiddleName: userDetail.MiddleName,
+		LastName:   userDetail.LastName,
+	})
+	return profile, nil
+}
+
+// validName trims the name and checks that it fits its column, a problem is reported wrapping invalid
+func validName(field, name string, required bool, invalid error) (string, error) {
+	name = strings.TrimSpace(name)
+	if problem := nameProblem(field, name, required); problem != "" {
+		return "", fmt.Errorf("%w: %s", invalid, problem)
+	}
+	return name, nil
+}
+
+// nameProblem describes what is wrong with a trimmed name, it is empty when the name is valid
+func nameProblem(field, name string, required bool) string {
+	if required && name == "" {
+		return field + " must not be empty"
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Sprintf("%s must be at most %d characters", field, maxNameLength)
+	}
+	return ""
+}
+
+// notFoundAs replaces gorm.ErrRecordNotFound with the error of the service
+func notFoundAs(err error, notFound error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
+}
+
+// latest returns the later of two times
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
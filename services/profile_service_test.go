@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func profileTime(day int) time.Time {
+	return time.Date(2026, time.October, day, 9, 0, 0, 0, time.UTC)
+}
+
+func newProfileMocks(userID uint) (*mocks.MockDatabaseOperationService, *mocks.MockRoleService) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	lastLoginAt := profileTime(18)
+	mockDB.On("FindUserByID", userID).Return(&models.User{
+		ID:                 userID,
+		Email:              "test@example.com",
+		CreatedAt:          profileTime(1),
+		UpdatedAt:          profileTime(5),
+		LastLoginAt:        &lastLoginAt,
+		LastLoginIP:        "203.0.113.7",
+		LastLoginUserAgent: "curl/8.5.0",
+	}, nil)
+	mockDB.On("FindUserDetailsByUserID", userID).Return(&models.UserDetail{UserID: userID, FirstName: "John", MiddleName: "Q", LastName: "Doe", UpdatedAt: profileTime(10)}, nil)
+	mockRoles := new(mocks.MockRoleService)
+	mockRoles.On("FindRolesByUserID", userID).Return([]models.Role{{RoleName: "admin"}, {RoleName: "editor"}}, nil)
+	return mockDB, mockRoles
+}
+
+func TestProfileService_GetProfile(t *testing.T) {
+	mockDB, mockRoles := newProfileMocks(7)
+
+	profile, err := NewProfileService(mockDB, mockRoles, nil).GetProfile(7)
+
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", profile.Email)
+	assert.Equal(t, "John", profile.FirstName)
+	assert.Equal(t, []string{"admin", "editor"}, profile.Roles)
+	assert.Equal(t, profileTime(1), profile.CreatedAt)
+	// the details changed after the account
+	assert.Equal(t, profileTime(10), profile.UpdatedAt)
+	assert.Equal(t, profileTime(18), *profile.LastLoginAt)
+	assert.Equal(t, "203.0.113.7", profile.LastLoginIP)
+	assert.Equal(t, "curl/8.5.0", profile.LastLoginUserAgent)
+}
+
+func TestProfileService_GetProfile_NotFound(t *testing.T) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	mockDB.On("FindUserByID", uint(7)).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := NewProfileService(mockDB, new(mocks.MockRoleService), nil).GetProfile(7)
+
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
+
+func TestProfileService_UpdateProfile(t *testing.T) {
+	mockDB, mockRoles := newProfileMocks(7)
+	mockDB.On("UpdateUserDetails", &models.UserDetail{UserID: 7, FirstName: "Jane", MiddleName: "", LastName: "Doe", PreferredLocale: "pt-BR", UpdatedAt: profileTime(10)}).
+		Run(func(args mock.Arguments) {
+			args.Get(0).(*models.UserDetail).UpdatedAt = profileTime(19)
+		}).Return(nil)
+	events := NewInMemoryEventPublisher()
+	firstName, middleName, locale := "  Jane ", "", "pt_br"
+
+	profile, err := NewProfileService(mockDB, mockRoles, events).UpdateProfile(7, models.UpdateProfileRequest{
+		FirstName:       &firstName,
+		MiddleName:      &middleName,
+		PreferredLocale: &locale,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", profile.FirstName)
+	assert.Empty(t, profile.MiddleName)
+	assert.Equal(t, "Doe", profile.LastName)
+	assert.Equal(t, "pt-BR", profile.PreferredLocale)
+	assert.Equal(t, profileTime(19), profile.UpdatedAt)
+	mockDB.AssertExpectations(t)
+	updated := events.EventsOfType(models.EventUserUpdated)
+	require.Len(t, updated, 1)
+	assert.JSONEq(t, `{"email":"test@example.com","first_name":"Jane","middle_name":"","last_name":"Doe"}`, string(updated[0].Data))
+}
+
+func TestProfileService_UpdateProfile_Invalid(t *testing.T) {
+	blank, tooLong, locale := " ", strings.Repeat("a", 101), "klingon please"
+	tests := []struct {
+		name          string
+		input         models.UpdateProfileRequest
+		expectedError string
+	}{
+		{"nothing to update", models.UpdateProfileRequest{}, "invalid profile: no fields to update"},
+		{"blank first name", models.UpdateProfileRequest{FirstName: &blank}, "invalid profile: first_name must not be empty"},
+		{"blank last name", models.UpdateProfileRequest{LastName: &blank}, "invalid profile: last_name must not be empty"},
+		{"long middle name", models.UpdateProfileRequest{MiddleName: &tooLong}, "invalid profile: middle_name must be at most 100 characters"},
+		{"invalid locale", models.UpdateProfileRequest{PreferredLocale: &locale}, "invalid profile: invalid locale klingon please"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockRoles := newProfileMocks(7)
+
+			_, err := NewProfileService(mockDB, mockRoles, nil).UpdateProfile(7, tt.input)
+
+			assert.EqualError(t, err, tt.expectedError)
+			assert.ErrorIs(t, err, ErrInvalidProfile)
+			mockDB.AssertNotCalled(t, "UpdateUserDetails", mock.Anything)
+		})
+	}
+}
+
+func TestProfileService_UpdateProfile_Failure(t *testing.T) {
+	mockDB, mockRoles := newProfileMocks(7)
+	mockDB.On("UpdateUserDetails", mock.Anything).Return(errors.New("connection refused"))
+	events := NewInMemoryEventPublisher()
+	lastName := "Smith"
+
+	_, err := NewProfileService(mockDB, mockRoles, events).UpdateProfile(7, models.UpdateProfileRequest{LastName: &lastName})
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Empty(t, events.Events())
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const defaultRedisStreamTTL = 24 * time.Hour
+
+// StreamClient is the part of the redis client used for stream delivery, *redis.Client satisfies it
+type StreamClient interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XTrimMinID(ctx context.Context, key string, minID string) *redis.IntCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	Close() error
+}
+
+type RedisStreamConfig struct {
+	Stream string
+	// TTL bounds how long an entry stays in the stream, entries older than it are trimmed by ID
+	TTL time.Duration
+	// MaxLen additionally caps the number of entries when greater than zero
+	MaxLen int64
+	// ConsumerGroup is created at startup when set, so consumers reading from it also see
+	// entries published before they first connected
+	ConsumerGroup string
+}
+
+// RedisPasswordDeliveryService appends credential notifications to a Redis Stream. Entries get
+// server generated IDs, which are time ordered and can be read with XREADGROUP and acknowledged
+// with XACK. Since the ID starts with its creation time in milliseconds, entries past the TTL are
+// trimmed with MINID on every publish and by StartRetention.
+type RedisPasswordDeliveryService struct {
+	Client StreamClient
+	Config RedisStreamConfig
+}
+
+func redisOptionsFromEnv() (*redis.Options, error) {
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+		}
+		return options, nil
+	}
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		return nil, errors.New("no redis address found in environment variable")
+	}
+	options := &redis.Options{
+		Addr:     addr,
+		Username: os.Getenv("REDIS_USERNAME"),
+		Password: os.Getenv("REDIS_PASSWORD"),
+	}
+	if db := os.Getenv("REDIS_DB"); db != "" {
+		number, err := strconv.Atoi(db)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid REDIS_DB: %s", db)
+		}
+		options.DB = number
+	}
+	if strings.EqualFold(os.Getenv("REDIS_TLS"), "true") {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return options, nil
+}
+
+func redisStreamConfigFromEnv() (RedisStreamConfig, error) {
+	config := RedisStreamConfig{
+		Stream:        os.Getenv("REDIS_STREAM"),
+		ConsumerGroup: os.Getenv("REDIS_CONSUMER_GROUP"),
+	}
+	if config.Stream == "" {
+		return config, errors.New("no redis stream found in environment variable")
+	}
+	ttl, err := durationFromEnv("REDIS_STREAM_TTL", defaultRedisStreamTTL)
+	if err != nil {
+		return config, err
+	}
+	config.TTL = ttl
+	if maxLen := os.Getenv("REDIS_STREAM_MAXLEN"); maxLen != "" {
+		config.MaxLen, err = strconv.ParseInt(maxLen, 10, 64)
+		if err != nil || config.MaxLen < 0 {
+			return config, fmt.Errorf("invalid REDIS_STREAM_MAXLEN: %s", maxLen)
+		}
+	}
+	return config, nil
+}
+
+// NewRedisPasswordDeliveryService connects with REDIS_URL, or REDIS_ADDR, REDIS_USERNAME, REDIS_PASSWORD,
+// REDIS_DB and REDIS_TLS, and publishes to REDIS_STREAM
+func NewRedisPasswordDeliveryService() (*RedisPasswordDeliveryService, error) {
+	options, err := redisOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	config, err := redisStreamConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+
+	service, err := NewRedisPasswordDeliveryServiceWithClient(client, config)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return service, nil
+}
+
+func NewRedisPasswordDeliveryServiceWithClient(client StreamClient, config RedisStreamConfig) (*RedisPasswordDeliveryService, error) {
+	if config.Stream == "" {
+		return nil, errors.New("redis stream name is required")
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultRedisStreamTTL
+	}
+	if config.ConsumerGroup != "" {
+		err := client.XGroupCreateMkStream(context.Background(), config.Stream, config.ConsumerGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, fmt.Errorf("failed to create redis consumer group: %v", err)
+		}
+	}
+	return &RedisPasswordDeliveryService{Client: client, Config: config}, nil
+}
+
+// minID is the smallest ID an entry created within the TTL can have
+func (s *RedisPasswordDeliveryService) minID() string {
+	return strconv.FormatInt(time.Now().Add(-s.Config.TTL).UnixMilli(), 10)
+}
+
+func (s *RedisPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
+	payload, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	id, err := s.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.Config.Stream,
+		MaxLen: s.Config.MaxLen,
+		ID:     "*",
+		Values: []any{"email", credentials.Email, "payload", string(payload)},
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to add message to Redis stream: %v", err)
+		return err
+	}
+	if _, err := s.Trim(ctx); err != nil {
+		log.Printf("Failed to trim Redis stream %s: %v", s.Config.Stream, err)
+	}
+
+	log.Printf("Password sent to Redis stream %s as %s for user %s", s.Config.Stream, id, credentials.Email)
+	return nil
+}
+
+// Trim removes entries older than the TTL and returns how many were removed
+func (s *RedisPasswordDeliveryService) Trim(ctx context.Context) (int64, error) {
+	return s.Client.XTrimMinID(ctx, s.Config.Stream, s.minID()).Result()
+}
+
+// StartRetention trims the stream every interval until the context is cancelled, so entries
+// expire even when nothing new is published
+func (s *RedisPasswordDeliveryService) StartRetention(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if trimmed, err := s.Trim(ctx); err != nil {
+					log.Printf("Failed to trim Redis stream %s: %v", s.Config.Stream, err)
+				} else if trimmed > 0 {
+					log.Printf("Trimmed %d entries from Redis stream %s", trimmed, s.Config.Stream)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisDelivery(t *testing.T, config RedisStreamConfig) (*miniredis.Miniredis, *RedisPasswordDeliveryService) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	service, err := NewRedisPasswordDeliveryServiceWithClient(client, config)
+	require.NoError(t, err)
+	return server, service
+}
+
+func TestRedisPasswordDeliveryService_SendPassword(t *testing.T) {
+	server, service := setupRedisDelivery(t, RedisStreamConfig{Stream: "credentials", TTL: time.Hour})
+
+	credentials := models.UserCredentials{Email: "test@example.com", FirstName: "John", Password: "securePassword123"}
+	require.NoError(t, service.SendPassword(credentials))
+	require.NoError(t, service.SendPassword(credentials))
+
+	entries, err := server.Stream("credentials")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Less(t, entries[0].ID, entries[1].ID)
+	assert.Equal(t, []string{"email", "test@example.com", "payload", entries[0].Values[3]}, entries[0].Values)
+
+	var delivered models.UserCredentials
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Values[3]), &delivered))
+	assert.Equal(t, credentials, delivered)
+}
+
+func TestRedisPasswordDeliveryService_SendPasswordTrimsExpiredEntries(t *testing.T) {
+	server, service := setupRedisDelivery(t, RedisStreamConfig{Stream: "credentials", TTL: time.Hour})
+	expiredID := time.Now().Add(-2 * time.Hour).UnixMilli()
+	_, err := server.XAdd("credentials", strconv.FormatInt(expiredID, 10)+"-0", []string{"email", "old@example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.SendPassword(models.UserCredentials{Email: "new@example.com"}))
+
+	entries, err := server.Stream("credentials")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "new@example.com", entries[0].Values[1])
+}
+
+func TestRedisPasswordDeliveryService_MaxLen(t *testing.T) {
+	server, service := setupRedisDelivery(t, RedisStreamConfig{Stream: "credentials", TTL: time.Hour, MaxLen: 2})
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		require.NoError(t, service.SendPassword(models.UserCredentials{Email: email}))
+	}
+
+	entries, err := server.Stream("credentials")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "b@example.com", entries[0].Values[1])
+}
+
+func TestRedisPasswordDeliveryService_ConsumerGroupReadsEarlierEntries(t *testing.T) {
+	server, service := setupRedisDelivery(t, RedisStreamConfig{Stream: "credentials", ConsumerGroup: "mailers"})
+	require.NoError(t, service.SendPassword(models.UserCredentials{Email: "test@example.com"}))
+
+	// creating the group again, as a restarted instance would, is not an error
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	_, err := NewRedisPasswordDeliveryServiceWithClient(client, service.Config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "mailers", Consumer: "mailer-1", Streams: []string{"credentials", ">"}, Count: 10,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, streams, 1)
+	require.Len(t, streams[0].Messages, 1)
+	message := streams[0].Messages[0]
+	assert.Equal(t, "test@example.com", message.Values["email"])
+	assert.Equal(t, int64(1), client.XAck(ctx, "credentials", "mailers", message.ID).Val())
+}
+
+func TestRedisPasswordDeliveryService_SendPasswordError(t *testing.T) {
+	server, service := setupRedisDelivery(t, RedisStreamConfig{Stream: "credentials"})
+	server.Close()
+
+	err := service.SendPassword(models.UserCredentials{Email: "test@example.com"})
+	assert.Error(t, err)
+}
+
+func TestNewRedisPasswordDeliveryService_FromEnvironment(t *testing.T) {
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_URL", "redis://"+server.Addr()+"/0")
+	t.Setenv("REDIS_STREAM", "credentials")
+	t.Setenv("REDIS_STREAM_TTL", "30m")
+	t.Setenv("REDIS_STREAM_MAXLEN", "1000")
+
+	service, err := NewRedisPasswordDeliveryService()
+	require.NoError(t, err)
+	defer service.Client.Close()
+	assert.Equal(t, RedisStreamConfig{Stream: "credentials", TTL: 30 * time.Minute, MaxLen: 1000}, service.Config)
+}
+
+func TestNewRedisPasswordDeliveryService_InvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"missing address", map[string]string{"REDIS_STREAM": "credentials"}},
+		{"missing stream", map[string]string{"REDIS_ADDR": "localhost:6379"}},
+		{"invalid url", map[string]string{"REDIS_URL": "http://localhost", "REDIS_STREAM": "credentials"}},
+		{"invalid db", map[string]string{"REDIS_ADDR": "localhost:6379", "REDIS_DB": "first", "REDIS_STREAM": "credentials"}},
+		{"invalid ttl", map[string]string{"REDIS_ADDR": "localhost:6379", "REDIS_STREAM": "credentials", "REDIS_STREAM_TTL": "-1h"}},
+		{"invalid maxlen", map[string]string{"REDIS_ADDR": "localhost:6379", "REDIS_STREAM": "credentials", "REDIS_STREAM_MAXLEN": "many"}},
+		{"unreachable", map[string]string{"REDIS_ADDR": "127.0.0.1:1", "REDIS_STREAM": "credentials"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			service, err := NewRedisPasswordDeliveryService()
+			assert.Error(t, err)
+			assert.Nil(t, service)
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IRoleService interface {
+	ListRoles(offset, limit int) ([]models.Role, int64, error)
+	FindRoleByID(roleID uint) (*models.Role, error)
+	FindRoleByName(roleName string) (*models.Role, error)
+	CreateRole(role *models.Role) error
+	RenameRole(roleID uint, roleName string) error
+	DeleteRole(roleID uint) error
+	FindRoleMembers(roleID uint) ([]models.User, error)
+	AddRoleMembers(roleID uint, userIDs []uint) error
+	RemoveRoleMembers(roleID uint, userIDs []uint) error
+	SetRoleMembers(roleID uint, userIDs []uint) error
+	FindRolesByUserID(userID uint) ([]models.Role, error)
+	SyncUserRoles(userID uint, grantedRoles []string, managedRoles []string) error
+	HasPermission(userID uint, permissionName string) (bool, error)
+}
+
+// RoleService publishes role.assigned and role.revoked for every user whose roles actually changed
+type RoleService struct {
+	db     *gorm.DB
+	events IEventPublisher
+}
+
+// NewRoleService publishes role.assigned and role.revoked whenever a membership is added or removed
+func NewRoleService(db *gorm.DB, events IEventPublisher) *RoleService {
+	return &RoleService{db: db, events: events}
+}
+
+func (s *RoleService) publishMembershipEvents(eventType string, role models.Role, userIDs []uint) {
+	for _, userID := range userIDs {
+		publishEvent(s.events, eventType, &userID, models.RoleMembershipData{RoleID: role.ID, RoleName: role.RoleName})
+	}
+}
+
+// publishMembershipChanges looks the role up only when there is something to publish
+func (s *RoleService) publishMembershipChanges(roleID uint, assigned, revoked []uint) {
+	if s.events == nil || len(assigned)+len(revoked) == 0 {
+		return
+	}
+	role, err := s.FindRoleByID(roleID)
+	if err != nil {
+		role = &models.Role{ID: roleID}
+	}
+	s.publishMembershipEvents(models.EventRoleAssigned, *role, assigned)
+	s.publishMembershipEvents(models.EventRoleRevoked, *role, revoked)
+}
+
+func memberUserIDs(members []models.UserRole) []uint {
+	userIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	return userIDs
+}
+
+func (s *RoleService) ListRoles(offset, limit int) ([]models.Role, int64, error) {
+	var total int64
+	if err := s.db.Model(&models.Role{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var roles []models.Role
+	if err := s.db.Order("id").Offset(offset).Limit(limit).Find(&roles).Error; err != nil {
+		return nil, 0, err
+	}
+	return roles, total, nil
+}
+
+func (s *RoleService) FindRoleByID(roleID uint) (*models.Role, error) {
+	var role models.Role
+	if err := s.db.First(&role, roleID).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *RoleService) FindRoleByName(roleName string) (*models.Role, error) {
+	var role models.Role
+	if err := s.db.Where("role_name = ?", roleName).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *RoleService) CreateRole(role *models.Role) error {
+	return s.db.Create(role).Error
+}
+
+func (s *RoleService) RenameRole(roleID uint, roleName string) error {
+	return s.db.Model(&models.Role{ID: roleID}).Update("role_name", roleName).Error
+}
+
+func (s *RoleService) DeleteRole(roleID uint) error {
+	role, err := s.FindRoleByID(roleID)
+	if err != nil {
+		return err
+	}
+	var revoked []models.UserRole
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Returning{}).Where("role_id = ?", roleID).Delete(&revoked).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Role{}, roleID).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.publishMembershipEvents(models.EventRoleRevoked, *role, memberUserIDs(revoked))
+	return nil
+}
+
+func (s *RoleService) FindRoleMembers(roleID uint) ([]models.User, error) {
+	var users []models.User
+	err := s.db.Joins("JOIN user_roles ON user_roles.user_id = users.id").
+		Where("user_roles.role_id = ?", roleID).
+		Order("users.id").
+		Find(&users).Error
+	return users, err
+}
+
+func (s *RoleService) AddRoleMembers(roleID uint, userIDs []uint) error {
+	var assigned []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		assigned, err = addRoleMembers(tx, roleID, userIDs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.publishMembershipChanges(roleID, assigned, nil)
+	return nil
+}
+
+// addRoleMembers returns the users that were not members before
+func addRoleMembers(db *gorm.DB, roleID uint, userIDs []uint) ([]uint, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var existing []uint
+	if err := db.Model(&models.UserRole{}).Where("role_id = ? AND user_id IN ?", roleID, userIDs).Pluck("user_id", &existing).Error; err != nil {
+		return nil, err
+	}
+	isMember := map[uint]bool{}
+	for _, userID := range existing {
+		isMember[userID] = true
+	}
+	var assigned []uint
+	var members []models.UserRole
+	for _, userID := range userIDs {
+		if isMember[userID] {
+			continue
+		}
+		isMember[userID] = true
+		assigned = append(assigned, userID)
+		members = append(members, models.UserRole{UserID: userID, RoleID: roleID})
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+func (s *RoleService) RemoveRoleMembers(roleID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var revoked []models.UserRole
+	err := s.db.Clauses(clause.Returning{}).Where("role_id = ? AND user_id IN ?", roleID, userIDs).Delete(&revoked).Error
+	if err != nil {
+		return err
+	}
+	s.publishMembershipChanges(roleID, nil, memberUserIDs(revoked))
+	return nil
+}
+
+func (s *RoleService) SetRoleMembers(roleID uint, userIDs []uint) error {
+	var assigned []uint
+	var revoked []models.UserRole
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		remove := tx.Clauses(clause.Returning{}).Where("role_id = ?", roleID)
+		if len(userIDs) > 0 {
+			remove = remove.Where("user_id NOT IN ?", userIDs)
+		}
+		if err := remove.Delete(&revoked).Error; err != nil {
+			return err
+		}
+		var err error
+		assigned, err = addRoleMembers(tx, roleID, userIDs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.publishMembershipChanges(roleID, assigned, memberUserIDs(revoked))
+	return nil
+}
+
+func (s *RoleService) FindRolesByUserID(userID uint) ([]models.Role, error) {
+	var roles []models.Role
+	err := s.db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.role_name").
+		Find(&roles).Error
+	return roles, err
+}
+
+// HasPermission reports whether any role of the user has the permission
+func (s *RoleService) HasPermission(userID uint, permissionName string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UserRole{}).
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND permissions.permission_name = ?", userID, permissionName).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SyncUserRoles grants the user grantedRoles and takes away any other role in managedRoles, creating
+// roles that do not exist yet. Roles outside managedRoles are left alone so that roles assigned
+// locally survive a sync from an external directory.
+func (s *RoleService) SyncUserRoles(userID uint, grantedRoles []string, managedRoles []string) error {
+	var assigned, revoked []models.Role
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		granted := map[string]bool{}
+		for _, roleName := range grantedRoles {
+			granted[roleName] = true
+		}
+
+		for _, roleName := range managedRoles {
+			if granted[roleName] {
+				continue
+			}
+			var role models.Role
+			err := tx.Where("role_name = ?", roleName).First(&role).Error
+			if err == gorm.ErrRecordNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			result := tx.Where("user_id = ? AND role_id = ?", userID, role.ID).Delete(&models.UserRole{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				revoked = append(revoked, role)
+			}
+		}
+
+		for roleName := range granted {
+			role := models.Role{RoleName: roleName}
+			if err := tx.Where(models.Role{RoleName: roleName}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.UserRole{UserID: userID, RoleID: role.ID})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				assigned = append(assigned, role)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, role := range assigned {
+		s.publishMembershipEvents(models.EventRoleAssigned, role, []uint{userID})
+	}
+	for _, role := range revoked {
+		s.publishMembershipEvents(models.EventRoleRevoked, role, []uint{userID})
+	}
+	return nil
+}
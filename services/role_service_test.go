@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService_SyncUserRoles(t *testing.T) {
+	events := NewInMemoryEventPublisher()
+	roleService := NewRoleService(DBOperationService.db, events)
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+
+	roleNames := func() []string {
+		roles, err := roleService.FindRolesByUserID(user.ID)
+		require.NoError(t, err)
+		var names []string
+		for _, role := range roles {
+			names = append(names, role.RoleName)
+		}
+		return names
+	}
+
+	require.NoError(t, roleService.SyncUserRoles(user.ID, []string{"ldap-admin", "local-editor"}, []string{"ldap-admin", "local-editor"}))
+	assert.Equal(t, []string{"ldap-admin", "local-editor"}, roleNames())
+
+	// Only managed roles are revoked, local-editor is not part of the managed set any more
+	require.NoError(t, roleService.SyncUserRoles(user.ID, []string{"ldap-viewer"}, []string{"ldap-admin", "ldap-viewer"}))
+	assert.Equal(t, []string{"ldap-viewer", "local-editor"}, roleNames())
+
+	assert.Len(t, events.EventsOfType(models.EventRoleAssigned), 3)
+	revoked := events.EventsOfType(models.EventRoleRevoked)
+	require.Len(t, revoked, 1)
+	assert.JSONEq(t, fmt.Sprintf(`{"role_id":%d,"role_name":"ldap-admin"}`, mustFindRole(t, roleService, "ldap-admin").ID), string(revoked[0].Data))
+}
+
+func mustFindRole(t *testing.T, roleService *RoleService, roleName string) *models.Role {
+	role, err := roleService.FindRoleByName(roleName)
+	require.NoError(t, err)
+	return role
+}
+
+func TestRoleService_Membership(t *testing.T) {
+	events := NewInMemoryEventPublisher()
+	roleService := NewRoleService(DBOperationService.db, events)
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+
+	role := &models.Role{RoleName: "scim-engineering"}
+	require.NoError(t, roleService.CreateRole(role))
+	require.NoError(t, roleService.AddRoleMembers(role.ID, []uint{user.ID}))
+	require.NoError(t, roleService.AddRoleMembers(role.ID, []uint{user.ID}))
+	// only the first call changed anything
+	assigned := events.EventsOfType(models.EventRoleAssigned)
+	require.Len(t, assigned, 1)
+	assert.Equal(t, strconv.FormatUint(uint64(user.ID), 10), assigned[0].Subject)
+
+	members, err := roleService.FindRoleMembers(role.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, user.Email, members[0].Email)
+
+	require.NoError(t, roleService.RenameRole(role.ID, "scim-platform"))
+	renamed, err := roleService.FindRoleByName("scim-platform")
+	require.NoError(t, err)
+	assert.Equal(t, role.ID, renamed.ID)
+
+	require.NoError(t, roleService.SetRoleMembers(role.ID, []uint{user.ID}))
+	require.NoError(t, roleService.SetRoleMembers(role.ID, nil))
+	members, err = roleService.FindRoleMembers(role.ID)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+	assert.Len(t, events.EventsOfType(models.EventRoleAssigned), 1)
+	assert.Len(t, events.EventsOfType(models.EventRoleRevoked), 1)
+
+	require.NoError(t, roleService.DeleteRole(role.ID))
+	_, err = roleService.FindRoleByID(role.ID)
+	assert.Error(t, err)
+}
+
+func TestRoleService_HasPermission(t *testing.T) {
+	roleService := NewRoleService(DBOperationService.db, nil)
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}))
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("user_id = ?", user.ID).Delete(&models.UserRole{})
+
+	allowed, err := roleService.HasPermission(user.ID, models.PermissionAdmin)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// the migrations seed the admin role with the admin permission
+	require.NoError(t, roleService.AddRoleMembers(mustFindRole(t, roleService, "admin").ID, []uint{user.ID}))
+	allowed, err = roleService.HasPermission(user.ID, models.PermissionAdmin)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = roleService.HasPermission(user.ID, "unknown")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
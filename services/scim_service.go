@@ -0,0 +1,736 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+// SCIM errors, the messages are the scimType values defined in RFC 7644 section 3.12
+var (
+	ErrSCIMNotFound      = errors.New("notFound")
+	ErrSCIMUniqueness    = errors.New("uniqueness")
+	ErrSCIMInvalidValue  = errors.New("invalidValue")
+	ErrSCIMInvalidFilter = errors.New("invalidFilter")
+	ErrSCIMInvalidPath   = errors.New("invalidPath")
+)
+
+const (
+	SCIMDefaultCount = 100
+	SCIMMaxCount     = 500
+)
+
+var (
+	scimFilterPattern       = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+	scimMemberFilterPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+)
+
+// SCIMService maps SCIM 2.0 Users onto users and user_details, and SCIM Groups onto roles
+// Group membership events come from the role service.
+type SCIMService struct {
+	dbService     IDatabaseOperationService
+	roleService   IRoleService
+	statusService IUserStatusService
+	dataService   IUserDataService
+	events        IEventPublisher
+}
+
+// NewSCIMService publishes user.registered, user.updated and user.password_changed as the identity provider
+// provisions and changes users. Deprovisioned users are erased by dataService, which publishes user.deleted.
+func NewSCIMService(dbService IDatabaseOperationService, roleService IRoleService, dataService IUserDataService, events IEventPublisher) *SCIMService {
+	return &SCIMService{
+		dbService:     dbService,
+		roleService:   roleService,
+		statusService: NewUserStatusService(dbService, events),
+		dataService:   dataService,
+		events:        events,
+	}
+}
+
+func scimErrorf(sentinel error, format string, args ...any) error {
+	return fmt.Errorf("%w: %s", sentinel, fmt.Sprintf(format, args...))
+}
+
+// parseSCIMFilter supports the single `attribute eq "value"` expression provisioning clients send
+// when checking whether a resource already exists
+func parseSCIMFilter(filter string) (string, string, error) {
+	matches := scimFilterPattern.FindStringSubmatch(filter)
+	if matches == nil {
+		return "", "", scimErrorf(ErrSCIMInvalidFilter, "only attribute eq \"value\" filters are supported")
+	}
+	value, err := strconv.Unquote(`"` + matches[2] + `"`)
+	if err != nil {
+		return "", "", scimErrorf(ErrSCIMInvalidFilter, "invalid filter value")
+	}
+	return strings.ToLower(matches[1]), value, nil
+}
+
+func normalizeSCIMPage(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = SCIMDefaultCount
+	}
+	if count > SCIMMaxCount {
+		count = SCIMMaxCount
+	}
+	return startIndex, count
+}
+
+func newSCIMListResponse(total int64, startIndex int, resources []any) *models.SCIMListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+func parseSCIMID(id string) (uint, error) {
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, ErrSCIMNotFound
+	}
+	return uint(parsed), nil
+}
+
+// Users
+
+func (s *SCIMService) ListUsers(baseURL, filter string, startIndex, count int) (*models.SCIMListResponse, error) {
+	startIndex, count = normalizeSCIMPage(startIndex, count)
+	var users []models.User
+	var total int64
+	if filter != "" {
+		attribute, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if attribute != "username" && attribute != "emails.value" {
+			return nil, scimErrorf(ErrSCIMInvalidFilter, "filtering on %s is not supported", attribute)
+		}
+		if user, err := s.dbService.FindUserByEmail(strings.ToLower(value)); err == nil {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				users = []models.User{*user}
+			}
+		}
+	} else {
+		var err error
+		if users, total, err = s.dbService.ListUsers(startIndex-1, count); err != nil {
+			return nil, err
+		}
+	}
+
+	resources := make([]any, 0, len(users))
+	for _, user := range users {
+		resource, err := s.userResource(baseURL, &user)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return newSCIMListResponse(total, startIndex, resources), nil
+}
+
+func (s *SCIMService) GetUser(baseURL, id string) (*models.SCIMUser, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.userResource(baseURL, user)
+}
+
+// CreateUser provisions an account directly. A password sent by the client is stored hashed; without
+// one the account gets a random password nobody knows and is meant for LDAP or federated login.
+func (s *SCIMService) CreateUser(baseURL string, input models.SCIMUser) (*models.SCIMUser, error) {
+	email, err := scimUserEmail(input)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.dbService.FindUserByEmail(email); err == nil {
+		return nil, scimErrorf(ErrSCIMUniqueness, "userName %s is already taken", email)
+	}
+
+	password := input.Password
+	if password == "" {
+		password, err = utils.GenerateRandomPassword(16)
+		if err != nil {
+			return nil, errors.New("Error while generating temporary password and hash")
+		}
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, errors.New("Error while hashing password")
+	}
+
+	user := models.User{Email: email, Password: hashedPassword, Status: models.UserStatusActive}
+	userDetail := scimUserDetail(email, input.Name)
+	if err := s.dbService.CreateUser(&user, &userDetail); err != nil {
+		log.Printf("Error provisioning SCIM user %s: %v", email, err)
+		return nil, errors.New("error while registering user")
+	}
+	publishEvent(s.events, models.EventUserRegistered, &user.ID, models.UserRegisteredData{
+		Email:      user.Email,
+		FirstName:  userDetail.FirstName,
+		MiddleName: userDetail.MiddleName,
+		LastName:   userDetail.LastName,
+		Method:     models.EventMethodSCIM,
+	})
+	if err := s.applySCIMActive(&user, input.Active); err != nil {
+		return nil, err
+	}
+	return s.userResource(baseURL, &user)
+}
+
+func (s *SCIMService) ReplaceUser(baseURL, id string, input models.SCIMUser) (*models.SCIMUser, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, errors.New("Invalid user Id")
+	}
+	if err := s.updateUser(user, userDetails, input); err != nil {
+		return nil, err
+	}
+	return s.userResource(baseURL, user)
+}
+
+func (s *SCIMService) PatchUser(baseURL, id string, patch models.SCIMPatchRequest) (*models.SCIMUser, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, errors.New("Invalid user Id")
+	}
+
+	target := models.SCIMUser{
+		UserName: user.Email,
+		Name: models.SCIMName{
+			GivenName:  userDetails.FirstName,
+			MiddleName: userDetails.MiddleName,
+			FamilyName: userDetails.LastName,
+		},
+		Emails: []models.SCIMEmail{{Value: user.Email, Primary: true}},
+	}
+	for _, operation := range patch.Operations {
+		if err := applySCIMUserOperation(&target, strings.ToLower(operation.Op), operation.Path, operation.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.updateUser(user, userDetails, target); err != nil {
+		return nil, err
+	}
+	return s.userResource(baseURL, user)
+}
+
+// DeleteUser erases the user like an admin erasure does. The row stays with the deleted status, so the ID is
+// never reused, and the user.deleted event tells consumers to erase their copies.
+func (s *SCIMService) DeleteUser(id string) error {
+	user, err := s.findUser(id)
+	if err != nil {
+		return err
+	}
+	err = s.dataService.EraseUser(user.ID, 0)
+	if errors.Is(err, ErrProfileNotFound) || errors.Is(err, ErrUserErased) {
+		return ErrSCIMNotFound
+	}
+	return err
+}
+
+// findUser fails with ErrSCIMNotFound for erased users, they were deleted as far as SCIM is concerned
+func (s *SCIMService) findUser(id string) (*models.User, error) {
+	userID, err := parseSCIMID(id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.dbService.FindUserByID(userID)
+	if err != nil || user.ErasedAt != nil {
+		return nil, ErrSCIMNotFound
+	}
+	return user, nil
+}
+
+// updateUser only replaces the names of userDetails, SCIM knows nothing about the preferred locale
+func (s *SCIMService) updateUser(user *models.User, userDetails *models.UserDetail, input models.SCIMUser) error {
+	email, err := scimUserEmail(input)
+	if err != nil {
+		return err
+	}
+	if email != user.Email {
+		// the lookup ignores case, so the user finds itself when only the case changes
+		if existing, err := s.dbService.FindUserByEmail(email); err == nil && existing.ID != user.ID {
+			return scimErrorf(ErrSCIMUniqueness, "userName %s is already taken", email)
+		}
+	}
+	if input.Password != "" {
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			return errors.New("Error while hashing password")
+		}
+		user.Password = hashedPassword
+	}
+	// the identity provider has not proven the new address
+	if !strings.EqualFold(email, user.Email) {
+		user.EmailVerifiedAt = nil
+	}
+	user.Email = email
+	names := scimUserDetail(email, input.Name)
+	userDetail := *userDetails
+	userDetail.FirstName, userDetail.MiddleName, userDetail.LastName = names.FirstName, names.MiddleName, names.LastName
+	if err := s.dbService.UpdateUser(user, &userDetail); err != nil {
+		log.Printf("Error updating SCIM user %d: %v", user.ID, err)
+		return errors.New("error while updating user")
+	}
+	publishEvent(s.events, models.EventUserUpdated, &user.ID, models.UserUpdatedData{
+		Email:      user.Email,
+		FirstName:  userDetail.FirstName,
+		MiddleName: userDetail.MiddleName,
+		LastName:   userDetail.LastName,
+	})
+	if input.Password != "" {
+		publishEvent(s.events, models.EventUserPasswordChanged, &user.ID, models.UserPasswordChangedData{Method: models.EventMethodSCIM})
+	}
+	return s.applySCIMActive(user, input.Active)
+}
+
+// applySCIMActive maps the active attribute onto the account status: deactivated users are disabled and
+// activated users that were disabled or suspended become active again
+func (s *SCIMService) applySCIMActive(user *models.User, active *bool) error {
+	if active == nil || *active == scimActive(user) {
+		return nil
+	}
+	status := models.UserStatusDisabled
+	if *active {
+		status = models.UserStatusActive
+	}
+	changed, err := s.statusService.ChangeStatus(user.ID, models.UserStatusChange{Status: status, Method: models.EventMethodSCIM})
+	if errors.Is(err, ErrInvalidStatusTransition) {
+		return scimErrorf(ErrSCIMInvalidValue, "%v", err)
+	}
+	if err != nil {
+		return err
+	}
+	user.Status = changed.Status
+	user.StatusReason = changed.Reason
+	user.StatusChangedAt = changed.ChangedAt
+	return nil
+}
+
+// scimActive reports users as active unless they were disabled, suspended or deleted
+func scimActive(user *models.User) bool {
+	return user.Status == models.UserStatusActive || user.Status == models.UserStatusPendingVerification
+}
+
+func (s *SCIMService) userResource(baseURL string, user *models.User) (*models.SCIMUser, error) {
+	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+	if err != nil {
+		return nil, errors.New("Invalid user Id")
+	}
+	roles, err := s.roleService.FindRolesByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := scimActive(user)
+	resource := &models.SCIMUser{
+		Schemas:  []string{models.SCIMUserSchema},
+		ID:       id,
+		UserName: user.Email,
+		Name: models.SCIMName{
+			Formatted:  utils.BuildProfileClaims(user.Email, user.EmailVerifiedAt != nil, *userDetails).Name,
+			GivenName:  userDetails.FirstName,
+			MiddleName: userDetails.MiddleName,
+			FamilyName: userDetails.LastName,
+		},
+		Emails: []models.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active: &active,
+		Meta:   &models.SCIMMeta{ResourceType: "User", Location: baseURL + "/Users/" + id},
+	}
+	if !user.CreatedAt.IsZero() {
+		created, lastModified := user.CreatedAt, latest(user.UpdatedAt, userDetails.UpdatedAt)
+		resource.Meta.Created, resource.Meta.LastModified = &created, &lastModified
+	}
+	for _, role := range roles {
+		roleID := strconv.FormatUint(uint64(role.ID), 10)
+		resource.Groups = append(resource.Groups, models.SCIMReference{Value: roleID, Display: role.RoleName, Ref: baseURL + "/Groups/" + roleID})
+	}
+	return resource, nil
+}
+
+// scimUserEmail returns the login email, which is the userName or else the primary email
+func scimUserEmail(input models.SCIMUser) (string, error) {
+	email := input.UserName
+	if !strings.Contains(email, "@") && len(input.Emails) > 0 {
+		email = input.Emails[0].Value
+		for _, candidate := range input.Emails {
+			if candidate.Primary {
+				email = candidate.Value
+				break
+			}
+		}
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return "", scimErrorf(ErrSCIMInvalidValue, "userName or a primary email must be an email address")
+	}
+	return email, nil
+}
+
+func scimUserDetail(email string, name models.SCIMName) models.UserDetail {
+	firstName := strings.TrimSpace(name.GivenName)
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(email, "@")
+	}
+	return models.UserDetail{
+		FirstName:  firstName,
+		MiddleName: strings.TrimSpace(name.MiddleName),
+		LastName:   strings.TrimSpace(name.FamilyName),
+	}
+}
+
+func applySCIMUserOperation(target *models.SCIMUser, op, path string, value json.RawMessage) error {
+	if op != "add" && op != "replace" && op != "remove" {
+		return scimErrorf(ErrSCIMInvalidValue, "unsupported patch operation %q", op)
+	}
+	stringValue := func() (string, error) {
+		if op == "remove" {
+			return "", nil
+		}
+		var parsed string
+		if err := json.Unmarshal(value, &parsed); err != nil {
+			return "", scimErrorf(ErrSCIMInvalidValue, "%s must be a string", path)
+		}
+		return parsed, nil
+	}
+
+	lowerPath := strings.ToLower(path)
+	switch {
+	case lowerPath == "":
+		if op == "remove" {
+			return scimErrorf(ErrSCIMInvalidPath, "remove requires a path")
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(value, &attributes); err != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "value must be an object when no path is given")
+		}
+		for attribute, attributeValue := range attributes {
+			if err := applySCIMUserOperation(target, op, attribute, attributeValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	case lowerPath == "username":
+		if op == "remove" {
+			return scimErrorf(ErrSCIMInvalidValue, "userName is required")
+		}
+		userName, err := stringValue()
+		target.UserName = userName
+		return err
+	case lowerPath == "name":
+		if op == "remove" {
+			target.Name = models.SCIMName{}
+			return nil
+		}
+		if err := json.Unmarshal(value, &target.Name); err != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "name must be an object")
+		}
+		return nil
+	case lowerPath == "name.givenname":
+		givenName, err := stringValue()
+		target.Name.GivenName = givenName
+		return err
+	case lowerPath == "name.middlename":
+		middleName, err := stringValue()
+		target.Name.MiddleName = middleName
+		return err
+	case lowerPath == "name.familyname":
+		familyName, err := stringValue()
+		target.Name.FamilyName = familyName
+		return err
+	case lowerPath == "name.formatted", lowerPath == "displayname":
+		// Derived from the name parts
+		return nil
+	case lowerPath == "password":
+		password, err := stringValue()
+		target.Password = password
+		return err
+	case lowerPath == "active":
+		var active bool
+		if op == "remove" || json.Unmarshal(value, &active) != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "active must be a boolean")
+		}
+		target.Active = &active
+		return nil
+	case lowerPath == "emails":
+		if op == "remove" {
+			target.Emails = nil
+			return nil
+		}
+		if err := json.Unmarshal(value, &target.Emails); err != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "emails must be a list")
+		}
+		return nil
+	case strings.HasPrefix(lowerPath, "emails["):
+		email, err := stringValue()
+		if err != nil {
+			return err
+		}
+		target.Emails = []models.SCIMEmail{{Value: email, Primary: true}}
+		return nil
+	}
+	return scimErrorf(ErrSCIMInvalidPath, "unsupported path %q", path)
+}
+
+// Groups
+
+func (s *SCIMService) ListGroups(baseURL, filter string, startIndex, count int, excludeMembers bool) (*models.SCIMListResponse, error) {
+	startIndex, count = normalizeSCIMPage(startIndex, count)
+	var roles []models.Role
+	var total int64
+	if filter != "" {
+		attribute, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if attribute != "displayname" {
+			return nil, scimErrorf(ErrSCIMInvalidFilter, "filtering on %s is not supported", attribute)
+		}
+		if role, err := s.roleService.FindRoleByName(value); err == nil {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				roles = []models.Role{*role}
+			}
+		}
+	} else {
+		var err error
+		if roles, total, err = s.roleService.ListRoles(startIndex-1, count); err != nil {
+			return nil, err
+		}
+	}
+
+	resources := make([]any, 0, len(roles))
+	for _, role := range roles {
+		resource, err := s.groupResource(baseURL, &role, excludeMembers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return newSCIMListResponse(total, startIndex, resources), nil
+}
+
+func (s *SCIMService) GetGroup(baseURL, id string, excludeMembers bool) (*models.SCIMGroup, error) {
+	role, err := s.findRole(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(baseURL, role, excludeMembers)
+}
+
+func (s *SCIMService) CreateGroup(baseURL string, input models.SCIMGroup) (*models.SCIMGroup, error) {
+	displayName := strings.TrimSpace(input.DisplayName)
+	if displayName == "" {
+		return nil, scimErrorf(ErrSCIMInvalidValue, "displayName is required")
+	}
+	if _, err := s.roleService.FindRoleByName(displayName); err == nil {
+		return nil, scimErrorf(ErrSCIMUniqueness, "group %s already exists", displayName)
+	}
+	memberIDs, err := s.memberIDs(input.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{RoleName: displayName}
+	if err := s.roleService.CreateRole(&role); err != nil {
+		log.Printf("Error creating SCIM group %s: %v", displayName, err)
+		return nil, errors.New("error while creating group")
+	}
+	if err := s.roleService.AddRoleMembers(role.ID, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.groupResource(baseURL, &role, false)
+}
+
+func (s *SCIMService) ReplaceGroup(baseURL, id string, input models.SCIMGroup) (*models.SCIMGroup, error) {
+	role, err := s.findRole(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renameRole(role, input.DisplayName); err != nil {
+		return nil, err
+	}
+	memberIDs, err := s.memberIDs(input.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.roleService.SetRoleMembers(role.ID, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.groupResource(baseURL, role, false)
+}
+
+func (s *SCIMService) PatchGroup(baseURL, id string, patch models.SCIMPatchRequest) (*models.SCIMGroup, error) {
+	role, err := s.findRole(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range patch.Operations {
+		if err := s.applyGroupOperation(role, strings.ToLower(operation.Op), operation.Path, operation.Value); err != nil {
+			return nil, err
+		}
+	}
+	return s.groupResource(baseURL, role, false)
+}
+
+func (s *SCIMService) DeleteGroup(id string) error {
+	role, err := s.findRole(id)
+	if err != nil {
+		return err
+	}
+	return s.roleService.DeleteRole(role.ID)
+}
+
+func (s *SCIMService) findRole(id string) (*models.Role, error) {
+	roleID, err := parseSCIMID(id)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.roleService.FindRoleByID(roleID)
+	if err != nil {
+		return nil, ErrSCIMNotFound
+	}
+	return role, nil
+}
+
+func (s *SCIMService) renameRole(role *models.Role, displayName string) error {
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" {
+		return scimErrorf(ErrSCIMInvalidValue, "displayName is required")
+	}
+	if displayName == role.RoleName {
+		return nil
+	}
+	if existing, err := s.roleService.FindRoleByName(displayName); err == nil && existing.ID != role.ID {
+		return scimErrorf(ErrSCIMUniqueness, "group %s already exists", displayName)
+	}
+	if err := s.roleService.RenameRole(role.ID, displayName); err != nil {
+		return err
+	}
+	role.RoleName = displayName
+	return nil
+}
+
+// memberIDs resolves member references to existing user ids
+func (s *SCIMService) memberIDs(members []models.SCIMReference) ([]uint, error) {
+	userIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		userID, err := parseSCIMID(member.Value)
+		if err != nil {
+			return nil, scimErrorf(ErrSCIMInvalidValue, "unknown member %q", member.Value)
+		}
+		if _, err := s.dbService.FindUserByID(userID); err != nil {
+			return nil, scimErrorf(ErrSCIMInvalidValue, "unknown member %q", member.Value)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+func (s *SCIMService) applyGroupOperation(role *models.Role, op, path string, value json.RawMessage) error {
+	if op != "add" && op != "replace" && op != "remove" {
+		return scimErrorf(ErrSCIMInvalidValue, "unsupported patch operation %q", op)
+	}
+	decodeMembers := func() ([]uint, error) {
+		var members []models.SCIMReference
+		if err := json.Unmarshal(value, &members); err != nil {
+			return nil, scimErrorf(ErrSCIMInvalidValue, "members must be a list")
+		}
+		return s.memberIDs(members)
+	}
+
+	lowerPath := strings.ToLower(path)
+	switch {
+	case lowerPath == "":
+		if op == "remove" {
+			return scimErrorf(ErrSCIMInvalidPath, "remove requires a path")
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(value, &attributes); err != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "value must be an object when no path is given")
+		}
+		for attribute, attributeValue := range attributes {
+			if err := s.applyGroupOperation(role, op, attribute, attributeValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	case lowerPath == "displayname":
+		var displayName string
+		if op == "remove" || json.Unmarshal(value, &displayName) != nil {
+			return scimErrorf(ErrSCIMInvalidValue, "displayName must be a string")
+		}
+		return s.renameRole(role, displayName)
+	case lowerPath == "members":
+		if op == "remove" && len(value) == 0 {
+			return s.roleService.SetRoleMembers(role.ID, nil)
+		}
+		userIDs, err := decodeMembers()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			return s.roleService.AddRoleMembers(role.ID, userIDs)
+		case "remove":
+			return s.roleService.RemoveRoleMembers(role.ID, userIDs)
+		}
+		return s.roleService.SetRoleMembers(role.ID, userIDs)
+	case op == "remove" && scimMemberFilterPattern.MatchString(path):
+		userID, err := parseSCIMID(scimMemberFilterPattern.FindStringSubmatch(path)[1])
+		if err != nil {
+			return nil
+		}
+		return s.roleService.RemoveRoleMembers(role.ID, []uint{userID})
+	}
+	return scimErrorf(ErrSCIMInvalidPath, "unsupported path %q", path)
+}
+
+func (s *SCIMService) groupResource(baseURL string, role *models.Role, excludeMembers bool) (*models.SCIMGroup, error) {
+	id := strconv.FormatUint(uint64(role.ID), 10)
+	resource := &models.SCIMGroup{
+		Schemas:     []string{models.SCIMGroupSchema},
+		ID:          id,
+		DisplayName: role.RoleName,
+		Meta:        &models.SCIMMeta{ResourceType: "Group", Location: baseURL + "/Groups/" + id},
+	}
+	if excludeMembers {
+		return resource, nil
+	}
+	members, err := s.roleService.FindRoleMembers(role.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		userID := strconv.FormatUint(uint64(member.ID), 10)
+		resource.Members = append(resource.Members, models.SCIMReference{Value: userID, Display: member.Email, Ref: baseURL + "/Users/" + userID})
+	}
+	return resource, nil
+}
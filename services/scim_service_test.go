@@ -0,0 +1,378 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testSCIMBaseURL = "https://auth.example.com/scim/v2"
+
+func newTestSCIMService() (*SCIMService, *mocks.MockDatabaseOperationService, *mocks.MockRoleService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockRoleService := new(mocks.MockRoleService)
+	return NewSCIMService(mockDBService, mockRoleService, new(mocks.MockUserDataService), nil), mockDBService, mockRoleService
+}
+
+func testUser() *models.User {
+	return &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash, Status: models.UserStatusActive}
+}
+
+func testUserDetails() *models.UserDetail {
+	return &models.UserDetail{UserID: mocks.TestUserId, FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName}
+}
+
+func patchOperation(op, path string, value any) models.SCIMPatchOperation {
+	operation := models.SCIMPatchOperation{Op: op, Path: path}
+	if value != nil {
+		operation.Value, _ = json.Marshal(value)
+	}
+	return operation
+}
+
+func TestParseSCIMFilter(t *testing.T) {
+	attribute, value, err := parseSCIMFilter(`userName Eq "jane\"roe@example.com"`)
+	require.NoError(t, err)
+	assert.Equal(t, "username", attribute)
+	assert.Equal(t, `jane"roe@example.com`, value)
+
+	_, _, err = parseSCIMFilter(`userName sw "jane"`)
+	assert.ErrorIs(t, err, ErrSCIMInvalidFilter)
+}
+
+func TestSCIMService_ListUsers_FilterByUserName(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(testUser(), nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{{ID: 3, RoleName: "admin"}}, nil)
+
+	response, err := service.ListUsers(testSCIMBaseURL, `userName eq "User1@TestMail.com"`, 1, -1)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), response.TotalResults)
+	require.Len(t, response.Resources, 1)
+	user := response.Resources[0].(*models.SCIMUser)
+	assert.Equal(t, "1", user.ID)
+	assert.Equal(t, mocks.TestUserEmail, user.UserName)
+	assert.Equal(t, "Test User", user.Name.Formatted)
+	assert.Equal(t, []models.SCIMReference{{Value: "3", Display: "admin", Ref: testSCIMBaseURL + "/Groups/3"}}, user.Groups)
+	assert.Equal(t, testSCIMBaseURL+"/Users/1", user.Meta.Location)
+	assert.Empty(t, user.Password)
+}
+
+func TestSCIMService_ListUsers_NoMatch(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	mockDBService.On("FindUserByEmail", "nobody@example.com").Return(nil, errors.New("record not found"))
+
+	response, err := service.ListUsers(testSCIMBaseURL, `userName eq "nobody@example.com"`, 1, -1)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), response.TotalResults)
+	assert.Empty(t, response.Resources)
+}
+
+func TestSCIMService_ListUsers_Paginates(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	mockDBService.On("ListUsers", 10, SCIMMaxCount).Return([]models.User{}, int64(3), nil)
+
+	response, err := service.ListUsers(testSCIMBaseURL, "", 11, 10000)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), response.TotalResults)
+	assert.Equal(t, 11, response.StartIndex)
+}
+
+func TestSCIMService_CreateUser(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	events := NewInMemoryEventPublisher()
+	service.events = events
+	mockDBService.On("FindUserByEmail", "jane@example.com").Return(nil, errors.New("record not found"))
+	mockDBService.On("CreateUser", mock.MatchedBy(func(user *models.User) bool {
+		return user.Email == "jane@example.com" && user.Password != ""
+	}), &models.UserDetail{FirstName: "Jane", LastName: "Roe"}).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.User).ID = 5
+	}).Return(nil)
+	mockDBService.On("FindUserDetailsByUserID", uint(5)).Return(&models.UserDetail{UserID: 5, FirstName: "Jane", LastName: "Roe"}, nil)
+	mockRoleService.On("FindRolesByUserID", uint(5)).Return([]models.Role{}, nil)
+
+	user, err := service.CreateUser(testSCIMBaseURL, models.SCIMUser{
+		UserName: "employee-42",
+		Name:     models.SCIMName{GivenName: "Jane", FamilyName: "Roe"},
+		Emails:   []models.SCIMEmail{{Value: "personal@example.net"}, {Value: "Jane@Example.com", Primary: true}},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "5", user.ID)
+	assert.Equal(t, "jane@example.com", user.UserName)
+	assert.True(t, *user.Active)
+	mockDBService.AssertExpectations(t)
+	registered := events.EventsOfType(models.EventUserRegistered)
+	require.Len(t, registered, 1)
+	assert.Equal(t, "5", registered[0].Subject)
+	assert.Contains(t, string(registered[0].Data), `"method":"scim"`)
+}
+
+func TestSCIMService_DeleteUser_ErasesUser(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	dataService := new(mocks.MockUserDataService)
+	service.dataService = dataService
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil)
+	dataService.On("EraseUser", mocks.TestUserId, uint(0)).Return(nil)
+
+	require.NoError(t, service.DeleteUser(strconv.FormatUint(uint64(mocks.TestUserId), 10)))
+
+	dataService.AssertExpectations(t)
+	mockDBService.AssertNotCalled(t, "DeleteUser", mock.Anything)
+}
+
+func TestSCIMService_GetUser_ErasedUser(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	erased := testUser()
+	erasedAt := time.Now()
+	erased.ErasedAt = &erasedAt
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(erased, nil)
+
+	_, err := service.GetUser(testSCIMBaseURL, "1")
+
+	assert.ErrorIs(t, err, ErrSCIMNotFound)
+	assert.ErrorIs(t, service.DeleteUser("1"), ErrSCIMNotFound)
+}
+
+func TestSCIMService_CreateUser_Duplicate(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(testUser(), nil)
+
+	_, err := service.CreateUser(testSCIMBaseURL, models.SCIMUser{UserName: mocks.TestUserEmail})
+
+	assert.ErrorIs(t, err, ErrSCIMUniqueness)
+	mockDBService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestSCIMService_CreateUser_InvalidUserName(t *testing.T) {
+	service, _, _ := newTestSCIMService()
+
+	_, err := service.CreateUser(testSCIMBaseURL, models.SCIMUser{UserName: "Jane Roe <jane@example.com>"})
+
+	assert.ErrorIs(t, err, ErrSCIMInvalidValue)
+}
+
+func TestSCIMService_PatchUser(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockDBService.On("UpdateUser", mock.MatchedBy(func(user *models.User) bool {
+		return user.Email == mocks.TestUserEmail && user.Password == mocks.TestUserPasswordHash
+	}), &models.UserDetail{UserID: mocks.TestUserId, FirstName: "Testy", MiddleName: "Q", LastName: ""}).Return(nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	_, err := service.PatchUser(testSCIMBaseURL, "1", models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{
+		patchOperation("Replace", "", map[string]any{"name.givenName": "Testy"}),
+		patchOperation("add", "name.middleName", "Q"),
+		patchOperation("remove", "name.familyName", nil),
+	}})
+
+	require.NoError(t, err)
+	mockDBService.AssertExpectations(t)
+}
+
+func TestSCIMService_PatchUser_Deactivate(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	events := NewInMemoryEventPublisher()
+	service.events = events
+	service.statusService = NewUserStatusService(mockDBService, events)
+	disabled := testUser()
+	disabled.Status = models.UserStatusDisabled
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil).Twice()
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(disabled, nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockDBService.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+	mockDBService.On("UpdateUserStatus", mocks.TestUserId, models.UserStatusActive, models.UserStatusDisabled, "").Return(nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	user, err := service.PatchUser(testSCIMBaseURL, "1", models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{
+		patchOperation("replace", "active", false),
+	}})
+
+	require.NoError(t, err)
+	assert.False(t, *user.Active)
+	mockDBService.AssertExpectations(t)
+	changed := events.EventsOfType(models.EventUserStatusChanged)
+	require.Len(t, changed, 1)
+	assert.Contains(t, string(changed[0].Data), `"method":"scim"`)
+}
+
+func TestSCIMService_ReplaceUser_ReactivateDeletedUser(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	deleted := testUser()
+	deleted.Status = models.UserStatusDeleted
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(deleted, nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockDBService.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+	active := true
+
+	_, err := service.ReplaceUser(testSCIMBaseURL, "1", models.SCIMUser{UserName: mocks.TestUserEmail, Active: &active})
+
+	assert.ErrorIs(t, err, ErrSCIMInvalidValue)
+	mockDBService.AssertNotCalled(t, "UpdateUserStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSCIMService_ReplaceUser_KeepsPreferredLocale(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	userDetails := testUserDetails()
+	userDetails.PreferredLocale = "de"
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(userDetails, nil)
+	mockDBService.On("UpdateUser", mock.Anything, mock.MatchedBy(func(userDetail *models.UserDetail) bool {
+		return userDetail.FirstName == "Jane" && userDetail.LastName == "Roe" && userDetail.PreferredLocale == "de"
+	})).Return(nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	_, err := service.ReplaceUser(testSCIMBaseURL, "1", models.SCIMUser{
+		UserName: mocks.TestUserEmail,
+		Name:     models.SCIMName{GivenName: "Jane", FamilyName: "Roe"},
+	})
+
+	require.NoError(t, err)
+	mockDBService.AssertExpectations(t)
+}
+
+func TestSCIMService_ReplaceUser_NewEmailIsNotVerified(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	user := testUser()
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(user, nil)
+	mockDBService.On("FindUserByEmail", "new@example.com").Return(nil, errors.New("record not found"))
+	mockDBService.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	_, err := service.ReplaceUser(testSCIMBaseURL, "1", models.SCIMUser{UserName: "new@example.com"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email)
+	assert.Nil(t, user.EmailVerifiedAt)
+}
+
+func TestSCIMService_ReplaceUser_ChangesOnlyTheCaseOfTheEmail(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	user := testUser()
+	user.Email = "User1@TestMail.com"
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(user, nil)
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockDBService.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+	mockRoleService.On("FindRolesByUserID", mocks.TestUserId).Return([]models.Role{}, nil)
+
+	resource, err := service.ReplaceUser(testSCIMBaseURL, "1", models.SCIMUser{UserName: mocks.TestUserEmail})
+
+	require.NoError(t, err)
+	assert.Equal(t, mocks.TestUserEmail, resource.UserName)
+}
+
+func TestSCIMService_PatchUser_UnsupportedPath(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil)
+	mockDBService.On("FindUserDetailsByUserID", mocks.TestUserId).Return(testUserDetails(), nil)
+
+	_, err := service.PatchUser(testSCIMBaseURL, "1", models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{
+		patchOperation("replace", "nickName", "tess"),
+	}})
+
+	assert.ErrorIs(t, err, ErrSCIMInvalidPath)
+}
+
+func TestSCIMService_DeleteUser_NotFound(t *testing.T) {
+	service, mockDBService, _ := newTestSCIMService()
+	mockDBService.On("FindUserByID", uint(99)).Return(nil, errors.New("record not found"))
+
+	assert.ErrorIs(t, service.DeleteUser("99"), ErrSCIMNotFound)
+	assert.ErrorIs(t, service.DeleteUser("not-a-number"), ErrSCIMNotFound)
+}
+
+func TestSCIMService_CreateGroup(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	mockRoleService.On("FindRoleByName", "engineering").Return(nil, errors.New("record not found"))
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(testUser(), nil)
+	mockRoleService.On("CreateRole", &models.Role{RoleName: "engineering"}).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.Role).ID = 4
+	}).Return(nil)
+	mockRoleService.On("AddRoleMembers", uint(4), []uint{mocks.TestUserId}).Return(nil)
+	mockRoleService.On("FindRoleMembers", uint(4)).Return([]models.User{*testUser()}, nil)
+
+	group, err := service.CreateGroup(testSCIMBaseURL, models.SCIMGroup{
+		DisplayName: "engineering",
+		Members:     []models.SCIMReference{{Value: "1"}},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "4", group.ID)
+	assert.Equal(t, []models.SCIMReference{{Value: "1", Display: mocks.TestUserEmail, Ref: testSCIMBaseURL + "/Users/1"}}, group.Members)
+	mockRoleService.AssertExpectations(t)
+}
+
+func TestSCIMService_CreateGroup_UnknownMember(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	mockRoleService.On("FindRoleByName", "engineering").Return(nil, errors.New("record not found"))
+	mockDBService.On("FindUserByID", uint(42)).Return(nil, errors.New("record not found"))
+
+	_, err := service.CreateGroup(testSCIMBaseURL, models.SCIMGroup{
+		DisplayName: "engineering",
+		Members:     []models.SCIMReference{{Value: "42"}},
+	})
+
+	assert.ErrorIs(t, err, ErrSCIMInvalidValue)
+	mockRoleService.AssertNotCalled(t, "CreateRole", mock.Anything)
+}
+
+func TestSCIMService_PatchGroup(t *testing.T) {
+	service, mockDBService, mockRoleService := newTestSCIMService()
+	role := &models.Role{ID: 4, RoleName: "engineering"}
+	mockRoleService.On("FindRoleByID", uint(4)).Return(role, nil)
+	mockRoleService.On("FindRoleByName", "platform").Return(nil, errors.New("record not found"))
+	mockRoleService.On("RenameRole", uint(4), "platform").Return(nil)
+	mockDBService.On("FindUserByID", uint(2)).Return(&models.User{ID: 2}, nil)
+	mockRoleService.On("AddRoleMembers", uint(4), []uint{2}).Return(nil)
+	mockRoleService.On("RemoveRoleMembers", uint(4), []uint{1}).Return(nil)
+	mockRoleService.On("FindRoleMembers", uint(4)).Return([]models.User{}, nil)
+
+	group, err := service.PatchGroup(testSCIMBaseURL, "4", models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{
+		patchOperation("replace", "displayName", "platform"),
+		patchOperation("add", "members", []models.SCIMReference{{Value: "2"}}),
+		patchOperation("remove", `members[value eq "1"]`, nil),
+	}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "platform", group.DisplayName)
+	mockRoleService.AssertExpectations(t)
+}
+
+func TestSCIMService_ReplaceGroup_NameTaken(t *testing.T) {
+	service, _, mockRoleService := newTestSCIMService()
+	mockRoleService.On("FindRoleByID", uint(4)).Return(&models.Role{ID: 4, RoleName: "engineering"}, nil)
+	mockRoleService.On("FindRoleByName", "admin").Return(&models.Role{ID: 1, RoleName: "admin"}, nil)
+
+	_, err := service.ReplaceGroup(testSCIMBaseURL, "4", models.SCIMGroup{DisplayName: "admin"})
+
+	assert.ErrorIs(t, err, ErrSCIMUniqueness)
+}
+
+func TestSCIMService_ListGroups_ExcludeMembers(t *testing.T) {
+	service, _, mockRoleService := newTestSCIMService()
+	mockRoleService.On("FindRoleByName", "admin").Return(&models.Role{ID: 1, RoleName: "admin"}, nil)
+
+	response, err := service.ListGroups(testSCIMBaseURL, `displayName eq "admin"`, 1, -1, true)
+
+	require.NoError(t, err)
+	require.Len(t, response.Resources, 1)
+	assert.Equal(t, "admin", response.Resources[0].(*models.SCIMGroup).DisplayName)
+	mockRoleService.AssertNotCalled(t, "FindRoleMembers", mock.Anything)
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const (
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "implicit"
+	SMTPTLSNone     = "none"
+
+	defaultSMTPTimeout = 10 * time.Second
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// From is the sender, either an address or "Name <address>"
+	From string
+	// TLSMode is starttls, where the server must offer STARTTLS, implicit for SMTPS or none
+	TLSMode string
+	Timeout time.Duration
+	// TLSConfig replaces the default, which verifies the server certificate against Host
+	TLSConfig *tls.Config
+}
+
+// SMTPPasswordDeliveryService emails credentials and notifications directly to the user, rendered
+// from the templates of their message type
+type SMTPPasswordDeliveryService struct {
+	Config    SMTPConfig
+	Templates *EmailTemplates
+	sender    *mail.Address
+}
+
+// NewSMTPPasswordDeliveryService reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM,
+// SMTP_TLS and SMTP_TIMEOUT. Templates are loaded as described in LoadEmailTemplatesFromEnv.
+func NewSMTPPasswordDeliveryService() (*SMTPPasswordDeliveryService, error) {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		TLSMode:  strings.ToLower(os.Getenv("SMTP_TLS")),
+	}
+	timeout, err := durationFromEnv("SMTP_TIMEOUT", defaultSMTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = timeout
+
+	emailTemplates, err := LoadEmailTemplatesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewSMTPPasswordDeliveryServiceWithConfig(config, emailTemplates)
+}
+
+func NewSMTPPasswordDeliveryServiceWithConfig(config SMTPConfig, emailTemplates *EmailTemplates) (*SMTPPasswordDeliveryService, error) {
+	if config.Host == "" {
+		return nil, errors.New("no smtp host found in environment variable")
+	}
+	if config.From == "" {
+		return nil, errors.New("no smtp sender found in environment variable")
+	}
+	sender, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp sender %s: %v", config.From, err)
+	}
+
+	switch config.TLSMode {
+	case "":
+		config.TLSMode = SMTPTLSStartTLS
+	case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		return nil, fmt.Errorf("unsupported smtp tls mode: %s", config.TLSMode)
+	}
+	if config.Port == "" {
+		config.Port = "587"
+		if config.TLSMode == SMTPTLSImplicit {
+			config.Port = "465"
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSMTPTimeout
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+	}
+
+	return &SMTPPasswordDeliveryService{Config: config, Templates: emailTemplates, sender: sender}, nil
+}
+
+func (s *SMTPPasswordDeliveryService) SendPassword(credentials models.UserCredentials) error {
+	if err := s.sendRendered(credentials.Email, EmailCredentials, credentials.Locale, credentials.Message, credentials); err != nil {
+		log.Printf("Failed to email password: %v", err)
+		return err
+	}
+	log.Printf("Password emailed to user %s", credentials.Email)
+	return nil
+}
+
+func (s *SMTPPasswordDeliveryService) SendSetupLink(link models.PasswordSetupLink) error {
+	if err := s.sendRendered(link.Email, EmailPasswordSetup, link.Locale, link.Message, link); err != nil {
+		log.Printf("Failed to email password setup link: %v", err)
+		return err
+	}
+	log.Printf("Password setup link emailed to user %s", link.Email)
+	return nil
+}
+
+func (s *SMTPPasswordDeliveryService) SendEmailChangeConfirmation(confirmation models.EmailChangeConfirmation) error {
+	if err := s.sendRendered(confirmation.Email, EmailChangeConfirmation, confirmation.Locale, confirmation.Message, confirmation); err != nil {
+		log.Printf("Failed to email address confirmation: %v", err)
+		return err
+	}
+	log.Printf("Address confirmation emailed to %s", confirmation.Email)
+	return nil
+}
+
+func (s *SMTPPasswordDeliveryService) SendEmailChangeNotice(notice models.EmailChangeNotice) error {
+	if err := s.sendRendered(notice.Email, EmailChangeNotice, notice.Locale, notice.Message, notice); err != nil {
+		log.Printf("Failed to email address change notice: %v", err)
+		return err
+	}
+	log.Printf("Address change notice emailed to %s", notice.Email)
+	return nil
+}
+
+func (s *SMTPPasswordDeliveryService) SendInvitation(invitation models.UserInvitation) error {
+	if err := s.sendRendered(invitation.Email, EmailInvitation, invitation.Locale, invitation.Message, invitation); err != nil {
+		log.Printf("Failed to email invitation: %v", err)
+		return err
+	}
+	log.Printf("Invitation emailed to %s", invitation.Email)
+	return nil
+}
+
+// SendEmail renders the message type with data in the default locale and sends it to a single recipient
+func (s *SMTPPasswordDeliveryService) SendEmail(to string, messageType string, data any) error {
+	return s.sendRendered(to, messageType, "", nil, data)
+}
+
+// sendRendered sends the message the outbox relay rendered, or renders the message type in locale itself
+// when there is none
+func (s *SMTPPasswordDeliveryService) sendRendered(to string, messageType string, locale string, rendered *models.RenderedMessage, data any) error {
+	if rendered == nil {
+		var err error
+		if rendered, err = s.Templates.RenderLocalized(messageType, locale, data); err != nil {
+			return err
+		}
+	}
+	message, err := s.buildMessage(to, rendered)
+	if err != nil {
+		return err
+	}
+	return s.send(to, message)
+}
+
+func (s *SMTPPasswordDeliveryService) buildMessage(to string, email *RenderedEmail) ([]byte, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	domain := s.sender.Address[strings.LastIndex(s.sender.Address, "@")+1:]
+
+	var message bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&message, "%s: %s\r\n", name, value)
+	}
+	header("From", s.sender.String())
+	header("To", (&mail.Address{Address: to}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(idBytes), domain))
+	header("MIME-Version", "1.0")
+
+	if email.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		message.WriteString("\r\n")
+		if err := writeQuotedPrintable(&message, email.Text); err != nil {
+			return nil, err
+		}
+		return message.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&message)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	message.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write([]byte(body)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func (s *SMTPPasswordDeliveryService) send(to string, message []byte) error {
+	addr := net.JoinHostPort(s.Config.Host, s.Config.Port)
+	dialer := &net.Dialer{Timeout: s.Config.Timeout}
+	var conn net.Conn
+	var err error
+	if s.Config.TLSMode == SMTPTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.Config.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %v", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(s.Config.Timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.Config.TLSMode == SMTPTLSStartTLS {
+		if err := client.Hello("localhost"); err != nil {
+			return err
+		}
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(s.Config.TLSConfig); err != nil {
+			return err
+		}
+	}
+	if s.Config.Username != "" {
+		// PlainAuth refuses to send the password over a connection without TLS unless it is to localhost
+		if err := client.Auth(smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.sender.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"crypto/tls"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/templates"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSMTPDelivery(t *testing.T, server *tests.SMTPServerStub, config SMTPConfig) *SMTPPasswordDeliveryService {
+	emailTemplates, err := LoadEmailTemplates(templates.Email)
+	require.NoError(t, err)
+	config.Host = server.Host()
+	config.Port = strconv.Itoa(server.Port())
+	config.From = "Accounts <accounts@example.com>"
+	config.Timeout = 5 * time.Second
+	service, err := NewSMTPPasswordDeliveryServiceWithConfig(config, emailTemplates)
+	require.NoError(t, err)
+	return service
+}
+
+// readAlternatives returns the decoded body of every part of a multipart/alternative message by content type
+func readAlternatives(t *testing.T, data string) (*mail.Message, map[string]string) {
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	bodies := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+	return message, bodies
+}
+
+func TestSMTPPasswordDeliveryService_SendPasswordWithStartTLSAndAuth(t *testing.T) {
+	server := tests.NewSMTPServerStub(true)
+	defer server.Close()
+	server.RequireAuth("mailer", "mailer-secret")
+	service := newSMTPDelivery(t, server, SMTPConfig{
+		Username:  "mailer",
+		Password:  "mailer-secret",
+		TLSConfig: &tls.Config{RootCAs: server.CertPool(), ServerName: server.Host()},
+	})
+
+	credentials := models.UserCredentials{Email: "john@example.com", FirstName: "John <b>", Password: "Temp-Pass=123"}
+	require.NoError(t, service.SendPassword(credentials))
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].TLS)
+	assert.Equal(t, "mailer", messages[0].Username)
+	assert.Equal(t, "accounts@example.com", messages[0].From)
+	assert.Equal(t, []string{"john@example.com"}, messages[0].To)
+
+	message, bodies := readAlternatives(t, messages[0].Data)
+	assert.Equal(t, "Your account has been created", message.Header.Get("Subject"))
+	assert.Equal(t, `"Accounts" <accounts@example.com>`, message.Header.Get("From"))
+	assert.Contains(t, bodies["text/plain"], "Hello John <b>,")
+	assert.Contains(t, bodies["text/plain"], "Your temporary password is: Temp-Pass=123")
+	assert.Contains(t, bodies["text/html"], "Hello John &lt;b&gt;,")
+	assert.Contains(t, bodies["text/html"], "<code>Temp-Pass=123</code>")
+}
+
+func TestSMTPPasswordDeliveryService_SendPasswordWithoutTLS(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{TLSMode: SMTPTLSNone})
+
+	require.NoError(t, service.SendPassword(models.UserCredentials{Email: "john@example.com", Password: "secret"}))
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	assert.False(t, messages[0].TLS)
+}
+
+func TestSMTPPasswordDeliveryService_SendSetupLink(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{TLSMode: SMTPTLSNone})
+
+	link := models.PasswordSetupLink{
+		Email:     "john@example.com",
+		FirstName: "John",
+		SetupURL:  "https://app.example.com/set-password?token=abc&x=1",
+		Token:     "abc",
+		ExpiresAt: time.Date(2026, 10, 22, 9, 30, 0, 0, time.UTC),
+	}
+	require.NoError(t, service.SendSetupLink(link))
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	message, bodies := readAlternatives(t, messages[0].Data)
+	assert.Equal(t, "Set the password for your new account", message.Header.Get("Subject"))
+	assert.Contains(t, bodies["text/plain"], "https://app.example.com/set-password?token=abc&x=1")
+	assert.Contains(t, bodies["text/plain"], "expires on 22 October 2026 at 09:30 UTC")
+	assert.Contains(t, bodies["text/html"], `href="https://app.example.com/set-password?token=abc&amp;x=1"`)
+}
+
+func TestSMTPPasswordDeliveryService_SendEmailChange(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{TLSMode: SMTPTLSNone})
+
+	require.NoError(t, service.SendEmailChangeConfirmation(models.EmailChangeConfirmation{
+		Email:         "new@example.com",
+		PreviousEmail: "john@example.com",
+		FirstName:     "John",
+		Token:         "abc",
+		ExpiresAt:     time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+	}))
+	require.NoError(t, service.SendEmailChangeNotice(models.EmailChangeNotice{
+		Email:       "john@example.com",
+		NewEmail:    "new@example.com",
+		FirstName:   "John",
+		RequestedAt: time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC),
+	}))
+
+	messages := server.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, []string{"new@example.com"}, messages[0].To)
+	message, bodies := readAlternatives(t, messages[0].Data)
+	assert.Equal(t, "Confirm your new email address", message.Header.Get("Subject"))
+	// without a confirmation page the token is sent as a code
+	assert.Contains(t, bodies["text/plain"], "Confirm the new address with this code: abc")
+
+	assert.Equal(t, []string{"john@example.com"}, messages[1].To)
+	message, bodies = readAlternatives(t, messages[1].Data)
+	assert.Equal(t, "A change of your email address was requested", message.Header.Get("Subject"))
+	assert.Contains(t, bodies["text/plain"], "from john@example.com to new@example.com")
+}
+
+func TestSMTPPasswordDeliveryService_SendInvitation(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{TLSMode: SMTPTLSNone})
+
+	require.NoError(t, service.SendInvitation(models.UserInvitation{
+		Email:     "jane@example.com",
+		InvitedBy: "John Doe",
+		AcceptURL: "https://app.example.com/accept-invitation?token=abc",
+		Token:     "abc",
+		ExpiresAt: time.Date(2026, 10, 26, 9, 30, 0, 0, time.UTC),
+	}))
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"jane@example.com"}, messages[0].To)
+	message, bodies := readAlternatives(t, messages[0].Data)
+	assert.Equal(t, "You have been invited to create an account", message.Header.Get("Subject"))
+	assert.Contains(t, bodies["text/plain"], "Hello,\n")
+	assert.Contains(t, bodies["text/plain"], "John Doe invited you to create an account for jane@example.com.")
+	assert.Contains(t, bodies["text/html"], `href="https://app.example.com/accept-invitation?token=abc"`)
+}
+
+func TestSMTPPasswordDeliveryService_SendsRenderedMessage(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{TLSMode: SMTPTLSNone})
+
+	// a message rendered by the outbox relay is sent as it is
+	require.NoError(t, service.SendPassword(models.UserCredentials{
+		Email:    "john@example.com",
+		Password: "secret",
+		Locale:   "de",
+		Message:  &models.RenderedMessage{Locale: "de", Subject: "Willkommen zurück", Text: "Gerendert"},
+	}))
+	// otherwise the service renders the message in the locale itself
+	require.NoError(t, service.SendSetupLink(models.PasswordSetupLink{Email: "john@example.com", FirstName: "John", Locale: "de-CH"}))
+
+	messages := server.Messages()
+	require.Len(t, messages, 2)
+	decoder := new(mime.WordDecoder)
+	message, err := mail.ReadMessage(strings.NewReader(messages[0].Data))
+	require.NoError(t, err)
+	subject, err := decoder.DecodeHeader(message.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Willkommen zurück", subject)
+	assert.Equal(t, "text/plain; charset=utf-8", message.Header.Get("Content-Type"))
+
+	message, bodies := readAlternatives(t, messages[1].Data)
+	subject, err = decoder.DecodeHeader(message.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Legen Sie das Passwort für Ihr neues Konto fest", subject)
+	assert.Contains(t, bodies["text/plain"], "Hallo John,")
+}
+
+func TestSMTPPasswordDeliveryService_StartTLSRequired(t *testing.T) {
+	server := tests.NewSMTPServerStub(false)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{})
+
+	err := service.SendPassword(models.UserCredentials{Email: "john@example.com", Password: "secret"})
+
+	assert.EqualError(t, err, "smtp server does not support STARTTLS")
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPPasswordDeliveryService_UntrustedCertificate(t *testing.T) {
+	server := tests.NewSMTPServerStub(true)
+	defer server.Close()
+	service := newSMTPDelivery(t, server, SMTPConfig{})
+
+	err := service.SendPassword(models.UserCredentials{Email: "john@example.com", Password: "secret"})
+
+	assert.Error(t, err)
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPPasswordDeliveryService_AuthenticationFailure(t *testing.T) {
+	server := tests.NewSMTPServerStub(true)
+	defer server.Close()
+	server.RequireAuth("mailer", "mailer-secret")
+	service := newSMTPDelivery(t, server, SMTPConfig{
+		Username:  "mailer",
+		Password:  "wrong",
+		TLSConfig: &tls.Config{RootCAs: server.CertPool(), ServerName: server.Host()},
+	})
+
+	err := service.SendPassword(models.UserCredentials{Email: "john@example.com", Password: "secret"})
+
+	assert.Error(t, err)
+	assert.Empty(t, server.Messages())
+}
+
+func TestNewSMTPPasswordDeliveryService_FromEnvironment(t *testing.T) {
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "accounts@example.com")
+	t.Setenv("SMTP_TLS", "IMPLICIT")
+	t.Setenv("SMTP_TIMEOUT", "3s")
+
+	service, err := NewSMTPPasswordDeliveryService()
+
+	require.NoError(t, err)
+	assert.Equal(t, "465", service.Config.Port)
+	assert.Equal(t, SMTPTLSImplicit, service.Config.TLSMode)
+	assert.Equal(t, 3*time.Second, service.Config.Timeout)
+	assert.Equal(t, "smtp.example.com", service.Config.TLSConfig.ServerName)
+}
+
+func TestNewSMTPPasswordDeliveryService_InvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"missing host", map[string]string{"SMTP_FROM": "accounts@example.com"}},
+		{"missing sender", map[string]string{"SMTP_HOST": "smtp.example.com"}},
+		{"invalid sender", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "accounts"}},
+		{"invalid tls mode", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "accounts@example.com", "SMTP_TLS": "ssl"}},
+		{"invalid timeout", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "accounts@example.com", "SMTP_TIMEOUT": "soon"}},
+		{"missing template dir", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "accounts@example.com", "SMTP_TEMPLATE_DIR": "/nonexistent"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			service, err := NewSMTPPasswordDeliveryService()
+			assert.Error(t, err)
+			assert.Nil(t, service)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// TokenIntrospectionService implements token introspection (RFC 7662) and revocation (RFC 7009)
+type TokenIntrospectionService struct {
+	dbService     IDatabaseOperationService
+	clientService IOAuthClientService
+	tokenStore    ITokenStoreService
+}
+
+func NewTokenIntrospectionService(dbService IDatabaseOperationService, clientService IOAuthClientService, tokenStore ITokenStoreService) *TokenIntrospectionService {
+	return &TokenIntrospectionService{
+		dbService:     dbService,
+		clientService: clientService,
+		tokenStore:    tokenStore,
+	}
+}
+
+// Introspect reports whether the token is active. Unknown, expired or revoked tokens are reported
+// as inactive rather than as errors; the only error is a failed client authentication.
+func (s *TokenIntrospectionService) Introspect(input models.IntrospectionRequest) (*models.IntrospectionResponse, error) {
+	if _, err := s.clientService.AuthenticateClient(input.ClientID, input.ClientSecret); err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	introspectors := []func(string) *models.IntrospectionResponse{s.introspectAccessToken, s.introspectRefreshToken}
+	if input.TokenTypeHint == TokenTypeHintRefreshToken {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+	for _, introspect := range introspectors {
+		if response := introspect(input.Token); response != nil {
+			return response, nil
+		}
+	}
+	return &models.IntrospectionResponse{Active: false}, nil
+}
+
+func (s *TokenIntrospectionService) introspectAccessToken(token string) *models.IntrospectionResponse {
+	claims, err := utils.ParseJWT(token)
+	if err != nil {
+		return nil
+	}
+	if claims.ID != "" {
+		revoked, err := s.tokenStore.IsAccessTokenRevoked(claims.ID)
+		if err != nil {
+			log.Printf("Error checking access token revocation: %v", err)
+			return nil
+		}
+		if revoked {
+			return nil
+		}
+	}
+	if claims.UserID != 0 && claims.IssuedAt != nil {
+		revoked, err := s.tokenStore.IsAccessTokenEmailRevoked(claims.UserID, claims.Email, claims.IssuedAt.Time)
+		if err != nil {
+			log.Printf("Error checking access token revocation: %v", err)
+			return nil
+		}
+		if revoked {
+			return nil
+		}
+	}
+	if claims.UserID != 0 {
+		active, err := s.IsUserActive(claims.UserID)
+		if err != nil {
+			log.Printf("Error checking the status of user %d: %v", claims.UserID, err)
+			return nil
+		}
+		if !active {
+			return nil
+		}
+	}
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		Username:  claims.Email,
+		TokenType: "Bearer",
+		ClientID:  claims.ClientID,
+		Subject:   strconv.FormatUint(uint64(claims.UserID), 10),
+		JTI:       claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+	return response
+}
+
+func (s *TokenIntrospectionService) introspectRefreshToken(token string) *models.IntrospectionResponse {
+	refreshToken, err := s.tokenStore.FindRefreshToken(utils.HashToken(token))
+	if err != nil || !isRefreshTokenActive(refreshToken) {
+		return nil
+	}
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		Scope:     refreshToken.Scope,
+		ClientID:  refreshToken.ClientID,
+		TokenType: TokenTypeHintRefreshToken,
+		ExpiresAt: refreshToken.ExpiresAt.Unix(),
+		Subject:   strconv.FormatUint(uint64(refreshToken.UserID), 10),
+	}
+	if user, err := s.dbService.FindUserByID(refreshToken.UserID); err == nil {
+		if checkUserActive(user) != nil {
+			return nil
+		}
+		response.Username = user.Email
+	}
+	return response
+}
+
+// Revoke invalidates the token. Following RFC 7009 an unknown or already invalid token is not an error,
+// and a token can only be revoked by the client it was issued to. Revoking the access token of another
+// client, or one of the login endpoints, is answered like an unknown token without revoking it.
+func (s *TokenIntrospectionService) Revoke(input models.IntrospectionRequest) error {
+	if _, err := s.clientService.AuthenticateClient(input.ClientID, input.ClientSecret); err != nil {
+		return ErrInvalidClient
+	}
+
+	revokers := []func(models.IntrospectionRequest) (bool, error){s.revokeAccessToken, s.revokeRefreshToken}
+	if input.TokenTypeHint == TokenTypeHintRefreshToken {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+	for _, revoke := range revokers {
+		if found, err := revoke(input); found || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TokenIntrospectionService) revokeAccessToken(input models.IntrospectionRequest) (bool, error) {
+	claims, err := utils.ParseJWT(input.Token)
+	if err != nil {
+		return false, nil
+	}
+	if claims.ClientID != input.ClientID || claims.ID == "" || claims.ExpiresAt == nil {
+		return true, nil
+	}
+	return true, s.tokenStore.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time)
+}
+
+func (s *TokenIntrospectionService) revokeRefreshToken(input models.IntrospectionRequest) (bool, error) {
+	tokenHash := utils.HashToken(input.Token)
+	refreshToken, err := s.tokenStore.FindRefreshToken(tokenHash)
+	if err != nil {
+		return false, nil
+	}
+	if refreshToken.ClientID != input.ClientID {
+		return true, ErrUnauthorizedClient
+	}
+	return true, s.tokenStore.RevokeRefreshToken(tokenHash)
+}
+
+// IsAccessTokenRevoked lets the auth middleware reject access tokens revoked before their expiry
+func (s *TokenIntrospectionService) IsAccessTokenRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return s.tokenStore.IsAccessTokenRevoked(jti)
+}
+
+// IsAccessTokenEmailRevoked lets the auth middleware reject access tokens carrying an email address the
+// user has changed since
+func (s *TokenIntrospectionService) IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error) {
+	return s.tokenStore.IsAccessTokenEmailRevoked(userID, email, issuedAt)
+}
+
+// IsUserActive lets the auth middleware reject access tokens of users who were suspended, disabled or
+// deleted since the token was issued
+func (s *TokenIntrospectionService) IsUserActive(userID uint) (bool, error) {
+	user, err := s.dbService.FindUserByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Status == models.UserStatusActive, nil
+}
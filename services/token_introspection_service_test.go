@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIntrospectionService() (*TokenIntrospectionService, *mocks.MockDatabaseOperationService, *mocks.MockTokenStoreService) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	mockClientService := new(mocks.MockOAuthClientService)
+	mockClientService.On("AuthenticateClient", mocks.TestClientID, mocks.TestClientSecret).Return(&models.OAuthClient{ClientID: mocks.TestClientID}, nil)
+	mockClientService.On("AuthenticateClient", mock.Anything, mock.Anything).Return(nil, errors.New("Invalid client"))
+	mockTokenStore := new(mocks.MockTokenStoreService)
+	return NewTokenIntrospectionService(mockDBService, mockClientService, mockTokenStore), mockDBService, mockTokenStore
+}
+
+func introspectionRequest(token, hint string) models.IntrospectionRequest {
+	return models.IntrospectionRequest{
+		Token:         token,
+		TokenTypeHint: hint,
+		ClientID:      mocks.TestClientID,
+		ClientSecret:  mocks.TestClientSecret,
+	}
+}
+
+func TestTokenIntrospectionService_Introspect_ActiveAccessToken(t *testing.T) {
+	service, mockDBService, mockTokenStore := newTestIntrospectionService()
+	accessToken, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId})
+	require.NoError(t, err)
+	mockTokenStore.On("IsAccessTokenRevoked", mock.AnythingOfType("string")).Return(false, nil)
+	mockTokenStore.On("IsAccessTokenEmailRevoked", mocks.TestUserId, mocks.TestUserEmail, mock.AnythingOfType("time.Time")).Return(false, nil)
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(&models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}, nil)
+
+	response, err := service.Introspect(introspectionRequest(accessToken, ""))
+
+	assert.NoError(t, err)
+	assert.True(t, response.Active)
+	assert.Equal(t, mocks.TestUserEmail, response.Username)
+	assert.Equal(t, "1", response.Subject)
+	assert.NotZero(t, response.ExpiresAt)
+}
+
+func TestTokenIntrospectionService_Introspect_AccessTokenWithChangedEmail(t *testing.T) {
+	service, _, mockTokenStore := newTestIntrospectionService()
+	accessToken, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId})
+	require.NoError(t, err)
+	mockTokenStore.On("IsAccessTokenRevoked", mock.AnythingOfType("string")).Return(false, nil)
+	mockTokenStore.On("IsAccessTokenEmailRevoked", mocks.TestUserId, mocks.TestUserEmail, mock.AnythingOfType("time.Time")).Return(true, nil)
+	mockTokenStore.On("FindRefreshToken", utils.HashToken(accessToken)).Return(nil, errors.New("record not found"))
+
+	response, err := service.Introspect(introspectionRequest(accessToken, TokenTypeHintAccessToken))
+
+	assert.NoError(t, err)
+	assert.False(t, response.Active)
+}
+
+func TestTokenIntrospectionService_Introspect_AccessTokenOfSuspendedUser(t *testing.T) {
+	service, mockDBService, mockTokenStore := newTestIntrospectionService()
+	accessToken, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId})
+	require.NoError(t, err)
+	mockTokenStore.On("IsAccessTokenRevoked", mock.AnythingOfType("string")).Return(false, nil)
+	mockTokenStore.On("IsAccessTokenEmailRevoked", mocks.TestUserId, mocks.TestUserEmail, mock.AnythingOfType("time.Time")).Return(false, nil)
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(&models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusSuspended}, nil)
+	mockTokenStore.On("FindRefreshToken", utils.HashToken(accessToken)).Return(nil, errors.New("record not found"))
+
+	response, err := service.Introspect(introspectionRequest(accessToken, TokenTypeHintAccessToken))
+
+	assert.NoError(t, err)
+	assert.False(t, response.Active)
+}
+
+func TestTokenIntrospectionService_Introspect_RevokedAccessToken(t *testing.T) {
+	service, _, mockTokenStore := newTestIntrospectionService()
+	accessToken, err := utils.GenerateJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId})
+	require.NoError(t, err)
+	mockTokenStore.On("IsAccessTokenRevoked", mock.AnythingOfType("string")).Return(true, nil)
+	mockTokenStore.On("FindRefreshToken", utils.HashToken(accessToken)).Return(nil, errors.New("record not found"))
+
+	response, err := service.Introspect(introspectionRequest(accessToken, TokenTypeHintAccessToken))
+
+	assert.NoError(t, err)
+	assert.False(t, response.Active)
+}
+
+func TestTokenIntrospectionService_Introspect_RefreshToken(t *testing.T) {
+	service, mockDBService, mockTokenStore := newTestIntrospectionService()
+	storedToken := &models.RefreshToken{UserID: mocks.TestUserId, ClientID: mocks.TestClientID, Scope: "openid", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenStore.On("FindRefreshToken", utils.HashToken("refresh-token")).Return(storedToken, nil)
+	mockDBService.On("FindUserByID", mocks.TestUserId).Return(&models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Status: models.UserStatusActive}, nil)
+
+	response, err := service.Introspect(introspectionRequest("refresh-token", TokenTypeHintRefreshToken))
+
+	assert.NoError(t, err)
+	assert.True(t, response.Active)
+	assert.Equal(t, TokenTypeHintRefreshToken, response.TokenType)
+	assert.Equal(t, mocks.TestClientID, response.ClientID)
+	assert.Equal(t, mocks.TestUserEmail, response.Username)
+}
+
+func TestTokenIntrospectionService_Introspect_InvalidClient(t *testing.T) {
+	service, _, _ := newTestIntrospectionService()
+	request := introspectionRequest("whatever", "")
+	request.ClientSecret = "wrong"
+
+	response, err := service.Introspect(request)
+
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func TestTokenIntrospectionService_Revoke_AccessToken(t *testing.T) {
+	service, _, mockTokenStore := newTestIntrospectionService()
+	accessToken, err := utils.GenerateClientJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId}, mocks.TestClientID)
+	require.NoError(t, err)
+	claims, err := utils.ParseJWT(accessToken)
+	require.NoError(t, err)
+	mockTokenStore.On("RevokeAccessToken", claims.ID, claims.ExpiresAt.Time).Return(nil)
+
+	err = service.Revoke(introspectionRequest(accessToken, ""))
+
+	assert.NoError(t, err)
+	mockTokenStore.AssertExpectations(t)
+}
+
+func TestTokenIntrospectionService_Revoke_AccessTokenOfAnotherClient(t *testing.T) {
+	for name, clientID := range map[string]string{"another client": "other-client", "the login endpoints": ""} {
+		t.Run(name, func(t *testing.T) {
+			service, _, mockTokenStore := newTestIntrospectionService()
+			accessToken, err := utils.GenerateClientJWT(mocks.TestUserEmail, models.UserDetail{UserID: mocks.TestUserId}, clientID)
+			require.NoError(t, err)
+
+			err = service.Revoke(introspectionRequest(accessToken, TokenTypeHintAccessToken))
+
+			assert.NoError(t, err, "the token is treated like an unknown one")
+			mockTokenStore.AssertNotCalled(t, "RevokeAccessToken", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTokenIntrospectionService_Revoke_RefreshTokenOfAnotherClient(t *testing.T) {
+	service, _, mockTokenStore := newTestIntrospectionService()
+	storedToken := &models.RefreshToken{UserID: mocks.TestUserId, ClientID: "other-client", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenStore.On("FindRefreshToken", utils.HashToken("refresh-token")).Return(storedToken, nil)
+
+	err := service.Revoke(introspectionRequest("refresh-token", TokenTypeHintRefreshToken))
+
+	assert.ErrorIs(t, err, ErrUnauthorizedClient)
+	mockTokenStore.AssertNotCalled(t, "RevokeRefreshToken", mock.Anything)
+}
+
+func TestTokenIntrospectionService_Revoke_UnknownToken(t *testing.T) {
+	service, _, mockTokenStore := newTestIntrospectionService()
+	mockTokenStore.On("FindRefreshToken", utils.HashToken("unknown")).Return(nil, errors.New("record not found"))
+
+	err := service.Revoke(introspectionRequest("unknown", ""))
+
+	assert.NoError(t, err, "revoking an unknown token is not an error")
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ITokenStoreService interface {
+	CreateRefreshToken(refreshToken *models.RefreshToken) error
+	FindRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(tokenHash string) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error)
+}
+
+type TokenStoreService struct {
+	db *gorm.DB
+}
+
+func NewTokenStoreService(db *gorm.DB) *TokenStoreService {
+	return &TokenStoreService{db: db}
+}
+
+func (s *TokenStoreService) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	return s.db.Create(refreshToken).Error
+}
+
+func (s *TokenStoreService) FindRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	if err := s.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+func (s *TokenStoreService) RevokeRefreshToken(tokenHash string) error {
+	return s.db.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", tokenHash).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *TokenStoreService) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Entries are only needed until the token would have expired on its own
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	})
+}
+
+func (s *TokenStoreService) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsAccessTokenEmailRevoked reports whether access tokens of the user carrying email were revoked after
+// issuedAt, because the user changed their address since
+func (s *TokenStoreService) IsAccessTokenEmailRevoked(userID uint, email string, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.RevokedTokenEmail{}).
+		Where("user_id = ? AND email = ? AND revoked_at >= ?", userID, email, issuedAt).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// revokeAccessTokenEmail revokes every access token of the user issued until now with email as its claim
+func revokeAccessTokenEmail(tx *gorm.DB, userID uint, email string) error {
+	now := time.Now()
+	if err := tx.Where("expires_at < ?", now).Delete(&models.RevokedTokenEmail{}).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.RevokedTokenEmail{
+		UserID:    userID,
+		Email:     email,
+		RevokedAt: now,
+		ExpiresAt: now.Add(utils.AccessTokenLifetime),
+	}).Error
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUserErased = errors.New("user data has already been erased")
+
+type IUserDataService interface {
+	ExportUserData(userID uint) (*models.UserDataExport, error)
+	EraseUser(userID, actorID uint) error
+}
+
+// UserDataService answers data access and erasure requests for a single user
+type UserDataService struct {
+	db     *gorm.DB
+	events IEventPublisher
+}
+
+// NewUserDataService publishes user.deleted with erased set when a user is erased, exports publish nothing
+func NewUserDataService(db *gorm.DB, events IEventPublisher) *UserDataService {
+	return &UserDataService{db: db, events: events}
+}
+
+// ExportUserData collects every row stored about the user. It fails with ErrProfileNotFound for unknown users.
+func (s *UserDataService) ExportUserData(userID uint) (*models.UserDataExport, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	export := &models.UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		User: models.UserDataAccount{
+			ID:                 user.ID,
+			Email:              user.Email,
+			Status:             user.Status,
+			StatusReason:       user.StatusReason,
+			StatusChangedAt:    user.StatusChangedAt,
+			CreatedAt:          user.CreatedAt,
+			UpdatedAt:          user.UpdatedAt,
+			LastLoginAt:        user.LastLoginAt,
+			LastLoginIP:        user.LastLoginIP,
+			LastLoginUserAgent: user.LastLoginUserAgent,
+		},
+		Roles:               []string{},
+		Sessions:            []models.UserDataSession{},
+		FederatedIdentities: []models.UserDataFederatedIdentity{},
+		PasswordSetupLinks:  []models.UserDataToken{},
+		EmailChanges:        []models.UserDataEmailChange{},
+		Messages:            []models.UserDataMessage{},
+	}
+
+	var userDetail models.UserDetail
+	err := s.db.Where("user_id = ?", userID).First(&userDetail).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		export.Details = &models.UserDataDetails{
+			FirstName:       userDetail.FirstName,
+			MiddleName:      userDetail.MiddleName,
+			LastName:        userDetail.LastName,
+			PreferredLocale: userDetail.PreferredLocale,
+			CreatedAt:       userDetail.CreatedAt,
+			UpdatedAt:       userDetail.UpdatedAt,
+		}
+	}
+
+	err = s.db.Table("user_roles").Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).Order("roles.role_name").Pluck("roles.role_name", &export.Roles).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, rows := range []struct {
+		table, order string
+		dest         any
+	}{
+		{"refresh_tokens", "id", &export.Sessions},
+		{"federated_identities", "provider, subject", &export.FederatedIdentities},
+		{"password_setup_tokens", "id", &export.PasswordSetupLinks},
+		{"email_change_tokens", "id", &export.EmailChanges},
+		{"outbox_messages", "id", &export.Messages},
+	} {
+		if err := s.db.Table(rows.table).Where("user_id = ?", userID).Order(rows.order).Find(rows.dest).Error; err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", rows.table, err)
+		}
+	}
+	return export, nil
+}
+
+// EraseUser deletes everything stored about the user and anonymizes the user and their details, the user
+// stays deleted so the ID is never reused. Admins cannot erase themselves. A user.deleted event with erased
+// set tells consumers to erase their copies as well.
+func (s *UserDataService) EraseUser(userID, actorID uint) error {
+	if actorID != 0 && actorID == userID {
+		return fmt.Errorf("%w: admins cannot erase themselves", ErrInvalidStatusChange)
+	}
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return notFoundAs(err, ErrProfileNotFound)
+		}
+		if user.ErasedAt != nil {
+			return ErrUserErased
+		}
+		for _, table := range []string{
+			"user_roles", "refresh_tokens", "federated_identities", "password_setup_tokens", "email_change_tokens",
+			"revoked_token_emails", "outbox_messages",
+		} {
+			if err := tx.Table(table).Where("user_id = ?", userID).Delete(nil).Error; err != nil {
+				return fmt.Errorf("erasing %s: %w", table, err)
+			}
+		}
+		if err := tx.Table("password_deliveries").Where("email = ?", user.Email).Delete(nil).Error; err != nil {
+			return fmt.Errorf("erasing password_deliveries: %w", err)
+		}
+		// invitations are sent before the user exists, so they are found by the address as well
+		err := tx.Where("user_id = ? OR LOWER(email) = LOWER(?)", userID, user.Email).Delete(&models.Invitation{}).Error
+		if err != nil {
+			return fmt.Errorf("erasing invitations: %w", err)
+		}
+		err = tx.Where("message_type = ? AND LOWER(payload->>'email') = LOWER(?)", models.OutboxInvitation, user.Email).
+			Delete(&models.OutboxMessage{}).Error
+		if err != nil {
+			return fmt.Errorf("erasing invitation messages: %w", err)
+		}
+		// the details are blanked rather than deleted, everything listing users expects them
+		err = tx.Model(&models.UserDetail{}).Where("user_id = ?", userID).
+			Updates(map[string]any{"firstname": "", "middlename": "", "lastname": "", "preferred_locale": ""}).Error
+		if err != nil {
+			return fmt.Errorf("erasing user_details: %w", err)
+		}
+		now := time.Now()
+		return tx.Model(&user).Updates(map[string]any{
+			"email":             erasedEmail(userID),
+			"password":          "",
+			"status":            models.UserStatusDeleted,
+			"status_reason":     "",
+			"status_changed_at": now,
+			"erased_at":         now,
+			// the last login stays, only the client it came from is erased
+			"last_login_ip":         "",
+			"last_login_user_agent": "",
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	publishEvent(s.events, models.EventUserDeleted, &userID, models.UserDeletedData{Email: user.Email, Erased: true})
+	return nil
+}
+
+// erasedEmail replaces the address of an erased user, the .invalid domain can never receive mail
+func erasedEmail(userID uint) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", userID)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserDataService(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	defer DBOperationService.db.Where("client_id = ?", "data-client").Delete(&models.OAuthClient{})
+	events := NewInMemoryEventPublisher()
+	userDataService := NewUserDataService(DBOperationService.db, events)
+
+	user := &models.User{Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash}
+	require.NoError(t, DBOperationService.CreateUser(user, &models.UserDetail{FirstName: mocks.TestUserFirstName, LastName: mocks.TestUserLastName, PreferredLocale: "de"}))
+	db := DBOperationService.db
+	require.NoError(t, db.Exec("INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE role_name = 'admin'", user.ID).Error)
+	require.NoError(t, db.Create(&models.OAuthClient{ClientID: "data-client", ClientSecret: "hash"}).Error)
+	require.NoError(t, db.Create(&models.RefreshToken{TokenHash: "refresh-hash", UserID: user.ID, ClientID: "data-client", Scope: "openid", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+	require.NoError(t, db.Create(&models.FederatedIdentity{Provider: "okta", Subject: "upstream-1", UserID: user.ID, Email: mocks.TestUserEmail}).Error)
+	require.NoError(t, db.Create(&models.EmailChangeToken{TokenHash: "change-hash", UserID: user.ID, NewEmail: "new@testmail.com", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+	require.NoError(t, db.Create(&models.OutboxMessage{UserID: &user.ID, MessageType: models.OutboxEmailChangeNotice, Payload: `{"email":"user@testmail.com"}`, NextAttemptAt: time.Now()}).Error)
+	require.NoError(t, db.Create(&models.Invitation{Email: "USER1@testmail.com", TokenHash: "invitation-hash", ExpiresAt: time.Now(), UserID: &user.ID}).Error)
+	require.NoError(t, db.Create(&models.OutboxMessage{MessageType: models.OutboxInvitation, Payload: `{"email":"USER1@testmail.com"}`, NextAttemptAt: time.Now()}).Error)
+	require.NoError(t, DBOperationService.RecordLogin(user.ID, models.LoginMetadata{IPAddress: "203.0.113.7", UserAgent: "test-client/1.0"}))
+
+	t.Run("exports every row", func(t *testing.T) {
+		export, err := userDataService.ExportUserData(user.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, mocks.TestUserEmail, export.User.Email)
+		assert.Equal(t, models.UserStatusActive, export.User.Status)
+		require.NotNil(t, export.Details)
+		assert.Equal(t, "de", export.Details.PreferredLocale)
+		assert.False(t, export.Details.CreatedAt.IsZero())
+		require.NotNil(t, export.User.LastLoginAt)
+		assert.Equal(t, "203.0.113.7", export.User.LastLoginIP)
+		assert.Equal(t, "test-client/1.0", export.User.LastLoginUserAgent)
+		assert.Equal(t, []string{"admin"}, export.Roles)
+		require.Len(t, export.Sessions, 1)
+		assert.Equal(t, "data-client", export.Sessions[0].ClientID)
+		assert.Equal(t, []models.UserDataFederatedIdentity{{Provider: "okta", Subject: "upstream-1", Email: mocks.TestUserEmail}}, export.FederatedIdentities)
+		assert.Empty(t, export.PasswordSetupLinks)
+		require.Len(t, export.EmailChanges, 1)
+		assert.Equal(t, "new@testmail.com", export.EmailChanges[0].NewEmail)
+		require.Len(t, export.Messages, 1)
+		assert.Equal(t, models.OutboxEmailChangeNotice, export.Messages[0].MessageType)
+
+		// no hashes or message contents
+		encoded, err := json.Marshal(export)
+		require.NoError(t, err)
+		assert.NotContains(t, string(encoded), "hash")
+		assert.NotContains(t, string(encoded), mocks.TestUserPasswordHash)
+
+		_, err = userDataService.ExportUserData(user.ID + 100)
+		assert.Equal(t, ErrProfileNotFound, err)
+	})
+
+	t.Run("erases every row", func(t *testing.T) {
+		assert.ErrorIs(t, userDataService.EraseUser(user.ID, user.ID), ErrInvalidStatusChange)
+
+		require.NoError(t, userDataService.EraseUser(user.ID, user.ID+1))
+
+		erased, err := DBOperationService.FindUserByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("erased-%d@erased.invalid", user.ID), erased.Email)
+		assert.Empty(t, erased.Password)
+		assert.Equal(t, models.UserStatusDeleted, erased.Status)
+		assert.NotNil(t, erased.ErasedAt)
+		assert.Empty(t, erased.LastLoginIP)
+		assert.Empty(t, erased.LastLoginUserAgent)
+
+		export, err := userDataService.ExportUserData(user.ID)
+		require.NoError(t, err)
+		require.NotNil(t, export.Details)
+		assert.Empty(t, export.Details.FirstName)
+		assert.Empty(t, export.Details.LastName)
+		assert.Empty(t, export.Details.PreferredLocale)
+		assert.Empty(t, export.Roles)
+		assert.Empty(t, export.Sessions)
+		assert.Empty(t, export.FederatedIdentities)
+		assert.Empty(t, export.EmailChanges)
+		assert.Empty(t, export.Messages)
+		var invitations, invitationMessages int64
+		require.NoError(t, db.Model(&models.Invitation{}).Count(&invitations).Error)
+		require.NoError(t, db.Model(&models.OutboxMessage{}).Where("message_type = ?", models.OutboxInvitation).Count(&invitationMessages).Error)
+		assert.Zero(t, invitations)
+		assert.Zero(t, invitationMessages)
+
+		deleted := events.EventsOfType(models.EventUserDeleted)
+		require.Len(t, deleted, 1)
+		assert.JSONEq(t, `{"email":"`+mocks.TestUserEmail+`","erased":true}`, string(deleted[0].Data))
+
+		assert.Equal(t, ErrUserErased, userDataService.EraseUser(user.ID, 0))
+		assert.Equal(t, ErrProfileNotFound, userDataService.EraseUser(user.ID+100, 0))
+	})
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUserDirectoryPageSize = 50
+	userDirectoryExportBatchSize = 500
+)
+
+var ErrInvalidUserDirectoryQuery = errors.New("invalid user directory query")
+
+// userDirectorySortColumns are the columns the directory can be ordered by, ties are broken by the user ID
+var userDirectorySortColumns = map[string]string{
+	"id":         "users.id",
+	"email":      "users.email",
+	"first_name": "COALESCE(user_details.firstname, '')",
+	"last_name":  "COALESCE(user_details.lastname, '')",
+	"created_at": "users.created_at",
+}
+
+type IUserDirectoryService interface {
+	ListUsers(query models.UserDirectoryQuery) (*models.UserDirectoryPage, error)
+	ExportUsers(query models.UserDirectoryQuery, write func(models.UserDirectoryEntry) error) error
+}
+
+// UserDirectoryService lets admins browse all users page by page
+type UserDirectoryService struct {
+	db *gorm.DB
+}
+
+func NewUserDirectoryService(db *gorm.DB) *UserDirectoryService {
+	return &UserDirectoryService{db: db}
+}
+
+type userDirectoryRow struct {
+	ID                 uint
+	Email              string
+	FirstName          string
+	MiddleName         string
+	LastName           string
+	PreferredLocale    string
+	Status             string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	LastLoginAt        *time.Time
+	LastLoginIP        string
+	LastLoginUserAgent string
+}
+
+// userDirectoryCursor is the position after the last user of a page, encoded as next_cursor
+type userDirectoryCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    uint   `json:"id"`
+}
+
+// ListUsers returns one page of the users matching the query. Invalid queries fail with
+// ErrInvalidUserDirectoryQuery.
+func (s *UserDirectoryService) ListUsers(query models.UserDirectoryQuery) (*models.UserDirectoryPage, error) {
+	sortField, descending := strings.CutPrefix(query.Sort, "-")
+	if sortField == "" {
+		sortField = "id"
+	}
+	sortColumn, known := userDirectorySortColumns[sortField]
+	if !known {
+		return nil, fmt.Errorf("%w: unsupported sort %s", ErrInvalidUserDirectoryQuery, query.Sort)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultUserDirectoryPageSize
+	}
+
+	db, err := s.filter(query)
+	if err != nil {
+		return nil, err
+	}
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	if query.After != "" {
+		cursor, err := decodeUserDirectoryCursor(query.After, sortField+direction)
+		if err != nil {
+			return nil, err
+		}
+		if db, err = afterUserDirectoryCursor(db, sortField, sortColumn, descending, cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []userDirectoryRow
+	err = db.Select("users.id, users.email, users.created_at, " +
+		"COALESCE(user_details.firstname, '') AS first_name, COALESCE(user_details.middlename, '') AS middle_name, " +
+		"COALESCE(user_details.lastname, '') AS last_name, COALESCE(user_details.preferred_locale, '') AS preferred_locale, " +
+		"users.status, GREATEST(users.updated_at, COALESCE(user_details.updated_at, users.updated_at)) AS updated_at, " +
+		"users.last_login_at, COALESCE(users.last_login_ip, '') AS last_login_ip, " +
+		"COALESCE(users.last_login_user_agent, '') AS last_login_user_agent").
+		Order(sortColumn + " " + direction).
+		Order("users.id " + direction).
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserDirectoryPage{Users: make([]models.UserDirectoryEntry, 0, len(rows))}
+	roles, err := s.roleNames(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		userRoles := roles[row.ID]
+		if userRoles == nil {
+			userRoles = []string{}
+		}
+		page.Users = append(page.Users, models.UserDirectoryEntry{
+			ID:                 row.ID,
+			Email:              row.Email,
+			FirstName:          row.FirstName,
+			MiddleName:         row.MiddleName,
+			LastName:           row.LastName,
+			PreferredLocale:    row.PreferredLocale,
+			Roles:              userRoles,
+			Status:             row.Status,
+			CreatedAt:          row.CreatedAt,
+			UpdatedAt:          row.UpdatedAt,
+			LastLoginAt:        row.LastLoginAt,
+			LastLoginIP:        row.LastLoginIP,
+			LastLoginUserAgent: row.LastLoginUserAgent,
+		})
+	}
+	if len(rows) == limit {
+		page.NextCursor = encodeUserDirectoryCursor(sortField, direction, rows[len(rows)-1])
+	}
+	return page, nil
+}
+
+// ExportUsers hands every user matching the query to write, in the order of the query. After and Limit
+// are ignored.
+func (s *UserDirectoryService) ExportUsers(query models.UserDirectoryQuery, write func(models.UserDirectoryEntry) error) error {
+	query.After = ""
+	query.Limit = userDirectoryExportBatchSize
+	for {
+		page, err := s.ListUsers(query)
+		if err != nil {
+			return err
+		}
+		for _, user := range page.Users {
+			if err := write(user); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.After = page.NextCursor
+	}
+}
+
+// filter applies the search and the filters of the query
+func (s *UserDirectoryService) filter(query models.UserDirectoryQuery) (*gorm.DB, error) {
+	db := s.db.Table("users").Joins("LEFT JOIN user_details ON user_details.user_id = users.id")
+	for _, term := range strings.Fields(query.Search) {
+		pattern := "%" + escapeLike(term) + "%"
+		db = db.Where("(users.email ILIKE ? OR user_details.firstname ILIKE ? OR user_details.middlename ILIKE ? OR user_details.lastname ILIKE ?)",
+			pattern, pattern, pattern, pattern)
+	}
+	if query.Role != "" {
+		db = db.Where("EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE user_roles.user_id = users.id AND roles.role_name = ?)", query.Role)
+	}
+	if query.Status != "" {
+		if !userStatuses[query.Status] {
+			return nil, fmt.Errorf("%w: unsupported status %s", ErrInvalidUserDirectoryQuery, query.Status)
+		}
+		db = db.Where("users.status = ?", query.Status)
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("users.created_at >= ?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("users.created_at < ?", *query.CreatedBefore)
+	}
+	return db, nil
+}
+
+func (s *UserDirectoryService) roleNames(rows []userDirectoryRow) (map[uint][]string, error) {
+	roles := make(map[uint][]string, len(rows))
+	if len(rows) == 0 {
+		return roles, nil
+	}
+	userIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.ID)
+	}
+	var userRoles []struct {
+		UserID   uint
+		RoleName string
+	}
+	err := s.db.Table("user_roles").
+		Select("user_roles.user_id, roles.role_name").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id IN ?", userIDs).
+		Order("roles.role_name").
+		Scan(&userRoles).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, userRole := range userRoles {
+		roles[userRole.UserID] = append(roles[userRole.UserID], userRole.RoleName)
+	}
+	return roles, nil
+}
+
+func afterUserDirectoryCursor(db *gorm.DB, sortField, sortColumn string, descending bool, cursor userDirectoryCursor) (*gorm.DB, error) {
+	comparison := ">"
+	if descending {
+		comparison = "<"
+	}
+	switch sortField {
+	case "id":
+		return db.Where("users.id "+comparison+" ?", cursor.ID), nil
+	case "created_at":
+		createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid after cursor", ErrInvalidUserDirectoryQuery)
+		}
+		return db.Where("(users.created_at, users.id) "+comparison+" (?, ?)", createdAt, cursor.ID), nil
+	default:
+		return db.Where("("+sortColumn+", users.id) "+comparison+" (?, ?)", cursor.Value, cursor.ID), nil
+	}
+}
+
+func encodeUserDirectoryCursor(sortField, direction string, last userDirectoryRow) string {
+	cursor := userDirectoryCursor{Sort: sortField + direction, ID: last.ID}
+	switch sortField {
+	case "email":
+		cursor.Value = last.Email
+	case "first_name":
+		cursor.Value = last.FirstName
+	case "last_name":
+		cursor.Value = last.LastName
+	case "created_at":
+		cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeUserDirectoryCursor reads a next_cursor, which must have been issued for the same sort
+func decodeUserDirectoryCursor(after, sort string) (userDirectoryCursor, error) {
+	var cursor userDirectoryCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil || json.Unmarshal(decoded, &cursor) != nil || cursor.Sort != sort {
+		return cursor, fmt.Errorf("%w: invalid after cursor", ErrInvalidUserDirectoryQuery)
+	}
+	return cursor, nil
+}
+
+// escapeLike makes the wildcards of a LIKE pattern match themselves
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
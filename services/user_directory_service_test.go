@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func directoryEmails(users []models.UserDirectoryEntry) []string {
+	emails := []string{}
+	for _, user := range users {
+		emails = append(emails, user.Email)
+	}
+	return emails
+}
+
+func TestUserDirectoryService(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	directoryService := NewUserDirectoryService(DBOperationService.db)
+
+	for _, user := range []struct {
+		email, firstName, lastName string
+	}{
+		{"anna@testmail.com", "Anna", "Zimmer"},
+		{"ben@testmail.com", "Ben", "Young"},
+		{"carla_x@testmail.com", "Carla", "Xavier"},
+	} {
+		require.NoError(t, DBOperationService.CreateUser(
+			&models.User{Email: user.email, Password: mocks.TestUserPasswordHash},
+			&models.UserDetail{FirstName: user.firstName, LastName: user.lastName},
+		))
+	}
+	ben, err := DBOperationService.FindUserByEmail("ben@testmail.com")
+	require.NoError(t, err)
+	require.NoError(t, DBOperationService.db.Exec("INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE role_name = 'admin'", ben.ID).Error)
+	carla, err := DBOperationService.FindUserByEmail("carla_x@testmail.com")
+	require.NoError(t, err)
+	require.NoError(t, DBOperationService.UpdateUserStatus(carla.ID, models.UserStatusActive, models.UserStatusSuspended, "testing"))
+	require.NoError(t, DBOperationService.RecordLogin(ben.ID, models.LoginMetadata{IPAddress: "203.0.113.7", UserAgent: "test-client/1.0"}))
+
+	t.Run("pages in order", func(t *testing.T) {
+		page, err := directoryService.ListUsers(models.UserDirectoryQuery{Sort: "-last_name", Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"anna@testmail.com", "ben@testmail.com"}, directoryEmails(page.Users))
+		require.NotEmpty(t, page.NextCursor)
+		assert.Equal(t, []string{"admin"}, page.Users[1].Roles)
+		assert.Equal(t, []string{}, page.Users[0].Roles)
+		assert.Nil(t, page.Users[0].LastLoginAt)
+		require.NotNil(t, page.Users[1].LastLoginAt)
+		assert.Equal(t, "203.0.113.7", page.Users[1].LastLoginIP)
+		assert.Equal(t, "test-client/1.0", page.Users[1].LastLoginUserAgent)
+		assert.False(t, page.Users[1].UpdatedAt.Before(page.Users[1].CreatedAt))
+
+		page, err = directoryService.ListUsers(models.UserDirectoryQuery{Sort: "-last_name", Limit: 2, After: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"carla_x@testmail.com"}, directoryEmails(page.Users))
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("pages by creation time", func(t *testing.T) {
+		var emails []string
+		query := models.UserDirectoryQuery{Sort: "created_at", Limit: 1}
+		for {
+			page, err := directoryService.ListUsers(query)
+			require.NoError(t, err)
+			emails = append(emails, directoryEmails(page.Users)...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.After = page.NextCursor
+		}
+		assert.Equal(t, []string{"anna@testmail.com", "ben@testmail.com", "carla_x@testmail.com"}, emails)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    models.UserDirectoryQuery
+			expected []string
+		}{
+			{"search over names", models.UserDirectoryQuery{Search: "ANNA zim"}, []string{"anna@testmail.com"}},
+			{"search over email", models.UserDirectoryQuery{Search: "ben@"}, []string{"ben@testmail.com"}},
+			{"wildcards match themselves", models.UserDirectoryQuery{Search: "_x"}, []string{"carla_x@testmail.com"}},
+			{"role", models.UserDirectoryQuery{Role: "admin"}, []string{"ben@testmail.com"}},
+			{"suspended", models.UserDirectoryQuery{Status: models.UserStatusSuspended}, []string{"carla_x@testmail.com"}},
+			{"active", models.UserDirectoryQuery{Status: models.UserStatusActive}, []string{"anna@testmail.com", "ben@testmail.com"}},
+			{"created in the future", models.UserDirectoryQuery{CreatedAfter: func() *time.Time { tomorrow := time.Now().Add(24 * time.Hour); return &tomorrow }()}, []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				page, err := directoryService.ListUsers(tt.query)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, directoryEmails(page.Users))
+			})
+		}
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for _, query := range []models.UserDirectoryQuery{
+			{Sort: "password"},
+			{Status: "asleep"},
+			{After: "not a cursor"},
+		} {
+			_, err := directoryService.ListUsers(query)
+			assert.ErrorIs(t, err, ErrInvalidUserDirectoryQuery)
+		}
+		// a cursor only continues the sort it was issued for
+		page, err := directoryService.ListUsers(models.UserDirectoryQuery{Sort: "email", Limit: 1})
+		require.NoError(t, err)
+		_, err = directoryService.ListUsers(models.UserDirectoryQuery{Sort: "-email", After: page.NextCursor})
+		assert.ErrorIs(t, err, ErrInvalidUserDirectoryQuery)
+	})
+
+	t.Run("exports every match", func(t *testing.T) {
+		var exported []models.UserDirectoryEntry
+		err := directoryService.ExportUsers(models.UserDirectoryQuery{Sort: "email", Limit: 1}, func(user models.UserDirectoryEntry) error {
+			exported = append(exported, user)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"anna@testmail.com", "ben@testmail.com", "carla_x@testmail.com"}, directoryEmails(exported))
+	})
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxUserImportRows is the most users one import can create, hashing their passwords takes about a second each
+	MaxUserImportRows       = 1000
+	userImportBatchSize     = 100
+	maxUserImportLineLength = 64 * 1024
+)
+
+var (
+	// ErrInvalidUserImport is returned when the file cannot be read at all
+	ErrInvalidUserImport = errors.New("invalid user import")
+	// ErrUserImportRejected is returned with the errors of every invalid row, no user was created
+	ErrUserImportRejected = errors.New("user import has invalid rows")
+	// ErrUserImportIncomplete is returned when some batches could not be stored, the result tells which rows
+	ErrUserImportIncomplete = errors.New("user import is incomplete")
+)
+
+var userImportCSVColumns = []string{"email", "first_name", "middle_name", "last_name", "preferred_locale", "roles"}
+
+type IUserImportService interface {
+	ImportUsers(format string, data io.Reader) (*models.UserImportResult, error)
+}
+
+// UserImportService registers many users at once, each of them is sent their credentials through the outbox
+// like after /auth/register
+type UserImportService struct {
+	db         *gorm.DB
+	onboarding models.OnboardingConfig
+	events     IEventPublisher
+}
+
+// NewUserImportService publishes user.registered and role.assigned for the rows of an import once they are
+// committed, nothing is published for an import that fails
+func NewUserImportService(db *gorm.DB, onboarding models.OnboardingConfig, events IEventPublisher) *UserImportService {
+	return &UserImportService{db: db, onboarding: onboarding, events: events}
+}
+
+// userImportRow is a parsed row with the line it started on
+type userImportRow struct {
+	line int
+	models.UserImportRow
+}
+
+// ImportUsers reads the rows of a models.UserImportCSV or models.UserImportJSONLines file and validates all of
+// them first. When any row is invalid nothing is created and ErrUserImportRejected is returned with the
+// errors of every row. Otherwise the users are created in batches, a batch that fails is reported row by row
+// with ErrUserImportIncomplete and the following batches are still created.
+func (s *UserImportService) ImportUsers(format string, data io.Reader) (*models.UserImportResult, error) {
+	var rows []userImportRow
+	var rowErrors []models.UserImportRowError
+	var err error
+	switch format {
+	case models.UserImportCSV:
+		rows, rowErrors, err = parseUserImportCSV(data)
+	case models.UserImportJSONLines:
+		rows, rowErrors, err = parseUserImportJSONLines(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidUserImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	total := len(rows) + len(rowErrors)
+	if total == 0 {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidUserImport)
+	}
+	if total > MaxUserImportRows {
+		return nil, fmt.Errorf("%w: at most %d rows can be imported at once", ErrInvalidUserImport, MaxUserImportRows)
+	}
+
+	result := &models.UserImportResult{Rows: total, Errors: append([]models.UserImportRowError{}, rowErrors...)}
+	roles, err := s.validate(rows, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		slices.SortFunc(result.Errors, func(a, b models.UserImportRowError) int { return a.Line - b.Line })
+		return result, ErrUserImportRejected
+	}
+
+	for start := 0; start < len(rows); start += userImportBatchSize {
+		batch := rows[start:min(start+userImportBatchSize, len(rows))]
+		if err := s.createBatch(batch, roles); err != nil {
+			log.Printf("Failed to import the users of lines %d to %d: %v", batch[0].line, batch[len(batch)-1].line, err)
+			for _, row := range batch {
+				result.Errors = append(result.Errors, models.UserImportRowError{
+					Line:   row.line,
+					Email:  row.Email,
+					Errors: []string{"user could not be created"},
+				})
+			}
+			continue
+		}
+		result.Created += len(batch)
+	}
+	if len(result.Errors) > 0 {
+		return result, ErrUserImportIncomplete
+	}
+	return result, nil
+}
+
+// validate normalizes the rows in place and adds the errors of invalid rows to the result. It returns the
+// roles named by the rows.
+func (s *UserImportService) validate(rows []userImportRow, result *models.UserImportResult) (map[string]models.Role, error) {
+	var roleNames, emails []string
+	for _, row := range rows {
+		roleNames = append(roleNames, row.Roles...)
+		emails = append(emails, strings.ToLower(strings.TrimSpace(row.Email)))
+	}
+	roles := map[string]models.Role{}
+	if len(roleNames) > 0 {
+		var found []models.Role
+		if err := s.db.Where("role_name IN ?", roleNames).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, role := range found {
+			roles[role.RoleName] = role
+		}
+	}
+	taken := map[string]bool{}
+	var takenEmails []string
+	if err := s.db.Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &takenEmails).Error; err != nil {
+		return nil, err
+	}
+	for _, email := range takenEmails {
+		taken[email] = true
+	}
+
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		var problems []string
+		row.Email = strings.TrimSpace(row.Email)
+		email := strings.ToLower(row.Email)
+		if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email {
+			problems = append(problems, "email must be an email address")
+		} else if taken[email] {
+			problems = append(problems, "email is already in use")
+		} else if line, duplicate := seen[email]; duplicate {
+			problems = append(problems, fmt.Sprintf("email is already imported on line %d", line))
+		} else {
+			seen[email] = row.line
+		}
+		for _, name := range []struct {
+			field    string
+			value    *string
+			required bool
+		}{
+			{"first_name", &row.FirstName, true},
+			{"middle_name", &row.MiddleName, false},
+			{"last_name", &row.LastName, true},
+		} {
+			*name.value = strings.TrimSpace(*name.value)
+			if problem := nameProblem(name.field, *name.value, name.required); problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+		if locale := strings.TrimSpace(row.PreferredLocale); locale != "" {
+			normalized, err := utils.NormalizeLocale(locale)
+			if err != nil {
+				problems = append(problems, err.Error())
+			}
+			row.PreferredLocale = normalized
+		}
+		slices.Sort(row.Roles)
+		row.Roles = slices.Compact(row.Roles)
+		for _, roleName := range row.Roles {
+			if _, known := roles[roleName]; !known {
+				problems = append(problems, fmt.Sprintf("role %s does not exist", roleName))
+			}
+		}
+		if len(problems) > 0 {
+			result.Errors = append(result.Errors, models.UserImportRowError{Line: row.line, Email: row.Email, Errors: problems})
+		}
+	}
+	return roles, nil
+}
+
+// createBatch stores the users of the batch with their details, roles and credential deliveries in one
+// transaction, and publishes their events once it is committed
+func (s *UserImportService) createBatch(batch []userImportRow, roles map[string]models.Role) error {
+	registrations, err := s.prepare(batch)
+	if err != nil {
+		return err
+	}
+
+	users := make([]models.User, 0, len(batch))
+	for _, registration := range registrations {
+		users = append(users, registration.user)
+	}
+	var userRoles []models.UserRole
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// validate checked the addresses, a user registered since then fails the batch
+		if err := tx.Create(&users).Error; err != nil {
+			return emailTakenAs(err)
+		}
+		userDetails := make([]models.UserDetail, 0, len(batch))
+		messages := make([]*models.OutboxMessage, 0, len(batch))
+		var setupTokens []*models.PasswordSetupToken
+		for i, registration := range registrations {
+			registration.user = users[i]
+			registration.userDetail.UserID = users[i].ID
+			userDetails = append(userDetails, registration.userDetail)
+			registration.message.UserID = &registration.user.ID
+			messages = append(messages, registration.message)
+			if registration.setupToken != nil {
+				registration.setupToken.UserID = users[i].ID
+				setupTokens = append(setupTokens, registration.setupToken)
+			}
+			for _, roleName := range batch[i].Roles {
+				userRoles = append(userRoles, models.UserRole{UserID: users[i].ID, RoleID: roles[roleName].ID})
+			}
+		}
+		if err := tx.Create(&userDetails).Error; err != nil {
+			return err
+		}
+		if len(setupTokens) > 0 {
+			if err := tx.Create(&setupTokens).Error; err != nil {
+				return err
+			}
+		}
+		if len(userRoles) > 0 {
+			if err := tx.Create(&userRoles).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&messages).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	roleNames := map[uint]string{}
+	for _, role := range roles {
+		roleNames[role.ID] = role.RoleName
+	}
+	for _, registration := range registrations {
+		publishRegistered(s.events, &registration.user, &registration.userDetail, models.EventMethodImport)
+	}
+	for _, userRole := range userRoles {
+		publishEvent(s.events, models.EventRoleAssigned, &userRole.UserID, models.RoleMembershipData{RoleID: userRole.RoleID, RoleName: roleNames[userRole.RoleID]})
+	}
+	return nil
+}
+
+// prepare generates the credentials of the batch on every CPU, hashing a password takes long
+func (s *UserImportService) prepare(batch []userImportRow) ([]*registration, error) {
+	registrations := make([]*registration, len(batch))
+	errs := make([]error, len(batch))
+	rowIndexes := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rowIndexes {
+				registrations[i], errs[i] = newRegistration(batch[i].UserRegitrationRequest, s.onboarding)
+			}
+		}()
+	}
+	for i := range batch {
+		rowIndexes <- i
+	}
+	close(rowIndexes)
+	wg.Wait()
+	return registrations, errors.Join(errs...)
+}
+
+func parseUserImportCSV(data io.Reader) ([]userImportRow, []models.UserImportRowError, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidUserImport, err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if !slices.Contains(userImportCSVColumns, column) {
+			return nil, nil, fmt.Errorf("%w: unknown column %s", ErrInvalidUserImport, column)
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, nil, fmt.Errorf("%w: the email column is missing", ErrInvalidUserImport)
+	}
+
+	var rows []userImportRow
+	var rowErrors []models.UserImportRowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, rowErrors, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidUserImport, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rowErrors = append(rowErrors, models.UserImportRowError{
+				Line:   line,
+				Errors: []string{fmt.Sprintf("row has %d fields, the header has %d", len(record), len(header))},
+			})
+			continue
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		row := userImportRow{line: line}
+		row.Email = field("email")
+		row.FirstName = field("first_name")
+		row.MiddleName = field("middle_name")
+		row.LastName = field("last_name")
+		row.PreferredLocale = field("preferred_locale")
+		for _, roleName := range strings.Split(field("roles"), ";") {
+			if roleName = strings.TrimSpace(roleName); roleName != "" {
+				row.Roles = append(row.Roles, roleName)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func parseUserImportJSONLines(data io.Reader) ([]userImportRow, []models.UserImportRowError, error) {
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 0, 4096), maxUserImportLineLength)
+	var rows []userImportRow
+	var rowErrors []models.UserImportRowError
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		row := userImportRow{line: line}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.UserImportRow); err != nil {
+			rowErrors = append(rowErrors, models.UserImportRowError{Line: line, Errors: []string{"invalid JSON: " + err.Error()}})
+			continue
+		}
+		if decoder.More() {
+			rowErrors = append(rowErrors, models.UserImportRowError{Line: line, Errors: []string{"invalid JSON: more than one object on the line"}})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidUserImport, err)
+	}
+	return rows, rowErrors, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserImportCSV(t *testing.T) {
+	rows, rowErrors, err := parseUserImportCSV(strings.NewReader("\ufeffemail,first_name,last_name,roles\n" +
+		"jane@example.com,Jane,\"Doe, Jr.\",admin; editor\n" +
+		"john@example.com,John\n" +
+		"\"multi\nline\",Ann,Smith,\n"))
+
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].line)
+	assert.Equal(t, "jane@example.com", rows[0].Email)
+	assert.Equal(t, "Doe, Jr.", rows[0].LastName)
+	assert.Equal(t, []string{"admin", "editor"}, rows[0].Roles)
+	assert.Equal(t, 4, rows[1].line)
+	assert.Empty(t, rows[1].Roles)
+	assert.Equal(t, []models.UserImportRowError{{Line: 3, Errors: []string{"row has 2 fields, the header has 4"}}}, rowErrors)
+
+	for _, header := range []string{"email,firstname\n", "first_name,last_name\n"} {
+		_, _, err := parseUserImportCSV(strings.NewReader(header))
+		assert.ErrorIs(t, err, ErrInvalidUserImport, header)
+	}
+}
+
+func TestParseUserImportJSONLines(t *testing.T) {
+	rows, rowErrors, err := parseUserImportJSONLines(strings.NewReader(
+		`{"email":"jane@example.com","first_name":"Jane","last_name":"Doe","preferred_locale":"de","roles":["admin"]}` + "\n" +
+			"\n" +
+			`{"email":"john@example.com","password":"secret"}` + "\n" +
+			`{"email":"ann@example.com"} {}` + "\n"))
+
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 1, rows[0].line)
+	assert.Equal(t, "de", rows[0].PreferredLocale)
+	assert.Equal(t, []string{"admin"}, rows[0].Roles)
+	require.Len(t, rowErrors, 2)
+	assert.Equal(t, 3, rowErrors[0].Line)
+	assert.Contains(t, rowErrors[0].Errors[0], "unknown field")
+	assert.Equal(t, 4, rowErrors[1].Line)
+}
+
+func TestUserImportService(t *testing.T) {
+	sqlDB, err := DBOperationService.db.DB()
+	require.NoError(t, err)
+	defer tests.DeleteTestData(sqlDB)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.OutboxMessage{})
+	events := NewInMemoryEventPublisher()
+	importService := NewUserImportService(DBOperationService.db, models.OnboardingConfig{Mode: models.OnboardingPassword}, events)
+	require.NoError(t, DBOperationService.CreateUser(
+		&models.User{Email: "taken@testmail.com", Password: mocks.TestUserPasswordHash},
+		&models.UserDetail{FirstName: "Taken", LastName: "User"},
+	))
+
+	t.Run("rejects the whole file when a row is invalid", func(t *testing.T) {
+		result, err := importService.ImportUsers(models.UserImportCSV, strings.NewReader("email,first_name,last_name,preferred_locale,roles\n"+
+			"anna@testmail.com,Anna,Zimmer,,\n"+
+			"TAKEN@testmail.com,Taken,Again,,\n"+
+			"not an email,,Young,klingon please,astronaut\n"+
+			"Anna@testmail.com,Anna,Twice,,\n"))
+
+		assert.ErrorIs(t, err, ErrUserImportRejected)
+		assert.Equal(t, 4, result.Rows)
+		assert.Zero(t, result.Created)
+		assert.Equal(t, []models.UserImportRowError{
+			{Line: 3, Email: "TAKEN@testmail.com", Errors: []string{"email is already in use"}},
+			{Line: 4, Email: "not an email", Errors: []string{
+				"email must be an email address", "first_name must not be empty", "invalid locale klingon please", "role astronaut does not exist",
+			}},
+			{Line: 5, Email: "Anna@testmail.com", Errors: []string{"email is already imported on line 2"}},
+		}, result.Errors)
+		_, err = DBOperationService.FindUserByEmail("anna@testmail.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("creates the users with their roles and credentials", func(t *testing.T) {
+		result, err := importService.ImportUsers(models.UserImportJSONLines, strings.NewReader(
+			`{"email":" anna@testmail.com ","first_name":"Anna","last_name":"Zimmer","preferred_locale":"pt_br","roles":["admin"]}`+"\n"+
+				`{"email":"ben@testmail.com","first_name":"Ben","last_name":"Young"}`+"\n"))
+
+		require.NoError(t, err)
+		assert.Equal(t, &models.UserImportResult{Rows: 2, Created: 2, Errors: []models.UserImportRowError{}}, result)
+		anna, err := DBOperationService.FindUserByEmail("anna@testmail.com")
+		require.NoError(t, err)
+		assert.Equal(t, models.UserStatusActive, anna.Status)
+		details, err := DBOperationService.FindUserDetailsByUserID(anna.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "pt-BR", details.PreferredLocale)
+		roles, err := NewRoleService(DBOperationService.db, nil).FindRolesByUserID(anna.ID)
+		require.NoError(t, err)
+		require.Len(t, roles, 1)
+		assert.Equal(t, "admin", roles[0].RoleName)
+
+		var messages []models.OutboxMessage
+		require.NoError(t, DBOperationService.db.Where("message_type = ?", models.OutboxPasswordDelivery).Order("id").Find(&messages).Error)
+		require.Len(t, messages, 2)
+		assert.Equal(t, anna.ID, *messages[0].UserID)
+		assert.Contains(t, messages[0].Payload, `"email":"anna@testmail.com"`)
+
+		registered := events.EventsOfType(models.EventUserRegistered)
+		require.Len(t, registered, 2)
+		assert.Contains(t, string(registered[0].Data), `"method":"import"`)
+		assert.Len(t, events.EventsOfType(models.EventRoleAssigned), 1)
+	})
+
+	t.Run("unreadable files", func(t *testing.T) {
+		for format, data := range map[string]string{
+			models.UserImportCSV:       "",
+			models.UserImportJSONLines: "\n\n",
+			"xlsx":                     "email\n",
+		} {
+			_, err := importService.ImportUsers(format, strings.NewReader(data))
+			assert.ErrorIs(t, err, ErrInvalidUserImport, format)
+		}
+	})
+}
@@ -3,34 +3,77 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
 
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
 	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 )
 
 type UserLoginService struct {
-	dbService IDatabaseOperationService
+	dbService      IDatabaseOperationService
+	authenticators []Authenticator
+	events         IEventPublisher
 }
 
-func NewUserLoginService(dbService IDatabaseOperationService) *UserLoginService {
+// NewUserLoginService tries the given authenticators in order. Without any, passwords are checked
+// against the local database. Successful logins are recorded through dbService unless it is nil.
+func NewUserLoginService(dbService IDatabaseOperationService, authenticators ...Authenticator) *UserLoginService {
+	return NewUserLoginServiceWithEvents(dbService, nil, authenticators...)
+}
+
+// NewUserLoginServiceWithEvents also publishes user.logged_in and user.login_failed to events
+func NewUserLoginServiceWithEvents(dbService IDatabaseOperationService, events IEventPublisher, authenticators ...Authenticator) *UserLoginService {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewDatabaseAuthenticator(dbService)}
+	}
 	return &UserLoginService{
-		dbService: dbService,
+		dbService:      dbService,
+		authenticators: authenticators,
+		events:         events,
 	}
 }
 
-func (s *UserLoginService) Login(input models.LoginRequest) (string, error) {
-	user, err := s.dbService.FindUserByEmail(input.Email)
-	if err != nil {
-		return "", errors.New("Invalid credentials")
+// Authenticate verifies the credentials and returns the matching user with its details
+func (s *UserLoginService) Authenticate(input models.LoginRequest) (*models.User, *models.UserDetail, error) {
+	err := ErrInvalidCredentials
+	for _, authenticator := range s.authenticators {
+		var user *models.User
+		var userDetails *models.UserDetail
+		user, userDetails, err = authenticator.Authenticate(input.Email, input.Password)
+		if err == nil {
+			// the credentials are right, but no other authenticator would accept the user either
+			if err = checkUserActive(user); err != nil {
+				break
+			}
+			recordLogin(s.dbService, user.ID, input.Metadata)
+			publishEvent(s.events, models.EventUserLoggedIn, &user.ID, models.UserLoggedInData{
+				Email:  user.Email,
+				Method: models.EventMethodPassword,
+			})
+			return user, userDetails, nil
+		}
 	}
+	publishEvent(s.events, models.EventUserLoginFailed, nil, models.UserLoginFailedData{Email: input.Email, Reason: loginFailureReason(err)})
+	return nil, nil, err
+}
 
-	if !utils.CheckPasswordHash(input.Password, user.Password) {
-		return "", errors.New("Invalid credentials")
+// loginFailureReason maps the error of a login to the reason published with user.login_failed, errors of
+// the backends themselves are not passed on
+func loginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCredentials):
+		return models.LoginFailedInvalidCredentials
+	case errors.Is(err, ErrAccountInactive):
+		return models.LoginFailedAccountInactive
+	default:
+		return models.LoginFailedBackendError
 	}
+}
 
-	userDetails, err := s.dbService.FindUserDetailsByUserID(user.ID)
+func (s *UserLoginService) Login(input models.LoginRequest) (string, error) {
+	user, userDetails, err := s.Authenticate(input)
 	if err != nil {
-		return "", errors.New("Invalid user Id")
+		return "", err
 	}
 
 	token, err := utils.GenerateJWT(user.Email, *userDetails)
@@ -41,3 +84,13 @@ func (s *UserLoginService) Login(input models.LoginRequest) (string, error) {
 
 	return token, nil
 }
+
+// recordLogin stores the user's last login. A failure is only logged, the login itself succeeded.
+func recordLogin(dbService IDatabaseOperationService, userID uint, login models.LoginMetadata) {
+	if dbService == nil {
+		return
+	}
+	if err := dbService.RecordLogin(userID, login); err != nil {
+		log.Printf("Failed to record the login of user %d: %v", userID, err)
+	}
+}
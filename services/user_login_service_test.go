@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
@@ -21,6 +22,7 @@ func TestLogin_Success(t *testing.T) {
 	user := &models.User{
 		Email:    mocks.TestUserEmail,
 		Password: mocks.TestUserPasswordHash,
+		Status:   models.UserStatusActive,
 	}
 	userDetails := &models.UserDetail{
 		FirstName: mocks.TestUserFirstName,
@@ -29,6 +31,7 @@ func TestLogin_Success(t *testing.T) {
 
 	mockDBService.On("FindUserByEmail", input.Email).Return(user, nil)
 	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
 
 	result, err := loginService.Login(input)
 
@@ -50,6 +53,7 @@ func TestLogin_InvalidCredentials(t *testing.T) {
 	user := &models.User{
 		Email:    mocks.TestUserEmail,
 		Password: mocks.TestUserPasswordHash,
+		Status:   models.UserStatusActive,
 	}
 	mockDBService.On("FindUserByEmail", user.Email).Return(user, nil)
 
@@ -72,6 +76,7 @@ func TestLogin_FailToFindUserDetails(t *testing.T) {
 	user := &models.User{
 		Email:    mocks.TestUserEmail,
 		Password: mocks.TestUserPasswordHash,
+		Status:   models.UserStatusActive,
 	}
 	mockDBService.On("FindUserByEmail", user.Email).Return(user, nil)
 	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(nil, errors.New("user details not found"))
@@ -100,6 +105,7 @@ func TestLogin_FailToGenerateToken(t *testing.T) {
 	user := &models.User{
 		Email:    mocks.TestUserEmail,
 		Password: mocks.TestUserPasswordHash,
+		Status:   models.UserStatusActive,
 	}
 	userDetails := &models.UserDetail{
 		FirstName: mocks.TestUserFirstName,
@@ -108,6 +114,7 @@ func TestLogin_FailToGenerateToken(t *testing.T) {
 
 	mockDBService.On("FindUserByEmail", user.Email).Return(user, nil)
 	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(userDetails, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
 
 	// Call the Login method
 	result, err := loginService.Login(input)
@@ -118,3 +125,103 @@ func TestLogin_FailToGenerateToken(t *testing.T) {
 
 	mockDBService.AssertExpectations(t)
 }
+
+type stubAuthenticator struct {
+	user *models.User
+	err  error
+}
+
+func (a stubAuthenticator) Authenticate(email, password string) (*models.User, *models.UserDetail, error) {
+	if a.err != nil {
+		return nil, nil, a.err
+	}
+	return a.user, &models.UserDetail{UserID: a.user.ID, FirstName: mocks.TestUserFirstName}, nil
+}
+
+func TestLogin_FallsThroughAuthenticators(t *testing.T) {
+	directoryUser := &models.User{ID: 2, Email: mocks.TestUserEmail, Status: models.UserStatusActive}
+	loginService := NewUserLoginService(nil,
+		stubAuthenticator{err: ErrInvalidCredentials},
+		stubAuthenticator{user: directoryUser},
+	)
+
+	user, _, err := loginService.Authenticate(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+
+	assert.NoError(t, err)
+	assert.Equal(t, directoryUser, user)
+}
+
+func TestLogin_AllAuthenticatorsFail(t *testing.T) {
+	loginService := NewUserLoginService(nil,
+		stubAuthenticator{err: ErrInvalidCredentials},
+		stubAuthenticator{err: ErrInvalidCredentials},
+	)
+
+	token, err := loginService.Login(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+
+	assert.Empty(t, token)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthenticate_PublishesEvents(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	events := NewInMemoryEventPublisher()
+	loginService := NewUserLoginServiceWithEvents(mockDBService, events)
+	user := &models.User{ID: mocks.TestUserId, Email: mocks.TestUserEmail, Password: mocks.TestUserPasswordHash, Status: models.UserStatusActive}
+	mockDBService.On("FindUserByEmail", mocks.TestUserEmail).Return(user, nil)
+	mockDBService.On("FindUserDetailsByUserID", user.ID).Return(&models.UserDetail{UserID: user.ID}, nil)
+	mockDBService.On("RecordLogin", user.ID, models.LoginMetadata{}).Return(nil)
+
+	_, _, err := loginService.Authenticate(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+	assert.NoError(t, err)
+	_, _, err = loginService.Authenticate(models.LoginRequest{Email: mocks.TestUserEmail, Password: "wrongpassword"})
+	assert.Error(t, err)
+
+	published := events.Events()
+	if assert.Len(t, published, 2) {
+		assert.Equal(t, models.EventUserLoggedIn, published[0].Type)
+		assert.Equal(t, strconv.FormatUint(uint64(mocks.TestUserId), 10), published[0].Subject)
+		assert.JSONEq(t, `{"email":"`+mocks.TestUserEmail+`","method":"password"}`, string(published[0].Data))
+		assert.Equal(t, models.EventUserLoginFailed, published[1].Type)
+		// a failed login does not reveal which user the email belongs to
+		assert.Empty(t, published[1].Subject)
+		assert.JSONEq(t, `{"email":"`+mocks.TestUserEmail+`","reason":"invalid_credentials"}`, string(published[1].Data))
+	}
+}
+
+func TestLogin_InactiveUser(t *testing.T) {
+	events := NewInMemoryEventPublisher()
+	for _, status := range []string{models.UserStatusPendingVerification, models.UserStatusSuspended, models.UserStatusDisabled, models.UserStatusDeleted} {
+		loginService := NewUserLoginServiceWithEvents(nil, events,
+			stubAuthenticator{user: &models.User{ID: 2, Email: mocks.TestUserEmail, Status: status}},
+			stubAuthenticator{user: &models.User{ID: 2, Email: mocks.TestUserEmail, Status: models.UserStatusActive}},
+		)
+
+		token, err := loginService.Login(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+
+		assert.Empty(t, token)
+		assert.ErrorIs(t, err, ErrAccountInactive, status)
+	}
+	failed := events.EventsOfType(models.EventUserLoginFailed)
+	assert.Len(t, failed, 4)
+	for _, event := range failed {
+		// the status of the account is not revealed
+		assert.JSONEq(t, `{"email":"`+mocks.TestUserEmail+`","reason":"account_inactive"}`, string(event.Data))
+	}
+	assert.Empty(t, events.EventsOfType(models.EventUserLoggedIn))
+}
+
+func TestAuthenticate_BackendErrorIsNotPublished(t *testing.T) {
+	events := NewInMemoryEventPublisher()
+	loginService := NewUserLoginServiceWithEvents(nil, events,
+		stubAuthenticator{err: errors.New("ldap: dial tcp 10.0.0.5:636: connection refused")},
+	)
+
+	_, _, err := loginService.Authenticate(models.LoginRequest{Email: mocks.TestUserEmail, Password: mocks.TestUserPassword})
+
+	assert.Error(t, err)
+	failed := events.EventsOfType(models.EventUserLoginFailed)
+	if assert.Len(t, failed, 1) {
+		assert.JSONEq(t, `{"email":"`+mocks.TestUserEmail+`","reason":"backend_error"}`, string(failed[0].Data))
+	}
+}
@@ -1,49 +1,170 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
 	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 )
 
+// UserRegistrationService creates the user together with an outbox message carrying either a temporary
+// password or a one-time link to set one, the OutboxRelay hands it to the password delivery service afterwards
 type UserRegistrationService struct {
-	passwordDeliveryService PasswordDeliveryService
-	dbService               IDatabaseOperationService
+	dbService  IDatabaseOperationService
+	onboarding models.OnboardingConfig
+	events     IEventPublisher
 }
 
-func NewUserRegistrationService(passwordDeliveryService PasswordDeliveryService, dbService IDatabaseOperationService) *UserRegistrationService {
+func NewUserRegistrationService(dbService IDatabaseOperationService) *UserRegistrationService {
+	return NewUserRegistrationServiceWithOnboarding(dbService, models.OnboardingConfig{Mode: models.OnboardingPassword}, nil)
+}
+
+// NewUserRegistrationServiceWithOnboarding publishes user.registered once the user and their details are stored
+func NewUserRegistrationServiceWithOnboarding(dbService IDatabaseOperationService, onboarding models.OnboardingConfig, events IEventPublisher) *UserRegistrationService {
 	return &UserRegistrationService{
-		passwordDeliveryService: passwordDeliveryService,
-		dbService:               dbService,
+		dbService:  dbService,
+		onboarding: onboarding,
+		events:     events,
 	}
 }
 
+// registration is a user ready to be stored together with the outbox message delivering their credentials
+type registration struct {
+	user       models.User
+	userDetail models.UserDetail
+	// setupToken is only set in the setup link onboarding mode
+	setupToken *models.PasswordSetupToken
+	message    *models.OutboxMessage
+}
+
+// RegisterUser returns ErrEmailTaken when somebody already has the email address
 func (s *UserRegistrationService) RegisterUser(input models.UserRegitrationRequest) error {
+	registration, err := newRegistration(input, s.onboarding)
+	if err != nil {
+		return err
+	}
+
+	if registration.setupToken != nil {
+		err = s.dbService.CreateUserWithPasswordSetup(&registration.user, &registration.userDetail, registration.setupToken, registration.message)
+	} else {
+		err = s.dbService.CreateUserWithOutbox(&registration.user, &registration.userDetail, registration.message)
+	}
+	if errors.Is(err, ErrEmailTaken) {
+		return err
+	}
+	if err != nil {
+		return errors.New("error while registering user")
+	}
+	publishRegistered(s.events, &registration.user, &registration.userDetail, models.EventMethodRegistration)
+	return nil
+}
+
+// newRegistration prepares the user with either a temporary password or, in the setup link onboarding mode, a
+// one-time link to set one
+func newRegistration(input models.UserRegitrationRequest, onboarding models.OnboardingConfig) (*registration, error) {
 	generatedPassword, hashedPassword, err := utils.GetRandomPasswordAndHash()
 	if err != nil {
-		return errors.New("Error while generating temporary password and hash")
+		return nil, errors.New("Error while generating temporary password and hash")
+	}
+
+	registration := &registration{
+		user: models.User{Email: input.Email, Password: hashedPassword, Status: models.UserStatusActive},
+		userDetail: models.UserDetail{
+			FirstName:       input.FirstName,
+			MiddleName:      input.MiddleName,
+			LastName:        input.LastName,
+			PreferredLocale: input.PreferredLocale,
+		},
 	}
-	fmt.Println("Generated Password:", generatedPassword)
 
-	user := models.User{Email: input.Email, Password: hashedPassword}
-	userDetail := models.UserDetail{
+	if onboarding.Mode == models.OnboardingSetupLink {
+		// nobody learns the generated password, the user chooses their own through the link and becomes
+		// active with it
+		registration.user.Status = models.UserStatusPendingVerification
+		if err := registration.withSetupLink(input, onboarding); err != nil {
+			return nil, err
+		}
+		return registration, nil
+	}
+
+	userCredentials := models.UserCredentials{
+		Email:      input.Email,
 		FirstName:  input.FirstName,
 		MiddleName: input.MiddleName,
 		LastName:   input.LastName,
+		Password:   generatedPassword,
+		Locale:     input.PreferredLocale,
 	}
+	if registration.message, err = newOutboxMessage(models.OutboxPasswordDelivery, userCredentials); err != nil {
+		return nil, errors.New("error while registering user")
+	}
+	return registration, nil
+}
 
-	if err := s.dbService.CreateUser(&user, &userDetail); err != nil {
+func publishRegistered(events IEventPublisher, user *models.User, userDetail *models.UserDetail, method string) {
+	publishEvent(events, models.EventUserRegistered, &user.ID, models.UserRegisteredData{
+		Email:      user.Email,
+		FirstName:  userDetail.FirstName,
+		MiddleName: userDetail.MiddleName,
+		LastName:   userDetail.LastName,
+		Method:     method,
+	})
+}
+
+func (r *registration) withSetupLink(input models.UserRegitrationRequest, onboarding models.OnboardingConfig) error {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
 		return errors.New("error while registering user")
 	}
+	setupURL, err := passwordSetupURL(onboarding.SetupURL, token)
+	if err != nil {
+		return errors.New("error while registering user")
+	}
+	expiresAt := time.Now().Add(onboarding.TokenTTL)
 
-	userCredentials := models.UserCredentials{
+	message, err := newOutboxMessage(models.OutboxPasswordSetupLink, models.PasswordSetupLink{
 		Email:      input.Email,
 		FirstName:  input.FirstName,
 		MiddleName: input.MiddleName,
 		LastName:   input.LastName,
-		Password:   generatedPassword,
+		SetupURL:   setupURL,
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		Locale:     input.PreferredLocale,
+	})
+	if err != nil {
+		return errors.New("error while registering user")
+	}
+	r.message = message
+	r.setupToken = &models.PasswordSetupToken{TokenHash: utils.HashToken(token), ExpiresAt: expiresAt}
+	return nil
+}
+
+func newOutboxMessage(messageType string, payload any) (*models.OutboxMessage, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxMessage{
+		EventID:       uuid.NewString(),
+		MessageType:   messageType,
+		Payload:       string(encoded),
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// passwordSetupURL adds the token to the query of the configured setup page
+func passwordSetupURL(base, token string) (string, error) {
+	setupURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
 	}
-	return s.passwordDeliveryService.SendPassword(userCredentials)
+	query := setupURL.Query()
+	query.Set("token", token)
+	setupURL.RawQuery = query.Encode()
+	return setupURL.String(), nil
 }
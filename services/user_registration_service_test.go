@@ -1,13 +1,17 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegisterUser_Success(t *testing.T) {
@@ -23,11 +27,10 @@ func TestRegisterUser_Success(t *testing.T) {
 	}
 
 	// Set up expected calls and return values on the mock database
-	mockDB.On("CreateUser", mock.AnythingOfType("*models.User"), mock.AnythingOfType("*models.UserDetail")).Return(nil)
+	mockDB.On("CreateUserWithOutbox", mock.AnythingOfType("*models.User"), mock.AnythingOfType("*models.UserDetail"), mock.AnythingOfType("*models.OutboxMessage")).Return(nil)
 
 	// Create the service with the mock database
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	service := NewUserRegistrationService(mockPasswordDeliveryService, mockDB)
+	service := NewUserRegistrationService(mockDB)
 
 	// Call the RegisterUser method
 	err := service.RegisterUser(input)
@@ -50,11 +53,10 @@ func TestRegisterUser_FailOnCreateUser(t *testing.T) {
 	}
 
 	// Set up the mock to return an error on CreateUser
-	mockDB.On("CreateUser", mock.AnythingOfType("*models.User"), mock.AnythingOfType("*models.UserDetail")).Return(errors.New("database error"))
+	mockDB.On("CreateUserWithOutbox", mock.AnythingOfType("*models.User"), mock.AnythingOfType("*models.UserDetail"), mock.AnythingOfType("*models.OutboxMessage")).Return(errors.New("database error"))
 
 	// Create the service with the mock database
-	mockPasswordDeliveryService := &mocks.MockPasswordDeliveryService{ShouldFail: false}
-	service := NewUserRegistrationService(mockPasswordDeliveryService, mockDB)
+	service := NewUserRegistrationService(mockDB)
 
 	// Call the RegisterUser method
 	err := service.RegisterUser(input)
@@ -64,3 +66,94 @@ func TestRegisterUser_FailOnCreateUser(t *testing.T) {
 	assert.EqualError(t, err, "error while registering user", "Expected error message for database failure")
 	mockDB.AssertExpectations(t) // Ensure that all expectations were met
 }
+
+func TestRegisterUser_EmailTaken(t *testing.T) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	mockDB.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(ErrEmailTaken)
+
+	err := NewUserRegistrationService(mockDB).RegisterUser(models.UserRegitrationRequest{Email: "taken@example.com", FirstName: "Jane", LastName: "Doe"})
+
+	assert.ErrorIs(t, err, ErrEmailTaken)
+}
+
+func TestRegisterUser_WritesPasswordDeliveryToOutbox(t *testing.T) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	input := models.UserRegitrationRequest{Email: "test@example.com", FirstName: "John", LastName: "Doe", PreferredLocale: "de-AT"}
+
+	var message *models.OutboxMessage
+	var user *models.User
+	var userDetail *models.UserDetail
+	mockDB.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			user = args.Get(0).(*models.User)
+			userDetail = args.Get(1).(*models.UserDetail)
+			message = args.Get(2).(*models.OutboxMessage)
+		}).
+		Return(nil)
+
+	err := NewUserRegistrationService(mockDB).RegisterUser(input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.OutboxPasswordDelivery, message.MessageType)
+	var credentials models.UserCredentials
+	assert.NoError(t, json.Unmarshal([]byte(message.Payload), &credentials))
+	assert.Equal(t, "test@example.com", credentials.Email)
+	assert.Equal(t, "John", credentials.FirstName)
+	assert.True(t, utils.CheckPasswordHash(credentials.Password, user.Password))
+	// the relay renders the message in the user's locale
+	assert.Equal(t, "de-AT", credentials.Locale)
+	assert.Nil(t, credentials.Message)
+	assert.Equal(t, "de-AT", userDetail.PreferredLocale)
+}
+
+func TestRegisterUser_WritesSetupLinkToOutbox(t *testing.T) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	input := models.UserRegitrationRequest{Email: "test@example.com", FirstName: "John", LastName: "Doe"}
+	onboarding := models.OnboardingConfig{
+		Mode:     models.OnboardingSetupLink,
+		SetupURL: "https://app.example.com/set-password?lang=en",
+		TokenTTL: time.Hour,
+	}
+
+	var setupToken *models.PasswordSetupToken
+	var message *models.OutboxMessage
+	mockDB.On("CreateUserWithPasswordSetup", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			setupToken = args.Get(2).(*models.PasswordSetupToken)
+			message = args.Get(3).(*models.OutboxMessage)
+		}).
+		Return(nil)
+
+	err := NewUserRegistrationServiceWithOnboarding(mockDB, onboarding, nil).RegisterUser(input)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxPasswordSetupLink, message.MessageType)
+	assert.NotEmpty(t, message.EventID)
+	assert.NotContains(t, message.Payload, "password\"")
+	var link models.PasswordSetupLink
+	require.NoError(t, json.Unmarshal([]byte(message.Payload), &link))
+	assert.Equal(t, "test@example.com", link.Email)
+	assert.Equal(t, utils.HashToken(link.Token), setupToken.TokenHash)
+	assert.Equal(t, "https://app.example.com/set-password?lang=en&token="+link.Token, link.SetupURL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), setupToken.ExpiresAt, time.Minute)
+	assert.True(t, setupToken.ExpiresAt.Equal(link.ExpiresAt))
+	mockDB.AssertNotCalled(t, "CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRegisterUser_PublishesRegisteredEvent(t *testing.T) {
+	mockDB := new(mocks.MockDatabaseOperationService)
+	events := NewInMemoryEventPublisher()
+	mockDB.On("CreateUserWithOutbox", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { args.Get(0).(*models.User).ID = 12 }).
+		Return(nil)
+	service := NewUserRegistrationServiceWithOnboarding(mockDB, models.OnboardingConfig{Mode: models.OnboardingPassword}, events)
+
+	require.NoError(t, service.RegisterUser(models.UserRegitrationRequest{Email: "test@example.com", FirstName: "John", LastName: "Doe"}))
+
+	registered := events.EventsOfType(models.EventUserRegistered)
+	require.Len(t, registered, 1)
+	assert.Equal(t, "12", registered[0].Subject)
+	assert.JSONEq(t, `{"email":"test@example.com","first_name":"John","middle_name":"","last_name":"Doe","method":"registration"}`, string(registered[0].Data))
+	// no password ends up in the domain event stream
+	assert.NotContains(t, string(registered[0].Data), "password")
+}
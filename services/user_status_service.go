@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const maxStatusReasonLength = 500
+
+var (
+	// ErrAccountInactive is returned instead of a token for users who are not active
+	ErrAccountInactive         = errors.New("Account is not active")
+	ErrInvalidStatusChange     = errors.New("invalid status change")
+	ErrInvalidStatusTransition = errors.New("status transition is not allowed")
+)
+
+var userStatuses = map[string]bool{
+	models.UserStatusActive:              true,
+	models.UserStatusPendingVerification: true,
+	models.UserStatusSuspended:           true,
+	models.UserStatusDisabled:            true,
+	models.UserStatusDeleted:             true,
+}
+
+// userStatusTransitions maps each status to the statuses a user can be moved to it from. Pending users
+// become active by setting their password, and deleted users stay deleted.
+var userStatusTransitions = map[string][]string{
+	models.UserStatusActive:    {models.UserStatusSuspended, models.UserStatusDisabled},
+	models.UserStatusSuspended: {models.UserStatusActive},
+	models.UserStatusDisabled:  {models.UserStatusActive, models.UserStatusPendingVerification, models.UserStatusSuspended},
+	models.UserStatusDeleted:   {models.UserStatusActive, models.UserStatusPendingVerification, models.UserStatusSuspended, models.UserStatusDisabled},
+}
+
+// checkUserActive fails with ErrAccountInactive unless the user may log in
+func checkUserActive(user *models.User) error {
+	if user.Status != models.UserStatusActive {
+		return fmt.Errorf("%w: account is %s", ErrAccountInactive, user.Status)
+	}
+	return nil
+}
+
+type IUserStatusService interface {
+	ChangeStatus(userID uint, change models.UserStatusChange) (*models.UserStatus, error)
+}
+
+// UserStatusService moves users through their lifecycle, access tokens of users who are not active are
+// rejected by the auth middleware from then on
+type UserStatusService struct {
+	dbService IDatabaseOperationService
+	events    IEventPublisher
+}
+
+// NewUserStatusService publishes user.status_changed for every transition an admin makes
+func NewUserStatusService(dbService IDatabaseOperationService, events IEventPublisher) *UserStatusService {
+	return &UserStatusService{dbService: dbService, events: events}
+}
+
+// ChangeStatus moves the user to change.Status. It fails with ErrInvalidStatusTransition when the user's
+// current status cannot be left for it, and with ErrInvalidStatusChange when admins change their own status.
+func (s *UserStatusService) ChangeStatus(userID uint, change models.UserStatusChange) (*models.UserStatus, error) {
+	reason := strings.TrimSpace(change.Reason)
+	if utf8.RuneCountInString(reason) > maxStatusReasonLength {
+		return nil, fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidStatusChange, maxStatusReasonLength)
+	}
+	if change.ActorID != 0 && change.ActorID == userID {
+		return nil, fmt.Errorf("%w: admins cannot change their own status", ErrInvalidStatusChange)
+	}
+	allowedFrom, known := userStatusTransitions[change.Status]
+	if !known {
+		return nil, fmt.Errorf("%w: unsupported status %s", ErrInvalidStatusChange, change.Status)
+	}
+
+	user, err := s.dbService.FindUserByID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	if !slices.Contains(allowedFrom, user.Status) {
+		return nil, fmt.Errorf("%w: user is %s", ErrInvalidStatusTransition, user.Status)
+	}
+	if err := s.dbService.UpdateUserStatus(userID, user.Status, change.Status, reason); err != nil {
+		// the status changed since it was read
+		return nil, notFoundAs(err, ErrInvalidStatusTransition)
+	}
+
+	updated, err := s.dbService.FindUserByID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrProfileNotFound)
+	}
+	publishEvent(s.events, models.EventUserStatusChanged, &userID, models.UserStatusChangedData{
+		Email:          user.Email,
+		Status:         change.Status,
+		PreviousStatus: user.Status,
+		Reason:         reason,
+		Method:         change.Method,
+	})
+	return &models.UserStatus{
+		ID:        updated.ID,
+		Email:     updated.Email,
+		Status:    updated.Status,
+		Reason:    updated.StatusReason,
+		ChangedAt: updated.StatusChangedAt,
+	}, nil
+}
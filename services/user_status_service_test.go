@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func userWithStatus(status string) *models.User {
+	return &models.User{ID: 7, Email: "jane@example.com", Status: status}
+}
+
+func TestUserStatusService_ChangeStatus(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	events := NewInMemoryEventPublisher()
+	service := NewUserStatusService(mockDBService, events)
+	suspended := userWithStatus(models.UserStatusSuspended)
+	suspended.StatusReason = "unpaid invoice"
+	mockDBService.On("FindUserByID", uint(7)).Return(userWithStatus(models.UserStatusActive), nil).Once()
+	mockDBService.On("FindUserByID", uint(7)).Return(suspended, nil).Once()
+	mockDBService.On("UpdateUserStatus", uint(7), models.UserStatusActive, models.UserStatusSuspended, "unpaid invoice").Return(nil)
+
+	changed, err := service.ChangeStatus(7, models.UserStatusChange{
+		Status:  models.UserStatusSuspended,
+		Reason:  " unpaid invoice ",
+		Method:  models.EventMethodAdmin,
+		ActorID: 1,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &models.UserStatus{ID: 7, Email: "jane@example.com", Status: models.UserStatusSuspended, Reason: "unpaid invoice"}, changed)
+	mockDBService.AssertExpectations(t)
+	published := events.EventsOfType(models.EventUserStatusChanged)
+	require.Len(t, published, 1)
+	assert.Equal(t, "7", published[0].Subject)
+	assert.JSONEq(t, `{"email":"jane@example.com","status":"suspended","previous_status":"active","reason":"unpaid invoice","method":"admin"}`, string(published[0].Data))
+}
+
+func TestUserStatusService_ChangeStatus_Transitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{models.UserStatusActive, models.UserStatusSuspended, true},
+		{models.UserStatusSuspended, models.UserStatusActive, true},
+		{models.UserStatusPendingVerification, models.UserStatusDisabled, true},
+		{models.UserStatusDisabled, models.UserStatusDeleted, true},
+		{models.UserStatusPendingVerification, models.UserStatusActive, false},
+		{models.UserStatusDisabled, models.UserStatusSuspended, false},
+		{models.UserStatusActive, models.UserStatusActive, false},
+		{models.UserStatusDeleted, models.UserStatusActive, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			mockDBService := new(mocks.MockDatabaseOperationService)
+			service := NewUserStatusService(mockDBService, nil)
+			mockDBService.On("FindUserByID", uint(7)).Return(userWithStatus(tt.from), nil).Once()
+			mockDBService.On("FindUserByID", uint(7)).Return(userWithStatus(tt.to), nil)
+			mockDBService.On("UpdateUserStatus", uint(7), tt.from, tt.to, "").Return(nil)
+
+			_, err := service.ChangeStatus(7, models.UserStatusChange{Status: tt.to, Method: models.EventMethodAdmin})
+
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+				mockDBService.AssertNotCalled(t, "UpdateUserStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUserStatusService_ChangeStatus_InvalidChanges(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	service := NewUserStatusService(mockDBService, nil)
+
+	for _, change := range []models.UserStatusChange{
+		{Status: models.UserStatusDisabled, ActorID: 7},
+		{Status: models.UserStatusPendingVerification},
+		{Status: "asleep"},
+		{Status: models.UserStatusDisabled, Reason: strings.Repeat("x", 501)},
+	} {
+		_, err := service.ChangeStatus(7, change)
+		assert.ErrorIs(t, err, ErrInvalidStatusChange)
+	}
+	mockDBService.AssertNotCalled(t, "FindUserByID", mock.Anything)
+}
+
+func TestUserStatusService_ChangeStatus_UnknownUser(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	service := NewUserStatusService(mockDBService, nil)
+	mockDBService.On("FindUserByID", uint(7)).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := service.ChangeStatus(7, models.UserStatusChange{Status: models.UserStatusDisabled})
+
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
+
+func TestUserStatusService_ChangeStatus_ConcurrentChange(t *testing.T) {
+	mockDBService := new(mocks.MockDatabaseOperationService)
+	events := NewInMemoryEventPublisher()
+	service := NewUserStatusService(mockDBService, events)
+	mockDBService.On("FindUserByID", uint(7)).Return(userWithStatus(models.UserStatusActive), nil)
+	mockDBService.On("UpdateUserStatus", uint(7), models.UserStatusActive, models.UserStatusDisabled, "").Return(gorm.ErrRecordNotFound)
+
+	_, err := service.ChangeStatus(7, models.UserStatusChange{Status: models.UserStatusDisabled})
+
+	assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+	assert.Empty(t, events.Events())
+
+	mockDBService = new(mocks.MockDatabaseOperationService)
+	service = NewUserStatusService(mockDBService, nil)
+	mockDBService.On("FindUserByID", uint(7)).Return(userWithStatus(models.UserStatusActive), nil)
+	mockDBService.On("UpdateUserStatus", uint(7), models.UserStatusActive, models.UserStatusDisabled, "").Return(errors.New("connection lost"))
+
+	_, err = service.ChangeStatus(7, models.UserStatusChange{Status: models.UserStatusDisabled})
+
+	assert.EqualError(t, err, "connection lost")
+}
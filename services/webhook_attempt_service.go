@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"gorm.io/gorm"
+)
+
+const defaultWebhookAttemptRetention = 7 * 24 * time.Hour
+
+type IWebhookAttemptService interface {
+	RecordAttempt(attempt *models.WebhookDeliveryAttempt) error
+	ListAttempts(afterID uint64, limit int, endpoint, eventID string) ([]models.WebhookDeliveryAttempt, error)
+}
+
+// WebhookAttemptService is the log of every request made to a webhook endpoint
+type WebhookAttemptService struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+// WebhookAttemptRetentionFromEnv reads WEBHOOK_ATTEMPT_RETENTION, a week by default
+func WebhookAttemptRetentionFromEnv() (time.Duration, error) {
+	return durationFromEnv("WEBHOOK_ATTEMPT_RETENTION", defaultWebhookAttemptRetention)
+}
+
+// NewWebhookAttemptService keeps attempts for the retention, a week when it is zero
+func NewWebhookAttemptService(db *gorm.DB, retention time.Duration) *WebhookAttemptService {
+	if retention <= 0 {
+		retention = defaultWebhookAttemptRetention
+	}
+	return &WebhookAttemptService{db: db, retention: retention}
+}
+
+func (s *WebhookAttemptService) RecordAttempt(attempt *models.WebhookDeliveryAttempt) error {
+	return s.db.Create(attempt).Error
+}
+
+// ListAttempts returns up to limit attempts with an ID above afterID, oldest first. An empty endpoint or
+// eventID matches every attempt.
+func (s *WebhookAttemptService) ListAttempts(afterID uint64, limit int, endpoint, eventID string) ([]models.WebhookDeliveryAttempt, error) {
+	query := s.db.Where("id > ?", afterID)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	attempts := []models.WebhookDeliveryAttempt{}
+	err := query.Order("id").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+// PurgeExpired deletes the attempts that are past their retention
+func (s *WebhookAttemptService) PurgeExpired() (int64, error) {
+	result := s.db.Where("created_at < ?", time.Now().Add(-s.retention)).Delete(&models.WebhookDeliveryAttempt{})
+	return result.RowsAffected, result.Error
+}
+
+// StartRetention purges expired attempts every interval until the context is cancelled
+func (s *WebhookAttemptService) StartRetention(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if purged, err := s.PurgeExpired(); err != nil {
+					log.Printf("Failed to purge webhook delivery attempts: %v", err)
+				} else if purged > 0 {
+					log.Printf("Purged %d webhook delivery attempts", purged)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookAttemptService(t *testing.T) {
+	attemptService := NewWebhookAttemptService(DBOperationService.db, time.Hour)
+	defer DBOperationService.db.Where("1 = 1").Delete(&models.WebhookDeliveryAttempt{})
+
+	eventID := uuid.NewString()
+	status := 503
+	for attempt, endpoint := range []string{"partner", "audit", "partner"} {
+		require.NoError(t, attemptService.RecordAttempt(&models.WebhookDeliveryAttempt{
+			Endpoint: endpoint, EventID: eventID, EventType: models.EventUserRegistered, Attempt: attempt + 1, StatusCode: &status,
+		}))
+	}
+	old := models.WebhookDeliveryAttempt{Endpoint: "partner", EventID: uuid.NewString(), EventType: models.EventUserDeleted, Attempt: 1, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	require.NoError(t, attemptService.RecordAttempt(&old))
+
+	attempts, err := attemptService.ListAttempts(0, 10, "partner", eventID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, 503, *attempts[0].StatusCode)
+
+	attempts, err = attemptService.ListAttempts(attempts[0].ID, 1, "", "")
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, "audit", attempts[0].Endpoint)
+
+	purged, err := attemptService.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+)
+
+const (
+	defaultWebhookMaxAttempts = 3
+	defaultWebhookBackoffBase = time.Second
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookWorkers     = 4
+	// webhookResponseLimit is how much of a response body is read before the connection is reused
+	webhookResponseLimit = 64 << 10
+)
+
+// credentialEventTypes carry a password or a setup token. They are only sent to endpoints that list them
+// by name, a wildcard never matches them.
+var credentialEventTypes = map[string]bool{
+	models.EventUserCredentialsIssued:          true,
+	models.EventUserPasswordSetupRequested:     true,
+	models.EventUserEmailConfirmationRequested: true,
+	models.EventUserInvitationRequested:        true,
+}
+
+type WebhookEndpoint struct {
+	Name   string
+	URL    string
+	Secret string
+	// Events are the event types sent to the endpoint: exact types, prefixes such as user.* or * for all.
+	// No events means all of them.
+	Events []string
+	// PublicKey belongs to the receiver, when set the event data is envelope encrypted for it
+	PublicKey *rsa.PublicKey
+}
+
+// Accepts reports whether the event type passes the endpoint's filter
+func (e WebhookEndpoint) Accepts(eventType string) bool {
+	if credentialEventTypes[eventType] {
+		for _, pattern := range e.Events {
+			if pattern == eventType {
+				return true
+			}
+		}
+		return false
+	}
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, pattern := range e.Events {
+		if pattern == "*" || pattern == eventType ||
+			(strings.HasSuffix(pattern, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookConfig struct {
+	Endpoints []WebhookEndpoint
+	// MaxAttempts is how often a request to one endpoint is tried before the delivery to it fails
+	MaxAttempts int
+	// BackoffBase is the delay before the second try, it doubles with every further try
+	BackoffBase time.Duration
+	Timeout     time.Duration
+	// QueueSize is how many domain events wait for a worker before further ones are dropped
+	QueueSize int
+	// Workers is how many domain events are delivered at the same time
+	Workers int
+}
+
+func webhookEndpointFromEnv(name string) (WebhookEndpoint, error) {
+	prefix := "WEBHOOK_" + strings.ToUpper(name) + "_"
+	endpoint := WebhookEndpoint{Name: name, URL: os.Getenv(prefix + "URL"), Secret: os.Getenv(prefix + "SECRET")}
+	endpointURL, err := url.Parse(endpoint.URL)
+	if err != nil || (endpointURL.Scheme != "https" && endpointURL.Scheme != "http") || endpointURL.Host == "" {
+		return endpoint, fmt.Errorf("%sURL must be an absolute http or https URL", prefix)
+	}
+	if endpoint.Secret == "" {
+		return endpoint, fmt.Errorf("%sSECRET is required", prefix)
+	}
+	for _, pattern := range strings.Split(os.Getenv(prefix+"EVENTS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			endpoint.Events = append(endpoint.Events, pattern)
+		}
+	}
+	if endpointURL.Scheme == "http" {
+		// passwords and tokens are never sent in the clear
+		for _, pattern := range endpoint.Events {
+			if credentialEventTypes[pattern] {
+				return endpoint, fmt.Errorf("%sURL must use https to receive %s events", prefix, pattern)
+			}
+		}
+		log.Printf("Webhook endpoint %s does not use https, events are sent to it unencrypted", name)
+	}
+	if keyFile := os.Getenv(prefix + "PUBLIC_KEY"); keyFile != "" {
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return endpoint, fmt.Errorf("failed to read %sPUBLIC_KEY: %v", prefix, err)
+		}
+		if endpoint.PublicKey, err = utils.ParseRSAPublicKey(pemBytes); err != nil {
+			return endpoint, fmt.Errorf("invalid %sPUBLIC_KEY: %v", prefix, err)
+		}
+	}
+	return endpoint, nil
+}
+
+// WebhookConfigFromEnv reads the endpoints listed in WEBHOOK_ENDPOINTS. Each endpoint NAME is configured
+// through WEBHOOK_<NAME>_URL, WEBHOOK_<NAME>_SECRET, WEBHOOK_<NAME>_EVENTS and WEBHOOK_<NAME>_PUBLIC_KEY.
+// Retries are set by WEBHOOK_MAX_ATTEMPTS and WEBHOOK_BACKOFF_BASE, and each request times out after
+// WEBHOOK_TIMEOUT. Domain events are queued up to WEBHOOK_QUEUE_SIZE and sent by WEBHOOK_WORKERS workers.
+func WebhookConfigFromEnv() (WebhookConfig, error) {
+	var config WebhookConfig
+	for _, name := range strings.Split(os.Getenv("WEBHOOK_ENDPOINTS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		endpoint, err := webhookEndpointFromEnv(name)
+		if err != nil {
+			return config, err
+		}
+		config.Endpoints = append(config.Endpoints, endpoint)
+	}
+	var err error
+	if config.MaxAttempts, err = positiveIntFromEnv("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts); err != nil {
+		return config, err
+	}
+	if config.BackoffBase, err = durationFromEnv("WEBHOOK_BACKOFF_BASE", defaultWebhookBackoffBase); err != nil {
+		return config, err
+	}
+	if config.Timeout, err = durationFromEnv("WEBHOOK_TIMEOUT", defaultWebhookTimeout); err != nil {
+		return config, err
+	}
+	if config.QueueSize, err = positiveIntFromEnv("WEBHOOK_QUEUE_SIZE", defaultWebhookQueueSize); err != nil {
+		return config, err
+	}
+	if config.Workers, err = positiveIntFromEnv("WEBHOOK_WORKERS", defaultWebhookWorkers); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// webhookStatusError is a response outside 2xx
+type webhookStatusError struct {
+	StatusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.StatusCode)
+}
+
+// retryable is false for client errors, which a retry would not fix, except timeouts and rate limits
+func (e *webhookStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// WebhookService posts events in the CloudEvents structured JSON format to HTTP endpoints. Every request
+// is signed with the endpoint's secret in the Webhook-Signature header and carries the event ID in
+// Webhook-Id, which stays the same across retries.
+type WebhookService struct {
+	config   WebhookConfig
+	client   *http.Client
+	attempts IWebhookAttemptService
+	sleep    func(time.Duration)
+
+	// queue holds the domain events until a worker delivers them, it is closed by Close
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan models.Event
+	workers sync.WaitGroup
+}
+
+// NewWebhookService reads the configuration from the environment, see WebhookConfigFromEnv
+func NewWebhookService(attempts IWebhookAttemptService) (*WebhookService, error) {
+	config, err := WebhookConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhookServiceWithConfig(config, attempts)
+}
+
+// NewWebhookServiceWithConfig records every request in attempts, which may be nil. It starts the workers
+// that deliver domain events, Close stops them.
+func NewWebhookServiceWithConfig(config WebhookConfig, attempts IWebhookAttemptService) (*WebhookService, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, errors.New("no webhook endpoints found in environment variable")
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaultWebhookBackoffBase
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultWebhookTimeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultWebhookQueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultWebhookWorkers
+	}
+	service := &WebhookService{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		attempts: attempts,
+		sleep:    time.Sleep,
+		queue:    make(chan models.Event, config.QueueSize),
+	}
+	for i := 0; i < config.Workers; i++ {
+		service.workers.Add(1)
+		go service.work()
+	}
+	return service, nil
+}
+
+func (s *WebhookService) work() {
+	defer s.workers.Done()
+	for event := range s.queue {
+		if _, err := s.deliver(event); err != nil {
+			log.Printf("Failed to deliver %s event %s to webhooks: %v", event.Type, event.ID, err)
+		}
+	}
+}
+
+func (s *WebhookService) recordAttempt(endpoint WebhookEndpoint, event models.Event, attempt int, statusCode int, err error, duration time.Duration) {
+	if s.attempts == nil {
+		return
+	}
+	record := &models.WebhookDeliveryAttempt{
+		Endpoint:   endpoint.Name,
+		EventID:    event.ID,
+		EventType:  event.Type,
+		Attempt:    attempt,
+		DurationMs: duration.Milliseconds(),
+	}
+	if statusCode != 0 {
+		record.StatusCode = &statusCode
+	}
+	if err != nil {
+		message := err.Error()
+		record.Error = &message
+	}
+	if recordErr := s.attempts.RecordAttempt(record); recordErr != nil {
+		log.Printf("Failed to record webhook attempt for event %s: %v", event.ID, recordErr)
+	}
+}
+
+func (s *WebhookService) post(endpoint WebhookEndpoint, event models.Event, body []byte, attempt int) error {
+	request, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", models.EventContentType)
+	request.Header.Set("Webhook-Id", event.ID)
+	request.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhook(endpoint.Secret, time.Now(), body))
+
+	started := time.Now()
+	response, err := s.client.Do(request)
+	if err != nil {
+		s.recordAttempt(endpoint, event, attempt, 0, err, time.Since(started))
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, webhookResponseLimit))
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err = &webhookStatusError{StatusCode: response.StatusCode}
+	}
+	s.recordAttempt(endpoint, event, attempt, response.StatusCode, err, time.Since(started))
+	return err
+}
+
+func (s *WebhookService) deliverTo(endpoint WebhookEndpoint, event models.Event) error {
+	if endpoint.PublicKey != nil {
+		sealed, err := utils.EncryptEnvelope(endpoint.PublicKey, event.Data)
+		if err != nil {
+			return err
+		}
+		event.Data = sealed
+		event.DataContentType = utils.EnvelopeContentType
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	delay := s.config.BackoffBase
+	for attempt := 1; ; attempt++ {
+		err := s.post(endpoint, event, body, attempt)
+		var statusErr *webhookStatusError
+		if err == nil || attempt >= s.config.MaxAttempts || (errors.As(err, &statusErr) && !statusErr.retryable()) {
+			return err
+		}
+		s.sleep(delay)
+		delay *= 2
+	}
+}
+
+// deliver sends the event to every endpoint that accepts it and returns how many did
+func (s *WebhookService) deliver(event models.Event) (int, error) {
+	accepted := 0
+	var errs []error
+	for _, endpoint := range s.config.Endpoints {
+		if !endpoint.Accepts(event.Type) {
+			continue
+		}
+		accepted++
+		if err := s.deliverTo(endpoint, event); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", endpoint.Name, err))
+		}
+	}
+	return accepted, errors.Join(errs...)
+}
+
+// PublishEvent delivers a password delivery event and waits for the result. It fails when no endpoint
+// accepts the event type, so that the outbox keeps the message instead of dropping it. When only some
+// endpoints fail, the relay's retry sends the event to all of them again.
+func (s *WebhookService) PublishEvent(event models.Event) error {
+	accepted, err := s.deliver(event)
+	if accepted == 0 {
+		return fmt.Errorf("no webhook endpoint accepts %s events", event.Type)
+	}
+	if err != nil {
+		log.Printf("Failed to deliver event %s to webhooks: %v", event.ID, err)
+		return err
+	}
+	log.Printf("Event %s of type %s delivered to webhooks", event.ID, event.Type)
+	return nil
+}
+
+func (s *WebhookService) SendPassword(credentials models.UserCredentials) error {
+	event, err := NewEvent(models.EventUserCredentialsIssued, nil, credentials)
+	if err != nil {
+		return err
+	}
+	return s.PublishEvent(event)
+}
+
+func (s *WebhookService) SendSetupLink(link models.PasswordSetupLink) error {
+	event, err := NewEvent(models.EventUserPasswordSetupRequested, nil, link)
+	if err != nil {
+		return err
+	}
+	return s.PublishEvent(event)
+}
+
+// Publish queues a domain event for delivery in the background, so that slow endpoints and their retries
+// do not hold up the request that caused the event. The event is dropped when the queue is full or the
+// service was closed.
+func (s *WebhookService) Publish(event models.Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("the webhook service is closed")
+	}
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("the webhook queue is full, %s event %s is dropped", event.Type, event.ID)
+	}
+}
+
+// Close stops accepting domain events and waits until the queued ones were delivered, or until the context
+// is done. Password deliveries are not affected.
+func (s *WebhookService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook events were still being delivered: %w", ctx.Err())
+	}
+}
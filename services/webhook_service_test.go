@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shibbirmcc/user-auth-and-permissions/mocks"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/shibbirmcc/user-auth-and-permissions/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver answers with the given statuses in turn and keeps every request it received
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestWebhookService(t *testing.T, attempts IWebhookAttemptService, endpoints ...WebhookEndpoint) (*WebhookService, *[]time.Duration) {
+	service, err := NewWebhookServiceWithConfig(WebhookConfig{Endpoints: endpoints, MaxAttempts: 3, BackoffBase: time.Second}, attempts)
+	require.NoError(t, err)
+	var delays []time.Duration
+	service.sleep = func(delay time.Duration) { delays = append(delays, delay) }
+	return service, &delays
+}
+
+func TestWebhookEndpoint_Accepts(t *testing.T) {
+	all := WebhookEndpoint{}
+	assert.True(t, all.Accepts(models.EventUserRegistered))
+	assert.False(t, all.Accepts(models.EventUserCredentialsIssued))
+
+	filtered := WebhookEndpoint{Events: []string{"role.*", models.EventUserDeleted, models.EventUserPasswordSetupRequested}}
+	assert.True(t, filtered.Accepts(models.EventRoleAssigned))
+	assert.True(t, filtered.Accepts(models.EventUserDeleted))
+	assert.True(t, filtered.Accepts(models.EventUserPasswordSetupRequested))
+	assert.False(t, filtered.Accepts(models.EventUserRegistered))
+
+	// wildcards never match events that carry credentials
+	wildcard := WebhookEndpoint{Events: []string{"*", "user.*"}}
+	assert.True(t, wildcard.Accepts(models.EventUserLoggedIn))
+	assert.False(t, wildcard.Accepts(models.EventUserCredentialsIssued))
+	assert.False(t, wildcard.Accepts(models.EventUserPasswordSetupRequested))
+	assert.False(t, wildcard.Accepts(models.EventUserEmailConfirmationRequested))
+	assert.False(t, wildcard.Accepts(models.EventUserInvitationRequested))
+	assert.True(t, wildcard.Accepts(models.EventUserEmailChangeNoticeRequested))
+}
+
+func TestWebhookService_PublishEvent_Signed(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	attempts := new(mocks.MockWebhookAttemptService)
+	attempts.On("RecordAttempt", mock.MatchedBy(func(attempt *models.WebhookDeliveryAttempt) bool {
+		return attempt.Endpoint == "partner" && attempt.Attempt == 1 && *attempt.StatusCode == http.StatusNoContent && attempt.Error == nil
+	})).Return(nil)
+	service, _ := newTestWebhookService(t, attempts, WebhookEndpoint{Name: "partner", URL: server.URL, Secret: "secret", Events: []string{models.EventUserCredentialsIssued}})
+
+	userID := uint(42)
+	event, err := NewEvent(models.EventUserCredentialsIssued, &userID, models.UserCredentials{Email: "test@example.com", Password: "securePassword123"})
+	require.NoError(t, err)
+	require.NoError(t, service.PublishEvent(event))
+
+	require.Equal(t, 1, receiver.count())
+	request := receiver.requests[0]
+	assert.Equal(t, models.EventContentType, request.Header.Get("Content-Type"))
+	assert.Equal(t, event.ID, request.Header.Get("Webhook-Id"))
+	assert.NoError(t, utils.VerifyWebhookSignature("secret", request.Header.Get(utils.WebhookSignatureHeader), receiver.bodies[0], time.Minute, time.Now()))
+	var delivered models.Event
+	require.NoError(t, json.Unmarshal(receiver.bodies[0], &delivered))
+	assert.Equal(t, event.ID, delivered.ID)
+	assert.Equal(t, "42", delivered.Subject)
+	attempts.AssertExpectations(t)
+}
+
+func TestWebhookService_PublishEvent_RetriesWithBackoff(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	attempts := new(mocks.MockWebhookAttemptService)
+	attempts.On("RecordAttempt", mock.Anything).Return(nil)
+	service, delays := newTestWebhookService(t, attempts, WebhookEndpoint{Name: "partner", URL: server.URL, Secret: "secret", Events: []string{models.EventUserCredentialsIssued}})
+
+	require.NoError(t, service.SendPassword(models.UserCredentials{Email: "test@example.com"}))
+
+	assert.Equal(t, 3, receiver.count())
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *delays)
+	attempts.AssertNumberOfCalls(t, "RecordAttempt", 3)
+	// the event ID stays the same across retries, the signature is renewed
+	assert.Equal(t, receiver.requests[0].Header.Get("Webhook-Id"), receiver.requests[2].Header.Get("Webhook-Id"))
+}
+
+func TestWebhookService_PublishEvent_GivesUp(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, _ := newTestWebhookService(t, nil, WebhookEndpoint{Name: "partner", URL: server.URL, Secret: "secret", Events: []string{models.EventUserPasswordSetupRequested}})
+
+	err := service.SendSetupLink(models.PasswordSetupLink{Email: "test@example.com", Token: "setup-token"})
+
+	assert.ErrorContains(t, err, "webhook partner: webhook responded with status 500")
+	assert.Equal(t, 3, receiver.count())
+}
+
+func TestWebhookService_PublishEvent_DoesNotRetryClientErrors(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, delays := newTestWebhookService(t, nil, WebhookEndpoint{Name: "partner", URL: server.URL, Secret: "secret", Events: []string{models.EventUserCredentialsIssued}})
+
+	err := service.SendPassword(models.UserCredentials{Email: "test@example.com"})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, receiver.count())
+	assert.Empty(t, *delays)
+}
+
+func TestWebhookService_PublishEvent_NoEndpointAccepts(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, _ := newTestWebhookService(t, nil, WebhookEndpoint{Name: "audit", URL: server.URL, Secret: "secret"})
+
+	err := service.SendPassword(models.UserCredentials{Email: "test@example.com"})
+
+	assert.EqualError(t, err, "no webhook endpoint accepts user.credentials_issued events")
+	assert.Zero(t, receiver.count())
+}
+
+func TestWebhookService_PublishEvent_Encrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, _ := newTestWebhookService(t, nil, WebhookEndpoint{
+		Name: "partner", URL: server.URL, Secret: "secret",
+		Events: []string{models.EventUserCredentialsIssued}, PublicKey: &privateKey.PublicKey,
+	})
+
+	credentials := models.UserCredentials{Email: "test@example.com", Password: "securePassword123"}
+	require.NoError(t, service.SendPassword(credentials))
+
+	require.Equal(t, 1, receiver.count())
+	assert.NotContains(t, string(receiver.bodies[0]), "securePassword123")
+	var event models.Event
+	require.NoError(t, json.Unmarshal(receiver.bodies[0], &event))
+	assert.Equal(t, utils.EnvelopeContentType, event.DataContentType)
+	opened, err := utils.DecryptEnvelope(privateKey, event.Data)
+	require.NoError(t, err)
+	var delivered models.UserCredentials
+	require.NoError(t, json.Unmarshal(opened, &delivered))
+	assert.Equal(t, credentials, delivered)
+}
+
+func TestWebhookService_Publish(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, _ := newTestWebhookService(t, nil,
+		WebhookEndpoint{Name: "roles", URL: server.URL, Secret: "secret", Events: []string{"role.*"}},
+		WebhookEndpoint{Name: "all", URL: server.URL, Secret: "secret"},
+	)
+
+	userID := uint(7)
+	event, err := NewEvent(models.EventUserLoggedIn, &userID, models.UserLoggedInData{Email: "test@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, service.Publish(event))
+
+	// domain events are delivered in the background, only the endpoint without a filter wants this one
+	assert.Eventually(t, func() bool { return receiver.count() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookService_CloseDeliversQueuedEvents(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	service, _ := newTestWebhookService(t, nil, WebhookEndpoint{Name: "all", URL: server.URL, Secret: "secret"})
+
+	for i := 0; i < 5; i++ {
+		event, err := NewEvent(models.EventUserLoggedIn, nil, models.UserLoggedInData{Email: "test@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, service.Publish(event))
+	}
+	require.NoError(t, service.Close(context.Background()))
+
+	assert.Equal(t, 5, receiver.count())
+	event, err := NewEvent(models.EventUserLoggedIn, nil, models.UserLoggedInData{Email: "test@example.com"})
+	require.NoError(t, err)
+	assert.Error(t, service.Publish(event))
+}
+
+func TestWebhookService_PublishDropsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer close(release)
+	service, err := NewWebhookServiceWithConfig(WebhookConfig{
+		Endpoints: []WebhookEndpoint{{Name: "slow", URL: server.URL, Secret: "secret"}},
+		QueueSize: 1,
+		Workers:   1,
+	}, nil)
+	require.NoError(t, err)
+
+	published := 0
+	for i := 0; i < 5; i++ {
+		event, err := NewEvent(models.EventUserLoggedIn, nil, models.UserLoggedInData{Email: "test@example.com"})
+		require.NoError(t, err)
+		if service.Publish(event) == nil {
+			published++
+		}
+	}
+	// one event is with the worker and one waits in the queue at most
+	assert.LessOrEqual(t, published, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestWebhookConfigFromEnv(t *testing.T) {
+	t.Setenv("WEBHOOK_ENDPOINTS", "Partner, audit")
+	t.Setenv("WEBHOOK_PARTNER_URL", "https://partner.example/hooks")
+	t.Setenv("WEBHOOK_PARTNER_SECRET", "partner-secret")
+	t.Setenv("WEBHOOK_PARTNER_EVENTS", "user.credentials_issued, role.*")
+	t.Setenv("WEBHOOK_AUDIT_URL", "https://audit.example/events")
+	t.Setenv("WEBHOOK_AUDIT_SECRET", "audit-secret")
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "5")
+
+	config, err := WebhookConfigFromEnv()
+	require.NoError(t, err)
+	require.Len(t, config.Endpoints, 2)
+	assert.Equal(t, "partner", config.Endpoints[0].Name)
+	assert.Equal(t, []string{"user.credentials_issued", "role.*"}, config.Endpoints[0].Events)
+	assert.Empty(t, config.Endpoints[1].Events)
+	assert.Equal(t, 5, config.MaxAttempts)
+	assert.Equal(t, defaultWebhookBackoffBase, config.BackoffBase)
+	assert.Equal(t, defaultWebhookTimeout, config.Timeout)
+}
+
+func TestWebhookConfigFromEnv_Invalid(t *testing.T) {
+	t.Setenv("WEBHOOK_ENDPOINTS", "partner")
+	t.Setenv("WEBHOOK_PARTNER_URL", "partner.example/hooks")
+	t.Setenv("WEBHOOK_PARTNER_SECRET", "secret")
+	_, err := WebhookConfigFromEnv()
+	assert.EqualError(t, err, "WEBHOOK_PARTNER_URL must be an absolute http or https URL")
+
+	t.Setenv("WEBHOOK_PARTNER_URL", "https://partner.example/hooks")
+	t.Setenv("WEBHOOK_PARTNER_SECRET", "")
+	_, err = WebhookConfigFromEnv()
+	assert.EqualError(t, err, "WEBHOOK_PARTNER_SECRET is required")
+
+	t.Setenv("WEBHOOK_PARTNER_URL", "http://partner.example/hooks")
+	t.Setenv("WEBHOOK_PARTNER_SECRET", "secret")
+	t.Setenv("WEBHOOK_PARTNER_EVENTS", "role.*,user.credentials_issued")
+	_, err = WebhookConfigFromEnv()
+	assert.EqualError(t, err, "WEBHOOK_PARTNER_URL must use https to receive user.credentials_issued events")
+
+	// plain http is still allowed for endpoints that receive no credentials
+	t.Setenv("WEBHOOK_PARTNER_EVENTS", "role.*")
+	_, err = WebhookConfigFromEnv()
+	assert.NoError(t, err)
+}
+
+func TestNewWebhookService_NoEndpoints(t *testing.T) {
+	t.Setenv("WEBHOOK_ENDPOINTS", "")
+
+	service, err := NewWebhookService(nil)
+
+	assert.Nil(t, service)
+	assert.EqualError(t, err, "no webhook endpoints found in environment variable")
+}
@@ -0,0 +1,10 @@
+package templates
+
+import "embed"
+
+// Email holds the built-in email templates. Every message type has email/<type>.subject.tmpl and
+// email/<type>.txt.tmpl, and optionally email/<type>.html.tmpl for an HTML alternative. Translations use the
+// same names in email/<locale>/.
+//
+//go:embed email
+var Email embed.FS
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProviderStub is an in-process upstream OpenID Connect provider for federated login tests.
+// It serves discovery, a key set and a token endpoint that redeems codes created with IssueCode.
+type OIDCProviderStub struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	// Claims are added to every ID token issued by the token endpoint
+	Claims jwt.MapClaims
+
+	key   *rsa.PrivateKey
+	keyID string
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func NewOIDCProviderStub(clientID, clientSecret string) *OIDCProviderStub {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	stub := &OIDCProviderStub{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Claims:       jwt.MapClaims{},
+		key:          key,
+		keyID:        "stub-key",
+		codes:        map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", stub.discovery)
+	mux.HandleFunc("/jwks", stub.jwks)
+	mux.HandleFunc("/token", stub.token)
+	stub.Server = httptest.NewServer(mux)
+	return stub
+}
+
+func (s *OIDCProviderStub) Issuer() string {
+	return s.Server.URL
+}
+
+func (s *OIDCProviderStub) Close() {
+	s.Server.Close()
+}
+
+// IssueCode registers an authorization code that redeems to an ID token carrying the given nonce
+func (s *OIDCProviderStub) IssueCode(nonce string) string {
+	codeBytes := make([]byte, 16)
+	_, _ = rand.Read(codeBytes)
+	code := hex.EncodeToString(codeBytes)
+	s.mu.Lock()
+	s.codes[code] = nonce
+	s.mu.Unlock()
+	return code
+}
+
+// SignIDToken signs the claims with the stub's published key
+func (s *OIDCProviderStub) SignIDToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.key)
+}
+
+func (s *OIDCProviderStub) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.Issuer() + "/authorize",
+		"token_endpoint":         s.Issuer() + "/token",
+		"jwks_uri":               s.Issuer() + "/jwks",
+	})
+}
+
+func (s *OIDCProviderStub) jwks(w http.ResponseWriter, r *http.Request) {
+	publicKey := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": s.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *OIDCProviderStub) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	nonce, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !found {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   s.Issuer(),
+		"aud":   s.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": nonce,
+	}
+	for name, value := range s.Claims {
+		claims[name] = value
+	}
+	idToken, err := s.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
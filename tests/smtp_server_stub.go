@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPMessage is a mail accepted by the SMTPServerStub
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data string
+	// TLS and Username record how the session that sent the message was secured
+	TLS      bool
+	Username string
+}
+
+// SMTPServerStub is an in-process SMTP server for delivery tests. It offers STARTTLS with a self
+// signed certificate for 127.0.0.1 when created with TLS enabled, and requires AUTH PLAIN once
+// RequireAuth was called.
+type SMTPServerStub struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	certPool  *x509.CertPool
+
+	mu       sync.Mutex
+	username string
+	password string
+	messages []SMTPMessage
+}
+
+func NewSMTPServerStub(enableTLS bool) *SMTPServerStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	stub := &SMTPServerStub{listener: listener}
+	if enableTLS {
+		stub.tlsConfig, stub.certPool = selfSignedTLSConfig()
+	}
+	go stub.serve()
+	return stub
+}
+
+func selfSignedTLSConfig() (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtp stub"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func (s *SMTPServerStub) Host() string {
+	return "127.0.0.1"
+}
+
+func (s *SMTPServerStub) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// CertPool trusts the certificate presented after STARTTLS
+func (s *SMTPServerStub) CertPool() *x509.CertPool {
+	return s.certPool
+}
+
+func (s *SMTPServerStub) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+func (s *SMTPServerStub) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMTPMessage(nil), s.messages...)
+}
+
+func (s *SMTPServerStub) Close() {
+	s.listener.Close()
+}
+
+func (s *SMTPServerStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+type smtpSession struct {
+	text      *textproto.Conn
+	tls       bool
+	username  string
+	message   SMTPMessage
+	needsAuth bool
+}
+
+func (s *SMTPServerStub) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	s.mu.Lock()
+	session := &smtpSession{text: textproto.NewConn(conn), needsAuth: s.username != ""}
+	s.mu.Unlock()
+	session.text.PrintfLine("220 localhost ESMTP stub")
+
+	for {
+		line, err := session.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			s.extensions(session)
+		case "STARTTLS":
+			if s.tlsConfig == nil || session.tls {
+				session.text.PrintfLine("502 STARTTLS not available")
+				continue
+			}
+			session.text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			session.text = textproto.NewConn(tlsConn)
+			session.tls = true
+		case "AUTH":
+			s.authenticate(session, argument)
+		case "MAIL":
+			if session.needsAuth && session.username == "" {
+				session.text.PrintfLine("530 Authentication required")
+				continue
+			}
+			session.message = SMTPMessage{From: smtpPath(argument), TLS: session.tls, Username: session.username}
+			session.text.PrintfLine("250 OK")
+		case "RCPT":
+			session.message.To = append(session.message.To, smtpPath(argument))
+			session.text.PrintfLine("250 OK")
+		case "DATA":
+			session.text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := session.text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			session.message.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, session.message)
+			s.mu.Unlock()
+			session.text.PrintfLine("250 OK queued")
+		case "RSET", "NOOP":
+			session.text.PrintfLine("250 OK")
+		case "QUIT":
+			session.text.PrintfLine("221 Bye")
+			return
+		default:
+			session.text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *SMTPServerStub) extensions(session *smtpSession) {
+	lines := []string{"localhost", "8BITMIME"}
+	if s.tlsConfig != nil && !session.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if session.needsAuth {
+		lines = append(lines, "AUTH PLAIN")
+	}
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		session.text.PrintfLine("250%s%s", separator, line)
+	}
+}
+
+func (s *SMTPServerStub) authenticate(session *smtpSession, argument string) {
+	mechanism, response, _ := strings.Cut(argument, " ")
+	if !strings.EqualFold(mechanism, "PLAIN") {
+		session.text.PrintfLine("504 Unrecognized authentication type")
+		return
+	}
+	if response == "" {
+		session.text.PrintfLine("334 ")
+		line, err := session.text.ReadLine()
+		if err != nil {
+			return
+		}
+		response = line
+	}
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	parts := strings.Split(string(decoded), "\x00")
+	s.mu.Lock()
+	valid := err == nil && len(parts) == 3 && parts[1] == s.username && parts[2] == s.password
+	s.mu.Unlock()
+	if !valid {
+		session.text.PrintfLine("535 Authentication credentials invalid")
+		return
+	}
+	session.username = parts[1]
+	session.text.PrintfLine("235 Authentication successful")
+}
+
+// smtpPath extracts the address from FROM:<address> and TO:<address> arguments
+func smtpPath(argument string) string {
+	start, end := strings.Index(argument, "<"), strings.Index(argument, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return argument[start+1 : end]
+}
+
+// Addr is host:port of the stub
+func (s *SMTPServerStub) Addr() string {
+	return net.JoinHostPort(s.Host(), strconv.Itoa(s.Port()))
+}
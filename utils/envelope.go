@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// EnvelopeAlgorithm names the scheme used by EncryptEnvelope: the payload is sealed with a fresh
+// AES-256-GCM key, which is wrapped with RSA-OAEP (SHA-256) for the consumer's public key
+const EnvelopeAlgorithm = "RSA-OAEP-256+A256GCM"
+
+// EnvelopeContentType is the content type of data that was replaced by its Envelope
+const EnvelopeContentType = "application/vnd.user-auth.envelope+json"
+
+// Envelope is an encrypted payload only the holder of the private key for KeyID can open
+type Envelope struct {
+	Algorithm    string `json:"alg"`
+	KeyID        string `json:"kid"`
+	EncryptedKey []byte `json:"encrypted_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// ParseRSAPublicKey reads a PEM encoded PKIX or PKCS #1 RSA public key
+func ParseRSAPublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// PublicKeyID is the base64url SHA-256 digest of the DER encoded key, consumers use it to pick their private key
+func PublicKeyID(publicKey *rsa.PublicKey) string {
+	digest := sha256.Sum256(x509.MarshalPKCS1PublicKey(publicKey))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func EncryptEnvelope(publicKey *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := Envelope{
+		Algorithm:    EnvelopeAlgorithm,
+		KeyID:        PublicKeyID(publicKey),
+		EncryptedKey: encryptedKey,
+		Nonce:        nonce,
+	}
+	// the header fields are authenticated along with the payload
+	envelope.Ciphertext = aead.Seal(nil, nonce, plaintext, envelopeAdditionalData(envelope))
+	return json.Marshal(envelope)
+}
+
+// DecryptEnvelope opens an envelope created by EncryptEnvelope, it is what a consumer runs
+func DecryptEnvelope(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Algorithm != EnvelopeAlgorithm {
+		return nil, fmt.Errorf("unsupported envelope algorithm: %s", envelope.Algorithm)
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, envelope.EncryptedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid envelope nonce")
+	}
+	return aead.Open(nil, envelope.Nonce, envelope.Ciphertext, envelopeAdditionalData(envelope))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func envelopeAdditionalData(envelope Envelope) []byte {
+	return []byte(envelope.Algorithm + "." + envelope.KeyID)
+}
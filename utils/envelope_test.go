@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptEnvelope_RoundTrip(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	plaintext := []byte(`{"email":"test@example.com","password":"secret"}`)
+
+	sealed, err := EncryptEnvelope(&privateKey.PublicKey, plaintext)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "secret")
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(sealed, &envelope))
+	assert.Equal(t, EnvelopeAlgorithm, envelope.Algorithm)
+	assert.Equal(t, PublicKeyID(&privateKey.PublicKey), envelope.KeyID)
+
+	opened, err := DecryptEnvelope(privateKey, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestDecryptEnvelope_RejectsTampering(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sealed, err := EncryptEnvelope(&privateKey.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+
+	_, err = DecryptEnvelope(otherKey, sealed)
+	assert.Error(t, err)
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(sealed, &envelope))
+	envelope.KeyID = "someone-else"
+	tampered, _ := json.Marshal(envelope)
+	_, err = DecryptEnvelope(privateKey, tampered)
+	assert.Error(t, err)
+}
+
+func TestParseRSAPublicKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	for _, block := range []*pem.Block{
+		{Type: "PUBLIC KEY", Bytes: pkix},
+		{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)},
+	} {
+		key, err := ParseRSAPublicKey(pem.EncodeToMemory(block))
+		require.NoError(t, err)
+		assert.True(t, privateKey.PublicKey.Equal(key))
+	}
+
+	_, err = ParseRSAPublicKey([]byte("not a key"))
+	assert.Error(t, err)
+}
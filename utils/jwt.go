@@ -9,7 +9,17 @@ import (
 	"github.com/shibbirmcc/user-auth-and-permissions/models"
 )
 
+const (
+	AccessTokenLifetime    = 24 * time.Hour
+	FederatedStateLifetime = 10 * time.Minute
+)
+
 func GenerateJWT(email string, userDetails models.UserDetail) (string, error) {
+	return GenerateClientJWT(email, userDetails, "")
+}
+
+// GenerateClientJWT issues an access token to the OAuth client, only that client can revoke it
+func GenerateClientJWT(email string, userDetails models.UserDetail, clientID string) (string, error) {
 	if email == "" {
 		return "", errors.New("email cannot be empty")
 	}
@@ -17,17 +27,74 @@ func GenerateJWT(email string, userDetails models.UserDetail) (string, error) {
 	if jwtSecret == "" {
 		return "", errors.New("JWT_SECRET is missing")
 	}
-	expirationTime := time.Now().Add(24 * time.Hour)
+	tokenID, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := &models.Claims{
 		Email:      email,
 		UserID:     userDetails.UserID,
 		FirstName:  userDetails.FirstName,
 		MiddleName: userDetails.MiddleName,
 		LastName:   userDetails.LastName,
+		ClientID:   clientID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        tokenID,
+			Audience:  jwt.ClaimStrings{models.AccessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenLifetime)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(jwtSecret))
 }
+
+// ParseJWT validates an access token issued by GenerateJWT and returns its claims
+func ParseJWT(tokenString string) (*models.Claims, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("JWT_SECRET is missing")
+	}
+	claims := &models.Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(models.AccessTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GenerateFederatedState signs the provider and nonce into the state parameter sent to an upstream
+// identity provider, so the callback can be verified without server side session storage.
+func GenerateFederatedState(provider, nonce string) (string, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return "", errors.New("JWT_SECRET is missing")
+	}
+	claims := &models.FederatedStateClaims{
+		Provider: provider,
+		Nonce:    nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{models.FederatedStateAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(FederatedStateLifetime)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtSecret))
+}
+
+func ParseFederatedState(state string) (*models.FederatedStateClaims, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("JWT_SECRET is missing")
+	}
+	claims := &models.FederatedStateClaims{}
+	_, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (any, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(models.FederatedStateAudience))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -79,3 +79,61 @@ func TestGenerateJWT_MissingSecret(t *testing.T) {
 	assert.EqualError(t, err, "JWT_SECRET is missing")
 	assert.Empty(t, tokenString)
 }
+
+func TestParseJWT_RoundTrip(t *testing.T) {
+	os.Setenv("JWT_SECRET", "mysecretkey")
+
+	tokenString, err := GenerateJWT("johndoe@example.com", mockUserDetails)
+	assert.NoError(t, err)
+
+	claims, err := ParseJWT(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "johndoe@example.com", claims.Email)
+	assert.Equal(t, mockUserDetails.UserID, claims.UserID)
+	assert.NotEmpty(t, claims.ID, "access tokens should carry a jti so they can be revoked")
+}
+
+func TestParseJWT_InvalidSignature(t *testing.T) {
+	os.Setenv("JWT_SECRET", "mysecretkey")
+	tokenString, err := GenerateJWT("johndoe@example.com", mockUserDetails)
+	assert.NoError(t, err)
+
+	os.Setenv("JWT_SECRET", "anothersecret")
+	claims, err := ParseJWT(tokenString)
+	assert.Error(t, err)
+	assert.Nil(t, claims)
+}
+
+func TestFederatedState_RoundTrip(t *testing.T) {
+	os.Setenv("JWT_SECRET", "mysecretkey")
+
+	state, err := GenerateFederatedState("gitlab", "nonce-value")
+	assert.NoError(t, err)
+
+	claims, err := ParseFederatedState(state)
+	assert.NoError(t, err)
+	assert.Equal(t, "gitlab", claims.Provider)
+	assert.Equal(t, "nonce-value", claims.Nonce)
+}
+
+func TestParseFederatedState_Tampered(t *testing.T) {
+	os.Setenv("JWT_SECRET", "mysecretkey")
+
+	claims, err := ParseFederatedState("not-a-state")
+	assert.Error(t, err)
+	assert.Nil(t, claims)
+}
+
+func TestFederatedState_IsNotAnAccessToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "mysecretkey")
+
+	state, err := GenerateFederatedState("gitlab", "nonce-value")
+	assert.NoError(t, err)
+	_, err = ParseJWT(state)
+	assert.Error(t, err)
+
+	accessToken, err := GenerateJWT("user@example.com", models.UserDetail{UserID: 1})
+	assert.NoError(t, err)
+	_, err = ParseFederatedState(accessToken)
+	assert.Error(t, err)
+}
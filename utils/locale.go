@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// NormalizeLocale returns the canonical BCP 47 form of a locale, so that de_at, de-AT and DE-at all name
+// the same template directory
+func NormalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %s", locale)
+	}
+	return tag.String(), nil
+}
+
+// BaseLocale returns the language of a normalized locale, de for de-AT
+func BaseLocale(locale string) string {
+	base, _, _ := strings.Cut(locale, "-")
+	return base
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"de":      "de",
+		"de_at":   "de-AT",
+		"DE-at":   "de-AT",
+		" pt-br ": "pt-BR",
+		"zh-hant": "zh-Hant",
+	}
+	for input, expected := range tests {
+		locale, err := NormalizeLocale(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, locale, input)
+	}
+
+	_, err := NormalizeLocale("not a locale")
+	assert.EqualError(t, err, "invalid locale not a locale")
+	_, err = NormalizeLocale("")
+	assert.Error(t, err)
+}
+
+func TestBaseLocale(t *testing.T) {
+	assert.Equal(t, "de", BaseLocale("de-AT"))
+	assert.Equal(t, "en", BaseLocale("en"))
+}
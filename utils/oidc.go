@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+)
+
+const IDTokenLifetime = time.Hour
+
+var (
+	signingKey     *rsa.PrivateKey
+	signingKeyErr  error
+	signingKeyOnce sync.Once
+)
+
+// GetOIDCSigningKey returns the RSA key used to sign ID tokens. The key is read from the PEM file at
+// OIDC_SIGNING_KEY_PATH; when that is not set an ephemeral key is generated, which is only suitable
+// for local development since tokens stop validating after a restart.
+func GetOIDCSigningKey() (*rsa.PrivateKey, error) {
+	signingKeyOnce.Do(func() {
+		keyPath := os.Getenv("OIDC_SIGNING_KEY_PATH")
+		if keyPath == "" {
+			log.Println("OIDC_SIGNING_KEY_PATH is not set, generating an ephemeral ID token signing key")
+			signingKey, signingKeyErr = rsa.GenerateKey(rand.Reader, 2048)
+			return
+		}
+		signingKey, signingKeyErr = loadRSAPrivateKey(keyPath)
+	})
+	return signingKey, signingKeyErr
+}
+
+func loadRSAPrivateKey(keyPath string) (*rsa.PrivateKey, error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OIDC signing key: %w", err)
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("OIDC signing key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing OIDC signing key: %w", err)
+	}
+	key, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("OIDC signing key must be an RSA key")
+	}
+	return key, nil
+}
+
+// SigningKeyID derives a stable key id from the public key so clients can match tokens against the JWKS
+func SigningKeyID(publicKey *rsa.PublicKey) string {
+	digest := sha256.Sum256(publicKey.N.Bytes())
+	return base64.RawURLEncoding.EncodeToString(digest[:12])
+}
+
+func GetJSONWebKeySet() (models.JSONWebKeySet, error) {
+	key, err := GetOIDCSigningKey()
+	if err != nil {
+		return models.JSONWebKeySet{}, err
+	}
+	publicKey := &key.PublicKey
+	return models.JSONWebKeySet{
+		Keys: []models.JSONWebKey{{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			KeyID:     SigningKeyID(publicKey),
+			Modulus:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	}, nil
+}
+
+// RSAPublicKeyFromJWK converts an RSA JSON Web Key published by an identity provider into a public key
+func RSAPublicKeyFromJWK(jwk models.JSONWebKey) (*rsa.PublicKey, error) {
+	if jwk.KeyType != "RSA" {
+		return nil, fmt.Errorf("unsupported key type: %s", jwk.KeyType)
+	}
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key modulus: %w", err)
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key exponent: %w", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
+
+// BuildProfileClaims maps a user onto the OpenID Connect standard profile and email claims
+func BuildProfileClaims(email string, emailVerified bool, userDetails models.UserDetail) models.ProfileClaims {
+	names := []string{userDetails.FirstName, userDetails.MiddleName, userDetails.LastName}
+	nonEmpty := names[:0]
+	for _, name := range names {
+		if name != "" {
+			nonEmpty = append(nonEmpty, name)
+		}
+	}
+	return models.ProfileClaims{
+		GivenName:     userDetails.FirstName,
+		MiddleName:    userDetails.MiddleName,
+		FamilyName:    userDetails.LastName,
+		Name:          strings.Join(nonEmpty, " "),
+		Email:         email,
+		EmailVerified: emailVerified,
+	}
+}
+
+func GenerateIDToken(issuer, clientID, nonce, email string, emailVerified bool, userDetails models.UserDetail) (string, error) {
+	if email == "" {
+		return "", errors.New("email cannot be empty")
+	}
+	key, err := GetOIDCSigningKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := &models.IDTokenClaims{
+		ProfileClaims:   BuildProfileClaims(email, emailVerified, userDetails),
+		Nonce:           nonce,
+		AuthorizedParty: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.FormatUint(uint64(userDetails.UserID), 10),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(IDTokenLifetime)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = SigningKeyID(&key.PublicKey)
+	return token.SignedString(key)
+}
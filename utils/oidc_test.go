@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shibbirmcc/user-auth-and-permissions/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateIDToken_Success verifies the ID token against the published key set
+func TestGenerateIDToken_Success(t *testing.T) {
+	tokenString, err := GenerateIDToken("https://auth.example.com", "wiki", "n-0S6_WzA2Mj", "johndoe@example.com", true, mockUserDetails)
+	require.NoError(t, err)
+
+	keySet, err := GetJSONWebKeySet()
+	require.NoError(t, err)
+	require.Len(t, keySet.Keys, 1)
+	jwk := keySet.Keys[0]
+
+	publicKey, err := RSAPublicKeyFromJWK(jwk)
+	require.NoError(t, err)
+
+	claims := &models.IDTokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		assert.Equal(t, jwk.KeyID, token.Header["kid"])
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience("wiki"), jwt.WithIssuer("https://auth.example.com"))
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+
+	assert.Equal(t, "12", claims.Subject)
+	assert.Equal(t, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.Equal(t, "John", claims.GivenName)
+	assert.Equal(t, "F.", claims.MiddleName)
+	assert.Equal(t, "Doe", claims.FamilyName)
+	assert.Equal(t, "John F. Doe", claims.Name)
+	assert.Equal(t, "johndoe@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+}
+
+func TestGenerateIDToken_EmptyEmail(t *testing.T) {
+	tokenString, err := GenerateIDToken("https://auth.example.com", "wiki", "", "", true, mockUserDetails)
+
+	assert.EqualError(t, err, "email cannot be empty")
+	assert.Empty(t, tokenString)
+}
+
+func TestBuildProfileClaims_WithoutMiddleName(t *testing.T) {
+	claims := BuildProfileClaims("jane@example.com", true, models.UserDetail{FirstName: "Jane", LastName: "Roe"})
+
+	assert.Equal(t, "Jane Roe", claims.Name)
+	assert.Empty(t, claims.MiddleName)
+}
+
+func TestBuildProfileClaims_UnverifiedEmail(t *testing.T) {
+	claims := BuildProfileClaims("jane@example.com", false, models.UserDetail{FirstName: "Jane", LastName: "Roe"})
+
+	assert.Equal(t, "jane@example.com", claims.Email)
+	assert.False(t, claims.EmailVerified)
+}
+
+func TestRSAPublicKeyFromJWK_UnsupportedKeyType(t *testing.T) {
+	publicKey, err := RSAPublicKeyFromJWK(models.JSONWebKey{KeyType: "EC"})
+
+	assert.Nil(t, publicKey)
+	assert.EqualError(t, err, "unsupported key type: EC")
+}
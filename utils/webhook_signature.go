@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader carries t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">. Several
+// v1 entries may be present while a secret is rotated.
+const WebhookSignatureHeader = "Webhook-Signature"
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+func webhookMAC(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignWebhook returns the WebhookSignatureHeader value for the body sent at timestamp
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	unix := timestamp.Unix()
+	return "t=" + strconv.FormatInt(unix, 10) + ",v1=" + webhookMAC(secret, unix, body)
+}
+
+// VerifyWebhookSignature checks a WebhookSignatureHeader value against the body. Signatures older or
+// newer than tolerance are rejected, so a captured request cannot be replayed later.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidWebhookSignature
+			}
+			timestamp = parsed
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidWebhookSignature
+	}
+	expected := []byte(webhookMAC(secret, timestamp, body))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	sentAt := time.Unix(1760000000, 0)
+	header := SignWebhook("secret", sentAt, body)
+
+	assert.Regexp(t, `^t=1760000000,v1=[0-9a-f]{64}$`, header)
+	assert.NoError(t, VerifyWebhookSignature("secret", header, body, 5*time.Minute, sentAt.Add(time.Minute)))
+	// a second signature from a rotated secret is accepted too
+	assert.NoError(t, VerifyWebhookSignature("secret", "t=1760000000,v1=00,"+header[len("t=1760000000,"):], body, 5*time.Minute, sentAt))
+
+	assert.ErrorIs(t, VerifyWebhookSignature("other", header, body, 5*time.Minute, sentAt), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("secret", header, []byte(`{"id":"2"}`), 5*time.Minute, sentAt), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("secret", header, body, 5*time.Minute, sentAt.Add(10*time.Minute)), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("secret", "v1=abc", body, 5*time.Minute, sentAt), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature("secret", "t=soon,v1=abc", body, 5*time.Minute, sentAt), ErrInvalidWebhookSignature)
+}